@@ -0,0 +1,100 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+	apperrors "weathersvc/app/app_errors"
+)
+
+// internalNets are ranges outside the private, loopback and link-local ones that still address the
+// infrastructure rather than a webhook receiver.
+//
+//nolint:gochecknoglobals // fixed table
+var internalNets = []netip.Prefix{
+	// carrier-grade NAT, which some cluster networks use
+	netip.MustParsePrefix("100.64.0.0/10"),
+}
+
+// ValidateWebhookURL checks raw is an http or https URL the notifier may deliver to. Hosts written as
+// addresses are checked here for early feedback; names are checked as they are dialled, because they
+// may resolve elsewhere by then.
+func ValidateWebhookURL(raw string, allowedHosts []string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return apperrors.CreateInvalidRequestError("webhook_url must be an http or https URL")
+	}
+	if len(allowedHosts) > 0 {
+		if !allowedHost(allowedHosts, u.Hostname()) {
+			return apperrors.CreateInvalidRequestError("webhook_url host is not allowed")
+		}
+		return nil
+	}
+	if ip, err := netip.ParseAddr(u.Hostname()); err == nil && !publicAddr(ip) {
+		return apperrors.CreateInvalidRequestError("webhook_url must not be a private, loopback or link-local address")
+	}
+	return nil
+}
+
+// publicAddr reports whether ip may receive webhooks when no hosts are allowed explicitly.
+func publicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, p := range internalNets {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+func allowedHost(allowedHosts []string, host string) bool {
+	for _, h := range allowedHosts {
+		if strings.EqualFold(h, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// dialContext connects webhook deliveries, redirects included. With allowedHosts only those hosts are
+// dialled, wherever they resolve; without, any host is dialled unless it resolves to an address
+// publicAddr refuses. The address is checked as the connection is made, after resolution, so a name
+// cannot be re-pointed at an internal address between a check and the dial.
+func dialContext(allowedHosts []string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	open := &net.Dialer{Timeout: 10 * time.Second}
+	guarded := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip, err := netip.ParseAddr(host); err != nil || !publicAddr(ip) {
+				return fmt.Errorf("%w: %s", apperrors.ErrWebhookDestination, host)
+			}
+			return nil
+		},
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if len(allowedHosts) == 0 {
+			return guarded.DialContext(ctx, network, addr)
+		}
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if !allowedHost(allowedHosts, host) {
+			return nil, fmt.Errorf("%w: %s", apperrors.ErrWebhookDestination, host)
+		}
+		return open.DialContext(ctx, network, addr)
+	}
+}
@@ -0,0 +1,182 @@
+package alerts
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+	"weathersvc/app/budget"
+	"weathersvc/app/config"
+	"weathersvc/app/logging"
+	"weathersvc/app/poller"
+	"weathersvc/app/service"
+)
+
+// deliveryQueue is the number of notifications waiting on webhook delivery before new ones are dropped.
+const deliveryQueue = 100
+
+type Engine interface {
+	// Run evaluates every rule on each interval and delivers notifications until ctx is cancelled.
+	Run(ctx context.Context)
+	// Evaluate checks every rule once against the current weather.
+	Evaluate(ctx context.Context)
+	DeadLetters() []DeadLetter
+}
+
+type engine struct {
+	store    Store
+	svc      service.Service
+	notifier Notifier
+	interval time.Duration
+	// hysteresis is the number of consecutive evaluations needed to fire or resolve an alert.
+	hysteresis int
+	mu         sync.Mutex
+	states     map[string]*ruleState
+	queue      chan Notification
+}
+
+// ruleState tracks the consecutive results for a rule so a borderline condition does not flap.
+type ruleState struct {
+	firing  bool
+	matches int
+	misses  int
+}
+
+func NewEngine(store Store, s service.Service, n Notifier, interval time.Duration, hysteresis int) Engine {
+	if interval <= 0 {
+		interval = config.DefaultAlertInterval
+	}
+	if hysteresis <= 0 {
+		hysteresis = 1
+	}
+	return &engine{
+		store:      store,
+		svc:        s,
+		notifier:   n,
+		interval:   interval,
+		hysteresis: hysteresis,
+		states:     map[string]*ruleState{},
+		queue:      make(chan Notification, deliveryQueue),
+	}
+}
+
+func (e *engine) Run(ctx context.Context) {
+	go e.deliver(ctx)
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.Evaluate(ctx)
+		}
+	}
+}
+
+func (e *engine) Evaluate(ctx context.Context) {
+	// alert lookups may use the upstream budget held back from best-effort callers
+	ctx = budget.WithPriority(ctx)
+	rules, err := e.store.List(ctx)
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to list alerts", "error", err)
+		return
+	}
+	// rules watching the same location share one lookup per evaluation
+	conds := map[string]service.WeatherCond{}
+	failed := map[string]bool{}
+	active := map[string]bool{}
+	for _, r := range rules {
+		active[r.ID] = true
+		expr, err := ParseExpression(r.Condition)
+		if err != nil {
+			logging.FromContext(ctx).Warn("alert has an invalid condition", "rule", r.ID, "error", err)
+			continue
+		}
+		key := poller.Key(r.Latitude, r.Longitude)
+		if failed[key] {
+			continue
+		}
+		cond, ok := conds[key]
+		if !ok {
+			if cond, err = e.svc.GetWeather(ctx, r.Latitude, r.Longitude); err != nil {
+				logging.FromContext(ctx).Warn("failed to evaluate alerts", "location", key, "error", err)
+				failed[key] = true
+				continue
+			}
+			conds[key] = cond
+		}
+		if state, changed := e.observe(r.ID, expr.Match(cond)); changed {
+			e.enqueue(Notification{
+				RuleID:     r.ID,
+				State:      state,
+				Condition:  r.Condition,
+				Latitude:   r.Latitude,
+				Longitude:  r.Longitude,
+				Temp:       string(cond.Temp),
+				Wind:       string(cond.Wind),
+				Weather:    cond.Condition,
+				Time:       time.Now().UTC(),
+				WebhookURL: r.WebhookURL,
+			})
+		}
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for id := range e.states {
+		if !active[id] {
+			delete(e.states, id)
+		}
+	}
+}
+
+// observe records one evaluation and reports the new state when the rule fires or resolves.
+func (e *engine) observe(id string, matched bool) (string, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	st, ok := e.states[id]
+	if !ok {
+		st = &ruleState{}
+		e.states[id] = st
+	}
+	if matched {
+		st.matches++
+		st.misses = 0
+		if !st.firing && st.matches >= e.hysteresis {
+			st.firing = true
+			return StateFiring, true
+		}
+		return "", false
+	}
+	st.misses++
+	st.matches = 0
+	if st.firing && st.misses >= e.hysteresis {
+		st.firing = false
+		return StateResolved, true
+	}
+	return "", false
+}
+
+func (e *engine) enqueue(n Notification) {
+	select {
+	case e.queue <- n:
+	default:
+		slog.Warn("alert delivery queue is full, dropping notification", "rule", n.RuleID, "state", n.State)
+	}
+}
+
+func (e *engine) deliver(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n := <-e.queue:
+			// failures are retried and dead-lettered by the notifier
+			e.notifier.Notify(ctx, n)
+		}
+	}
+}
+
+func (e *engine) DeadLetters() []DeadLetter {
+	return e.notifier.DeadLetters()
+}
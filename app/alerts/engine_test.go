@@ -0,0 +1,121 @@
+package alerts
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+	apperrors "weathersvc/app/app_errors"
+	"weathersvc/app/service"
+	mock_service "weathersvc/mocks/service"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeNotifier struct {
+	mu   sync.Mutex
+	sent []Notification
+}
+
+func (f *fakeNotifier) Notify(ctx context.Context, n Notification) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, n)
+	return nil
+}
+
+func (f *fakeNotifier) DeadLetters() []DeadLetter {
+	return nil
+}
+
+// drain returns the notifications queued so far.
+func drain(e Engine) []Notification {
+	var out []Notification
+	for {
+		select {
+		case n := <-e.(*engine).queue:
+			out = append(out, n)
+		default:
+			return out
+		}
+	}
+}
+
+func TestAlerts_Engine(t *testing.T) {
+	ctx := context.Background()
+	gale := service.WeatherCond{Temp: "cold", Condition: "light rain", Wind: "gale winds"}
+	calm := service.WeatherCond{Temp: "cold", Condition: "light rain", Wind: "calm winds"}
+	t.Run("Should fire and resolve with hysteresis and no duplicates", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		mockService := mock_service.NewMockService(ctrl)
+		store := NewMemoryStore()
+		rule, err := store.Create(ctx, Rule{Latitude: 1, Longitude: 2, Condition: `wind >= "gale winds"`, WebhookURL: "http://hook"})
+		assert.NoError(t, err)
+		e := NewEngine(store, mockService, &fakeNotifier{}, 0, 2)
+		for _, cond := range []service.WeatherCond{gale, calm, gale, gale, gale, calm, gale, calm, calm} {
+			mockService.EXPECT().GetWeather(gomock.Any(), 1.0, 2.0).Return(cond, nil)
+		}
+		var states []string
+		for i := 0; i < 9; i++ {
+			e.Evaluate(ctx)
+			for _, n := range drain(e) {
+				assert.Equal(t, rule.ID, n.RuleID)
+				assert.Equal(t, "http://hook", n.WebhookURL)
+				states = append(states, n.State)
+			}
+		}
+		assert.Equal(t, []string{StateFiring, StateResolved}, states)
+	})
+	t.Run("Should look up each location once per evaluation", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		mockService := mock_service.NewMockService(ctrl)
+		store := NewMemoryStore()
+		store.Create(ctx, Rule{Latitude: 1, Longitude: 2, Condition: `wind >= "gale winds"`, WebhookURL: "http://a"})
+		store.Create(ctx, Rule{Latitude: 1, Longitude: 2, Condition: `temp == "cold"`, WebhookURL: "http://b"})
+		store.Create(ctx, Rule{Latitude: 5, Longitude: 5, Condition: `temp == "cold"`, WebhookURL: "http://c"})
+		mockService.EXPECT().GetWeather(gomock.Any(), 1.0, 2.0).Return(gale, nil).Times(1)
+		mockService.EXPECT().GetWeather(gomock.Any(), 5.0, 5.0).Return(service.WeatherCond{}, apperrors.ErrTooManyRequests).Times(1)
+		e := NewEngine(store, mockService, &fakeNotifier{}, 0, 1)
+		e.Evaluate(ctx)
+		assert.Len(t, drain(e), 2)
+	})
+	t.Run("Should deliver queued notifications while running", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		mockService := mock_service.NewMockService(ctrl)
+		store := NewMemoryStore()
+		store.Create(ctx, Rule{Latitude: 1, Longitude: 2, Condition: `wind >= "gale winds"`, WebhookURL: "http://a"})
+		mockService.EXPECT().GetWeather(gomock.Any(), gomock.Any(), gomock.Any()).Return(gale, nil).AnyTimes()
+		notifier := &fakeNotifier{}
+		e := NewEngine(store, mockService, notifier, 0, 1)
+		e.Evaluate(ctx)
+		runCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go e.(*engine).deliver(runCtx)
+		assert.Eventually(t, func() bool {
+			notifier.mu.Lock()
+			defer notifier.mu.Unlock()
+			return len(notifier.sent) == 1
+		}, time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestAlerts_MemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	t.Run("Should create, get, list and delete rules", func(t *testing.T) {
+		created, err := store.Create(ctx, Rule{Latitude: 1, Longitude: 2, Condition: `temp == "hot"`, WebhookURL: "http://hook"})
+		assert.NoError(t, err)
+		assert.NotEmpty(t, created.ID)
+		got, err := store.Get(ctx, created.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, created, got)
+		rules, err := store.List(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, []Rule{created}, rules)
+		assert.NoError(t, store.Delete(ctx, created.ID))
+		_, err = store.Get(ctx, created.ID)
+		assert.ErrorIs(t, err, apperrors.ErrAlertNotFound)
+		assert.ErrorIs(t, store.Delete(ctx, created.ID), apperrors.ErrAlertNotFound)
+	})
+}
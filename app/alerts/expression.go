@@ -0,0 +1,216 @@
+/*
+expression.go: Alert condition expressions. An expression compares the classified weather against
+a classification, e.g. `wind >= "gale winds" or temp == "sub-freezing"`. Clauses are joined with
+`and`/`or`, where `and` binds tighter than `or`.
+*/
+package alerts
+
+import (
+	"fmt"
+	"strings"
+	apperrors "weathersvc/app/app_errors"
+	"weathersvc/app/service"
+)
+
+const (
+	fieldTemp      = "temp"
+	fieldWind      = "wind"
+	fieldCondition = "condition"
+)
+
+// Expression is a parsed alert condition, held as an OR of AND groups.
+type Expression struct {
+	groups [][]clause
+}
+
+type clause struct {
+	field string
+	op    string
+	value string
+}
+
+// ParseExpression parses and validates an alert condition expression.
+func ParseExpression(s string) (*Expression, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, apperrors.CreateInvalidRequestError("condition expression is empty")
+	}
+	expr := &Expression{groups: [][]clause{{}}}
+	for i := 0; i < len(tokens); {
+		if len(tokens)-i < 3 {
+			return nil, apperrors.CreateInvalidRequestError(fmt.Sprintf("incomplete condition near %q", strings.Join(tokens[i:], " ")))
+		}
+		c := clause{field: strings.ToLower(tokens[i]), op: strings.ToLower(tokens[i+1]), value: tokens[i+2]}
+		if err := c.validate(); err != nil {
+			return nil, err
+		}
+		expr.groups[len(expr.groups)-1] = append(expr.groups[len(expr.groups)-1], c)
+		i += 3
+		if i == len(tokens) {
+			break
+		}
+		switch strings.ToLower(tokens[i]) {
+		case "and", "&&":
+		case "or", "||":
+			expr.groups = append(expr.groups, []clause{})
+		default:
+			return nil, apperrors.CreateInvalidRequestError(fmt.Sprintf("expected `and` or `or` but found %q", tokens[i]))
+		}
+		i++
+		if i == len(tokens) {
+			return nil, apperrors.CreateInvalidRequestError("condition ends with a dangling `and`/`or`")
+		}
+	}
+	return expr, nil
+}
+
+// Match reports whether the classified weather satisfies the expression.
+func (e *Expression) Match(cond service.WeatherCond) bool {
+	for _, group := range e.groups {
+		matched := true
+		for _, c := range group {
+			if !c.match(cond) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (c clause) validate() error {
+	switch c.op {
+	case "==", "!=", ">", ">=", "<", "<=", "contains":
+	default:
+		return apperrors.CreateInvalidRequestError(fmt.Sprintf("unknown operator %q", c.op))
+	}
+	switch c.field {
+	case fieldCondition:
+		if c.op != "==" && c.op != "!=" && c.op != "contains" {
+			return apperrors.CreateInvalidRequestError("condition only supports ==, != and contains")
+		}
+		return nil
+	case fieldTemp, fieldWind:
+		if c.op == "contains" {
+			return apperrors.CreateInvalidRequestError("contains is only supported for condition")
+		}
+	default:
+		return apperrors.CreateInvalidRequestError(fmt.Sprintf("unknown field %q: use temp, wind or condition", c.field))
+	}
+	if c.field == fieldTemp && rank(temperatureRanks(), c.value) < 0 {
+		return apperrors.CreateInvalidRequestError(fmt.Sprintf("unknown temperature %q", c.value))
+	}
+	if c.field == fieldWind && rank(windRanks(), c.value) < 0 {
+		return apperrors.CreateInvalidRequestError(fmt.Sprintf("unknown wind %q", c.value))
+	}
+	return nil
+}
+
+func (c clause) match(cond service.WeatherCond) bool {
+	switch c.field {
+	case fieldTemp:
+		return compare(rank(temperatureRanks(), string(cond.Temp)), rank(temperatureRanks(), c.value), c.op)
+	case fieldWind:
+		return compare(rank(windRanks(), string(cond.Wind)), rank(windRanks(), c.value), c.op)
+	default:
+		got := strings.ToLower(cond.Condition)
+		want := strings.ToLower(c.value)
+		switch c.op {
+		case "==":
+			return got == want
+		case "!=":
+			return got != want
+		default:
+			return strings.Contains(got, want)
+		}
+	}
+}
+
+// compare applies op to two ranks on the same scale. Unknown classifications never match.
+func compare(got, want int, op string) bool {
+	if got < 0 {
+		return false
+	}
+	switch op {
+	case "==":
+		return got == want
+	case "!=":
+		return got != want
+	case ">":
+		return got > want
+	case ">=":
+		return got >= want
+	case "<":
+		return got < want
+	case "<=":
+		return got <= want
+	default:
+		return false
+	}
+}
+
+func temperatureRanks() []string {
+	scale := service.TemperatureScale()
+	ranks := make([]string, 0, len(scale))
+	for _, t := range scale {
+		ranks = append(ranks, string(t))
+	}
+	return ranks
+}
+
+func windRanks() []string {
+	scale := service.WindScale()
+	ranks := make([]string, 0, len(scale))
+	for _, w := range scale {
+		ranks = append(ranks, string(w))
+	}
+	return ranks
+}
+
+func rank(scale []string, v string) int {
+	for i, s := range scale {
+		if strings.EqualFold(s, v) {
+			return i
+		}
+	}
+	return -1
+}
+
+// tokenize splits an expression into fields, operators, quoted values and joiners.
+func tokenize(s string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(s); {
+		switch ch := s[i]; {
+		case ch == ' ' || ch == '\t' || ch == '\n':
+			i++
+		case ch == '"' || ch == '\'':
+			end := strings.IndexByte(s[i+1:], ch)
+			if end < 0 {
+				return nil, apperrors.CreateInvalidRequestError("condition has an unterminated quote")
+			}
+			tokens = append(tokens, s[i+1:i+1+end])
+			i += end + 2
+		case strings.IndexByte("=!<>", ch) >= 0:
+			j := i + 1
+			if j < len(s) && s[j] == '=' {
+				j++
+			}
+			tokens = append(tokens, s[i:j])
+			i = j
+		default:
+			j := i
+			for j < len(s) && strings.IndexByte(" \t\n\"'=!<>", s[j]) < 0 {
+				j++
+			}
+			tokens = append(tokens, s[i:j])
+			i = j
+		}
+	}
+	return tokens, nil
+}
@@ -0,0 +1,55 @@
+package alerts
+
+import (
+	"testing"
+	"weathersvc/app/service"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlerts_ParseExpression(t *testing.T) {
+	gale := service.WeatherCond{Temp: "cold", Condition: "light rain", Wind: "gale winds"}
+	storm := service.WeatherCond{Temp: "sub-freezing", Condition: "heavy snow", Wind: "storm winds"}
+	calm := service.WeatherCond{Temp: "hot", Condition: "clear sky", Wind: "calm winds"}
+	unknown := service.WeatherCond{Temp: "unknown", Condition: "unknown", Wind: "unknown wind"}
+	tests := []struct {
+		name string
+		expr string
+		want map[*service.WeatherCond]bool
+	}{
+		{"wind at least gale", `wind >= "gale winds"`, map[*service.WeatherCond]bool{&gale: true, &storm: true, &calm: false, &unknown: false}},
+		{"temp equals", `temp == 'sub-freezing'`, map[*service.WeatherCond]bool{&gale: false, &storm: true}},
+		{"temp below without spaces", `temp<"moderate"`, map[*service.WeatherCond]bool{&gale: true, &storm: true, &calm: false}},
+		{"condition contains", `condition contains "rain"`, map[*service.WeatherCond]bool{&gale: true, &storm: false}},
+		{"and binds tighter than or", `temp == "hot" or wind > "gale winds" and condition contains snow`, map[*service.WeatherCond]bool{&gale: false, &storm: true, &calm: true}},
+		{"symbolic joiners", `temp != "hot" && wind <= "gale winds"`, map[*service.WeatherCond]bool{&gale: true, &storm: false, &calm: false}},
+	}
+	for _, tt := range tests {
+		t.Run("Should match "+tt.name, func(t *testing.T) {
+			expr, err := ParseExpression(tt.expr)
+			assert.NoError(t, err)
+			for cond, want := range tt.want {
+				assert.Equal(t, want, expr.Match(*cond), "%s against %+v", tt.expr, *cond)
+			}
+		})
+	}
+	invalid := map[string]string{
+		``:                                 "invalid request: condition expression is empty",
+		`wind >=`:                          "invalid request: incomplete condition near \"wind >=\"",
+		`pressure > "high"`:                "invalid request: unknown field \"pressure\": use temp, wind or condition",
+		`temp > "toasty"`:                  "invalid request: unknown temperature \"toasty\"",
+		`wind = "calm winds"`:              "invalid request: unknown operator \"=\"",
+		`wind contains "gale"`:             "invalid request: contains is only supported for condition",
+		`condition > "rain"`:               "invalid request: condition only supports ==, != and contains",
+		`temp == "hot" xor temp == "cold"`: "invalid request: expected `and` or `or` but found \"xor\"",
+		`temp == "hot" and`:                "invalid request: condition ends with a dangling `and`/`or`",
+		`condition == "rain`:               "invalid request: condition has an unterminated quote",
+	}
+	for expr, wantErr := range invalid {
+		t.Run("Should reject "+expr, func(t *testing.T) {
+			got, err := ParseExpression(expr)
+			assert.EqualError(t, err, wantErr)
+			assert.Nil(t, got)
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package alerts
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+	apperrors "weathersvc/app/app_errors"
+)
+
+// Rule is an alert definition: notify WebhookURL when Condition matches the weather at a location.
+type Rule struct {
+	ID         string    `json:"id"`
+	Latitude   float64   `json:"latitude"`
+	Longitude  float64   `json:"longitude"`
+	Condition  string    `json:"condition"`
+	WebhookURL string    `json:"webhook_url"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type Store interface {
+	Create(ctx context.Context, r Rule) (Rule, error)
+	Get(ctx context.Context, id string) (Rule, error)
+	List(ctx context.Context) ([]Rule, error)
+	Delete(ctx context.Context, id string) error
+}
+
+type memoryStore struct {
+	mu    sync.RWMutex
+	rules map[string]Rule
+}
+
+// NewMemoryStore returns a Store that keeps rules in memory for the life of the process.
+func NewMemoryStore() Store {
+	return &memoryStore{rules: map[string]Rule{}}
+}
+
+func (m *memoryStore) Create(ctx context.Context, r Rule) (Rule, error) {
+	id, err := newID()
+	if err != nil {
+		return Rule{}, err
+	}
+	r.ID = id
+	r.CreatedAt = time.Now().UTC()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rules[r.ID] = r
+	return r, nil
+}
+
+func (m *memoryStore) Get(ctx context.Context, id string) (Rule, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	r, ok := m.rules[id]
+	if !ok {
+		return Rule{}, apperrors.ErrAlertNotFound
+	}
+	return r, nil
+}
+
+func (m *memoryStore) List(ctx context.Context) ([]Rule, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	rules := make([]Rule, 0, len(m.rules))
+	for _, r := range m.rules {
+		rules = append(rules, r)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].CreatedAt.Before(rules[j].CreatedAt)
+	})
+	return rules, nil
+}
+
+func (m *memoryStore) Delete(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.rules[id]; !ok {
+		return apperrors.ErrAlertNotFound
+	}
+	delete(m.rules, id)
+	return nil
+}
+
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
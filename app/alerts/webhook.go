@@ -0,0 +1,174 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+	"weathersvc/app/logging"
+)
+
+const (
+	// SignatureHeader carries the hex HMAC-SHA256 of the timestamp and request body, keyed with the webhook secret.
+	SignatureHeader = "X-Weathersvc-Signature"
+	// TimestampHeader carries when the delivery was sent, in Unix seconds.
+	TimestampHeader = "X-Weathersvc-Timestamp"
+	// maxDeadLetters is the number of undeliverable notifications kept for inspection.
+	maxDeadLetters = 100
+)
+
+const (
+	StateFiring   = "firing"
+	StateResolved = "resolved"
+)
+
+// Notification is the JSON body POSTed to a rule's webhook.
+type Notification struct {
+	RuleID     string    `json:"rule_id"`
+	State      string    `json:"state"`
+	Condition  string    `json:"condition"`
+	Latitude   float64   `json:"latitude"`
+	Longitude  float64   `json:"longitude"`
+	Temp       string    `json:"temp"`
+	Wind       string    `json:"wind"`
+	Weather    string    `json:"weather"`
+	Time       time.Time `json:"time"`
+	WebhookURL string    `json:"-"`
+}
+
+// DeadLetter is a notification that could not be delivered after every retry.
+type DeadLetter struct {
+	Notification Notification `json:"notification"`
+	WebhookURL   string       `json:"webhook_url"`
+	Attempts     int          `json:"attempts"`
+	Error        string       `json:"error"`
+	FailedAt     time.Time    `json:"failed_at"`
+}
+
+type Notifier interface {
+	// Notify delivers n, retrying failures and dead-lettering it once retries are exhausted.
+	Notify(ctx context.Context, n Notification) error
+	DeadLetters() []DeadLetter
+}
+
+type webhookNotifier struct {
+	client   *http.Client
+	secret   []byte
+	attempts int
+	backoff  time.Duration
+	mu       sync.Mutex
+	dead     []DeadLetter
+}
+
+// NewWebhookNotifier signs each delivery with secret, making up to attempts tries with exponential backoff.
+// Deliveries are only made to allowedHosts when any are given, and otherwise never to private, loopback
+// or link-local addresses.
+func NewWebhookNotifier(secret string, attempts int, backoff time.Duration, allowedHosts []string) Notifier {
+	if attempts <= 0 {
+		attempts = 1
+	}
+	if secret == "" {
+		slog.Warn("alert webhooks will be sent unsigned: `ALERT_WEBHOOK_SECRET` is not set")
+	}
+	return &webhookNotifier{
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+			// a proxy would dial on our behalf, past the destination checks
+			Transport: &http.Transport{
+				DialContext:         dialContext(allowedHosts),
+				ForceAttemptHTTP2:   true,
+				TLSHandshakeTimeout: 10 * time.Second,
+			},
+		},
+		secret:   []byte(secret),
+		attempts: attempts,
+		backoff:  backoff,
+	}
+}
+
+// Sign returns the signature sent in SignatureHeader for body sent at timestamp, the value of
+// TimestampHeader. Receivers recompute it and reject old timestamps, so captured deliveries cannot be
+// replayed.
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (w *webhookNotifier) Notify(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	wait := w.backoff
+	for attempt := 1; ; attempt++ {
+		err = w.post(ctx, n.WebhookURL, body)
+		if err == nil {
+			return nil
+		}
+		if attempt == w.attempts || ctx.Err() != nil {
+			break
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+	logging.FromContext(ctx).Warn("alert webhook failed, dead-lettering", "rule", n.RuleID, "attempts", w.attempts, "error", err)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.dead = append(w.dead, DeadLetter{
+		Notification: n,
+		WebhookURL:   n.WebhookURL,
+		Attempts:     w.attempts,
+		Error:        err.Error(),
+		FailedAt:     time.Now().UTC(),
+	})
+	if len(w.dead) > maxDeadLetters {
+		w.dead = w.dead[len(w.dead)-maxDeadLetters:]
+	}
+	return err
+}
+
+func (w *webhookNotifier) post(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// each attempt is signed afresh, so retries are not mistaken for replays
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set(TimestampHeader, timestamp)
+	if len(w.secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(w.secret, timestamp, body))
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending request: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (w *webhookNotifier) DeadLetters() []DeadLetter {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	dead := make([]DeadLetter, len(w.dead))
+	copy(dead, w.dead)
+	return dead
+}
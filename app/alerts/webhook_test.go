@@ -0,0 +1,125 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	apperrors "weathersvc/app/app_errors"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlerts_WebhookNotifier(t *testing.T) {
+	n := Notification{RuleID: "abc", State: StateFiring, Condition: `wind >= "gale winds"`, Wind: "gale winds"}
+	// the test receivers listen on loopback, which is refused unless allowed
+	local := []string{"127.0.0.1"}
+	t.Run("Should sign and deliver notifications", func(t *testing.T) {
+		var gotSig, gotTimestamp string
+		var gotBody []byte
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotSig = r.Header.Get(SignatureHeader)
+			gotTimestamp = r.Header.Get(TimestampHeader)
+			gotBody, _ = io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer ts.Close()
+		n.WebhookURL = ts.URL
+		err := NewWebhookNotifier("shh", 1, 0, local).Notify(context.Background(), n)
+		assert.NoError(t, err)
+		sent, err := strconv.ParseInt(gotTimestamp, 10, 64)
+		assert.NoError(t, err)
+		assert.InDelta(t, time.Now().Unix(), sent, 5)
+		assert.Equal(t, Sign([]byte("shh"), gotTimestamp, gotBody), gotSig)
+		assert.NotEqual(t, Sign([]byte("shh"), strconv.FormatInt(sent-3600, 10), gotBody), gotSig, "the signature covers the timestamp")
+		var got Notification
+		assert.NoError(t, json.Unmarshal(gotBody, &got))
+		assert.Equal(t, "abc", got.RuleID)
+		assert.Equal(t, StateFiring, got.State)
+	})
+	t.Run("Should retry failed deliveries", func(t *testing.T) {
+		var calls int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&calls, 1) < 3 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer ts.Close()
+		n.WebhookURL = ts.URL
+		notifier := NewWebhookNotifier("shh", 3, time.Millisecond, local)
+		assert.NoError(t, notifier.Notify(context.Background(), n))
+		assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+		assert.Empty(t, notifier.DeadLetters())
+	})
+	t.Run("Should dead-letter after retries are exhausted", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer ts.Close()
+		n.WebhookURL = ts.URL
+		notifier := NewWebhookNotifier("", 2, time.Millisecond, local)
+		err := notifier.Notify(context.Background(), n)
+		assert.EqualError(t, err, "webhook responded with status 500")
+		dead := notifier.DeadLetters()
+		if assert.Len(t, dead, 1) {
+			assert.Equal(t, "abc", dead[0].Notification.RuleID)
+			assert.Equal(t, ts.URL, dead[0].WebhookURL)
+			assert.Equal(t, 2, dead[0].Attempts)
+		}
+	})
+	t.Run("Should refuse internal destinations unless their host is allowed", func(t *testing.T) {
+		var calls int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+		}))
+		defer ts.Close()
+		n.WebhookURL = ts.URL
+		err := NewWebhookNotifier("", 1, 0, nil).Notify(context.Background(), n)
+		assert.ErrorIs(t, err, apperrors.ErrWebhookDestination)
+		// a name is refused by where it resolves
+		n.WebhookURL = strings.Replace(ts.URL, "127.0.0.1", "localhost", 1)
+		err = NewWebhookNotifier("", 1, 0, nil).Notify(context.Background(), n)
+		assert.ErrorIs(t, err, apperrors.ErrWebhookDestination)
+		err = NewWebhookNotifier("", 1, 0, []string{"hooks.example.com"}).Notify(context.Background(), n)
+		assert.ErrorIs(t, err, apperrors.ErrWebhookDestination)
+		assert.Zero(t, atomic.LoadInt32(&calls))
+		err = NewWebhookNotifier("", 1, 0, []string{"localhost"}).Notify(context.Background(), n)
+		assert.NoError(t, err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+}
+
+func TestAlerts_ValidateWebhookURL(t *testing.T) {
+	for raw, want := range map[string]string{
+		"https://hooks.example.com/weather":       "",
+		"https://93.184.216.34/hook":              "",
+		"ftp://hooks.example.com":                 "webhook_url must be an http or https URL",
+		"http://127.0.0.1:8080/hook":              "must not be a private, loopback or link-local address",
+		"http://169.254.169.254/latest/meta-data": "must not be a private, loopback or link-local address",
+		"http://10.0.0.7/hook":                    "must not be a private, loopback or link-local address",
+		"http://[::1]/hook":                       "must not be a private, loopback or link-local address",
+		"http://[::ffff:192.168.0.1]/hook":        "must not be a private, loopback or link-local address",
+		"http://100.64.0.1/hook":                  "must not be a private, loopback or link-local address",
+	} {
+		err := ValidateWebhookURL(raw, nil)
+		if want == "" {
+			assert.NoError(t, err, raw)
+		} else {
+			assert.ErrorContains(t, err, want, raw)
+		}
+	}
+	t.Run("Should only allow the listed hosts when any are", func(t *testing.T) {
+		allowed := []string{"hooks.internal", "10.0.0.7"}
+		assert.NoError(t, ValidateWebhookURL("http://hooks.internal/a", allowed))
+		assert.NoError(t, ValidateWebhookURL("http://10.0.0.7/a", allowed))
+		assert.ErrorContains(t, ValidateWebhookURL("https://hooks.example.com/a", allowed), "webhook_url host is not allowed")
+	})
+}
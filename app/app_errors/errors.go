@@ -8,12 +8,25 @@ import (
 //nolint:gochecknoglobals // 20240702BG allow
 var (
 	ErrMissingConfig        = errors.New("failed to start service: missing required config")
+	ErrInvalidConfig        = errors.New("failed to start service: invalid config")
+	ErrInvalidConfigFile    = errors.New("failed to start service: invalid config file")
+	ErrSecretUnavailable    = errors.New("failed to start service: could not read secret")
 	ErrInvalidRequest       = errors.New("invalid request")
 	ErrInvalidOWMAppID      = errors.New("config `WEATHER_ID` is invalid")
 	ErrInternalServiceError = errors.New("internal service error")
 	ErrTooManyRequests      = errors.New("too many requests; limit reached")
 	ErrNotFound             = errors.New("weather for coordinates not found")
 	ErrNoBody               = errors.New("request body missing: see `https://github.com/RebGov/WeatherService`")
+	ErrAlertNotFound        = errors.New("alert not found")
+	ErrWebhookDestination   = errors.New("webhook destination is not allowed")
+	ErrLocationNotFound     = errors.New("location not found")
+	ErrLocationExists       = errors.New("location already exists")
+	ErrUnauthorized         = errors.New("unauthorized: missing or invalid credentials")
+	ErrForbidden            = errors.New("forbidden")
+	ErrQuotaExceeded        = errors.New("API key quota exceeded")
+	ErrKeyNotFound          = errors.New("API key not found")
+	ErrRateLimited          = errors.New("rate limit exceeded; retry later")
+	ErrBudgetExhausted      = errors.New("upstream call budget exhausted; retry later")
 )
 
 // CreateMissingConfigError combines the missing environment config error and reason
@@ -21,7 +34,27 @@ func CreateMissingConfigError(v string) error {
 	return fmt.Errorf("%s for `%s`", ErrMissingConfig.Error(), v)
 }
 
+// CreateInvalidConfigError combines the invalid environment config error and reason
+func CreateInvalidConfigError(v string) error {
+	return fmt.Errorf("%s for `%s`", ErrInvalidConfig.Error(), v)
+}
+
+// CreateInvalidConfigFileError combines the invalid config file error, the file and reason
+func CreateInvalidConfigFileError(path string, err error) error {
+	return fmt.Errorf("%s `%s`: %v", ErrInvalidConfigFile.Error(), path, err)
+}
+
+// CreateSecretError combines the unreadable secret error, the option and reason
+func CreateSecretError(key string, err error) error {
+	return fmt.Errorf("%s for `%s`: %v", ErrSecretUnavailable.Error(), key, err)
+}
+
 // CreateInvalidRequestError combines the invalid request error and reason
 func CreateInvalidRequestError(v string) error {
 	return fmt.Errorf("%s: %s", ErrInvalidRequest.Error(), v)
 }
+
+// CreateForbiddenError combines the forbidden error and the missing scope
+func CreateForbiddenError(scope string) error {
+	return fmt.Errorf("%s: requires scope `%s`", ErrForbidden.Error(), scope)
+}
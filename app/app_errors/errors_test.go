@@ -16,6 +16,30 @@ func TestErrors_CreateMissingConfigError(t *testing.T) {
 	})
 }
 
+func TestErrors_CreateInvalidConfigError(t *testing.T) {
+	t.Run("", func(t *testing.T) {
+		expected := errors.New("failed to start service: invalid config for `POLL_INTERVAL`")
+		got := apperrors.CreateInvalidConfigError("POLL_INTERVAL")
+		assert.EqualError(t, got, expected.Error())
+	})
+}
+
+func TestErrors_CreateInvalidConfigFileError(t *testing.T) {
+	t.Run("", func(t *testing.T) {
+		expected := errors.New("failed to start service: invalid config file `weathersvc.yaml`: unknown field")
+		got := apperrors.CreateInvalidConfigFileError("weathersvc.yaml", errors.New("unknown field"))
+		assert.EqualError(t, got, expected.Error())
+	})
+}
+
+func TestErrors_CreateSecretError(t *testing.T) {
+	t.Run("", func(t *testing.T) {
+		expected := errors.New("failed to start service: could not read secret for `WEATHER_ID`: secret is empty")
+		got := apperrors.CreateSecretError("WEATHER_ID", errors.New("secret is empty"))
+		assert.EqualError(t, got, expected.Error())
+	})
+}
+
 func TestErrors_CreateInvalidRequestErrors(t *testing.T) {
 	t.Run("", func(t *testing.T) {
 		expected := errors.New("invalid request: latitude is out of range")
@@ -23,3 +47,11 @@ func TestErrors_CreateInvalidRequestErrors(t *testing.T) {
 		assert.EqualError(t, got, expected.Error())
 	})
 }
+
+func TestErrors_CreateForbiddenError(t *testing.T) {
+	t.Run("", func(t *testing.T) {
+		expected := errors.New("forbidden: requires scope `admin`")
+		got := apperrors.CreateForbiddenError("admin")
+		assert.EqualError(t, got, expected.Error())
+	})
+}
@@ -0,0 +1,255 @@
+/*
+jwks.go: JSON Web Key Sets. Signing keys are read from the issuer's JWKS endpoint and cached, or from
+a static file for tests and offline use.
+*/
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+	"weathersvc/app/logging"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// minRefetch stops tokens with unknown key ids from forcing a JWKS fetch on every request.
+const minRefetch = time.Minute
+
+type KeySet interface {
+	// PublicKey returns the signing key with id kid.
+	PublicKey(ctx context.Context, kid string) (crypto.PublicKey, error)
+}
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type staticKeySet struct {
+	keys map[string]crypto.PublicKey
+}
+
+// NewStaticKeySet reads a JWKS document from path once.
+func NewStaticKeySet(path string) (KeySet, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := parseJWKS(b)
+	if err != nil {
+		return nil, fmt.Errorf("error reading JWKS %s: %w", path, err)
+	}
+	return &staticKeySet{keys: keys}, nil
+}
+
+func (s *staticKeySet) PublicKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	k, ok := s.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return k, nil
+}
+
+type remoteKeySet struct {
+	url    string
+	ttl    time.Duration
+	client *http.Client
+	// fetches shares one JWKS fetch between the requests waiting on it.
+	fetches singleflight.Group
+	mu      sync.RWMutex
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+	// attempted is when the JWKS was last fetched, successfully or not.
+	attempted time.Time
+}
+
+// NewRemoteKeySet fetches the JWKS at url on first use and caches it for ttl. A token signed with
+// an unknown key id triggers an early refetch so key rotation is picked up. Cached keys are served
+// while the JWKS is fetched, so a slow issuer only holds up tokens signed with keys not yet known.
+func NewRemoteKeySet(url string, ttl time.Duration) KeySet {
+	return &remoteKeySet{url: url, ttl: ttl, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (s *remoteKeySet) PublicKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	s.mu.RLock()
+	k, ok := s.keys[kid]
+	age := time.Since(s.fetched)
+	due := s.keys == nil || time.Since(s.attempted) >= minRefetch
+	s.mu.RUnlock()
+	switch {
+	case ok && (age < s.ttl || !due):
+		return k, nil
+	case ok:
+		// the key is still served while the expired set is refreshed in the background
+		s.refresh(ctx)
+		return k, nil
+	case due:
+		select {
+		case <-s.refresh(ctx):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	k, ok = s.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return k, nil
+}
+
+// refresh fetches the JWKS unless a fetch is already running, and returns a channel that receives once
+// it is done. The fetch outlives ctx, as other requests may be waiting on it.
+func (s *remoteKeySet) refresh(ctx context.Context) <-chan singleflight.Result {
+	return s.fetches.DoChan(s.url, func() (any, error) {
+		s.mu.Lock()
+		s.attempted = time.Now()
+		s.mu.Unlock()
+		keys, err := s.fetch(context.WithoutCancel(ctx))
+		if err != nil {
+			// keep serving the cached keys through an outage at the issuer
+			logging.FromContext(ctx).Warn("failed to refresh JWKS", "url", s.url, "error", err)
+			return nil, err
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.keys = keys
+		s.fetched = time.Now()
+		return nil, nil
+	})
+}
+
+func (s *remoteKeySet) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error sending request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("JWKS responded with status %d", resp.StatusCode)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return parseJWKS(b)
+}
+
+// DiscoverJWKSURL reads the jwks_uri from the issuer's OpenID configuration.
+func DiscoverJWKSURL(ctx context.Context, issuer string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return "", fmt.Errorf("error creating request: %v", err)
+	}
+	resp, err := (&http.Client{Timeout: 10 * time.Second}).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error sending request: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("OpenID configuration responded with status %d", resp.StatusCode)
+	}
+	var doc struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return "", err
+	}
+	if doc.JWKSURI == "" {
+		return "", fmt.Errorf("OpenID configuration for %s has no jwks_uri", issuer)
+	}
+	return doc.JWKSURI, nil
+}
+
+func parseJWKS(b []byte) (map[string]crypto.PublicKey, error) {
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	keys := map[string]crypto.PublicKey{}
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			// issuers publish keys of types tokens here are never signed with, e.g. OKP
+			slog.Warn("skipping JWKS key", "kid", k.Kid, "error", err)
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
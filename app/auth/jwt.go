@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Claims are the token claims used for authorization. Scopes are read from the space separated
+// `scope` claim and the `scp` array, whichever the identity provider sends.
+type Claims struct {
+	jwt.RegisteredClaims
+	Scope string   `json:"scope,omitempty"`
+	Scp   []string `json:"scp,omitempty"`
+	Email string   `json:"email,omitempty"`
+}
+
+// Scopes returns every scope granted by the token.
+func (c Claims) Scopes() []string {
+	return append(strings.Fields(c.Scope), c.Scp...)
+}
+
+type Verifier interface {
+	// Verify checks token's signature, issuer, audience and expiry and returns its claims.
+	Verify(ctx context.Context, token string) (Claims, error)
+}
+
+type verifier struct {
+	keys   KeySet
+	parser *jwt.Parser
+}
+
+// NewVerifier accepts tokens signed by a key in keys, issued by issuer for audience.
+func NewVerifier(keys KeySet, issuer, audience string) Verifier {
+	return &verifier{
+		keys: keys,
+		parser: jwt.NewParser(
+			jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}),
+			jwt.WithIssuer(issuer),
+			jwt.WithAudience(audience),
+			jwt.WithExpirationRequired(),
+		),
+	}
+}
+
+func (v *verifier) Verify(ctx context.Context, token string) (Claims, error) {
+	var claims Claims
+	_, err := v.parser.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		if kid == "" {
+			return nil, fmt.Errorf("token has no key id")
+		}
+		return v.keys.PublicKey(ctx, kid)
+	})
+	if err != nil {
+		return Claims{}, err
+	}
+	return claims, nil
+}
@@ -0,0 +1,216 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testIssuer   = "https://id.example.com"
+	testAudience = "weathersvc"
+	testKid      = "test-key"
+)
+
+type testSigner struct {
+	key  *rsa.PrivateKey
+	jwks []byte
+}
+
+func newTestSigner(t *testing.T) *testSigner {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	jwks, err := json.Marshal(map[string]interface{}{"keys": []map[string]string{{
+		"kid": testKid,
+		"kty": "RSA",
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}})
+	require.NoError(t, err)
+	return &testSigner{key: key, jwks: jwks}
+}
+
+func (s *testSigner) claims(sub, scope string) Claims {
+	return Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    testIssuer,
+			Subject:   sub,
+			Audience:  jwt.ClaimStrings{testAudience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+		Scope: scope,
+	}
+}
+
+func (s *testSigner) token(t *testing.T, c Claims) string {
+	t.Helper()
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, c)
+	tok.Header["kid"] = testKid
+	signed, err := tok.SignedString(s.key)
+	require.NoError(t, err)
+	return signed
+}
+
+func (s *testSigner) verifier(t *testing.T) Verifier {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, s.jwks, 0o600))
+	keys, err := NewStaticKeySet(path)
+	require.NoError(t, err)
+	return NewVerifier(keys, testIssuer, testAudience)
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	ctx := context.Background()
+	signer := newTestSigner(t)
+	v := signer.verifier(t)
+	t.Run("Should accept valid tokens and read their scopes", func(t *testing.T) {
+		c := signer.claims("alice", "weather:read alerts:write")
+		c.Scp = []string{"locations:read"}
+		got, err := v.Verify(ctx, signer.token(t, c))
+		require.NoError(t, err)
+		assert.Equal(t, "alice", got.Subject)
+		assert.Equal(t, []string{"weather:read", "alerts:write", "locations:read"}, got.Scopes())
+	})
+	tests := map[string]func(c *Claims){
+		"Should reject the wrong issuer":   func(c *Claims) { c.Issuer = "https://evil.example.com" },
+		"Should reject the wrong audience": func(c *Claims) { c.Audience = jwt.ClaimStrings{"other"} },
+		"Should reject expired tokens":     func(c *Claims) { c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute)) },
+		"Should reject tokens without exp": func(c *Claims) { c.ExpiresAt = nil },
+	}
+	for name, mutate := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := signer.claims("alice", "admin")
+			mutate(&c)
+			_, err := v.Verify(ctx, signer.token(t, c))
+			assert.Error(t, err)
+		})
+	}
+	t.Run("Should reject tokens signed by an unknown key", func(t *testing.T) {
+		other := newTestSigner(t)
+		_, err := v.Verify(ctx, other.token(t, signer.claims("alice", "admin")))
+		assert.Error(t, err)
+	})
+	t.Run("Should reject unsigned tokens", func(t *testing.T) {
+		tok := jwt.NewWithClaims(jwt.SigningMethodNone, signer.claims("alice", "admin"))
+		tok.Header["kid"] = testKid
+		signed, err := tok.SignedString(jwt.UnsafeAllowNoneSignatureType)
+		require.NoError(t, err)
+		_, err = v.Verify(ctx, signed)
+		assert.Error(t, err)
+	})
+}
+
+func TestRemoteKeySet(t *testing.T) {
+	signer := newTestSigner(t)
+	fetches := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(map[string]string{"jwks_uri": "http://" + r.Host + "/jwks"})
+		case "/jwks":
+			fetches++
+			w.Write(signer.jwks)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	ctx := context.Background()
+	t.Run("Should discover the JWKS URL", func(t *testing.T) {
+		url, err := DiscoverJWKSURL(ctx, ts.URL)
+		require.NoError(t, err)
+		assert.Equal(t, ts.URL+"/jwks", url)
+	})
+	t.Run("Should fetch and cache signing keys", func(t *testing.T) {
+		keys := NewRemoteKeySet(ts.URL+"/jwks", time.Hour)
+		v := NewVerifier(keys, testIssuer, testAudience)
+		for i := 0; i < 3; i++ {
+			_, err := v.Verify(ctx, signer.token(t, signer.claims("alice", "admin")))
+			require.NoError(t, err)
+		}
+		assert.Equal(t, 1, fetches)
+		_, err := keys.PublicKey(ctx, "rotated")
+		assert.Error(t, err)
+		assert.Equal(t, 1, fetches, "unknown key ids should not refetch within a minute")
+	})
+	t.Run("Should serve cached keys while a refetch waits on the issuer", func(t *testing.T) {
+		var slow atomic.Int32
+		release := make(chan struct{})
+		hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if slow.Add(1) > 1 {
+				<-release
+			}
+			w.Write(signer.jwks)
+		}))
+		defer hanging.Close()
+		keys := NewRemoteKeySet(hanging.URL, time.Hour).(*remoteKeySet)
+		_, err := keys.PublicKey(ctx, testKid)
+		require.NoError(t, err)
+		keys.mu.Lock()
+		keys.attempted = time.Now().Add(-minRefetch)
+		keys.mu.Unlock()
+		var wg sync.WaitGroup
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := keys.PublicKey(ctx, "rotated")
+				assert.Error(t, err)
+			}()
+		}
+		assert.Eventually(t, func() bool { return slow.Load() == 2 }, time.Second, time.Millisecond)
+		done := make(chan error)
+		go func() {
+			_, err := keys.PublicKey(ctx, testKid)
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("a cached key waited on the refetch")
+		}
+		close(release)
+		wg.Wait()
+		assert.Equal(t, int32(2), slow.Load(), "waiting requests share one refetch")
+	})
+}
+
+func TestParseJWKS(t *testing.T) {
+	t.Run("Should skip keys of unsupported types and curves", func(t *testing.T) {
+		signer := newTestSigner(t)
+		var doc struct {
+			Keys []map[string]string `json:"keys"`
+		}
+		require.NoError(t, json.Unmarshal(signer.jwks, &doc))
+		doc.Keys = append(doc.Keys,
+			map[string]string{"kid": "ed", "kty": "OKP", "crv": "Ed25519", "x": "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"},
+			map[string]string{"kid": "k1", "kty": "EC", "crv": "secp256k1", "x": "AA", "y": "AA"},
+			map[string]string{"kid": "enc", "kty": "RSA", "use": "enc", "n": "AQAB", "e": "AQAB"},
+		)
+		b, err := json.Marshal(doc)
+		require.NoError(t, err)
+		keys, err := parseJWKS(b)
+		require.NoError(t, err)
+		assert.Len(t, keys, 1)
+		assert.Contains(t, keys, testKid)
+	})
+}
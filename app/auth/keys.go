@@ -0,0 +1,194 @@
+/*
+keys.go: API keys. Only the SHA-256 hash of a key is stored; the plaintext is returned once, when
+the key is issued.
+*/
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+	apperrors "weathersvc/app/app_errors"
+)
+
+// keyPrefix marks plaintext keys so they are easy to spot in logs and secret scanners.
+const keyPrefix = "wsk_"
+
+// Key is an issued API key. PerMinute and PerDay override the default quotas when set.
+type Key struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Hash  string `json:"hash,omitempty"`
+	Admin bool   `json:"admin"`
+	// Metrics marks a key for a metrics scraper, which may read metrics and nothing else.
+	Metrics   bool       `json:"metrics,omitempty"`
+	PerMinute int        `json:"per_minute,omitempty"`
+	PerDay    int        `json:"per_day,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+type KeyStore interface {
+	// Issue generates a new key from k's name, admin and metrics flags and quotas, returning it with its plaintext.
+	Issue(ctx context.Context, k Key) (Key, string, error)
+	// Import adds a key whose hash is already known, replacing any key with the same id.
+	Import(ctx context.Context, k Key) error
+	// Lookup returns the active key for plaintext, or ErrUnauthorized.
+	Lookup(ctx context.Context, plaintext string) (Key, error)
+	List(ctx context.Context) ([]Key, error)
+	Revoke(ctx context.Context, id string) error
+}
+
+// HashKey returns the stored form of a plaintext key.
+func HashKey(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
+
+type keyStore struct {
+	// path is the JSON file keys are loaded from and saved to; keys only live in memory when empty.
+	path string
+	mu   sync.RWMutex
+	keys map[string]Key
+}
+
+// NewMemoryKeyStore returns a KeyStore that keeps keys in memory for the life of the process.
+func NewMemoryKeyStore() KeyStore {
+	return &keyStore{keys: map[string]Key{}}
+}
+
+// NewFileKeyStore loads keys from the JSON file at path and saves every change back to it.
+// The file is created on the first change when it does not exist.
+func NewFileKeyStore(path string) (KeyStore, error) {
+	s := &keyStore{path: path, keys: map[string]Key{}}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var keys []Key
+	if err := json.Unmarshal(b, &keys); err != nil {
+		return nil, err
+	}
+	for _, k := range keys {
+		s.keys[k.ID] = k
+	}
+	return s, nil
+}
+
+func (s *keyStore) Issue(ctx context.Context, k Key) (Key, string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return Key{}, "", err
+	}
+	plaintext := keyPrefix + hex.EncodeToString(b)
+	k.ID = hex.EncodeToString(b[:6])
+	k.Hash = HashKey(plaintext)
+	k.CreatedAt = time.Now().UTC()
+	k.RevokedAt = nil
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[k.ID] = k
+	if err := s.save(); err != nil {
+		delete(s.keys, k.ID)
+		return Key{}, "", err
+	}
+	return k, plaintext, nil
+}
+
+func (s *keyStore) Import(ctx context.Context, k Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, existed := s.keys[k.ID]
+	s.keys[k.ID] = k
+	if err := s.save(); err != nil {
+		if existed {
+			s.keys[k.ID] = prev
+		} else {
+			delete(s.keys, k.ID)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *keyStore) Lookup(ctx context.Context, plaintext string) (Key, error) {
+	hash := HashKey(plaintext)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, k := range s.keys {
+		if k.Hash == hash && k.RevokedAt == nil {
+			return k, nil
+		}
+	}
+	return Key{}, apperrors.ErrUnauthorized
+}
+
+func (s *keyStore) List(ctx context.Context) ([]Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := make([]Key, 0, len(s.keys))
+	for _, k := range s.keys {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+	return keys, nil
+}
+
+func (s *keyStore) Revoke(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.keys[id]
+	if !ok || k.RevokedAt != nil {
+		return apperrors.ErrKeyNotFound
+	}
+	now := time.Now().UTC()
+	k.RevokedAt = &now
+	s.keys[id] = k
+	if err := s.save(); err != nil {
+		k.RevokedAt = nil
+		s.keys[id] = k
+		return err
+	}
+	return nil
+}
+
+// save writes every key to a temporary file and renames it over path, so a crash never leaves it half written.
+// The caller must hold mu.
+func (s *keyStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+	keys := make([]Key, 0, len(s.keys))
+	for _, k := range s.keys {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	b, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
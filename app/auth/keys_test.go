@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	apperrors "weathersvc/app/app_errors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyStore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "keys.json")
+	store, err := NewFileKeyStore(path)
+	require.NoError(t, err)
+	var issued Key
+	var plaintext string
+	t.Run("Should issue a key and only store its hash", func(t *testing.T) {
+		issued, plaintext, err = store.Issue(ctx, Key{Name: "dashboard", PerMinute: 5})
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(plaintext, keyPrefix))
+		assert.NotEmpty(t, issued.ID)
+		assert.Equal(t, HashKey(plaintext), issued.Hash)
+		assert.NotContains(t, issued.Hash, plaintext)
+	})
+	t.Run("Should look up a key by its plaintext", func(t *testing.T) {
+		got, err := store.Lookup(ctx, plaintext)
+		require.NoError(t, err)
+		assert.Equal(t, issued.ID, got.ID)
+		_, err = store.Lookup(ctx, "wsk_wrong")
+		assert.ErrorIs(t, err, apperrors.ErrUnauthorized)
+	})
+	t.Run("Should reload keys from the file", func(t *testing.T) {
+		reloaded, err := NewFileKeyStore(path)
+		require.NoError(t, err)
+		got, err := reloaded.Lookup(ctx, plaintext)
+		require.NoError(t, err)
+		assert.Equal(t, 5, got.PerMinute)
+	})
+	t.Run("Should import a known hash", func(t *testing.T) {
+		require.NoError(t, store.Import(ctx, Key{ID: "admin", Hash: HashKey("bootstrap"), Admin: true}))
+		got, err := store.Lookup(ctx, "bootstrap")
+		require.NoError(t, err)
+		assert.True(t, got.Admin)
+		keys, err := store.List(ctx)
+		require.NoError(t, err)
+		assert.Len(t, keys, 2)
+	})
+	t.Run("Should revoke a key", func(t *testing.T) {
+		require.NoError(t, store.Revoke(ctx, issued.ID))
+		_, err := store.Lookup(ctx, plaintext)
+		assert.ErrorIs(t, err, apperrors.ErrUnauthorized)
+		assert.ErrorIs(t, store.Revoke(ctx, issued.ID), apperrors.ErrKeyNotFound)
+		reloaded, err := NewFileKeyStore(path)
+		require.NoError(t, err)
+		_, err = reloaded.Lookup(ctx, plaintext)
+		assert.ErrorIs(t, err, apperrors.ErrUnauthorized)
+	})
+}
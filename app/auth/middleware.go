@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	apperrors "weathersvc/app/app_errors"
+	"weathersvc/app/logging"
+)
+
+// HeaderAPIKey carries the caller's plaintext API key.
+const HeaderAPIKey = "X-API-Key"
+
+const (
+	ScopeWeatherRead    = "weather:read"
+	ScopeAlertsRead     = "alerts:read"
+	ScopeAlertsWrite    = "alerts:write"
+	ScopeLocationsRead  = "locations:read"
+	ScopeLocationsWrite = "locations:write"
+	// ScopeMetricsRead lets metrics scrapers read metrics without the rights of an admin.
+	ScopeMetricsRead = "metrics:read"
+	// ScopeAdmin grants every other scope.
+	ScopeAdmin = "admin"
+)
+
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	// Subject is the API key id or the token subject.
+	Subject string
+	Method  string
+	Scopes  []string
+	// Claims holds the token claims for bearer token callers.
+	Claims *Claims
+}
+
+// HasScope reports whether the principal was granted scope, directly or through ScopeAdmin.
+func (p Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// KeyScopes returns the scopes granted to an API key: only metrics:read for a metrics key, otherwise
+// every scope but metrics:read and admin unless it is an admin key.
+func KeyScopes(k Key) []string {
+	if k.Metrics && !k.Admin {
+		return []string{ScopeMetricsRead}
+	}
+	scopes := []string{ScopeWeatherRead, ScopeAlertsRead, ScopeAlertsWrite, ScopeLocationsRead, ScopeLocationsWrite}
+	if k.Admin {
+		scopes = append(scopes, ScopeAdmin)
+	}
+	return scopes
+}
+
+type contextKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the authenticated caller.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// PrincipalFromContext returns the caller that authenticated the request, if any.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(contextKey{}).(Principal)
+	return p, ok
+}
+
+// Middleware rejects requests without an active API key or a valid bearer token and enforces each
+// caller's quota, falling back to defaults for keys that do not set their own. Bearer tokens are
+// rejected when v is nil. Every response carries X-RateLimit-* headers.
+func Middleware(keys KeyStore, q Quota, defaults Limits, v Verifier) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, limits, ok := authenticate(w, r, keys, v, defaults)
+			if !ok {
+				return
+			}
+			usage, ok := q.Allow(p.Method+":"+p.Subject, limits)
+			if usage.Limit > 0 {
+				w.Header().Set("X-RateLimit-Limit", strconv.Itoa(usage.Limit))
+				w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(usage.Remaining))
+				w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(usage.Reset.Unix(), 10))
+			}
+			if !ok {
+				logging.FromContext(r.Context()).Info("caller is over quota", "method", p.Method, "subject", p.Subject, "reset", usage.Reset)
+				w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(usage.Reset).Seconds())+1))
+				http.Error(w, apperrors.ErrQuotaExceeded.Error(), http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), p)))
+		})
+	}
+}
+
+// authenticate identifies the caller, writing a 401 and reporting false when it cannot.
+func authenticate(w http.ResponseWriter, r *http.Request, keys KeyStore, v Verifier, defaults Limits) (Principal, Limits, bool) {
+	if token, ok := bearerToken(r); ok {
+		if v == nil {
+			http.Error(w, apperrors.ErrUnauthorized.Error(), http.StatusUnauthorized)
+			return Principal{}, Limits{}, false
+		}
+		claims, err := v.Verify(r.Context(), token)
+		if err != nil {
+			logging.FromContext(r.Context()).Info("rejected bearer token", "error", err)
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			http.Error(w, apperrors.ErrUnauthorized.Error(), http.StatusUnauthorized)
+			return Principal{}, Limits{}, false
+		}
+		return Principal{Subject: claims.Subject, Method: MethodJWT, Scopes: claims.Scopes(), Claims: &claims}, defaults, true
+	}
+	plaintext := r.Header.Get(HeaderAPIKey)
+	if plaintext == "" {
+		if v != nil {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+		}
+		http.Error(w, apperrors.ErrUnauthorized.Error(), http.StatusUnauthorized)
+		return Principal{}, Limits{}, false
+	}
+	k, err := keys.Lookup(r.Context(), plaintext)
+	if err != nil {
+		http.Error(w, apperrors.ErrUnauthorized.Error(), http.StatusUnauthorized)
+		return Principal{}, Limits{}, false
+	}
+	return Principal{Subject: k.ID, Method: MethodAPIKey, Scopes: KeyScopes(k)}, k.limits(defaults), true
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	h := r.Header.Get("Authorization")
+	if len(h) < 7 || !strings.EqualFold(h[:7], "bearer ") {
+		return "", false
+	}
+	return strings.TrimSpace(h[7:]), true
+}
+
+// RequireScope only lets requests whose caller holds scope through to next. Writes are audit logged.
+func RequireScope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, ok := PrincipalFromContext(r.Context())
+		if !ok || !p.HasScope(scope) {
+			http.Error(w, apperrors.CreateForbiddenError(scope).Error(), http.StatusForbidden)
+			return
+		}
+		if r.Method != http.MethodGet {
+			audit(r, p)
+		}
+		next(w, r)
+	}
+}
+
+func audit(r *http.Request, p Principal) {
+	attrs := []any{"http_method", r.Method, "path", r.URL.Path, "method", p.Method, "subject", p.Subject}
+	if p.Claims != nil {
+		attrs = append(attrs, "issuer", p.Claims.Issuer, "email", p.Claims.Email)
+	}
+	logging.FromContext(r.Context()).Info("audit", attrs...)
+}
+
+func (k Key) limits(defaults Limits) Limits {
+	l := defaults
+	if k.PerMinute > 0 {
+		l.PerMinute = k.PerMinute
+	}
+	if k.PerDay > 0 {
+		l.PerDay = k.PerDay
+	}
+	return l
+}
@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+	keys := NewMemoryKeyStore()
+	_, plaintext, err := keys.Issue(context.Background(), Key{Name: "client", PerMinute: 1})
+	require.NoError(t, err)
+	signer := newTestSigner(t)
+	var seen Principal
+	h := Middleware(keys, NewQuota(), Limits{PerMinute: 10, PerDay: 100}, signer.verifier(t))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = PrincipalFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+	do := func(header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/weather/get", nil)
+		if value != "" {
+			req.Header.Set(header, value)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+	t.Run("Should reject missing and unknown keys 401", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, do(HeaderAPIKey, "").Code)
+		assert.Equal(t, http.StatusUnauthorized, do(HeaderAPIKey, "wsk_unknown").Code)
+	})
+	t.Run("Should pass the key to the handler with rate limit headers", func(t *testing.T) {
+		rr := do(HeaderAPIKey, plaintext)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, MethodAPIKey, seen.Method)
+		assert.True(t, seen.HasScope(ScopeWeatherRead))
+		assert.False(t, seen.HasScope(ScopeAdmin))
+		assert.Equal(t, "1", rr.Header().Get("X-RateLimit-Limit"))
+		assert.Equal(t, "0", rr.Header().Get("X-RateLimit-Remaining"))
+		assert.NotEmpty(t, rr.Header().Get("X-RateLimit-Reset"))
+	})
+	t.Run("Should reject keys over quota 429", func(t *testing.T) {
+		rr := do(HeaderAPIKey, plaintext)
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+		assert.Equal(t, "0", rr.Header().Get("X-RateLimit-Remaining"))
+		assert.NotEmpty(t, rr.Header().Get("Retry-After"))
+	})
+	t.Run("Should pass token claims to the handler", func(t *testing.T) {
+		rr := do("Authorization", "Bearer "+signer.token(t, signer.claims("alice", "weather:read")))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, MethodJWT, seen.Method)
+		assert.Equal(t, "alice", seen.Subject)
+		require.NotNil(t, seen.Claims)
+		assert.Equal(t, testIssuer, seen.Claims.Issuer)
+		assert.Equal(t, []string{"weather:read"}, seen.Scopes)
+		assert.Equal(t, "10", rr.Header().Get("X-RateLimit-Limit"))
+	})
+	t.Run("Should reject invalid tokens 401", func(t *testing.T) {
+		rr := do("Authorization", "Bearer not-a-token")
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		assert.Equal(t, `Bearer error="invalid_token"`, rr.Header().Get("WWW-Authenticate"))
+	})
+}
+
+func TestMiddleware_NoVerifier(t *testing.T) {
+	t.Run("Should reject bearer tokens when no issuer is configured 401", func(t *testing.T) {
+		signer := newTestSigner(t)
+		h := Middleware(NewMemoryKeyStore(), NewQuota(), Limits{}, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		req := httptest.NewRequest("GET", "/weather/get", nil)
+		req.Header.Set("Authorization", "Bearer "+signer.token(t, signer.claims("alice", "admin")))
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+}
+
+func TestRequireScope(t *testing.T) {
+	h := RequireScope(ScopeAlertsWrite, func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	for name, tc := range map[string]struct {
+		ctx  context.Context
+		want int
+	}{
+		"Should reject requests without a principal 403": {context.Background(), http.StatusForbidden},
+		"Should reject principals without the scope 403": {WithPrincipal(context.Background(), Principal{Scopes: []string{ScopeAlertsRead}}), http.StatusForbidden},
+		"Should allow principals with the scope 200":     {WithPrincipal(context.Background(), Principal{Scopes: []string{ScopeAlertsWrite}}), http.StatusOK},
+		"Should allow admins every scope 200":            {WithPrincipal(context.Background(), Principal{Scopes: []string{ScopeAdmin}}), http.StatusOK},
+	} {
+		t.Run(name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			h(rr, httptest.NewRequest("POST", "/alerts", nil).WithContext(tc.ctx))
+			assert.Equal(t, tc.want, rr.Code)
+			if tc.want == http.StatusForbidden {
+				assert.Contains(t, rr.Body.String(), "forbidden: requires scope `alerts:write`")
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// Limits caps the requests a key may make. A zero limit is unlimited.
+type Limits struct {
+	PerMinute int
+	PerDay    int
+}
+
+// Usage describes the window closest to its limit after a request.
+type Usage struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+type Quota interface {
+	// Allow counts one request for id and reports whether it fits within l. Rejected requests are not counted.
+	Allow(id string, l Limits) (Usage, bool)
+}
+
+type window struct {
+	start time.Time
+	count int
+}
+
+type counters struct {
+	minute window
+	day    window
+}
+
+type quota struct {
+	now func() time.Time
+	mu  sync.Mutex
+	ids map[string]*counters
+}
+
+// NewQuota returns a Quota that counts requests in fixed one minute and one UTC day windows.
+func NewQuota() Quota {
+	return &quota{now: time.Now, ids: map[string]*counters{}}
+}
+
+func (q *quota) Allow(id string, l Limits) (Usage, bool) {
+	now := q.now().UTC()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	c, ok := q.ids[id]
+	if !ok {
+		c = &counters{}
+		q.ids[id] = c
+	}
+	minute := now.Truncate(time.Minute)
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if !c.minute.start.Equal(minute) {
+		c.minute = window{start: minute}
+	}
+	if !c.day.start.Equal(day) {
+		c.day = window{start: day}
+	}
+	perMinute := Usage{Limit: l.PerMinute, Remaining: l.PerMinute - c.minute.count, Reset: minute.Add(time.Minute)}
+	perDay := Usage{Limit: l.PerDay, Remaining: l.PerDay - c.day.count, Reset: day.AddDate(0, 0, 1)}
+	if l.PerDay > 0 && perDay.Remaining <= 0 {
+		perDay.Remaining = 0
+		return perDay, false
+	}
+	if l.PerMinute > 0 && perMinute.Remaining <= 0 {
+		perMinute.Remaining = 0
+		return perMinute, false
+	}
+	c.minute.count++
+	c.day.count++
+	perMinute.Remaining--
+	perDay.Remaining--
+	switch {
+	case l.PerMinute <= 0:
+		return perDay, true
+	case l.PerDay <= 0 || perMinute.Remaining <= perDay.Remaining:
+		return perMinute, true
+	default:
+		return perDay, true
+	}
+}
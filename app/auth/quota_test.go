@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuota_Allow(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 30, 15, 0, time.UTC)
+	q := &quota{now: func() time.Time { return now }, ids: map[string]*counters{}}
+	t.Run("Should count down the tighter window", func(t *testing.T) {
+		usage, ok := q.Allow("a", Limits{PerMinute: 2, PerDay: 100})
+		assert.True(t, ok)
+		assert.Equal(t, Usage{Limit: 2, Remaining: 1, Reset: time.Date(2026, 10, 19, 12, 31, 0, 0, time.UTC)}, usage)
+		usage, ok = q.Allow("a", Limits{PerMinute: 2, PerDay: 100})
+		assert.True(t, ok)
+		assert.Equal(t, 0, usage.Remaining)
+	})
+	t.Run("Should reject requests over the minute quota", func(t *testing.T) {
+		usage, ok := q.Allow("a", Limits{PerMinute: 2, PerDay: 100})
+		assert.False(t, ok)
+		assert.Equal(t, 0, usage.Remaining)
+	})
+	t.Run("Should keep separate counts per key", func(t *testing.T) {
+		_, ok := q.Allow("b", Limits{PerMinute: 2, PerDay: 100})
+		assert.True(t, ok)
+	})
+	t.Run("Should reset the minute window", func(t *testing.T) {
+		now = now.Add(time.Minute)
+		usage, ok := q.Allow("a", Limits{PerMinute: 2, PerDay: 3})
+		assert.True(t, ok)
+		assert.Equal(t, Usage{Limit: 3, Remaining: 0, Reset: time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC)}, usage)
+	})
+	t.Run("Should reject requests over the daily quota until the next day", func(t *testing.T) {
+		now = now.Add(time.Minute)
+		usage, ok := q.Allow("a", Limits{PerMinute: 2, PerDay: 3})
+		assert.False(t, ok)
+		assert.Equal(t, time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC), usage.Reset)
+		now = now.Add(12 * time.Hour)
+		_, ok = q.Allow("a", Limits{PerMinute: 2, PerDay: 3})
+		assert.True(t, ok)
+	})
+	t.Run("Should allow unlimited windows", func(t *testing.T) {
+		for i := 0; i < 10; i++ {
+			_, ok := q.Allow("c", Limits{})
+			assert.True(t, ok)
+		}
+	})
+}
@@ -0,0 +1,181 @@
+/*
+budget.go: Upstream call budget. Every outbound OWM call is counted against per-minute, per-day and
+per-month budgets. Once a budget is nearly spent, the remaining headroom is kept for priority callers
+so alert evaluation keeps working while best-effort lookups are refused.
+*/
+package budget
+
+import (
+	"context"
+	"sync"
+	"time"
+	apperrors "weathersvc/app/app_errors"
+	"weathersvc/app/logging"
+)
+
+const (
+	WindowMinute = "minute"
+	WindowDay    = "day"
+	WindowMonth  = "month"
+)
+
+// Limits are the call budgets per window. A zero budget is unlimited.
+type Limits struct {
+	PerMinute int
+	PerDay    int
+	PerMonth  int
+	// Reserve is the fraction of each budget kept for priority callers, e.g. 0.2.
+	Reserve float64
+}
+
+// Usage is the consumption of one budget window.
+type Usage struct {
+	Window string    `json:"window"`
+	Used   int       `json:"used"`
+	Limit  int       `json:"limit"`
+	Reset  time.Time `json:"reset"`
+	// Refused counts the calls refused in this window.
+	Refused int `json:"refused"`
+}
+
+type Budget interface {
+	// Reserve counts one upstream call, or refuses it with ErrBudgetExhausted when the caller's share
+	// of a budget is spent. Callers marked WithPriority may use the reserved headroom.
+	Reserve(ctx context.Context) error
+	Usage() []Usage
+	// SetLimits replaces the budgets. Calls already counted in the current windows still count.
+	SetLimits(l Limits)
+}
+
+type priorityKey struct{}
+
+// WithPriority marks upstream calls made with ctx as priority.
+func WithPriority(ctx context.Context) context.Context {
+	return context.WithValue(ctx, priorityKey{}, true)
+}
+
+// IsPriority reports whether ctx was marked WithPriority.
+func IsPriority(ctx context.Context) bool {
+	p, _ := ctx.Value(priorityKey{}).(bool)
+	return p
+}
+
+type window struct {
+	name    string
+	limit   int
+	start   time.Time
+	used    int
+	refused int
+}
+
+type budget struct {
+	now     func() time.Time
+	reserve float64
+	mu      sync.Mutex
+	windows []*window
+}
+
+// NewBudget tracks calls against l in UTC calendar windows.
+func NewBudget(l Limits) Budget {
+	return &budget{
+		now:     time.Now,
+		reserve: l.Reserve,
+		windows: []*window{
+			{name: WindowMinute, limit: l.PerMinute},
+			{name: WindowDay, limit: l.PerDay},
+			{name: WindowMonth, limit: l.PerMonth},
+		},
+	}
+}
+
+func (b *budget) SetLimits(l Limits) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.reserve = l.Reserve
+	for _, w := range b.windows {
+		switch w.name {
+		case WindowMinute:
+			w.limit = l.PerMinute
+		case WindowDay:
+			w.limit = l.PerDay
+		default:
+			w.limit = l.PerMonth
+		}
+	}
+}
+
+func (b *budget) Reserve(ctx context.Context) error {
+	now := b.now().UTC()
+	priority := IsPriority(ctx)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, w := range b.windows {
+		w.roll(now)
+	}
+	for _, w := range b.windows {
+		if w.limit <= 0 {
+			continue
+		}
+		allowed := w.limit
+		if !priority {
+			allowed = w.limit - int(float64(w.limit)*b.reserve)
+		}
+		if w.used >= allowed {
+			w.refused++
+			if w.refused == 1 {
+				logging.FromContext(ctx).Warn("upstream budget is spent", "window", w.name, "limit", w.limit, "refusing", kind(priority), "until", w.end())
+			}
+			return apperrors.ErrBudgetExhausted
+		}
+	}
+	for _, w := range b.windows {
+		w.used++
+	}
+	return nil
+}
+
+func (b *budget) Usage() []Usage {
+	now := b.now().UTC()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	usage := make([]Usage, 0, len(b.windows))
+	for _, w := range b.windows {
+		w.roll(now)
+		usage = append(usage, Usage{Window: w.name, Used: w.used, Limit: w.limit, Reset: w.end(), Refused: w.refused})
+	}
+	return usage
+}
+
+// roll starts a new window once now has passed the end of the current one.
+func (w *window) roll(now time.Time) {
+	var start time.Time
+	switch w.name {
+	case WindowMinute:
+		start = now.Truncate(time.Minute)
+	case WindowDay:
+		start = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	default:
+		start = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	if !w.start.Equal(start) {
+		w.start, w.used, w.refused = start, 0, 0
+	}
+}
+
+func (w *window) end() time.Time {
+	switch w.name {
+	case WindowMinute:
+		return w.start.Add(time.Minute)
+	case WindowDay:
+		return w.start.AddDate(0, 0, 1)
+	default:
+		return w.start.AddDate(0, 1, 0)
+	}
+}
+
+func kind(priority bool) string {
+	if priority {
+		return "priority"
+	}
+	return "non-priority"
+}
@@ -0,0 +1,68 @@
+package budget
+
+import (
+	"context"
+	"testing"
+	"time"
+	apperrors "weathersvc/app/app_errors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBudget_Reserve(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 10, 31, 23, 59, 30, 0, time.UTC)
+	b := NewBudget(Limits{PerMinute: 5, PerDay: 100, PerMonth: 1000, Reserve: 0.4}).(*budget)
+	b.now = func() time.Time { return now }
+	t.Run("Should refuse non-priority calls once only the reserve is left", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			require.NoError(t, b.Reserve(ctx))
+		}
+		assert.ErrorIs(t, b.Reserve(ctx), apperrors.ErrBudgetExhausted)
+	})
+	t.Run("Should let priority calls use the reserve up to the limit", func(t *testing.T) {
+		pctx := WithPriority(ctx)
+		require.NoError(t, b.Reserve(pctx))
+		require.NoError(t, b.Reserve(pctx))
+		assert.ErrorIs(t, b.Reserve(pctx), apperrors.ErrBudgetExhausted)
+	})
+	t.Run("Should report usage per window", func(t *testing.T) {
+		usage := b.Usage()
+		require.Len(t, usage, 3)
+		assert.Equal(t, Usage{Window: WindowMinute, Used: 5, Limit: 5, Reset: time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC), Refused: 2}, usage[0])
+		assert.Equal(t, 5, usage[1].Used)
+		assert.Equal(t, time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC), usage[2].Reset)
+	})
+	t.Run("Should reset windows as they end", func(t *testing.T) {
+		now = now.Add(time.Minute)
+		require.NoError(t, b.Reserve(ctx))
+		usage := b.Usage()
+		assert.Equal(t, 1, usage[0].Used)
+		assert.Equal(t, 1, usage[1].Used, "a new day started")
+		assert.Equal(t, 1, usage[2].Used, "a new month started")
+	})
+	t.Run("Should not limit unlimited budgets", func(t *testing.T) {
+		unlimited := NewBudget(Limits{})
+		for i := 0; i < 100; i++ {
+			require.NoError(t, unlimited.Reserve(ctx))
+		}
+	})
+}
+
+func TestBudget_SetLimits(t *testing.T) {
+	ctx := context.Background()
+	t.Run("Should apply new limits to the current windows", func(t *testing.T) {
+		b := NewBudget(Limits{PerMinute: 2})
+		require.NoError(t, b.Reserve(ctx))
+		require.NoError(t, b.Reserve(ctx))
+		assert.ErrorIs(t, b.Reserve(ctx), apperrors.ErrBudgetExhausted)
+		b.SetLimits(Limits{PerMinute: 3, PerDay: 10})
+		require.NoError(t, b.Reserve(ctx))
+		assert.ErrorIs(t, b.Reserve(ctx), apperrors.ErrBudgetExhausted)
+		usage := b.Usage()
+		assert.Equal(t, 3, usage[0].Limit)
+		assert.Equal(t, 10, usage[1].Limit)
+		assert.Equal(t, 0, usage[2].Limit)
+	})
+}
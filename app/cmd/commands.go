@@ -0,0 +1,143 @@
+/*
+commands.go: The weathersvc command line. `serve` runs the service, and the other commands let on-call
+engineers check the config, the upstream and the weather pipeline from a shell.
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"weathersvc/app/config"
+	"weathersvc/app/logging"
+	"weathersvc/app/service"
+)
+
+// command is a subcommand of the weathersvc binary.
+type command struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, args []string, hup <-chan os.Signal, stdout io.Writer) error
+}
+
+func commands() []command {
+	return []command{
+		{"serve", "run the service (the default)", serve},
+		{"get", "print the classified conditions for --lat and --lon or a saved location", get},
+		{"validate-config", "check the config and report every invalid or missing option", validateConfig},
+		{"check-upstream", "call the upstream once with the configured app ids", checkUpstream},
+		{"tui", "show a live dashboard of a running server's weather for saved locations", dashboard},
+		{"openapi", "print the OpenAPI description of the HTTP API", printOpenAPI},
+		{"version", "print the version and build info", version},
+	}
+}
+
+// errInvalidConfig is returned by validate-config once the problems have been printed.
+var errInvalidConfig = errors.New("config is invalid") //nolint:gochecknoglobals // sentinel error
+
+// Execute runs the command named by the first of args, which exclude the program name. Without a command,
+// or when args start with a flag, the service is served as it was before the commands were added.
+func Execute(ctx context.Context, args []string, hup <-chan os.Signal) error {
+	return execute(ctx, args, hup, os.Stdout)
+}
+
+func execute(ctx context.Context, args []string, hup <-chan os.Signal, stdout io.Writer) error {
+	name := "serve"
+	if len(args) > 0 && (args[0] == "help" || args[0] == "-h" || args[0] == "--help") {
+		printUsage(stdout)
+		return nil
+	}
+	if len(args) > 0 && args[0] != "" && args[0][0] != '-' {
+		name, args = args[0], args[1:]
+	}
+	for _, c := range commands() {
+		if c.name == name {
+			err := c.run(ctx, args, hup, stdout)
+			if errors.Is(err, flag.ErrHelp) {
+				return nil
+			}
+			return err
+		}
+	}
+	printUsage(os.Stderr)
+	return fmt.Errorf("unknown command %q", name)
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: weathersvc <command> [flags]")
+	fmt.Fprintln(w, "\nCommands:")
+	for _, c := range commands() {
+		fmt.Fprintf(w, "  %-16s %s\n", c.name, c.usage)
+	}
+	fmt.Fprintln(w, "\nRun `weathersvc <command> -h` for the command's flags.")
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	return flag.NewFlagSet("weathersvc "+name, flag.ContinueOnError)
+}
+
+// setupLogging sends logs, including those written through the standard log package, e.g. by
+// dependencies, to stderr at the configured level. The returned level may be changed later.
+func setupLogging(conf *config.App) *slog.LevelVar {
+	level := &slog.LevelVar{}
+	level.Set(conf.LogLevel)
+	slog.SetDefault(logging.New(os.Stderr, conf.Env, level))
+	return level
+}
+
+// loadConfig parses the command's config flags and builds the config they select.
+func loadConfig(ctx context.Context, fs *flag.FlagSet, args []string) (*config.App, error) {
+	appConf := config.NewFlagConfig(fs)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	return appConf.NewApp(ctx)
+}
+
+// newService builds the service for a one-off command. History is kept in memory, so the command
+// neither records observations nor waits on the store of a running service.
+func newService(ctx context.Context, conf *config.App) (service.Service, error) {
+	conf.HistoryPath = ""
+	setupLogging(conf)
+	return service.NewService(ctx, conf, nil)
+}
+
+func validateConfig(ctx context.Context, args []string, _ <-chan os.Signal, stdout io.Writer) error {
+	fs := newFlagSet("validate-config")
+	conf, err := loadConfig(ctx, fs, args)
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		return err
+	case err != nil:
+		// NewApp joins every problem, one per line
+		fmt.Fprintln(stdout, err)
+		return errInvalidConfig
+	}
+	if conf.ConfigFile != "" {
+		fmt.Fprintf(stdout, "config is valid (%s)\n", conf.ConfigFile)
+		return nil
+	}
+	fmt.Fprintln(stdout, "config is valid")
+	return nil
+}
+
+func checkUpstream(ctx context.Context, args []string, _ <-chan os.Signal, stdout io.Writer) error {
+	conf, err := loadConfig(ctx, newFlagSet("check-upstream"), args)
+	if err != nil {
+		return err
+	}
+	svc, err := newService(ctx, conf)
+	if err != nil {
+		return err
+	}
+	defer svc.Close()
+	if err := svc.ValidateSvc(ctx); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "upstream is reachable (%s)\n", conf.Host)
+	return nil
+}
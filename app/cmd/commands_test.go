@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"weathersvc/app/locations"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newUpstream stands in for Open Weather Map, recording the coordinates asked for.
+func newUpstream(t *testing.T) (*httptest.Server, *[]string) {
+	t.Helper()
+	var got []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.URL.Query().Get("lat")+","+r.URL.Query().Get("lon"))
+		w.Write([]byte(`{"weather":[{"description":"clear sky"}],"main":{"feels_like":95},"wind":{"speed":2},"dt":1720197314,"cod":200}`))
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &got
+}
+
+func TestCommands_execute(t *testing.T) {
+	ctx := context.Background()
+	upstream, got := newUpstream(t)
+	setenv := func() {
+		os.Clearenv()
+		os.Setenv("WEATHER_ID", "fakeID")
+		os.Setenv("WEATHER_HOST", upstream.URL)
+	}
+	run := func(args ...string) (string, error) {
+		var out bytes.Buffer
+		err := execute(ctx, args, nil, &out)
+		return out.String(), err
+	}
+	t.Run("Should print the conditions for coordinates", func(t *testing.T) {
+		setenv()
+		out, err := run("get", "--lat", "32.78", "--lon", "-96.8")
+		assert.NoError(t, err)
+		assert.Contains(t, out, "Outside it is hot with light air and clear sky.")
+		assert.Contains(t, out, "observed:    2024-07-05T16:35:14Z")
+		assert.Equal(t, "32.780000,-96.800000", (*got)[len(*got)-1])
+	})
+	t.Run("Should print the conditions for a saved location as JSON", func(t *testing.T) {
+		setenv()
+		path := filepath.Join(t.TempDir(), "locations.db")
+		store, err := locations.NewBoltStore(path)
+		require.NoError(t, err)
+		_, err = store.Create(ctx, locations.Location{ID: "dallas", Name: "Dallas, TX", Latitude: 32.78, Longitude: -96.8})
+		require.NoError(t, err)
+		require.NoError(t, store.Close())
+		os.Setenv("LOCATIONS_PATH", path)
+		out, err := run("get", "--json", "dallas, tx")
+		assert.NoError(t, err)
+		assert.Contains(t, out, `"Temp": "hot"`)
+		assert.Equal(t, "32.780000,-96.800000", (*got)[len(*got)-1])
+		_, err = run("get", "Austin, TX")
+		assert.EqualError(t, err, `location not found: "Austin, TX"`)
+	})
+	t.Run("Should look up a saved location through the server holding the store", func(t *testing.T) {
+		setenv()
+		path := filepath.Join(t.TempDir(), "locations.db")
+		store, err := locations.NewBoltStore(path)
+		require.NoError(t, err)
+		defer store.Close()
+		_, err = store.Create(ctx, locations.Location{ID: "dallas", Name: "Dallas, TX", Latitude: 32.78, Longitude: -96.8})
+		require.NoError(t, err)
+		var key string
+		svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key = r.Header.Get("X-API-Key")
+			all, err := store.List(r.Context(), "")
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			json.NewEncoder(w).Encode(all)
+		}))
+		defer svr.Close()
+		os.Setenv("LOCATIONS_PATH", path)
+		_, err = run("get", "--server", svr.URL, "--api-key", "secret", "Dallas, TX")
+		assert.NoError(t, err)
+		assert.Equal(t, "secret", key)
+		assert.Equal(t, "32.780000,-96.800000", (*got)[len(*got)-1])
+		svr.Close()
+		_, err = run("get", "--server", svr.URL, "Dallas, TX")
+		assert.ErrorContains(t, err, "LOCATIONS_PATH is held by another process")
+	})
+	t.Run("Should fail to get without coordinates or a location", func(t *testing.T) {
+		setenv()
+		_, err := run("get", "--lat", "32.78")
+		assert.EqualError(t, err, "invalid request: give --lat and --lon or one location")
+		_, err = run("get", "--lat", "91", "--lon", "0")
+		assert.EqualError(t, err, "invalid request: latitude or longitude is out of range")
+	})
+	t.Run("Should validate the config", func(t *testing.T) {
+		setenv()
+		out, err := run("validate-config")
+		assert.NoError(t, err)
+		assert.Equal(t, "config is valid\n", out)
+		os.Setenv("CACHE_TTL", "soon")
+		os.Unsetenv("WEATHER_HOST")
+		out, err = run("validate-config")
+		assert.ErrorIs(t, err, errInvalidConfig)
+		assert.Equal(t, "failed to start service: missing required config for `Weather Host`\nfailed to start service: invalid config for `CACHE_TTL`\n", out)
+	})
+	t.Run("Should check the upstream", func(t *testing.T) {
+		setenv()
+		out, err := run("check-upstream")
+		assert.NoError(t, err)
+		assert.Equal(t, "upstream is reachable ("+upstream.URL+")\n", out)
+		os.Setenv("WEATHER_HOST", "http://127.0.0.1:1")
+		_, err = run("check-upstream")
+		assert.ErrorContains(t, err, "error sending request")
+	})
+	t.Run("Should print the version", func(t *testing.T) {
+		out, err := run("version")
+		assert.NoError(t, err)
+		assert.Contains(t, out, "weathersvc dev\n")
+		assert.Contains(t, out, "go version: go")
+	})
+	t.Run("Should print the API description", func(t *testing.T) {
+		out, err := run("openapi")
+		assert.NoError(t, err)
+		assert.Contains(t, out, "openapi: 3.1.0\n")
+		out, err = run("openapi", "--json")
+		assert.NoError(t, err)
+		assert.Contains(t, out, `"openapi": "3.1.0"`)
+	})
+	t.Run("Should list the commands", func(t *testing.T) {
+		out, err := run("help")
+		assert.NoError(t, err)
+		for _, c := range commands() {
+			assert.Contains(t, out, c.name)
+		}
+		_, err = run("bogus")
+		assert.EqualError(t, err, `unknown command "bogus"`)
+	})
+}
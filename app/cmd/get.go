@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	apperrors "weathersvc/app/app_errors"
+	"weathersvc/app/config"
+	"weathersvc/app/locations"
+	"weathersvc/app/server"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// get prints the classified conditions for --lat and --lon, or for the saved location named by its one
+// argument, through the same service layer the endpoints use.
+func get(ctx context.Context, args []string, _ <-chan os.Signal, stdout io.Writer) error {
+	fs := newFlagSet("get")
+	lat := fs.Float64("lat", 0, "latitude in decimal degrees")
+	lon := fs.Float64("lon", 0, "longitude in decimal degrees")
+	asJSON := fs.Bool("json", false, "print the response as the /v1/weather/get endpoint returns it")
+	remote := locationsServer{}
+	fs.StringVar(&remote.url, "server", "", "base URL of the running server to look up locations through while it holds LOCATIONS_PATH (default http://localhost:PORT)")
+	fs.StringVar(&remote.apiKey, "api-key", os.Getenv("WEATHERSVC_API_KEY"), "API key sent as X-API-Key to the running server, overriding WEATHERSVC_API_KEY")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), `Usage: weathersvc get [flags] --lat LAT --lon LON
+       weathersvc get [flags] "LOCATION"
+
+LOCATION is the id or name of a saved location in LOCATIONS_PATH, e.g. "Dallas, TX". While a running
+server holds LOCATIONS_PATH, the location is looked up through its /locations endpoint.`)
+		fs.PrintDefaults()
+	}
+	conf, err := loadConfig(ctx, fs, args)
+	if err != nil {
+		return err
+	}
+	if remote.url == "" {
+		remote.url = "http://localhost:" + conf.Port
+	}
+	coords := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { coords[f.Name] = true })
+	switch {
+	case fs.NArg() == 1 && !coords["lat"] && !coords["lon"]:
+		l, err := findLocation(ctx, conf, remote, fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		*lat, *lon = l.Latitude, l.Longitude
+	case fs.NArg() == 0 && coords["lat"] && coords["lon"]:
+		if *lat < -90 || *lat > 90 || *lon < -180 || *lon > 180 {
+			return apperrors.CreateInvalidRequestError("latitude or longitude is out of range")
+		}
+	default:
+		fs.Usage()
+		return apperrors.CreateInvalidRequestError("give --lat and --lon or one location")
+	}
+	svc, err := newService(ctx, conf)
+	if err != nil {
+		return err
+	}
+	defer svc.Close()
+	cond, err := svc.GetWeather(ctx, *lat, *lon)
+	if err != nil {
+		return err
+	}
+	resp := server.NewResponse(cond)
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(resp)
+	}
+	fmt.Fprintln(stdout, resp.Message)
+	fmt.Fprintf(stdout, "temperature: %s\ncondition:   %s\nwind:        %s\n", resp.Temp, resp.Condition, resp.Wind)
+	if resp.ObservedAt != nil {
+		fmt.Fprintf(stdout, "observed:    %s\n", resp.ObservedAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// locationsServer is the running server that saved locations are listed through while it holds the store.
+type locationsServer struct {
+	url    string
+	apiKey string
+}
+
+// list asks the running server for every saved location.
+func (s locationsServer) list(ctx context.Context) ([]locations.Location, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(s.url, "/")+"/locations", nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.apiKey != "" {
+		req.Header.Set("X-API-Key", s.apiKey)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", res.Status)
+	}
+	var all []locations.Location
+	if err := json.NewDecoder(res.Body).Decode(&all); err != nil {
+		return nil, fmt.Errorf("error decoding locations: %w", err)
+	}
+	return all, nil
+}
+
+// savedLocations lists the saved locations in LOCATIONS_PATH. Only one process can open the store at a
+// time, so while a running server holds it they are listed through that server instead.
+func savedLocations(ctx context.Context, conf *config.App, remote locationsServer) ([]locations.Location, error) {
+	store, err := locations.NewBoltStore(conf.LocationsPath)
+	if errors.Is(err, bolt.ErrTimeout) {
+		all, err := remote.list(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("LOCATIONS_PATH is held by another process, and listing locations from the server at %s failed: %w", remote.url, err)
+		}
+		return all, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+	return store.List(ctx, "")
+}
+
+// findLocation looks up a saved location by id, or else by name ignoring case.
+func findLocation(ctx context.Context, conf *config.App, remote locationsServer, name string) (locations.Location, error) {
+	if conf.LocationsPath == "" {
+		return locations.Location{}, fmt.Errorf("%w: %q, and LOCATIONS_PATH is not set", apperrors.ErrLocationNotFound, name)
+	}
+	all, err := savedLocations(ctx, conf, remote)
+	if err != nil {
+		return locations.Location{}, err
+	}
+	for _, l := range all {
+		if l.ID == name {
+			return l, nil
+		}
+	}
+	for _, l := range all {
+		if strings.EqualFold(l.Name, name) {
+			return l, nil
+		}
+	}
+	return locations.Location{}, fmt.Errorf("%w: %q", apperrors.ErrLocationNotFound, name)
+}
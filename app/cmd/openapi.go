@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"os"
+	"weathersvc/app/server"
+)
+
+// printOpenAPI writes the API description the server serves at /openapi.json, as YAML by default so
+// it can be checked in: `weathersvc openapi > docs/openapi.yaml`.
+func printOpenAPI(_ context.Context, args []string, _ <-chan os.Signal, stdout io.Writer) error {
+	fs := newFlagSet("openapi")
+	asJSON := fs.Bool("json", false, "print JSON instead of YAML")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	spec := server.OpenAPI()
+	b, err := spec.YAML()
+	if *asJSON {
+		b, err = spec.JSON()
+		b = append(b, '\n')
+	}
+	if err != nil {
+		return err
+	}
+	_, err = stdout.Write(b)
+	return err
+}
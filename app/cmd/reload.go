@@ -0,0 +1,93 @@
+/*
+reload.go: Config reloads on SIGHUP or when the config file changes. The new config is validated and
+its reloadable options are swapped in while requests go on being served. A config that changes
+options needing a restart is rejected whole.
+*/
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"time"
+	"weathersvc/app/config"
+)
+
+// watchInterval is how often the config file is checked for changes.
+const watchInterval = 2 * time.Second
+
+// reloadable is a component that applies reloaded options, such as the service or the server.
+type reloadable interface {
+	Reload(conf *config.App)
+}
+
+type reloader struct {
+	conf    config.AppConfig
+	running atomic.Pointer[config.App]
+	level   *slog.LevelVar
+	targets []reloadable
+}
+
+func newReloader(conf config.AppConfig, running *config.App, level *slog.LevelVar, targets ...reloadable) *reloader {
+	r := &reloader{conf: conf, level: level, targets: targets}
+	r.running.Store(running)
+	return r
+}
+
+// reload re-reads the config and applies it, keeping the running config when the new one is invalid
+// or changes options that need a restart.
+func (r *reloader) reload(ctx context.Context) bool {
+	next, err := r.conf.NewApp(ctx)
+	if err != nil {
+		slog.Error("config reload failed, keeping the running config", "error", err)
+		return false
+	}
+	if changed := config.RestartRequired(r.running.Load(), next); len(changed) > 0 {
+		slog.Error("config reload rejected, these options only change on restart", "options", changed)
+		return false
+	}
+	r.level.Set(next.LogLevel)
+	for _, t := range r.targets {
+		t.Reload(next)
+	}
+	r.running.Store(next)
+	slog.Info("config reloaded", "file", next.ConfigFile)
+	return true
+}
+
+// run reloads on each signal from hup and whenever the config file changes, until ctx is cancelled.
+func (r *reloader) run(ctx context.Context, hup <-chan os.Signal, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	path := r.running.Load().ConfigFile
+	last := checksum(path)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			r.reload(ctx)
+		case <-ticker.C:
+			if path == "" {
+				continue
+			}
+			// the content is compared rather than the modification time, so a file swapped in by a
+			// symlink, as Kubernetes does for mounted config maps, is noticed too
+			if sum := checksum(path); sum != last {
+				last = sum
+				r.reload(ctx)
+			}
+		}
+	}
+}
+
+// checksum returns the SHA-256 of the file at path, or the zero sum when it cannot be read.
+func checksum(path string) [sha256.Size]byte {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return [sha256.Size]byte{}
+	}
+	return sha256.Sum256(b)
+}
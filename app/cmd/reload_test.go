@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+	"weathersvc/app/config"
+	mock_config "weathersvc/mocks/config"
+	mock_server "weathersvc/mocks/server"
+	mock_service "weathersvc/mocks/service"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReloader_reload(t *testing.T) {
+	ctx := context.Background()
+	running := &config.App{Port: "8080", CacheTTL: time.Minute, LogLevel: slog.LevelInfo}
+	setup := func(t *testing.T) (*reloader, *mock_config.MockAppConfig, *mock_service.MockService, *mock_server.MockServer, *slog.LevelVar) {
+		ctrl := gomock.NewController(t)
+		conf := mock_config.NewMockAppConfig(ctrl)
+		svc := mock_service.NewMockService(ctrl)
+		svr := mock_server.NewMockServer(ctrl)
+		level := &slog.LevelVar{}
+		return newReloader(conf, running, level, svc, svr), conf, svc, svr, level
+	}
+	t.Run("Should apply a valid config", func(t *testing.T) {
+		r, conf, svc, svr, level := setup(t)
+		next := &config.App{Port: "8080", CacheTTL: 10 * time.Second, LogLevel: slog.LevelDebug}
+		conf.EXPECT().NewApp(ctx).Return(next, nil)
+		svc.EXPECT().Reload(next)
+		svr.EXPECT().Reload(next)
+		assert.True(t, r.reload(ctx))
+		assert.Same(t, next, r.running.Load())
+		assert.Equal(t, slog.LevelDebug, level.Level())
+	})
+	t.Run("Should keep the running config when the new one is invalid", func(t *testing.T) {
+		r, conf, _, _, _ := setup(t)
+		conf.EXPECT().NewApp(ctx).Return(nil, errors.New("invalid config"))
+		assert.False(t, r.reload(ctx))
+		assert.Same(t, running, r.running.Load())
+	})
+	t.Run("Should reject a config that changes restart-only options", func(t *testing.T) {
+		r, conf, _, _, _ := setup(t)
+		conf.EXPECT().NewApp(ctx).Return(&config.App{Port: "9090", CacheTTL: 10 * time.Second}, nil)
+		assert.False(t, r.reload(ctx))
+		assert.Same(t, running, r.running.Load())
+	})
+}
+
+func TestReloader_run(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	path := filepath.Join(t.TempDir(), "weathersvc.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("cache:\n  ttl: 1m\n"), 0o600))
+	ctrl := gomock.NewController(t)
+	conf := mock_config.NewMockAppConfig(ctrl)
+	svc := mock_service.NewMockService(ctrl)
+	reloaded := make(chan *config.App, 2)
+	svc.EXPECT().Reload(gomock.Any()).Do(func(next *config.App) { reloaded <- next }).Times(2)
+	conf.EXPECT().NewApp(gomock.Any()).Return(&config.App{ConfigFile: path, CacheTTL: 30 * time.Second}, nil).Times(2)
+	hup := make(chan os.Signal, 1)
+	r := newReloader(conf, &config.App{ConfigFile: path, CacheTTL: time.Minute}, &slog.LevelVar{}, svc)
+	go r.run(ctx, hup, 10*time.Millisecond)
+	t.Run("Should reload on SIGHUP", func(t *testing.T) {
+		hup <- syscall.SIGHUP
+		select {
+		case <-reloaded:
+		case <-time.After(time.Second):
+			t.Fatal("expected a reload after SIGHUP")
+		}
+	})
+	t.Run("Should reload when the config file changes", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, []byte("cache:\n  ttl: 30s\n"), 0o600))
+		select {
+		case <-reloaded:
+		case <-time.After(time.Second):
+			t.Fatal("expected a reload after the file changed")
+		}
+	})
+}
@@ -2,34 +2,81 @@ package cmd
 
 import (
 	"context"
-	"log"
+	"io"
+	"log/slog"
+	"os"
 	"weathersvc/app/config"
+	"weathersvc/app/metrics"
 	"weathersvc/app/server"
 	"weathersvc/app/service"
+	"weathersvc/app/tracing"
 )
 
-func Execute(ctx context.Context) error {
-	conf, err := config.NewAppConfig().NewApp(ctx)
+// serve runs the service with the serve command's args. The config is reloaded on each signal from hup
+// and whenever the config file changes.
+func serve(ctx context.Context, args []string, hup <-chan os.Signal, stdout io.Writer) error {
+	fs := newFlagSet("serve")
+	printConfig := fs.Bool("print-config", false, "print the effective config with secrets redacted and exit")
+	appConf := config.NewFlagConfig(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	conf, err := appConf.NewApp(ctx)
+	if err != nil {
+		return err
+	}
+	if *printConfig {
+		return conf.Print(stdout)
+	}
+	level := setupLogging(conf)
+	shutdownTracing, err := tracing.Setup(ctx, conf.TracingConfig)
 	if err != nil {
 		return err
 	}
-	svc := service.NewService(ctx, conf)
-	err = svc.ValidateSvc(ctx)
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			slog.Error("failed to flush traces", "error", err)
+		}
+	}()
+	m := metrics.NewMetrics()
+	svc, err := service.NewService(ctx, conf, m)
+	if err != nil {
+		return err
+	}
+	defer svc.Close()
+	// app ids are re-read so they can be rotated without a restart
+	if conf.AppIDSecret != nil {
+		go conf.AppIDSecret.Watch(ctx, conf.SecretRefresh)
+	}
+	if conf.AppIDsSecret != nil {
+		go conf.AppIDsSecret.Watch(ctx, conf.SecretRefresh)
+	}
+	// upstream budget consumption is scraped with the other metrics at /metrics
+	m.WatchBudget(svc.UpstreamUsage)
+	if err := svc.ValidateSvc(ctx); err != nil {
+		if !conf.AllowDegradedStart {
+			return err
+		}
+		// readiness reports the upstream as degraded until a call to it succeeds
+		slog.Warn("upstream is unreachable, starting degraded", "error", err)
+	}
+	svr, err := server.NewServer(conf, svc, m)
 	if err != nil {
 		return err
 	}
-	svr := server.NewServer(conf, svc)
-	log.Println("Service Starting")
+	go newReloader(appConf, conf, level, svc, svr).run(ctx, hup, watchInterval)
+	slog.Info("service starting", "env", conf.Env, "version", Version)
 	// listen for context cancellation to handle signal inter
 	go func() {
 		<-ctx.Done()
 		if err := svr.Close(); err != nil {
-			log.Printf("Failed to gracefully shutdown the service: %v", err)
+			slog.Error("failed to gracefully shutdown the service", "error", err)
 		}
 	}()
-	// Start the HTTP server.
+	// Start the HTTP server. Open returns once Close has drained requests, so the deferred svc.Close and
+	// trace flush only run after the requests that use them.
 	if err := svr.Open(); err != nil {
-		log.Printf("Failed to create rest server: %v", err)
+		slog.Error("failed to create rest server", "error", err)
 		return err
 	}
 	return nil
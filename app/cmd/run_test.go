@@ -3,12 +3,14 @@ package cmd_test
 import (
 	"context"
 	"errors"
+	"io"
 	"os"
 	"testing"
 
 	cmd "weathersvc/app/cmd"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCMD_Execute(t *testing.T) {
@@ -19,7 +21,7 @@ func TestCMD_Execute(t *testing.T) {
 	os.Setenv("ENV", "testing")
 	os.Setenv("SERVICE_URL", "http://fakevalue.com/")
 	t.Run("Should fail when config is invalid", func(t *testing.T) {
-		gotErr := cmd.Execute(ctx)
+		gotErr := cmd.Execute(ctx, nil, nil)
 		assert.EqualError(t, gotErr, errors.New("config `WEATHER_ID` is invalid").Error())
 	})
 	t.Run("Should fail to run due to missing `Weather App ID` config", func(t *testing.T) {
@@ -28,7 +30,29 @@ func TestCMD_Execute(t *testing.T) {
 		os.Setenv("PORT", "8081")
 		os.Setenv("ENV", "testing")
 		os.Setenv("SERVICE_URL", "fakevalue")
-		gotErr := cmd.Execute(ctx)
+		gotErr := cmd.Execute(ctx, nil, nil)
 		assert.EqualError(t, gotErr, errors.New("failed to start service: missing required config for `Weather App ID`").Error())
 	})
+	t.Run("Should fail on unknown flags", func(t *testing.T) {
+		gotErr := cmd.Execute(ctx, []string{"--verbose"}, nil)
+		assert.EqualError(t, gotErr, "flag provided but not defined: -verbose")
+	})
+	t.Run("Should print the effective config without starting", func(t *testing.T) {
+		os.Clearenv()
+		os.Setenv("WEATHER_ID", "fakeID")
+		os.Setenv("WEATHER_HOST", "fakeHost")
+		r, w, err := os.Pipe()
+		require.NoError(t, err)
+		stdout := os.Stdout
+		os.Stdout = w
+		gotErr := cmd.Execute(ctx, []string{"--print-config", "--cache.ttl=30s"}, nil)
+		os.Stdout = stdout
+		w.Close()
+		out, err := io.ReadAll(r)
+		require.NoError(t, err)
+		assert.NoError(t, gotErr)
+		assert.Contains(t, string(out), "ttl: 30s")
+		assert.Contains(t, string(out), "app_id: REDACTED")
+		assert.NotContains(t, string(out), "fakeID")
+	})
 }
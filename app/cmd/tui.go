@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"os"
+	"weathersvc/app/tui"
+)
+
+// dashboard runs the terminal dashboard against a running server. It needs none of the service's config.
+func dashboard(ctx context.Context, args []string, _ <-chan os.Signal, _ io.Writer) error {
+	fs := newFlagSet("tui")
+	opts := tui.Options{}
+	fs.StringVar(&opts.Server, "server", "http://localhost:8080", "base URL of the running server")
+	fs.StringVar(&opts.APIKey, "api-key", os.Getenv("WEATHERSVC_API_KEY"), "API key sent as X-API-Key, overriding WEATHERSVC_API_KEY")
+	fs.DurationVar(&opts.Interval, "interval", tui.DefaultInterval, "how often every location is polled")
+	fs.StringVar(&opts.File, "file", tui.DefaultFile(), "JSON file keeping the watched locations")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return tui.Run(ctx, opts)
+}
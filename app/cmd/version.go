@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"runtime/debug"
+)
+
+// Build info, stamped at build time, e.g.
+//
+//	go build -ldflags "-X weathersvc/app/cmd.Version=v1.4.0 -X weathersvc/app/cmd.Commit=$(git rev-parse --short HEAD) -X weathersvc/app/cmd.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// The commit and date fall back to the VCS info Go records when they are not stamped.
+//
+//nolint:gochecknoglobals // set by the linker
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildDate = ""
+)
+
+// buildInfo returns the commit and build date, falling back to the revision and commit time Go
+// records for builds inside a git checkout.
+func buildInfo() (commit, date string) {
+	commit, date = Commit, BuildDate
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return commit, date
+	}
+	for _, s := range info.Settings {
+		switch {
+		case s.Key == "vcs.revision" && commit == "":
+			commit = s.Value
+		case s.Key == "vcs.time" && date == "":
+			date = s.Value
+		}
+	}
+	return commit, date
+}
+
+func version(_ context.Context, args []string, _ <-chan os.Signal, stdout io.Writer) error {
+	fs := newFlagSet("version")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	commit, date := buildInfo()
+	if commit == "" {
+		commit = "unknown"
+	}
+	if date == "" {
+		date = "unknown"
+	}
+	fmt.Fprintf(stdout, "weathersvc %s\ncommit:     %s\nbuilt:      %s\ngo version: %s %s/%s\n",
+		Version, commit, date, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	return nil
+}
@@ -2,8 +2,79 @@ package config
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
 	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 	appErr "weathersvc/app/app_errors"
+	"weathersvc/app/secrets"
+)
+
+const (
+	// DefaultPollInterval is how often watched locations are refreshed from the upstream.
+	DefaultPollInterval = time.Minute
+	// DefaultMaxSubscriptions is how many locations a single websocket connection may watch.
+	DefaultMaxSubscriptions = 25
+	// DefaultAlertInterval is how often alert rules are evaluated.
+	DefaultAlertInterval = time.Minute
+	// DefaultAlertHysteresis is how many consecutive evaluations fire or resolve an alert.
+	DefaultAlertHysteresis = 2
+	// DefaultAlertWebhookAttempts is how many times an alert webhook is tried before it is dead-lettered.
+	DefaultAlertWebhookAttempts = 3
+	// DefaultAPIKeyPerMinute is how many requests an API key may make each minute unless the key sets its own quota.
+	DefaultAPIKeyPerMinute = 60
+	// DefaultAPIKeyPerDay is how many requests an API key may make each UTC day unless the key sets its own quota.
+	DefaultAPIKeyPerDay = 10000
+	// DefaultJWKSRefresh is how long an issuer's signing keys are cached.
+	DefaultJWKSRefresh = time.Hour
+	// DefaultRateLimit is how many requests each caller may make to each route before being limited.
+	DefaultRateLimit = "300/m"
+	// DefaultIPRateLimit is how many requests each client address may make across every route before
+	// its credentials are checked.
+	DefaultIPRateLimit = "1200/m"
+	// DefaultBudgetPerMinute and DefaultBudgetPerMonth match the Open Weather Map free plan.
+	DefaultBudgetPerMinute = 60
+	DefaultBudgetPerMonth  = 1000000
+	// DefaultBudgetReserve is the share of each upstream budget kept for priority callers.
+	DefaultBudgetReserve = 0.2
+	// DefaultCacheTTL is how long a fetched condition is served before it is refreshed in the background.
+	DefaultCacheTTL = time.Minute
+	// DefaultCacheMaxStale is how long a condition may be served while the upstream is failing.
+	DefaultCacheMaxStale = 30 * time.Minute
+	// DefaultCacheMaxAge is how long clients may reuse a weather response without revalidating it.
+	DefaultCacheMaxAge = 30 * time.Second
+	// DefaultHistoryRetention is how long observations are kept.
+	DefaultHistoryRetention = 30 * 24 * time.Hour
+	// DefaultHistoryMaxPerLocation is how many observations are kept at each location, a month of
+	// readings at the default poll interval.
+	DefaultHistoryMaxPerLocation = 50000
+	// DefaultServiceName names this service on exported traces.
+	DefaultServiceName = "weathersvc"
+	// DefaultHealthInterval is how often dependency health is checked.
+	DefaultHealthInterval = time.Minute
+	// DefaultHealthTimeout bounds each dependency check.
+	DefaultHealthTimeout = 5 * time.Second
+	// DefaultReadHeaderTimeout bounds reading request headers.
+	DefaultReadHeaderTimeout = 3 * time.Second
+	// DefaultShutdownTimeout is how long in-flight requests may drain on shutdown.
+	DefaultShutdownTimeout = 30 * time.Second
+	// DefaultSecretRefresh is how often secrets set by reference are re-read.
+	DefaultSecretRefresh = 5 * time.Minute
+	// DefaultKeyCooldown is how long an app id that was rate limited is left out of rotation.
+	DefaultKeyCooldown = time.Minute
+)
+
+// Trace exporters selectable with `OTEL_TRACES_EXPORTER`.
+const (
+	TraceExporterNone   = "none"
+	TraceExporterOTLP   = "otlp"
+	TraceExporterStdout = "stdout"
 )
 
 type AppConfig interface {
@@ -13,39 +84,624 @@ type AppConfig interface {
 type App struct {
 	Port string
 	Env  string
+	// ConfigFile is the YAML or TOML file the config was read from, if any.
+	ConfigFile string
+	// PollInterval is how often the shared poller refreshes each watched location.
+	PollInterval time.Duration
+	// MaxSubscriptions is how many locations a single websocket connection may watch.
+	MaxSubscriptions int
+	// HistoryPath is the embedded observation store file; history is kept in memory when empty.
+	HistoryPath string
+	// HistoryRetention is how long observations are kept; 0 keeps them regardless of age.
+	HistoryRetention time.Duration
+	// HistoryMaxPerLocation is how many of the latest observations are kept at each location; 0 is unlimited.
+	HistoryMaxPerLocation int
+	// LocationsPath is the embedded saved locations store file; locations are kept in memory when empty.
+	LocationsPath string
+	// CacheTTL is how long a fetched condition is fresh; it is then served stale while it is refreshed.
+	CacheTTL time.Duration
+	// CacheMaxStale is the oldest condition served when the upstream is failing.
+	CacheMaxStale time.Duration
+	// CacheMaxAge is the `Cache-Control: max-age` of weather responses; 0 makes clients revalidate every time.
+	CacheMaxAge time.Duration
+	// HealthInterval is how often dependency health is checked for readiness.
+	HealthInterval time.Duration
+	// HealthTimeout bounds each dependency check.
+	HealthTimeout time.Duration
+	// AllowDegradedStart starts the service even when the upstream is unreachable; readiness reports
+	// the upstream as degraded until a call to it succeeds.
+	AllowDegradedStart bool
+	// LogLevel is the least severe level logged.
+	LogLevel slog.Level
+	ServerConfig
 	WeatherClientConfig
+	AlertConfig
+	AuthConfig
+	RateLimitConfig
+	BudgetConfig
+	TracingConfig
+	SecretsConfig
+}
+
+// ServerConfig bounds HTTP connections. A zero read, write or idle timeout is unlimited.
+type ServerConfig struct {
+	// ReadHeaderTimeout bounds reading request headers.
+	ReadHeaderTimeout time.Duration
+	// ReadTimeout bounds reading each request, including the body.
+	ReadTimeout time.Duration
+	// WriteTimeout bounds writing each response. Streams are cut off once it passes, so it is unset by default.
+	WriteTimeout time.Duration
+	// IdleTimeout is how long a keep-alive connection may wait for the next request.
+	IdleTimeout time.Duration
+	// ShutdownTimeout is how long in-flight requests may drain on shutdown.
+	ShutdownTimeout time.Duration
 }
 
 type WeatherClientConfig struct {
 	Host  string
 	AppID string
+	// AppIDSecret re-reads the app id when it is set by reference, so it may be rotated. It is nil otherwise.
+	AppIDSecret *secrets.Secret
+	// AppIDs are more app ids, e.g. from other team accounts, that upstream calls are spread over with AppID.
+	AppIDs []AppKey
+	// AppIDsSecret re-reads AppIDs when they are set by reference, so they may be rotated. It is nil otherwise.
+	AppIDsSecret *secrets.Secret
+	// KeyCooldown is how long an app id that was rate limited is left out of rotation.
+	KeyCooldown time.Duration
+}
+
+// AppKey is an Open Weather Map app id and its share of upstream calls.
+type AppKey struct {
+	ID string
+	// Weight is how many calls the key takes for each call taken by a key of weight 1.
+	Weight int
+}
+
+// String formats k as `id` or `id=weight`, as it is set in `WEATHER_IDS`.
+func (k AppKey) String() string {
+	if k.Weight == 1 {
+		return k.ID
+	}
+	return k.ID + "=" + strconv.Itoa(k.Weight)
+}
+
+type AlertConfig struct {
+	// AlertInterval is how often alert rules are evaluated.
+	AlertInterval time.Duration
+	// AlertHysteresis is how many consecutive evaluations are needed to fire or resolve an alert.
+	AlertHysteresis int
+	// AlertWebhookAttempts is how many times a webhook is tried before it is dead-lettered.
+	AlertWebhookAttempts int
+	// AlertWebhookSecret is the HMAC key used to sign webhook bodies.
+	AlertWebhookSecret string
+	// AlertWebhookHosts are the only hosts webhooks are delivered to when set, even at internal
+	// addresses; otherwise any host outside the private, loopback and link-local ranges is.
+	AlertWebhookHosts []string
+}
+
+type AuthConfig struct {
+	// APIKeysPath is the JSON file holding hashed API keys; issued keys are kept in memory when empty.
+	APIKeysPath string
+	// AdminAPIKey is a plaintext key granted admin access, used to issue the first keys.
+	AdminAPIKey string
+	// APIKeyPerMinute and APIKeyPerDay are the quotas for keys that do not set their own.
+	APIKeyPerMinute int
+	APIKeyPerDay    int
+	// OIDCIssuer and OIDCAudience are the `iss` and `aud` bearer tokens must carry.
+	OIDCIssuer   string
+	OIDCAudience string
+	// JWKSURL overrides the signing keys URL discovered from the issuer.
+	JWKSURL string
+	// JWKSFile is a static JWKS document used instead of fetching keys, for tests and offline use.
+	JWKSFile string
+	// JWKSRefresh is how long fetched signing keys are cached.
+	JWKSRefresh time.Duration
+}
+
+// Enabled reports whether API key or bearer token authentication is configured. Requests are not authenticated otherwise.
+func (a AuthConfig) Enabled() bool {
+	return a.APIKeysPath != "" || a.AdminAPIKey != "" || a.OIDCEnabled()
+}
+
+// OIDCEnabled reports whether bearer tokens from an OIDC issuer are accepted.
+func (a AuthConfig) OIDCEnabled() bool {
+	return a.OIDCIssuer != ""
+}
+
+// Rate allows Count requests every Per. The zero Rate is unlimited.
+type Rate struct {
+	Count int
+	Per   time.Duration
+}
+
+// String formats r as read by ParseRate.
+func (r Rate) String() string {
+	unit := map[time.Duration]string{time.Second: "s", time.Minute: "m", time.Hour: "h"}[r.Per]
+	if r.Count <= 0 || unit == "" {
+		return "off"
+	}
+	return fmt.Sprintf("%d/%s", r.Count, unit)
+}
+
+type RateLimitConfig struct {
+	// RateLimit applies to each caller on each route without its own limit.
+	RateLimit Rate
+	// RouteRateLimits overrides RateLimit by route path template, e.g. `/alerts/{id}`.
+	RouteRateLimits map[string]Rate
+	// IPRateLimit applies to each client address across every route before authentication, so requests
+	// with missing or wrong credentials are limited too.
+	IPRateLimit Rate
+	// TrustedProxies may set `X-Forwarded-For` to the real client address.
+	TrustedProxies []*net.IPNet
+}
+
+// BudgetConfig caps outbound Open Weather Map calls. A zero budget is unlimited.
+type BudgetConfig struct {
+	BudgetPerMinute int
+	BudgetPerDay    int
+	BudgetPerMonth  int
+	// BudgetReserve is the share of each budget only priority callers may use.
+	BudgetReserve float64
+}
+
+// TracingConfig selects where OpenTelemetry spans are exported. The OTLP exporter also reads the
+// standard `OTEL_EXPORTER_OTLP_*` variables, e.g. `OTEL_EXPORTER_OTLP_ENDPOINT`.
+type TracingConfig struct {
+	// TraceExporter is one of none, otlp or stdout.
+	TraceExporter string
+	ServiceName   string
+	// TraceSampleRatio is the share of new traces sampled; incoming sampled traces are always kept.
+	TraceSampleRatio float64
+}
+
+// SecretsConfig selects where secrets set by reference are read from. `WEATHER_ID`, `WEATHER_IDS`,
+// `ADMIN_API_KEY`, `ALERT_WEBHOOK_SECRET` and `VAULT_TOKEN` may be read from the file named by the option with a `_FILE`
+// suffix, e.g. `WEATHER_ID_FILE`, and all but `VAULT_TOKEN` from Vault with a `vault:mount/path#field` value.
+type SecretsConfig struct {
+	// SecretRefresh is how often the app id is re-read when it is set by reference.
+	SecretRefresh time.Duration
+	// VaultAddr is the Vault server holding KV version 2 secrets.
+	VaultAddr      string
+	VaultToken     string
+	VaultNamespace string
 }
 
-type appConfigImpl struct{}
+type appConfigImpl struct {
+	// path is the config file named on the command line, which takes precedence over `CONFIG_FILE`.
+	path *string
+	// flags holds the options set on the command line, by environment variable name.
+	flags map[string]string
+}
 
+// NewAppConfig reads the config from the environment over the file named by `CONFIG_FILE`, if any.
 func NewAppConfig() AppConfig {
 	return &appConfigImpl{}
 }
 
+// NewFlagConfig registers `--config` and a flag for every config file option on fs, e.g. `--cache.ttl`.
+// Flags take precedence over the environment, which takes precedence over the file. fs must be parsed
+// before NewApp is called.
+func NewFlagConfig(fs *flag.FlagSet) AppConfig {
+	a := &appConfigImpl{flags: map[string]string{}}
+	a.path = fs.String("config", "", "YAML or TOML config `file`, overriding CONFIG_FILE")
+	fields(reflect.ValueOf(&File{}).Elem(), "", func(name, key string, _ reflect.Value) {
+		fs.Func(name, "overrides "+key, func(v string) error {
+			a.flags[key] = v
+			return nil
+		})
+	})
+	return a
+}
+
+// NewApp resolves every option from the flags, the environment, the config file and then the defaults.
+// All invalid and missing options are reported together.
 func (a *appConfigImpl) NewApp(ctx context.Context) (*App, error) {
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	path := os.Getenv("CONFIG_FILE")
+	if a.path != nil && *a.path != "" {
+		path = *a.path
+	}
+	l := &loader{ctx: ctx, flags: a.flags}
+	if path != "" {
+		f, err := LoadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		l.file = f.values()
+	}
+	app := l.app()
+	if err := errors.Join(l.errs...); err != nil {
+		return nil, err
+	}
+	app.ConfigFile = path
+	return app, nil
+}
+
+// loader reads options by environment variable name, collecting every problem instead of stopping at the first.
+type loader struct {
+	ctx   context.Context
+	flags map[string]string
+	file  map[string]string
+	errs  []error
+	// vault reads secrets set with a `vault:` value, once VAULT_ADDR is known.
+	vault secrets.Provider
+}
+
+// get returns the first non-empty value for key from the flags, the environment and the file.
+func (l *loader) get(key string) string {
+	if v := l.flags[key]; v != "" {
+		return v
+	}
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return l.file[key]
+}
+
+func (l *loader) invalid(key string) {
+	l.errs = append(l.errs, appErr.CreateInvalidConfigError(key))
+}
+
+func (l *loader) str(key, def string) string {
+	if v := l.get(key); v != "" {
+		return v
 	}
-	wAppID := os.Getenv("WEATHER_ID")
-	if wAppID == "" {
-		return nil, appErr.CreateMissingConfigError("Weather App ID")
+	return def
+}
+
+// required reads an option that has no default, reporting it missing by name when unset.
+func (l *loader) required(key, name string) string {
+	v := l.get(key)
+	if v == "" {
+		l.errs = append(l.errs, appErr.CreateMissingConfigError(name))
+	}
+	return v
+}
+
+// duration reads a positive duration such as `30s`, falling back to def when unset.
+func (l *loader) duration(key string, def time.Duration) time.Duration {
+	v := l.get(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		l.invalid(key)
+		return def
+	}
+	return d
+}
+
+// timeout reads a non-negative duration, where 0 is unlimited, falling back to def when unset.
+func (l *loader) timeout(key string, def time.Duration) time.Duration {
+	v := l.get(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		l.invalid(key)
+		return def
+	}
+	return d
+}
+
+// list reads comma separated values, ignoring empty ones.
+func (l *loader) list(key string) []string {
+	var out []string
+	for _, v := range strings.Split(l.get(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// integer reads a positive integer, falling back to def when unset.
+func (l *loader) integer(key string, def int) int {
+	v := l.get(key)
+	if v == "" {
+		return def
 	}
-	wHost := os.Getenv("WEATHER_HOST")
-	if wHost == "" {
-		return nil, appErr.CreateMissingConfigError("Weather Host")
+	i, err := strconv.Atoi(v)
+	if err != nil || i <= 0 {
+		l.invalid(key)
+		return def
 	}
-	return &App{
-		Port: port,
-		Env:  os.Getenv("ENV"),
+	return i
+}
+
+// limit reads a non-negative integer, where 0 means unlimited, falling back to def when unset.
+func (l *loader) limit(key string, def int) int {
+	v := l.get(key)
+	if v == "" {
+		return def
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil || i < 0 {
+		l.invalid(key)
+		return def
+	}
+	return i
+}
+
+// fraction reads a float within [0, upper], or [0, upper) when open is set, falling back to def when unset.
+func (l *loader) fraction(key string, def, upper float64, open bool) float64 {
+	v := l.get(key)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < 0 || f > upper || (open && f == upper) {
+		l.invalid(key)
+		return def
+	}
+	return f
+}
+
+func (l *loader) boolean(key string) bool {
+	v := l.get(key)
+	if v == "" {
+		return false
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		l.invalid(key)
+	}
+	return b
+}
+
+// secret reads an option that may instead be set by reference: a file named by the option with a `_FILE`
+// suffix, or a Vault entry with a `vault:` value. The Secret is returned for options set by reference.
+func (l *loader) secret(key string) (string, *secrets.Secret) {
+	var p secrets.Provider
+	ref := l.get(key + "_FILE")
+	switch v := l.get(key); {
+	case ref != "":
+		p = secrets.NewFileProvider()
+	case strings.HasPrefix(v, secrets.VaultPrefix):
+		if l.vault == nil {
+			l.errs = append(l.errs, appErr.CreateMissingConfigError("VAULT_ADDR"))
+			return "", nil
+		}
+		p, ref = l.vault, v
+	default:
+		return v, nil
+	}
+	s, err := secrets.New(l.ctx, key, p, ref)
+	if err == nil && s.Value() == "" {
+		err = errors.New("secret is empty")
+	}
+	if err != nil {
+		l.errs = append(l.errs, appErr.CreateSecretError(key, err))
+		return "", nil
+	}
+	return s.Value(), s
+}
+
+// appKeys reads a list of app ids, each optionally weighted as `id=weight`. The list may be set by
+// reference like any other secret, in which case its Secret is returned so the list may be rotated.
+func (l *loader) appKeys(key string) ([]AppKey, *secrets.Secret) {
+	v, s := l.secret(key)
+	if v == "" {
+		return nil, nil
+	}
+	keys, err := ParseAppKeys(v)
+	if err != nil {
+		l.invalid(key)
+		return nil, nil
+	}
+	return keys, s
+}
+
+// ParseAppKeys parses a comma separated list of app ids, each optionally weighted as `id=weight`.
+func ParseAppKeys(v string) ([]AppKey, error) {
+	var keys []AppKey
+	for _, entry := range strings.Split(v, ",") {
+		id, weight, weighted := strings.Cut(strings.TrimSpace(entry), "=")
+		k := AppKey{ID: id, Weight: 1}
+		if weighted {
+			w, err := strconv.Atoi(weight)
+			if err != nil || w < 1 {
+				return nil, fmt.Errorf("app id weight %q is not a positive integer", weight)
+			}
+			k.Weight = w
+		}
+		if k.ID == "" {
+			return nil, errors.New("app id is empty")
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+func (l *loader) app() *App {
+	secretsConf := l.secretsConfig()
+	appID, appIDSecret := l.secret("WEATHER_ID")
+	appIDs, appIDsSecret := l.appKeys("WEATHER_IDS")
+	set := func(key string) bool { return l.get(key) != "" || l.get(key+"_FILE") != "" }
+	if !set("WEATHER_ID") && !set("WEATHER_IDS") {
+		l.errs = append(l.errs, appErr.CreateMissingConfigError("Weather App ID"))
+	}
+	app := &App{
+		Port: l.str("PORT", "8080"),
+		Env:  l.get("ENV"),
 		WeatherClientConfig: WeatherClientConfig{
-			Host:  wHost,
-			AppID: wAppID,
+			AppID:        appID,
+			AppIDSecret:  appIDSecret,
+			AppIDs:       appIDs,
+			AppIDsSecret: appIDsSecret,
+			KeyCooldown:  l.duration("WEATHER_ID_COOLDOWN", DefaultKeyCooldown),
+			Host:         l.required("WEATHER_HOST", "Weather Host"),
 		},
-	}, nil
+		SecretsConfig:         secretsConf,
+		PollInterval:          l.duration("POLL_INTERVAL", DefaultPollInterval),
+		MaxSubscriptions:      l.integer("WS_MAX_SUBSCRIPTIONS", DefaultMaxSubscriptions),
+		ServerConfig:          l.serverConfig(),
+		AlertConfig:           l.alertConfig(),
+		AuthConfig:            l.authConfig(),
+		RateLimitConfig:       l.rateLimitConfig(),
+		BudgetConfig:          l.budgetConfig(),
+		HistoryPath:           l.get("HISTORY_PATH"),
+		HistoryRetention:      l.timeout("HISTORY_RETENTION", DefaultHistoryRetention),
+		HistoryMaxPerLocation: l.limit("HISTORY_MAX_PER_LOCATION", DefaultHistoryMaxPerLocation),
+		LocationsPath:         l.get("LOCATIONS_PATH"),
+		CacheTTL:              l.duration("CACHE_TTL", DefaultCacheTTL),
+		CacheMaxStale:         l.duration("CACHE_MAX_STALE", DefaultCacheMaxStale),
+		CacheMaxAge:           l.timeout("CACHE_MAX_AGE", DefaultCacheMaxAge),
+		HealthInterval:        l.duration("HEALTH_INTERVAL", DefaultHealthInterval),
+		HealthTimeout:         l.duration("HEALTH_TIMEOUT", DefaultHealthTimeout),
+		AllowDegradedStart:    l.boolean("ALLOW_DEGRADED_START"),
+	}
+	if app.CacheMaxStale < app.CacheTTL {
+		l.invalid("CACHE_MAX_STALE")
+	}
+	if v := l.get("LOG_LEVEL"); v != "" {
+		if err := app.LogLevel.UnmarshalText([]byte(v)); err != nil {
+			l.invalid("LOG_LEVEL")
+		}
+	}
+	app.TracingConfig = l.tracingConfig()
+	return app
+}
+
+// secretsConfig also sets up the Vault provider, so it is read before any secret.
+func (l *loader) secretsConfig() SecretsConfig {
+	conf := SecretsConfig{
+		SecretRefresh:  l.duration("SECRET_REFRESH_INTERVAL", DefaultSecretRefresh),
+		VaultAddr:      l.get("VAULT_ADDR"),
+		VaultNamespace: l.get("VAULT_NAMESPACE"),
+	}
+	conf.VaultToken, _ = l.secret("VAULT_TOKEN")
+	if conf.VaultAddr == "" {
+		return conf
+	}
+	if conf.VaultToken == "" {
+		l.errs = append(l.errs, appErr.CreateMissingConfigError("VAULT_TOKEN"))
+	}
+	l.vault = secrets.NewVaultProvider(conf.VaultAddr, conf.VaultToken, conf.VaultNamespace)
+	return conf
+}
+
+func (l *loader) serverConfig() ServerConfig {
+	return ServerConfig{
+		ReadHeaderTimeout: l.duration("SERVER_READ_HEADER_TIMEOUT", DefaultReadHeaderTimeout),
+		ReadTimeout:       l.timeout("SERVER_READ_TIMEOUT", 0),
+		WriteTimeout:      l.timeout("SERVER_WRITE_TIMEOUT", 0),
+		IdleTimeout:       l.timeout("SERVER_IDLE_TIMEOUT", 0),
+		ShutdownTimeout:   l.duration("SERVER_SHUTDOWN_TIMEOUT", DefaultShutdownTimeout),
+	}
+}
+
+func (l *loader) alertConfig() AlertConfig {
+	webhookSecret, _ := l.secret("ALERT_WEBHOOK_SECRET")
+	return AlertConfig{
+		AlertInterval:        l.duration("ALERT_INTERVAL", DefaultAlertInterval),
+		AlertHysteresis:      l.integer("ALERT_HYSTERESIS", DefaultAlertHysteresis),
+		AlertWebhookAttempts: l.integer("ALERT_WEBHOOK_ATTEMPTS", DefaultAlertWebhookAttempts),
+		AlertWebhookSecret:   webhookSecret,
+		AlertWebhookHosts:    l.list("ALERT_WEBHOOK_HOSTS"),
+	}
+}
+
+func (l *loader) authConfig() AuthConfig {
+	adminKey, _ := l.secret("ADMIN_API_KEY")
+	conf := AuthConfig{
+		APIKeysPath:     l.get("API_KEYS_PATH"),
+		AdminAPIKey:     adminKey,
+		APIKeyPerMinute: l.integer("API_KEY_PER_MINUTE", DefaultAPIKeyPerMinute),
+		APIKeyPerDay:    l.integer("API_KEY_PER_DAY", DefaultAPIKeyPerDay),
+		OIDCIssuer:      l.get("OIDC_ISSUER"),
+		OIDCAudience:    l.get("OIDC_AUDIENCE"),
+		JWKSURL:         l.get("OIDC_JWKS_URL"),
+		JWKSFile:        l.get("OIDC_JWKS_FILE"),
+		JWKSRefresh:     l.duration("OIDC_JWKS_REFRESH", DefaultJWKSRefresh),
+	}
+	if conf.OIDCEnabled() && conf.OIDCAudience == "" {
+		l.errs = append(l.errs, appErr.CreateMissingConfigError("OIDC_AUDIENCE"))
+	}
+	return conf
+}
+
+func (l *loader) rateLimitConfig() RateLimitConfig {
+	def, err := ParseRate(l.str("RATE_LIMIT", DefaultRateLimit))
+	if err != nil {
+		l.invalid("RATE_LIMIT")
+	}
+	ip, err := ParseRate(l.str("RATE_LIMIT_IP", DefaultIPRateLimit))
+	if err != nil {
+		l.invalid("RATE_LIMIT_IP")
+	}
+	routes := map[string]Rate{}
+	if v := l.get("RATE_LIMIT_ROUTES"); v != "" {
+		for _, entry := range strings.Split(v, ",") {
+			route, rate, ok := strings.Cut(strings.TrimSpace(entry), "=")
+			r, err := ParseRate(rate)
+			if !ok || !strings.HasPrefix(route, "/") || err != nil {
+				l.invalid("RATE_LIMIT_ROUTES")
+				break
+			}
+			routes[route] = r
+		}
+	}
+	var proxies []*net.IPNet
+	if v := l.get("TRUSTED_PROXIES"); v != "" {
+		for _, entry := range strings.Split(v, ",") {
+			cidr := strings.TrimSpace(entry)
+			if !strings.Contains(cidr, "/") {
+				if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+					cidr += "/32"
+				} else {
+					cidr += "/128"
+				}
+			}
+			_, ipNet, err := net.ParseCIDR(cidr)
+			if err != nil {
+				l.invalid("TRUSTED_PROXIES")
+				break
+			}
+			proxies = append(proxies, ipNet)
+		}
+	}
+	return RateLimitConfig{RateLimit: def, RouteRateLimits: routes, IPRateLimit: ip, TrustedProxies: proxies}
+}
+
+func (l *loader) budgetConfig() BudgetConfig {
+	return BudgetConfig{
+		BudgetPerMinute: l.limit("OWM_BUDGET_PER_MINUTE", DefaultBudgetPerMinute),
+		BudgetPerDay:    l.limit("OWM_BUDGET_PER_DAY", 0),
+		BudgetPerMonth:  l.limit("OWM_BUDGET_PER_MONTH", DefaultBudgetPerMonth),
+		BudgetReserve:   l.fraction("OWM_BUDGET_RESERVE", DefaultBudgetReserve, 1, true),
+	}
+}
+
+func (l *loader) tracingConfig() TracingConfig {
+	exporter := strings.ToLower(l.str("OTEL_TRACES_EXPORTER", TraceExporterNone))
+	switch exporter {
+	case TraceExporterNone, TraceExporterOTLP, TraceExporterStdout:
+	default:
+		l.invalid("OTEL_TRACES_EXPORTER")
+	}
+	return TracingConfig{
+		TraceExporter:    exporter,
+		ServiceName:      l.str("OTEL_SERVICE_NAME", DefaultServiceName),
+		TraceSampleRatio: l.fraction("TRACE_SAMPLE_RATIO", 1, 1, false),
+	}
+}
+
+// ParseRate reads a rate such as `100/m`: a count per second (`s`), minute (`m`) or hour (`h`).
+// `0` and `off` are unlimited.
+func ParseRate(v string) (Rate, error) {
+	if v == "0" || v == "off" {
+		return Rate{}, nil
+	}
+	count, unit, ok := strings.Cut(v, "/")
+	n, err := strconv.Atoi(count)
+	if !ok || err != nil || n <= 0 {
+		return Rate{}, appErr.CreateInvalidConfigError(v)
+	}
+	per := map[string]time.Duration{"s": time.Second, "m": time.Minute, "h": time.Hour}[unit]
+	if per == 0 {
+		return Rate{}, appErr.CreateInvalidConfigError(v)
+	}
+	return Rate{Count: n, Per: per}, nil
 }
@@ -2,8 +2,16 @@ package config_test
 
 import (
 	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 	apperrors "weathersvc/app/app_errors"
 	"weathersvc/app/config"
 
@@ -78,4 +86,489 @@ func TestNewApp(t *testing.T) {
 		assert.EqualValues(t, expected.WeatherClientConfig.AppID, resp.WeatherClientConfig.AppID)
 		assert.EqualValues(t, expected.WeatherClientConfig.Host, resp.WeatherClientConfig.Host)
 	})
+	t.Run("Should default PollInterval when POLL_INTERVAL is missing", func(t *testing.T) {
+		os.Clearenv()
+		os.Setenv("WEATHER_ID", "fakeID")
+		os.Setenv("WEATHER_HOST", "fakeHost")
+		resp, err := config.NewAppConfig().NewApp(ctx)
+		assert.NoError(t, err, "No errors expected for Config")
+		assert.Equal(t, config.DefaultPollInterval, resp.PollInterval)
+	})
+	t.Run("Should set PollInterval from POLL_INTERVAL", func(t *testing.T) {
+		os.Clearenv()
+		os.Setenv("WEATHER_ID", "fakeID")
+		os.Setenv("WEATHER_HOST", "fakeHost")
+		os.Setenv("POLL_INTERVAL", "30s")
+		resp, err := config.NewAppConfig().NewApp(ctx)
+		assert.NoError(t, err, "No errors expected for Config")
+		assert.Equal(t, 30*time.Second, resp.PollInterval)
+	})
+	t.Run("Should fail to create NewApp when POLL_INTERVAL is invalid", func(t *testing.T) {
+		os.Clearenv()
+		os.Setenv("WEATHER_ID", "fakeID")
+		os.Setenv("WEATHER_HOST", "fakeHost")
+		os.Setenv("POLL_INTERVAL", "soon")
+		resp, err := config.NewAppConfig().NewApp(ctx)
+		assert.EqualError(t, err, apperrors.CreateInvalidConfigError("POLL_INTERVAL").Error())
+		assert.Nil(t, resp)
+	})
+	t.Run("Should set MaxSubscriptions from WS_MAX_SUBSCRIPTIONS", func(t *testing.T) {
+		os.Clearenv()
+		os.Setenv("WEATHER_ID", "fakeID")
+		os.Setenv("WEATHER_HOST", "fakeHost")
+		resp, err := config.NewAppConfig().NewApp(ctx)
+		assert.NoError(t, err, "No errors expected for Config")
+		assert.Equal(t, config.DefaultMaxSubscriptions, resp.MaxSubscriptions)
+		os.Setenv("WS_MAX_SUBSCRIPTIONS", "3")
+		resp, err = config.NewAppConfig().NewApp(ctx)
+		assert.NoError(t, err, "No errors expected for Config")
+		assert.Equal(t, 3, resp.MaxSubscriptions)
+	})
+	t.Run("Should fail to create NewApp when WS_MAX_SUBSCRIPTIONS is invalid", func(t *testing.T) {
+		os.Clearenv()
+		os.Setenv("WEATHER_ID", "fakeID")
+		os.Setenv("WEATHER_HOST", "fakeHost")
+		os.Setenv("WS_MAX_SUBSCRIPTIONS", "-1")
+		resp, err := config.NewAppConfig().NewApp(ctx)
+		assert.EqualError(t, err, apperrors.CreateInvalidConfigError("WS_MAX_SUBSCRIPTIONS").Error())
+		assert.Nil(t, resp)
+	})
+	t.Run("Should set AlertConfig from the environment", func(t *testing.T) {
+		os.Clearenv()
+		os.Setenv("WEATHER_ID", "fakeID")
+		os.Setenv("WEATHER_HOST", "fakeHost")
+		os.Setenv("ALERT_INTERVAL", "5m")
+		os.Setenv("ALERT_HYSTERESIS", "3")
+		os.Setenv("ALERT_WEBHOOK_SECRET", "shh")
+		os.Setenv("ALERT_WEBHOOK_HOSTS", "hooks.internal, 10.0.0.7,")
+		resp, err := config.NewAppConfig().NewApp(ctx)
+		assert.NoError(t, err, "No errors expected for Config")
+		assert.Equal(t, config.AlertConfig{
+			AlertInterval:        5 * time.Minute,
+			AlertHysteresis:      3,
+			AlertWebhookAttempts: config.DefaultAlertWebhookAttempts,
+			AlertWebhookSecret:   "shh",
+			AlertWebhookHosts:    []string{"hooks.internal", "10.0.0.7"},
+		}, resp.AlertConfig)
+	})
+	t.Run("Should fail to create NewApp when ALERT_HYSTERESIS is invalid", func(t *testing.T) {
+		os.Clearenv()
+		os.Setenv("WEATHER_ID", "fakeID")
+		os.Setenv("WEATHER_HOST", "fakeHost")
+		os.Setenv("ALERT_HYSTERESIS", "often")
+		resp, err := config.NewAppConfig().NewApp(ctx)
+		assert.EqualError(t, err, apperrors.CreateInvalidConfigError("ALERT_HYSTERESIS").Error())
+		assert.Nil(t, resp)
+	})
+	t.Run("Should set AuthConfig from the environment", func(t *testing.T) {
+		os.Clearenv()
+		os.Setenv("WEATHER_ID", "fakeID")
+		os.Setenv("WEATHER_HOST", "fakeHost")
+		os.Setenv("ADMIN_API_KEY", "bootstrap")
+		os.Setenv("API_KEY_PER_MINUTE", "10")
+		resp, err := config.NewAppConfig().NewApp(ctx)
+		assert.NoError(t, err, "No errors expected for Config")
+		assert.Equal(t, config.AuthConfig{
+			AdminAPIKey:     "bootstrap",
+			APIKeyPerMinute: 10,
+			APIKeyPerDay:    config.DefaultAPIKeyPerDay,
+			JWKSRefresh:     config.DefaultJWKSRefresh,
+		}, resp.AuthConfig)
+		assert.True(t, resp.AuthConfig.Enabled())
+	})
+	t.Run("Should leave auth disabled when no keys are configured", func(t *testing.T) {
+		os.Clearenv()
+		os.Setenv("WEATHER_ID", "fakeID")
+		os.Setenv("WEATHER_HOST", "fakeHost")
+		resp, err := config.NewAppConfig().NewApp(ctx)
+		assert.NoError(t, err, "No errors expected for Config")
+		assert.False(t, resp.AuthConfig.Enabled())
+	})
+	t.Run("Should fail to create NewApp when API_KEY_PER_DAY is invalid", func(t *testing.T) {
+		os.Clearenv()
+		os.Setenv("WEATHER_ID", "fakeID")
+		os.Setenv("WEATHER_HOST", "fakeHost")
+		os.Setenv("API_KEY_PER_DAY", "-1")
+		resp, err := config.NewAppConfig().NewApp(ctx)
+		assert.EqualError(t, err, apperrors.CreateInvalidConfigError("API_KEY_PER_DAY").Error())
+		assert.Nil(t, resp)
+	})
+	t.Run("Should enable bearer tokens when OIDC_ISSUER is set", func(t *testing.T) {
+		os.Clearenv()
+		os.Setenv("WEATHER_ID", "fakeID")
+		os.Setenv("WEATHER_HOST", "fakeHost")
+		os.Setenv("OIDC_ISSUER", "https://id.example.com")
+		os.Setenv("OIDC_AUDIENCE", "weathersvc")
+		os.Setenv("OIDC_JWKS_FILE", "/etc/weathersvc/jwks.json")
+		resp, err := config.NewAppConfig().NewApp(ctx)
+		assert.NoError(t, err, "No errors expected for Config")
+		assert.True(t, resp.AuthConfig.OIDCEnabled())
+		assert.True(t, resp.AuthConfig.Enabled())
+		assert.Equal(t, "/etc/weathersvc/jwks.json", resp.JWKSFile)
+	})
+	t.Run("Should fail to create NewApp when OIDC_AUDIENCE is missing", func(t *testing.T) {
+		os.Clearenv()
+		os.Setenv("WEATHER_ID", "fakeID")
+		os.Setenv("WEATHER_HOST", "fakeHost")
+		os.Setenv("OIDC_ISSUER", "https://id.example.com")
+		resp, err := config.NewAppConfig().NewApp(ctx)
+		assert.EqualError(t, err, apperrors.CreateMissingConfigError("OIDC_AUDIENCE").Error())
+		assert.Nil(t, resp)
+	})
+	t.Run("Should default RateLimit when RATE_LIMIT is missing", func(t *testing.T) {
+		os.Clearenv()
+		os.Setenv("WEATHER_ID", "fakeID")
+		os.Setenv("WEATHER_HOST", "fakeHost")
+		resp, err := config.NewAppConfig().NewApp(ctx)
+		assert.NoError(t, err, "No errors expected for Config")
+		assert.Equal(t, config.Rate{Count: 300, Per: time.Minute}, resp.RateLimit)
+		assert.Equal(t, config.Rate{Count: 1200, Per: time.Minute}, resp.IPRateLimit)
+		assert.Empty(t, resp.RouteRateLimits)
+	})
+	t.Run("Should set RateLimitConfig from the environment", func(t *testing.T) {
+		os.Clearenv()
+		os.Setenv("WEATHER_ID", "fakeID")
+		os.Setenv("WEATHER_HOST", "fakeHost")
+		os.Setenv("RATE_LIMIT", "off")
+		os.Setenv("RATE_LIMIT_IP", "10/s")
+		os.Setenv("RATE_LIMIT_ROUTES", "/weather/get=5/s, /alerts/{id}=100/h")
+		os.Setenv("TRUSTED_PROXIES", "10.0.0.0/8,192.168.1.1")
+		resp, err := config.NewAppConfig().NewApp(ctx)
+		assert.NoError(t, err, "No errors expected for Config")
+		assert.Equal(t, config.Rate{}, resp.RateLimit)
+		assert.Equal(t, config.Rate{Count: 10, Per: time.Second}, resp.IPRateLimit)
+		assert.Equal(t, map[string]config.Rate{
+			"/weather/get": {Count: 5, Per: time.Second},
+			"/alerts/{id}": {Count: 100, Per: time.Hour},
+		}, resp.RouteRateLimits)
+		if assert.Len(t, resp.TrustedProxies, 2) {
+			assert.Equal(t, "192.168.1.1/32", resp.TrustedProxies[1].String())
+		}
+	})
+	t.Run("Should fail to create NewApp when rate limits are invalid", func(t *testing.T) {
+		for key, v := range map[string]string{
+			"RATE_LIMIT":        "5/week",
+			"RATE_LIMIT_ROUTES": "weather=5/s",
+			"RATE_LIMIT_IP":     "many",
+			"TRUSTED_PROXIES":   "proxy.local",
+		} {
+			os.Clearenv()
+			os.Setenv("WEATHER_ID", "fakeID")
+			os.Setenv("WEATHER_HOST", "fakeHost")
+			os.Setenv(key, v)
+			resp, err := config.NewAppConfig().NewApp(ctx)
+			assert.EqualError(t, err, apperrors.CreateInvalidConfigError(key).Error())
+			assert.Nil(t, resp)
+		}
+	})
+	t.Run("Should default BudgetConfig to the free plan", func(t *testing.T) {
+		os.Clearenv()
+		os.Setenv("WEATHER_ID", "fakeID")
+		os.Setenv("WEATHER_HOST", "fakeHost")
+		resp, err := config.NewAppConfig().NewApp(ctx)
+		assert.NoError(t, err, "No errors expected for Config")
+		assert.Equal(t, config.BudgetConfig{
+			BudgetPerMinute: config.DefaultBudgetPerMinute,
+			BudgetPerMonth:  config.DefaultBudgetPerMonth,
+			BudgetReserve:   config.DefaultBudgetReserve,
+		}, resp.BudgetConfig)
+	})
+	t.Run("Should set BudgetConfig from the environment", func(t *testing.T) {
+		os.Clearenv()
+		os.Setenv("WEATHER_ID", "fakeID")
+		os.Setenv("WEATHER_HOST", "fakeHost")
+		os.Setenv("OWM_BUDGET_PER_MINUTE", "0")
+		os.Setenv("OWM_BUDGET_PER_DAY", "5000")
+		os.Setenv("OWM_BUDGET_RESERVE", "0.5")
+		resp, err := config.NewAppConfig().NewApp(ctx)
+		assert.NoError(t, err, "No errors expected for Config")
+		assert.Equal(t, config.BudgetConfig{
+			BudgetPerDay:   5000,
+			BudgetPerMonth: config.DefaultBudgetPerMonth,
+			BudgetReserve:  0.5,
+		}, resp.BudgetConfig)
+	})
+	t.Run("Should fail to create NewApp when budgets are invalid", func(t *testing.T) {
+		for key, v := range map[string]string{
+			"OWM_BUDGET_PER_MONTH": "-1",
+			"OWM_BUDGET_RESERVE":   "1",
+		} {
+			os.Clearenv()
+			os.Setenv("WEATHER_ID", "fakeID")
+			os.Setenv("WEATHER_HOST", "fakeHost")
+			os.Setenv(key, v)
+			resp, err := config.NewAppConfig().NewApp(ctx)
+			assert.EqualError(t, err, apperrors.CreateInvalidConfigError(key).Error())
+			assert.Nil(t, resp)
+		}
+	})
+	t.Run("Should set cache durations from the environment", func(t *testing.T) {
+		os.Clearenv()
+		os.Setenv("WEATHER_ID", "fakeID")
+		os.Setenv("WEATHER_HOST", "fakeHost")
+		resp, err := config.NewAppConfig().NewApp(ctx)
+		assert.NoError(t, err, "No errors expected for Config")
+		assert.Equal(t, config.DefaultCacheTTL, resp.CacheTTL)
+		assert.Equal(t, config.DefaultCacheMaxStale, resp.CacheMaxStale)
+		assert.Equal(t, config.DefaultCacheMaxAge, resp.CacheMaxAge)
+		os.Setenv("CACHE_TTL", "10s")
+		os.Setenv("CACHE_MAX_STALE", "1h")
+		os.Setenv("CACHE_MAX_AGE", "0s")
+		resp, err = config.NewAppConfig().NewApp(ctx)
+		assert.NoError(t, err, "No errors expected for Config")
+		assert.Equal(t, 10*time.Second, resp.CacheTTL)
+		assert.Equal(t, time.Hour, resp.CacheMaxStale)
+		assert.Equal(t, time.Duration(0), resp.CacheMaxAge)
+	})
+	t.Run("Should set history retention from the environment", func(t *testing.T) {
+		os.Clearenv()
+		os.Setenv("WEATHER_ID", "fakeID")
+		os.Setenv("WEATHER_HOST", "fakeHost")
+		resp, err := config.NewAppConfig().NewApp(ctx)
+		assert.NoError(t, err, "No errors expected for Config")
+		assert.Equal(t, config.DefaultHistoryRetention, resp.HistoryRetention)
+		assert.Equal(t, config.DefaultHistoryMaxPerLocation, resp.HistoryMaxPerLocation)
+		os.Setenv("HISTORY_RETENTION", "0s")
+		os.Setenv("HISTORY_MAX_PER_LOCATION", "100")
+		resp, err = config.NewAppConfig().NewApp(ctx)
+		assert.NoError(t, err, "No errors expected for Config")
+		assert.Equal(t, time.Duration(0), resp.HistoryRetention)
+		assert.Equal(t, 100, resp.HistoryMaxPerLocation)
+	})
+	t.Run("Should fail to create NewApp when the max staleness is below the TTL", func(t *testing.T) {
+		os.Clearenv()
+		os.Setenv("WEATHER_ID", "fakeID")
+		os.Setenv("WEATHER_HOST", "fakeHost")
+		os.Setenv("CACHE_TTL", "10m")
+		os.Setenv("CACHE_MAX_STALE", "5m")
+		resp, err := config.NewAppConfig().NewApp(ctx)
+		assert.EqualError(t, err, apperrors.CreateInvalidConfigError("CACHE_MAX_STALE").Error())
+		assert.Nil(t, resp)
+	})
+	t.Run("Should set TracingConfig from the environment", func(t *testing.T) {
+		os.Clearenv()
+		os.Setenv("WEATHER_ID", "fakeID")
+		os.Setenv("WEATHER_HOST", "fakeHost")
+		resp, err := config.NewAppConfig().NewApp(ctx)
+		assert.NoError(t, err, "No errors expected for Config")
+		assert.Equal(t, config.TracingConfig{
+			TraceExporter:    config.TraceExporterNone,
+			ServiceName:      config.DefaultServiceName,
+			TraceSampleRatio: 1,
+		}, resp.TracingConfig)
+		os.Setenv("OTEL_TRACES_EXPORTER", "OTLP")
+		os.Setenv("OTEL_SERVICE_NAME", "weather-edge")
+		os.Setenv("TRACE_SAMPLE_RATIO", "0.25")
+		resp, err = config.NewAppConfig().NewApp(ctx)
+		assert.NoError(t, err, "No errors expected for Config")
+		assert.Equal(t, config.TracingConfig{
+			TraceExporter:    config.TraceExporterOTLP,
+			ServiceName:      "weather-edge",
+			TraceSampleRatio: 0.25,
+		}, resp.TracingConfig)
+	})
+	t.Run("Should fail to create NewApp when tracing is invalid", func(t *testing.T) {
+		for key, v := range map[string]string{
+			"OTEL_TRACES_EXPORTER": "zipkin",
+			"TRACE_SAMPLE_RATIO":   "2",
+		} {
+			os.Clearenv()
+			os.Setenv("WEATHER_ID", "fakeID")
+			os.Setenv("WEATHER_HOST", "fakeHost")
+			os.Setenv(key, v)
+			resp, err := config.NewAppConfig().NewApp(ctx)
+			assert.EqualError(t, err, apperrors.CreateInvalidConfigError(key).Error())
+			assert.Nil(t, resp)
+		}
+	})
+	t.Run("Should set LogLevel from the environment", func(t *testing.T) {
+		os.Clearenv()
+		os.Setenv("WEATHER_ID", "fakeID")
+		os.Setenv("WEATHER_HOST", "fakeHost")
+		resp, err := config.NewAppConfig().NewApp(ctx)
+		assert.NoError(t, err, "No errors expected for Config")
+		assert.Equal(t, slog.LevelInfo, resp.LogLevel)
+		os.Setenv("LOG_LEVEL", "debug")
+		resp, err = config.NewAppConfig().NewApp(ctx)
+		assert.NoError(t, err, "No errors expected for Config")
+		assert.Equal(t, slog.LevelDebug, resp.LogLevel)
+		os.Setenv("LOG_LEVEL", "verbose")
+		resp, err = config.NewAppConfig().NewApp(ctx)
+		assert.EqualError(t, err, apperrors.CreateInvalidConfigError("LOG_LEVEL").Error())
+		assert.Nil(t, resp)
+	})
+	t.Run("Should set health checks from the environment", func(t *testing.T) {
+		os.Clearenv()
+		os.Setenv("WEATHER_ID", "fakeID")
+		os.Setenv("WEATHER_HOST", "fakeHost")
+		resp, err := config.NewAppConfig().NewApp(ctx)
+		assert.NoError(t, err, "No errors expected for Config")
+		assert.Equal(t, config.DefaultHealthInterval, resp.HealthInterval)
+		assert.Equal(t, config.DefaultHealthTimeout, resp.HealthTimeout)
+		assert.False(t, resp.AllowDegradedStart)
+		os.Setenv("HEALTH_INTERVAL", "15s")
+		os.Setenv("HEALTH_TIMEOUT", "2s")
+		os.Setenv("ALLOW_DEGRADED_START", "true")
+		resp, err = config.NewAppConfig().NewApp(ctx)
+		assert.NoError(t, err, "No errors expected for Config")
+		assert.Equal(t, 15*time.Second, resp.HealthInterval)
+		assert.Equal(t, 2*time.Second, resp.HealthTimeout)
+		assert.True(t, resp.AllowDegradedStart)
+		os.Setenv("ALLOW_DEGRADED_START", "sometimes")
+		resp, err = config.NewAppConfig().NewApp(ctx)
+		assert.EqualError(t, err, apperrors.CreateInvalidConfigError("ALLOW_DEGRADED_START").Error())
+		assert.Nil(t, resp)
+	})
+	t.Run("Should set ServerConfig from the environment", func(t *testing.T) {
+		os.Clearenv()
+		os.Setenv("WEATHER_ID", "fakeID")
+		os.Setenv("WEATHER_HOST", "fakeHost")
+		resp, err := config.NewAppConfig().NewApp(ctx)
+		assert.NoError(t, err, "No errors expected for Config")
+		assert.Equal(t, config.ServerConfig{
+			ReadHeaderTimeout: config.DefaultReadHeaderTimeout,
+			ShutdownTimeout:   config.DefaultShutdownTimeout,
+		}, resp.ServerConfig)
+		os.Setenv("SERVER_READ_TIMEOUT", "10s")
+		os.Setenv("SERVER_WRITE_TIMEOUT", "0")
+		os.Setenv("SERVER_IDLE_TIMEOUT", "2m")
+		os.Setenv("SERVER_SHUTDOWN_TIMEOUT", "5s")
+		resp, err = config.NewAppConfig().NewApp(ctx)
+		assert.NoError(t, err, "No errors expected for Config")
+		assert.Equal(t, config.ServerConfig{
+			ReadHeaderTimeout: config.DefaultReadHeaderTimeout,
+			ReadTimeout:       10 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			ShutdownTimeout:   5 * time.Second,
+		}, resp.ServerConfig)
+		os.Setenv("SERVER_IDLE_TIMEOUT", "-1s")
+		resp, err = config.NewAppConfig().NewApp(ctx)
+		assert.EqualError(t, err, apperrors.CreateInvalidConfigError("SERVER_IDLE_TIMEOUT").Error())
+		assert.Nil(t, resp)
+	})
+	t.Run("Should report every invalid and missing option at once", func(t *testing.T) {
+		os.Clearenv()
+		os.Setenv("POLL_INTERVAL", "soon")
+		os.Setenv("OWM_BUDGET_RESERVE", "2")
+		resp, err := config.NewAppConfig().NewApp(ctx)
+		assert.Nil(t, resp)
+		assert.EqualError(t, err, strings.Join([]string{
+			apperrors.CreateMissingConfigError("Weather App ID").Error(),
+			apperrors.CreateMissingConfigError("Weather Host").Error(),
+			apperrors.CreateInvalidConfigError("POLL_INTERVAL").Error(),
+			apperrors.CreateInvalidConfigError("OWM_BUDGET_RESERVE").Error(),
+		}, "\n"))
+	})
+	t.Run("Should layer flags over the environment over the config file", func(t *testing.T) {
+		os.Clearenv()
+		path := filepath.Join(t.TempDir(), "weathersvc.yaml")
+		err := os.WriteFile(path, []byte(`
+port: "9090"
+provider:
+  host: http://owm.local
+  app_id: fileID
+cache:
+  ttl: 30s
+  max_stale: 10m
+`), 0o600)
+		assert.NoError(t, err)
+		os.Setenv("CONFIG_FILE", path)
+		os.Setenv("WEATHER_ID", "envID")
+		os.Setenv("CACHE_TTL", "45s")
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		conf := config.NewFlagConfig(fs)
+		assert.NoError(t, fs.Parse([]string{"--cache.ttl=1m"}))
+		resp, err := conf.NewApp(ctx)
+		assert.NoError(t, err, "No errors expected for Config")
+		assert.Equal(t, path, resp.ConfigFile)
+		assert.Equal(t, "9090", resp.Port)
+		assert.Equal(t, "http://owm.local", resp.Host)
+		assert.Equal(t, "envID", resp.AppID)
+		assert.Equal(t, time.Minute, resp.CacheTTL)
+		assert.Equal(t, 10*time.Minute, resp.CacheMaxStale)
+		assert.Equal(t, config.DefaultPollInterval, resp.PollInterval)
+	})
+	t.Run("Should prefer the config flag over CONFIG_FILE", func(t *testing.T) {
+		os.Clearenv()
+		os.Setenv("CONFIG_FILE", filepath.Join(t.TempDir(), "missing.yaml"))
+		path := filepath.Join(t.TempDir(), "weathersvc.toml")
+		err := os.WriteFile(path, []byte("[provider]\nhost = \"http://owm.local\"\napp_id = \"fileID\"\n"), 0o600)
+		assert.NoError(t, err)
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		conf := config.NewFlagConfig(fs)
+		assert.NoError(t, fs.Parse([]string{"--config", path}))
+		resp, err := conf.NewApp(ctx)
+		assert.NoError(t, err, "No errors expected for Config")
+		assert.Equal(t, "fileID", resp.AppID)
+	})
+	t.Run("Should read secrets set by reference", func(t *testing.T) {
+		os.Clearenv()
+		vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"data":{"data":{"admin":"vaultAdmin"}}}`))
+		}))
+		defer vault.Close()
+		dir := t.TempDir()
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "app_id"), []byte("fileID\n"), 0o600))
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "vault_token"), []byte("root"), 0o600))
+		os.Setenv("WEATHER_ID_FILE", filepath.Join(dir, "app_id"))
+		os.Setenv("WEATHER_HOST", "fakeHost")
+		os.Setenv("VAULT_ADDR", vault.URL)
+		os.Setenv("VAULT_TOKEN_FILE", filepath.Join(dir, "vault_token"))
+		os.Setenv("ADMIN_API_KEY", "vault:secret/weathersvc#admin")
+		resp, err := config.NewAppConfig().NewApp(ctx)
+		assert.NoError(t, err, "No errors expected for Config")
+		assert.Equal(t, "fileID", resp.AppID)
+		if assert.NotNil(t, resp.AppIDSecret) {
+			assert.Equal(t, "fileID", resp.AppIDSecret.Value())
+		}
+		assert.Equal(t, "root", resp.VaultToken)
+		assert.Equal(t, "vaultAdmin", resp.AdminAPIKey)
+		assert.Equal(t, config.DefaultSecretRefresh, resp.SecretRefresh)
+	})
+	t.Run("Should read more app ids with their weights", func(t *testing.T) {
+		os.Clearenv()
+		os.Setenv("WEATHER_HOST", "fakeHost")
+		os.Setenv("WEATHER_IDS", "teamA, teamB=3")
+		os.Setenv("WEATHER_ID_COOLDOWN", "30s")
+		resp, err := config.NewAppConfig().NewApp(ctx)
+		assert.NoError(t, err, "No errors expected for Config")
+		assert.Empty(t, resp.AppID)
+		assert.Equal(t, []config.AppKey{{ID: "teamA", Weight: 1}, {ID: "teamB", Weight: 3}}, resp.AppIDs)
+		assert.Equal(t, 30*time.Second, resp.KeyCooldown)
+	})
+	t.Run("Should keep the Secret of app ids set by reference", func(t *testing.T) {
+		os.Clearenv()
+		path := filepath.Join(t.TempDir(), "app_ids")
+		assert.NoError(t, os.WriteFile(path, []byte("teamA,teamB=2\n"), 0o600))
+		os.Setenv("WEATHER_HOST", "fakeHost")
+		os.Setenv("WEATHER_IDS_FILE", path)
+		resp, err := config.NewAppConfig().NewApp(ctx)
+		assert.NoError(t, err, "No errors expected for Config")
+		assert.Equal(t, []config.AppKey{{ID: "teamA", Weight: 1}, {ID: "teamB", Weight: 2}}, resp.AppIDs)
+		if assert.NotNil(t, resp.AppIDsSecret) {
+			assert.Equal(t, "teamA,teamB=2", resp.AppIDsSecret.Value())
+		}
+	})
+	t.Run("Should fail to create NewApp with invalid app id weights", func(t *testing.T) {
+		for _, v := range []string{"teamA=0", "teamA=x", "=2", "teamA,,teamB"} {
+			os.Clearenv()
+			os.Setenv("WEATHER_HOST", "fakeHost")
+			os.Setenv("WEATHER_IDS", v)
+			_, err := config.NewAppConfig().NewApp(ctx)
+			assert.EqualError(t, err, apperrors.CreateInvalidConfigError("WEATHER_IDS").Error(), v)
+		}
+	})
+	t.Run("Should fail to create NewApp when secrets cannot be read", func(t *testing.T) {
+		os.Clearenv()
+		missing := filepath.Join(t.TempDir(), "missing")
+		os.Setenv("WEATHER_ID_FILE", missing)
+		os.Setenv("WEATHER_HOST", "fakeHost")
+		os.Setenv("ALERT_WEBHOOK_SECRET", "vault:secret/weathersvc#webhook")
+		resp, err := config.NewAppConfig().NewApp(ctx)
+		assert.Nil(t, resp)
+		assert.EqualError(t, err, strings.Join([]string{
+			apperrors.CreateSecretError("WEATHER_ID", fmt.Errorf("open %s: no such file or directory", missing)).Error(),
+			apperrors.CreateMissingConfigError("VAULT_ADDR").Error(),
+		}, "\n"))
+	})
 }
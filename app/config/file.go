@@ -0,0 +1,268 @@
+/*
+file.go: Config files. A YAML or TOML file sets the same options as the environment, grouped into
+sections, e.g. `cache.ttl` sets `CACHE_TTL`. Durations are written as in the environment, e.g. `30s`.
+*/
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	appErr "weathersvc/app/app_errors"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Redacted replaces secrets when the config is printed.
+const Redacted = "REDACTED"
+
+// File is the schema of a config file. The `env` tag of each option names the environment variable it sets.
+type File struct {
+	Port               *string `yaml:"port,omitempty" toml:"port" env:"PORT"`
+	Env                *string `yaml:"env,omitempty" toml:"env" env:"ENV"`
+	LogLevel           *string `yaml:"log_level,omitempty" toml:"log_level" env:"LOG_LEVEL"`
+	PollInterval       *string `yaml:"poll_interval,omitempty" toml:"poll_interval" env:"POLL_INTERVAL"`
+	AllowDegradedStart *bool   `yaml:"allow_degraded_start,omitempty" toml:"allow_degraded_start" env:"ALLOW_DEGRADED_START"`
+	Server             struct {
+		ReadHeaderTimeout *string `yaml:"read_header_timeout,omitempty" toml:"read_header_timeout" env:"SERVER_READ_HEADER_TIMEOUT"`
+		ReadTimeout       *string `yaml:"read_timeout,omitempty" toml:"read_timeout" env:"SERVER_READ_TIMEOUT"`
+		WriteTimeout      *string `yaml:"write_timeout,omitempty" toml:"write_timeout" env:"SERVER_WRITE_TIMEOUT"`
+		IdleTimeout       *string `yaml:"idle_timeout,omitempty" toml:"idle_timeout" env:"SERVER_IDLE_TIMEOUT"`
+		ShutdownTimeout   *string `yaml:"shutdown_timeout,omitempty" toml:"shutdown_timeout" env:"SERVER_SHUTDOWN_TIMEOUT"`
+	} `yaml:"server,omitempty" toml:"server"`
+	Provider struct {
+		Host  *string `yaml:"host,omitempty" toml:"host" env:"WEATHER_HOST"`
+		AppID *string `yaml:"app_id,omitempty" toml:"app_id" env:"WEATHER_ID"`
+		// AppIDFile names a file holding the app id, e.g. a mounted Docker or Kubernetes secret.
+		AppIDFile *string `yaml:"app_id_file,omitempty" toml:"app_id_file" env:"WEATHER_ID_FILE"`
+		// AppIDs spreads upstream calls over more app ids, each optionally weighted as `id=weight`.
+		AppIDs     []string `yaml:"app_ids,omitempty" toml:"app_ids" env:"WEATHER_IDS"`
+		AppIDsFile *string  `yaml:"app_ids_file,omitempty" toml:"app_ids_file" env:"WEATHER_IDS_FILE"`
+		// KeyCooldown is how long an app id that was rate limited is left out of rotation.
+		KeyCooldown *string `yaml:"key_cooldown,omitempty" toml:"key_cooldown" env:"WEATHER_ID_COOLDOWN"`
+		Budget      struct {
+			PerMinute *int     `yaml:"per_minute,omitempty" toml:"per_minute" env:"OWM_BUDGET_PER_MINUTE"`
+			PerDay    *int     `yaml:"per_day,omitempty" toml:"per_day" env:"OWM_BUDGET_PER_DAY"`
+			PerMonth  *int     `yaml:"per_month,omitempty" toml:"per_month" env:"OWM_BUDGET_PER_MONTH"`
+			Reserve   *float64 `yaml:"reserve,omitempty" toml:"reserve" env:"OWM_BUDGET_RESERVE"`
+		} `yaml:"budget,omitempty" toml:"budget"`
+	} `yaml:"provider,omitempty" toml:"provider"`
+	Cache struct {
+		TTL      *string `yaml:"ttl,omitempty" toml:"ttl" env:"CACHE_TTL"`
+		MaxStale *string `yaml:"max_stale,omitempty" toml:"max_stale" env:"CACHE_MAX_STALE"`
+		MaxAge   *string `yaml:"max_age,omitempty" toml:"max_age" env:"CACHE_MAX_AGE"`
+	} `yaml:"cache,omitempty" toml:"cache"`
+	Storage struct {
+		HistoryPath           *string `yaml:"history_path,omitempty" toml:"history_path" env:"HISTORY_PATH"`
+		HistoryRetention      *string `yaml:"history_retention,omitempty" toml:"history_retention" env:"HISTORY_RETENTION"`
+		HistoryMaxPerLocation *int    `yaml:"history_max_per_location,omitempty" toml:"history_max_per_location" env:"HISTORY_MAX_PER_LOCATION"`
+		LocationsPath         *string `yaml:"locations_path,omitempty" toml:"locations_path" env:"LOCATIONS_PATH"`
+	} `yaml:"storage,omitempty" toml:"storage"`
+	Websocket struct {
+		MaxSubscriptions *int `yaml:"max_subscriptions,omitempty" toml:"max_subscriptions" env:"WS_MAX_SUBSCRIPTIONS"`
+	} `yaml:"websocket,omitempty" toml:"websocket"`
+	Alerts struct {
+		Interval          *string  `yaml:"interval,omitempty" toml:"interval" env:"ALERT_INTERVAL"`
+		Hysteresis        *int     `yaml:"hysteresis,omitempty" toml:"hysteresis" env:"ALERT_HYSTERESIS"`
+		WebhookAttempts   *int     `yaml:"webhook_attempts,omitempty" toml:"webhook_attempts" env:"ALERT_WEBHOOK_ATTEMPTS"`
+		WebhookSecret     *string  `yaml:"webhook_secret,omitempty" toml:"webhook_secret" env:"ALERT_WEBHOOK_SECRET"`
+		WebhookSecretFile *string  `yaml:"webhook_secret_file,omitempty" toml:"webhook_secret_file" env:"ALERT_WEBHOOK_SECRET_FILE"`
+		WebhookHosts      []string `yaml:"webhook_hosts,omitempty" toml:"webhook_hosts" env:"ALERT_WEBHOOK_HOSTS"`
+	} `yaml:"alerts,omitempty" toml:"alerts"`
+	Auth struct {
+		APIKeysPath     *string `yaml:"api_keys_path,omitempty" toml:"api_keys_path" env:"API_KEYS_PATH"`
+		AdminAPIKey     *string `yaml:"admin_api_key,omitempty" toml:"admin_api_key" env:"ADMIN_API_KEY"`
+		AdminAPIKeyFile *string `yaml:"admin_api_key_file,omitempty" toml:"admin_api_key_file" env:"ADMIN_API_KEY_FILE"`
+		APIKeyPerMinute *int    `yaml:"api_key_per_minute,omitempty" toml:"api_key_per_minute" env:"API_KEY_PER_MINUTE"`
+		APIKeyPerDay    *int    `yaml:"api_key_per_day,omitempty" toml:"api_key_per_day" env:"API_KEY_PER_DAY"`
+		OIDC            struct {
+			Issuer      *string `yaml:"issuer,omitempty" toml:"issuer" env:"OIDC_ISSUER"`
+			Audience    *string `yaml:"audience,omitempty" toml:"audience" env:"OIDC_AUDIENCE"`
+			JWKSURL     *string `yaml:"jwks_url,omitempty" toml:"jwks_url" env:"OIDC_JWKS_URL"`
+			JWKSFile    *string `yaml:"jwks_file,omitempty" toml:"jwks_file" env:"OIDC_JWKS_FILE"`
+			JWKSRefresh *string `yaml:"jwks_refresh,omitempty" toml:"jwks_refresh" env:"OIDC_JWKS_REFRESH"`
+		} `yaml:"oidc,omitempty" toml:"oidc"`
+	} `yaml:"auth,omitempty" toml:"auth"`
+	RateLimit struct {
+		Default *string `yaml:"default,omitempty" toml:"default" env:"RATE_LIMIT"`
+		// Routes maps route path templates to their rate, e.g. `/alerts/{id}: 5/s`.
+		Routes         map[string]string `yaml:"routes,omitempty" toml:"routes" env:"RATE_LIMIT_ROUTES"`
+		IP             *string           `yaml:"ip,omitempty" toml:"ip" env:"RATE_LIMIT_IP"`
+		TrustedProxies []string          `yaml:"trusted_proxies,omitempty" toml:"trusted_proxies" env:"TRUSTED_PROXIES"`
+	} `yaml:"rate_limit,omitempty" toml:"rate_limit"`
+	Health struct {
+		Interval *string `yaml:"interval,omitempty" toml:"interval" env:"HEALTH_INTERVAL"`
+		Timeout  *string `yaml:"timeout,omitempty" toml:"timeout" env:"HEALTH_TIMEOUT"`
+	} `yaml:"health,omitempty" toml:"health"`
+	Tracing struct {
+		Exporter    *string  `yaml:"exporter,omitempty" toml:"exporter" env:"OTEL_TRACES_EXPORTER"`
+		ServiceName *string  `yaml:"service_name,omitempty" toml:"service_name" env:"OTEL_SERVICE_NAME"`
+		SampleRatio *float64 `yaml:"sample_ratio,omitempty" toml:"sample_ratio" env:"TRACE_SAMPLE_RATIO"`
+	} `yaml:"tracing,omitempty" toml:"tracing"`
+	Secrets struct {
+		RefreshInterval *string `yaml:"refresh_interval,omitempty" toml:"refresh_interval" env:"SECRET_REFRESH_INTERVAL"`
+		Vault           struct {
+			Address   *string `yaml:"address,omitempty" toml:"address" env:"VAULT_ADDR"`
+			Token     *string `yaml:"token,omitempty" toml:"token" env:"VAULT_TOKEN"`
+			TokenFile *string `yaml:"token_file,omitempty" toml:"token_file" env:"VAULT_TOKEN_FILE"`
+			Namespace *string `yaml:"namespace,omitempty" toml:"namespace" env:"VAULT_NAMESPACE"`
+		} `yaml:"vault,omitempty" toml:"vault"`
+	} `yaml:"secrets,omitempty" toml:"secrets"`
+}
+
+// LoadFile reads a YAML (`.yaml`, `.yml`) or TOML (`.toml`) config file, rejecting unknown options.
+func LoadFile(path string) (*File, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, appErr.CreateInvalidConfigFileError(path, err)
+	}
+	f := &File{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(b))
+		dec.KnownFields(true)
+		if err := dec.Decode(f); err != nil && !errors.Is(err, io.EOF) {
+			return nil, appErr.CreateInvalidConfigFileError(path, err)
+		}
+	case ".toml":
+		md, err := toml.Decode(string(b), f)
+		if err != nil {
+			return nil, appErr.CreateInvalidConfigFileError(path, err)
+		}
+		if unknown := md.Undecoded(); len(unknown) > 0 {
+			return nil, appErr.CreateInvalidConfigFileError(path, fmt.Errorf("unknown option %q", unknown[0].String()))
+		}
+	default:
+		return nil, appErr.CreateInvalidConfigFileError(path, errors.New("expected a .yaml, .yml or .toml file"))
+	}
+	return f, nil
+}
+
+// values flattens the options set in f by environment variable name, in the environment's format.
+func (f *File) values() map[string]string {
+	vals := map[string]string{}
+	fields(reflect.ValueOf(f).Elem(), "", func(_, key string, v reflect.Value) {
+		switch {
+		case v.Kind() == reflect.Pointer && !v.IsNil():
+			vals[key] = fmt.Sprint(v.Elem().Interface())
+		case v.Kind() == reflect.Slice && v.Len() > 0:
+			vals[key] = strings.Join(v.Interface().([]string), ",")
+		case v.Kind() == reflect.Map && v.Len() > 0:
+			var entries []string
+			for k, rate := range v.Interface().(map[string]string) {
+				entries = append(entries, k+"="+rate)
+			}
+			sort.Strings(entries)
+			vals[key] = strings.Join(entries, ",")
+		}
+	})
+	return vals
+}
+
+// fields calls fn with the dotted file name and environment variable of every option in the schema v.
+func fields(v reflect.Value, prefix string, fn func(name, key string, field reflect.Value)) {
+	for i := 0; i < v.NumField(); i++ {
+		sf := v.Type().Field(i)
+		name := prefix + strings.Split(sf.Tag.Get("yaml"), ",")[0]
+		if sf.Type.Kind() == reflect.Struct {
+			fields(v.Field(i), name+".", fn)
+			continue
+		}
+		fn(name, sf.Tag.Get("env"), v.Field(i))
+	}
+}
+
+// Print writes the effective config as a YAML config file, with secrets redacted.
+func (a *App) Print(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(a.file(true)); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// file returns a as a config file, with secrets replaced by Redacted when redact is set.
+func (a *App) file(redact bool) *File {
+	secret := func(v string) string {
+		if redact && v != "" {
+			return Redacted
+		}
+		return v
+	}
+	f := &File{
+		Port:               ptr(a.Port),
+		Env:                ptr(a.Env),
+		LogLevel:           ptr(a.LogLevel.String()),
+		PollInterval:       ptr(a.PollInterval.String()),
+		AllowDegradedStart: ptr(a.AllowDegradedStart),
+	}
+	f.Server.ReadHeaderTimeout = ptr(a.ReadHeaderTimeout.String())
+	f.Server.ReadTimeout = ptr(a.ReadTimeout.String())
+	f.Server.WriteTimeout = ptr(a.WriteTimeout.String())
+	f.Server.IdleTimeout = ptr(a.IdleTimeout.String())
+	f.Server.ShutdownTimeout = ptr(a.ShutdownTimeout.String())
+	f.Provider.Host = ptr(a.Host)
+	f.Provider.AppID = ptr(secret(a.AppID))
+	for _, k := range a.AppIDs {
+		// the weight is kept so a redacted config still shows how calls are spread
+		f.Provider.AppIDs = append(f.Provider.AppIDs, AppKey{ID: secret(k.ID), Weight: k.Weight}.String())
+	}
+	f.Provider.KeyCooldown = ptr(a.KeyCooldown.String())
+	f.Provider.Budget.PerMinute = ptr(a.BudgetPerMinute)
+	f.Provider.Budget.PerDay = ptr(a.BudgetPerDay)
+	f.Provider.Budget.PerMonth = ptr(a.BudgetPerMonth)
+	f.Provider.Budget.Reserve = ptr(a.BudgetReserve)
+	f.Cache.TTL = ptr(a.CacheTTL.String())
+	f.Cache.MaxStale = ptr(a.CacheMaxStale.String())
+	f.Cache.MaxAge = ptr(a.CacheMaxAge.String())
+	f.Storage.HistoryPath = ptr(a.HistoryPath)
+	f.Storage.HistoryRetention = ptr(a.HistoryRetention.String())
+	f.Storage.HistoryMaxPerLocation = ptr(a.HistoryMaxPerLocation)
+	f.Storage.LocationsPath = ptr(a.LocationsPath)
+	f.Websocket.MaxSubscriptions = ptr(a.MaxSubscriptions)
+	f.Alerts.Interval = ptr(a.AlertInterval.String())
+	f.Alerts.Hysteresis = ptr(a.AlertHysteresis)
+	f.Alerts.WebhookAttempts = ptr(a.AlertWebhookAttempts)
+	f.Alerts.WebhookSecret = ptr(secret(a.AlertWebhookSecret))
+	f.Alerts.WebhookHosts = a.AlertWebhookHosts
+	f.Auth.APIKeysPath = ptr(a.APIKeysPath)
+	f.Auth.AdminAPIKey = ptr(secret(a.AdminAPIKey))
+	f.Auth.APIKeyPerMinute = ptr(a.APIKeyPerMinute)
+	f.Auth.APIKeyPerDay = ptr(a.APIKeyPerDay)
+	f.Auth.OIDC.Issuer = ptr(a.OIDCIssuer)
+	f.Auth.OIDC.Audience = ptr(a.OIDCAudience)
+	f.Auth.OIDC.JWKSURL = ptr(a.JWKSURL)
+	f.Auth.OIDC.JWKSFile = ptr(a.JWKSFile)
+	f.Auth.OIDC.JWKSRefresh = ptr(a.JWKSRefresh.String())
+	f.RateLimit.Default = ptr(a.RateLimit.String())
+	f.RateLimit.IP = ptr(a.IPRateLimit.String())
+	f.RateLimit.Routes = map[string]string{}
+	for route, rate := range a.RouteRateLimits {
+		f.RateLimit.Routes[route] = rate.String()
+	}
+	for _, proxy := range a.TrustedProxies {
+		f.RateLimit.TrustedProxies = append(f.RateLimit.TrustedProxies, proxy.String())
+	}
+	f.Health.Interval = ptr(a.HealthInterval.String())
+	f.Health.Timeout = ptr(a.HealthTimeout.String())
+	f.Tracing.Exporter = ptr(a.TraceExporter)
+	f.Tracing.ServiceName = ptr(a.ServiceName)
+	f.Tracing.SampleRatio = ptr(a.TraceSampleRatio)
+	f.Secrets.RefreshInterval = ptr(a.SecretRefresh.String())
+	f.Secrets.Vault.Address = ptr(a.VaultAddr)
+	f.Secrets.Vault.Token = ptr(secret(a.VaultToken))
+	f.Secrets.Vault.Namespace = ptr(a.VaultNamespace)
+	return f
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
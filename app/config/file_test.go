@@ -0,0 +1,90 @@
+package config_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"weathersvc/app/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadFile(t *testing.T) {
+	t.Run("Should read YAML and TOML files", func(t *testing.T) {
+		for _, path := range []string{
+			writeFile(t, "weathersvc.yml", "provider:\n  budget:\n    per_day: 0\nrate_limit:\n  trusted_proxies: [10.0.0.1]\n"),
+			writeFile(t, "weathersvc.toml", "[provider.budget]\nper_day = 0\n[rate_limit]\ntrusted_proxies = [\"10.0.0.1\"]\n"),
+		} {
+			f, err := config.LoadFile(path)
+			require.NoError(t, err)
+			if assert.NotNil(t, f.Provider.Budget.PerDay) {
+				assert.Equal(t, 0, *f.Provider.Budget.PerDay)
+			}
+			assert.Nil(t, f.Provider.Budget.PerMonth)
+			assert.Equal(t, []string{"10.0.0.1"}, f.RateLimit.TrustedProxies)
+		}
+	})
+	t.Run("Should accept an empty file", func(t *testing.T) {
+		_, err := config.LoadFile(writeFile(t, "weathersvc.yaml", ""))
+		assert.NoError(t, err)
+	})
+	t.Run("Should reject unknown options", func(t *testing.T) {
+		path := writeFile(t, "weathersvc.yaml", "cache:\n  tll: 30s\n")
+		_, err := config.LoadFile(path)
+		assert.ErrorContains(t, err, "invalid config file `"+path+"`")
+		assert.ErrorContains(t, err, "field tll not found")
+		path = writeFile(t, "weathersvc.toml", "[cache]\ntll = \"30s\"\n")
+		_, err = config.LoadFile(path)
+		assert.ErrorContains(t, err, `unknown option "cache.tll"`)
+	})
+	t.Run("Should reject unsupported and missing files", func(t *testing.T) {
+		_, err := config.LoadFile(writeFile(t, "weathersvc.json", "{}"))
+		assert.ErrorContains(t, err, "expected a .yaml, .yml or .toml file")
+		_, err = config.LoadFile(filepath.Join(t.TempDir(), "missing.yaml"))
+		assert.ErrorContains(t, err, "no such file or directory")
+	})
+}
+
+func TestApp_Print(t *testing.T) {
+	os.Clearenv()
+	ctx := context.Background()
+	t.Run("Should print a config file that loads the same config with secrets redacted", func(t *testing.T) {
+		os.Setenv("WEATHER_ID", "fakeID")
+		os.Setenv("WEATHER_HOST", "fakeHost")
+		os.Setenv("ADMIN_API_KEY", "admin-secret")
+		os.Setenv("RATE_LIMIT_ROUTES", "/weather/get=5/s")
+		os.Setenv("TRUSTED_PROXIES", "10.0.0.0/8")
+		os.Setenv("SERVER_READ_TIMEOUT", "10s")
+		os.Setenv("LOG_LEVEL", "debug")
+		want, err := config.NewAppConfig().NewApp(ctx)
+		require.NoError(t, err)
+		var buf bytes.Buffer
+		require.NoError(t, want.Print(&buf))
+		assert.NotContains(t, buf.String(), "fakeID")
+		assert.NotContains(t, buf.String(), "admin-secret")
+		assert.Contains(t, buf.String(), "app_id: "+config.Redacted)
+
+		os.Clearenv()
+		path := writeFile(t, "weathersvc.yaml", buf.String())
+		os.Setenv("CONFIG_FILE", path)
+		got, err := config.NewAppConfig().NewApp(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, config.Redacted, got.AppID)
+		assert.Equal(t, config.Redacted, got.AdminAPIKey)
+		assert.Equal(t, path, got.ConfigFile)
+		got.AppID, got.AdminAPIKey, got.ConfigFile = want.AppID, want.AdminAPIKey, want.ConfigFile
+		assert.Equal(t, want, got)
+		assert.Equal(t, 10*time.Second, got.ReadTimeout)
+	})
+}
@@ -0,0 +1,47 @@
+/*
+reload.go: Config reloads. Only options read while serving can change without a restart; the rest,
+like the listener port or the store paths, are fixed when the service starts.
+*/
+package config
+
+import (
+	"reflect"
+	"sort"
+)
+
+// Reloadable reports whether the option set by the environment variable key takes effect on reload.
+func Reloadable(key string) bool {
+	switch key {
+	case "LOG_LEVEL",
+		"CACHE_TTL", "CACHE_MAX_STALE",
+		"RATE_LIMIT", "RATE_LIMIT_ROUTES", "RATE_LIMIT_IP", "TRUSTED_PROXIES",
+		"OWM_BUDGET_PER_MINUTE", "OWM_BUDGET_PER_DAY", "OWM_BUDGET_PER_MONTH", "OWM_BUDGET_RESERVE":
+		return true
+	default:
+		return false
+	}
+}
+
+// RestartRequired lists, by environment variable, the options that differ between the running config
+// and next but only take effect on restart.
+func RestartRequired(running, next *App) []string {
+	was := running.file(false).values()
+	now := next.file(false).values()
+	// app ids set by reference are rotated by their Secrets rather than reloaded
+	if running.AppIDSecret != nil && next.AppIDSecret != nil {
+		delete(was, "WEATHER_ID")
+		delete(now, "WEATHER_ID")
+	}
+	if running.AppIDsSecret != nil && next.AppIDsSecret != nil {
+		delete(was, "WEATHER_IDS")
+		delete(now, "WEATHER_IDS")
+	}
+	var changed []string
+	fields(reflect.ValueOf(&File{}).Elem(), "", func(_, key string, _ reflect.Value) {
+		if was[key] != now[key] && !Reloadable(key) {
+			changed = append(changed, key)
+		}
+	})
+	sort.Strings(changed)
+	return changed
+}
@@ -0,0 +1,66 @@
+package config_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"weathersvc/app/config"
+	"weathersvc/app/secrets"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestartRequired(t *testing.T) {
+	running := &config.App{
+		Port:         "8080",
+		CacheTTL:     time.Minute,
+		HistoryPath:  "history.db",
+		ServerConfig: config.ServerConfig{ShutdownTimeout: time.Minute},
+		WeatherClientConfig: config.WeatherClientConfig{
+			Host:  "fakeHost",
+			AppID: "fakeID",
+		},
+		RateLimitConfig: config.RateLimitConfig{RateLimit: config.Rate{Count: 10, Per: time.Minute}},
+	}
+	t.Run("Should allow changes to reloadable options", func(t *testing.T) {
+		next := *running
+		next.CacheTTL = 10 * time.Second
+		next.RateLimitConfig = config.RateLimitConfig{RouteRateLimits: map[string]config.Rate{"/weather/get": {Count: 5, Per: time.Second}}}
+		next.BudgetPerDay = 500
+		assert.Empty(t, config.RestartRequired(running, &next))
+	})
+	t.Run("Should list the changed options that need a restart", func(t *testing.T) {
+		next := *running
+		next.Port = "9090"
+		next.HistoryPath = ""
+		next.ShutdownTimeout = time.Second
+		next.AppID = "otherID"
+		next.CacheTTL = time.Second
+		assert.Equal(t, []string{"HISTORY_PATH", "PORT", "SERVER_SHUTDOWN_TIMEOUT", "WEATHER_ID"}, config.RestartRequired(running, &next))
+	})
+	t.Run("Should ignore an app id rotated by reference", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "app_id")
+		require.NoError(t, os.WriteFile(path, []byte("fakeID"), 0o600))
+		secret, err := secrets.New(context.Background(), "WEATHER_ID", secrets.NewFileProvider(), path)
+		require.NoError(t, err)
+		was, next := *running, *running
+		was.AppIDSecret, next.AppIDSecret = secret, secret
+		next.AppID = "rotatedID"
+		assert.Empty(t, config.RestartRequired(&was, &next))
+	})
+	t.Run("Should ignore app ids rotated by reference", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "app_ids")
+		require.NoError(t, os.WriteFile(path, []byte("teamA"), 0o600))
+		secret, err := secrets.New(context.Background(), "WEATHER_IDS", secrets.NewFileProvider(), path)
+		require.NoError(t, err)
+		was, next := *running, *running
+		was.AppIDsSecret, next.AppIDsSecret = secret, secret
+		was.AppIDs, next.AppIDs = []config.AppKey{{ID: "teamA", Weight: 1}}, []config.AppKey{{ID: "teamB", Weight: 2}}
+		assert.Empty(t, config.RestartRequired(&was, &next))
+		next.AppIDsSecret = nil
+		assert.Equal(t, []string{"WEATHER_IDS"}, config.RestartRequired(&was, &next))
+	})
+}
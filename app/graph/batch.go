@@ -0,0 +1,92 @@
+package graph
+
+import (
+	"context"
+	"sync"
+	"weathersvc/app/service"
+)
+
+// maxConcurrentLookups bounds the upstream lookups one query makes at once, as maxConcurrentSites
+// does for tag queries on /weather/get.
+const maxConcurrentLookups = 8
+
+// batch gathers the upstream lookups of one query. Resolvers asking for the same kind of lookup at the
+// same coordinates share one call, however many places or fields ask for it, and the calls run
+// concurrently up to maxConcurrentLookups.
+type batch struct {
+	svc   service.Service
+	sem   chan struct{}
+	mu    sync.Mutex
+	calls map[lookup]*call
+}
+
+type lookup struct {
+	kind     string
+	lat, lon float64
+}
+
+type call struct {
+	done chan struct{}
+	val  any
+	err  error
+}
+
+type batchKey struct{}
+
+func newBatch(svc service.Service) *batch {
+	return &batch{svc: svc, sem: make(chan struct{}, maxConcurrentLookups), calls: map[lookup]*call{}}
+}
+
+func withBatch(ctx context.Context, b *batch) context.Context {
+	return context.WithValue(ctx, batchKey{}, b)
+}
+
+func batchFrom(ctx context.Context) *batch {
+	return ctx.Value(batchKey{}).(*batch)
+}
+
+// load returns the result of fn for key, calling it only for the first resolver that asks.
+func load[T any](ctx context.Context, b *batch, key lookup, fn func(context.Context) (T, error)) (T, error) {
+	b.mu.Lock()
+	c, ok := b.calls[key]
+	if !ok {
+		c = &call{done: make(chan struct{})}
+		b.calls[key] = c
+	}
+	b.mu.Unlock()
+	if !ok {
+		b.sem <- struct{}{}
+		c.val, c.err = fn(ctx)
+		<-b.sem
+		close(c.done)
+	}
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+	if c.err != nil {
+		var zero T
+		return zero, c.err
+	}
+	return c.val.(T), nil
+}
+
+func (b *batch) current(ctx context.Context, lat, lon float64) (service.WeatherCond, error) {
+	return load(ctx, b, lookup{"current", lat, lon}, func(ctx context.Context) (service.WeatherCond, error) {
+		return b.svc.GetWeather(ctx, lat, lon)
+	})
+}
+
+func (b *batch) forecast(ctx context.Context, lat, lon float64) (service.Forecast, error) {
+	return load(ctx, b, lookup{"forecast", lat, lon}, func(ctx context.Context) (service.Forecast, error) {
+		return b.svc.GetForecast(ctx, lat, lon)
+	})
+}
+
+func (b *batch) airQuality(ctx context.Context, lat, lon float64) (service.AirQuality, error) {
+	return load(ctx, b, lookup{"air", lat, lon}, func(ctx context.Context) (service.AirQuality, error) {
+		return b.svc.GetAirQuality(ctx, lat, lon)
+	})
+}
@@ -0,0 +1,98 @@
+// Package graph serves the weather as GraphQL, so clients can ask about several places and choose the
+// fields in one round trip. Resolvers batch their upstream lookups per query, and queries are checked
+// against depth and complexity limits before they run.
+package graph
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	apperrors "weathersvc/app/app_errors"
+	"weathersvc/app/locations"
+	"weathersvc/app/service"
+
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+//go:embed schema.graphql
+var schemaSource string //nolint:gochecknoglobals // embedded schema
+
+// Options bound the queries a handler runs.
+type Options struct {
+	// MaxDepth and MaxComplexity default to DefaultMaxDepth and DefaultMaxComplexity when zero.
+	MaxDepth      int
+	MaxComplexity int
+	// Introspection lets clients such as GraphiQL read the schema.
+	Introspection bool
+}
+
+// QueryRequest is a GraphQL request, sent as a JSON body or, for GET, as query parameters.
+type QueryRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+type handler struct {
+	schema *graphql.Schema
+	svc    service.Service
+	limits limits
+}
+
+// NewHandler serves GraphQL queries over the service and the saved locations in store.
+func NewHandler(s service.Service, store locations.Store, opts Options) (http.Handler, error) {
+	schemaOpts := []graphql.SchemaOpt{graphql.UseStringDescriptions(), graphql.UseFieldResolvers()}
+	if !opts.Introspection {
+		schemaOpts = append(schemaOpts, graphql.DisableIntrospection())
+	}
+	schema, err := graphql.ParseSchema(schemaSource, &resolver{store: store}, schemaOpts...)
+	if err != nil {
+		return nil, err
+	}
+	l := limits{maxDepth: opts.MaxDepth, maxComplexity: opts.MaxComplexity}
+	if l.maxDepth <= 0 {
+		l.maxDepth = DefaultMaxDepth
+	}
+	if l.maxComplexity <= 0 {
+		l.maxComplexity = DefaultMaxComplexity
+	}
+	return &handler{schema: schema, svc: s, limits: l}, nil
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req, err := decodeRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// errors in queries are reported in the body, as GraphQL clients expect
+	var resp any
+	if err := h.limits.check(req.Query, req.OperationName, req.Variables); err != nil {
+		resp = map[string]any{"errors": []map[string]string{{"message": err.Error()}}}
+	} else {
+		ctx := withBatch(r.Context(), newBatch(h.svc))
+		resp = h.schema.Exec(ctx, req.Query, req.OperationName, req.Variables)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp) //nolint:errcheck // the client has gone when this fails
+}
+
+func decodeRequest(r *http.Request) (QueryRequest, error) {
+	var req QueryRequest
+	if r.Method == http.MethodGet {
+		q := r.URL.Query()
+		req.Query, req.OperationName = q.Get("query"), q.Get("operationName")
+		if v := q.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				return req, apperrors.CreateInvalidRequestError("variables must be a JSON object")
+			}
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return req, apperrors.ErrNoBody
+	}
+	if req.Query == "" {
+		return req, apperrors.CreateInvalidRequestError("query missing")
+	}
+	return req, nil
+}
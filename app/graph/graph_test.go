@@ -0,0 +1,236 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+	apperrors "weathersvc/app/app_errors"
+	"weathersvc/app/locations"
+	"weathersvc/app/service"
+	mock_service "weathersvc/mocks/service"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type gqlResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+		Path    []any  `json:"path"`
+	} `json:"errors"`
+}
+
+func TestHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockService := mock_service.NewMockService(ctrl)
+	store := locations.NewMemoryStore()
+	for _, loc := range []locations.Location{
+		{ID: "dallas", Name: "Dallas, TX", Latitude: 32.78, Longitude: -96.8, Tags: []string{"office"}},
+		{ID: "berlin", Name: "Berlin", Latitude: 52.52, Longitude: 13.4, Tags: []string{"office"}},
+	} {
+		_, err := store.Create(context.Background(), loc)
+		require.NoError(t, err)
+	}
+	h, err := NewHandler(mockService, store, Options{})
+	require.NoError(t, err)
+	post := func(query string, variables map[string]any) gqlResponse {
+		body, err := json.Marshal(QueryRequest{Query: query, Variables: variables})
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest("POST", "/graphql", bytes.NewReader(body)))
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var resp gqlResponse
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+		return resp
+	}
+	observed := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	dallas := service.WeatherCond{
+		Temp: "hot", Condition: "clear sky", Wind: "light air", FeelsLike: 95, WindSpeed: 2, Place: "Dallas", ObservedAt: observed,
+	}
+
+	t.Run("Should look each place up once however many fields ask", func(t *testing.T) {
+		mockService.EXPECT().GetWeather(gomock.Any(), 32.78, -96.8).Return(dallas, nil).Times(1)
+		mockService.EXPECT().GetAirQuality(gomock.Any(), 32.78, -96.8).Return(service.AirQuality{
+			Index: 2, Class: "fair", Components: map[string]float64{"pm2_5": 3.5, "co": 200}, ObservedAt: observed,
+		}, nil).Times(1)
+		resp := post(`query($places: [PlaceInput!]) {
+			weather(places: $places) {
+				place { name latitude }
+				current { summary temperature { feelsLike unit class } wind { speed } observedAt }
+				airQuality { index class pollutants { name concentration } }
+			}
+			again: weather(places: $places) { current { condition } }
+		}`, map[string]any{"places": []any{
+			map[string]any{"latitude": 32.78, "longitude": -96.8},
+			map[string]any{"latitude": 32.78, "longitude": -96.8},
+		}})
+		require.Empty(t, resp.Errors)
+		assert.JSONEq(t, `{
+			"weather": [{
+				"place": {"name": "Dallas", "latitude": 32.78},
+				"current": {
+					"summary": "Outside it is hot with light air and clear sky.",
+					"temperature": {"feelsLike": 95, "unit": "F", "class": "hot"},
+					"wind": {"speed": 2},
+					"observedAt": "2026-10-19T12:00:00Z"
+				},
+				"airQuality": {"index": 2, "class": "fair", "pollutants": [{"name": "co", "concentration": 200}, {"name": "pm2_5", "concentration": 3.5}]}
+			}, {
+				"place": {"name": "Dallas", "latitude": 32.78},
+				"current": {
+					"summary": "Outside it is hot with light air and clear sky.",
+					"temperature": {"feelsLike": 95, "unit": "F", "class": "hot"},
+					"wind": {"speed": 2},
+					"observedAt": "2026-10-19T12:00:00Z"
+				},
+				"airQuality": {"index": 2, "class": "fair", "pollutants": [{"name": "co", "concentration": 200}, {"name": "pm2_5", "concentration": 3.5}]}
+			}],
+			"again": [{"current": {"condition": "clear sky"}}, {"current": {"condition": "clear sky"}}]
+		}`, string(resp.Data))
+	})
+	t.Run("Should name saved locations and give the forecast for the hours asked", func(t *testing.T) {
+		now := time.Now().UTC().Truncate(time.Second)
+		var steps []service.ForecastStep
+		for i := 1; i <= 4; i++ {
+			steps = append(steps, service.ForecastStep{
+				Time: now.Add(time.Duration(i) * forecastInterval), Temp: "warm", Condition: "light rain", Wind: "calm",
+				FeelsLike: 70, WindSpeed: 0.5, PrecipitationChance: 0.4,
+			})
+		}
+		mockService.EXPECT().GetForecast(gomock.Any(), 52.52, 13.4).Return(service.Forecast{Place: "Berlin", Steps: steps}, nil).Times(1)
+		resp := post(`{ weather(places: [{locationId: "berlin"}]) { place { id name } forecast(hours: 6) { time condition precipitationChance temperature { class } } } }`, nil)
+		require.Empty(t, resp.Errors)
+		var got struct {
+			Weather []struct {
+				Place    map[string]any
+				Forecast []map[string]any
+			}
+		}
+		require.NoError(t, json.Unmarshal(resp.Data, &got))
+		require.Len(t, got.Weather, 1)
+		assert.Equal(t, map[string]any{"id": "berlin", "name": "Berlin"}, got.Weather[0].Place)
+		require.Len(t, got.Weather[0].Forecast, 2)
+		assert.Equal(t, now.Add(forecastInterval).Format(time.RFC3339), got.Weather[0].Forecast[0]["time"])
+		assert.Equal(t, 0.4, got.Weather[0].Forecast[0]["precipitationChance"])
+		assert.Equal(t, map[string]any{"class": "warm"}, got.Weather[0].Forecast[0]["temperature"])
+	})
+	t.Run("Should give the places with a tag and null the fields whose lookups fail", func(t *testing.T) {
+		mockService.EXPECT().GetWeather(gomock.Any(), 32.78, -96.8).Return(dallas, nil)
+		mockService.EXPECT().GetWeather(gomock.Any(), 52.52, 13.4).Return(service.WeatherCond{}, apperrors.ErrTooManyRequests)
+		resp := post(`{ weather(tag: "office") { place { id } current { condition } } }`, nil)
+		require.Len(t, resp.Errors, 1)
+		assert.Equal(t, apperrors.ErrTooManyRequests.Error(), resp.Errors[0].Message)
+		assert.JSONEq(t, `{"weather": [
+			{"place": {"id": "berlin"}, "current": null},
+			{"place": {"id": "dallas"}, "current": {"condition": "clear sky"}}
+		]}`, string(resp.Data))
+	})
+	t.Run("Should reject places out of range or unknown", func(t *testing.T) {
+		for query, message := range map[string]string{
+			`{ weather(places: [{latitude: 91.5, longitude: 0.5}]) { place { latitude } } }`: "latitude or longitude is out of range",
+			`{ weather(places: [{latitude: 1.5}]) { place { latitude } } }`:                  "give latitude and longitude or locationId",
+			`{ weather(places: [{locationId: "nope"}]) { place { latitude } } }`:             apperrors.ErrLocationNotFound.Error(),
+		} {
+			resp := post(query, nil)
+			require.Len(t, resp.Errors, 1, query)
+			assert.Contains(t, resp.Errors[0].Message, message)
+		}
+	})
+	t.Run("Should refuse queries over the limits before looking anything up", func(t *testing.T) {
+		places := make([]any, maxPlaces)
+		for i := range places {
+			places[i] = map[string]any{"latitude": float64(i), "longitude": 1.0}
+		}
+		resp := post(`query($places: [PlaceInput!]) {
+			weather(places: $places) { current { condition } forecast(hours: 120) { time } airQuality { pollutants { name } } }
+		}`, map[string]any{"places": places})
+		require.Len(t, resp.Errors, 1)
+		assert.Contains(t, resp.Errors[0].Message, "query complexity")
+		assert.Empty(t, resp.Data)
+	})
+	t.Run("Should accept queries as GET parameters and hide the schema unless introspection is on", func(t *testing.T) {
+		get := func(h http.Handler, query string) string {
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, httptest.NewRequest("GET", "/graphql?query="+url.QueryEscape(query), nil))
+			require.Equal(t, http.StatusOK, rr.Code)
+			return rr.Body.String()
+		}
+		assert.JSONEq(t, `{"data": {}}`, get(h, `{ __schema { queryType { name } } }`))
+		open, err := NewHandler(mockService, store, Options{Introspection: true})
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"data": {"__schema": {"queryType": {"name": "Query"}}}}`, get(open, `{ __schema { queryType { name } } }`))
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest("GET", "/graphql", nil))
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+func TestLimits(t *testing.T) {
+	l := limits{maxDepth: DefaultMaxDepth, maxComplexity: DefaultMaxComplexity}
+	for _, tc := range []struct {
+		name, query string
+		variables   map[string]any
+		err         string
+	}{
+		{name: "Should allow the deepest fields", query: `{ weather(places: [{latitude: 1, longitude: 1}]) { forecast { wind { speed } } } }`},
+		{name: "Should not count introspection", query: `{ __schema { types { fields { type { ofType { ofType { ofType { name } } } } } } } }`},
+		{name: "Should leave syntax errors to the executor", query: `{ weather(`},
+		{
+			name:  "Should count fragments where they are spread",
+			query: `{ ...w } fragment w on Query { weather(places: [{latitude: 1, longitude: 1}]) { ...p } } fragment p on PlaceWeather { place { ... on Place { id { x { y { z { w } } } } } } }`,
+			err:   "query depth 7 exceeds the limit of 6",
+		},
+		{
+			name:  "Should count the places given",
+			query: `query($p: [PlaceInput!]) { weather(places: $p) { forecast(hours: 120) { time condition temperature { class } } } }`,
+			// 1 + 30 * (10 + 40 * 4) = 5101
+			variables: map[string]any{"p": make([]any, 30)},
+			err:       "query complexity 5101 exceeds the limit of 1000",
+		},
+		{
+			name:  "Should count the hours given by a variable",
+			query: `query($p: [PlaceInput!], $h: Int) { weather(places: $p) { forecast(hours: $h) { time condition temperature { class } } } }`,
+			// 1 + 6 * (10 + 40 * 4) = 1021, where the default 24 hours would cost 253
+			variables: map[string]any{"p": make([]any, 6), "h": float64(120)},
+			err:       "query complexity 1021 exceeds the limit of 1000",
+		},
+		{
+			name:      "Should count the hours given by a variable decoded as a number",
+			query:     `query($p: [PlaceInput!], $h: Int) { weather(places: $p) { forecast(hours: $h) { time condition temperature { class } } } }`,
+			variables: map[string]any{"p": make([]any, 6), "h": json.Number("120")},
+			err:       "query complexity 1021 exceeds the limit of 1000",
+		},
+		{
+			name:  "Should count as many places as a tag may have",
+			query: `{ weather(tag: "office") { current { condition } airQuality { index } } }`,
+			// 1 + 25 * (11 + 11) = 551
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := l.check(tc.query, "", tc.variables)
+			if tc.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.err)
+			}
+		})
+	}
+}
+
+func TestGraphiQLHandler(t *testing.T) {
+	t.Run("Should serve a page querying the endpoint", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		GraphiQLHandler("/graphql").ServeHTTP(rr, httptest.NewRequest("GET", "/graphiql", nil))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "text/html; charset=utf-8", rr.Header().Get("Content-Type"))
+		assert.Contains(t, rr.Body.String(), `url: "/graphql"`)
+	})
+}
@@ -0,0 +1,38 @@
+package graph
+
+import (
+	"html/template"
+	"net/http"
+)
+
+// graphiQLPage loads GraphiQL from a CDN, so the page needs no assets of its own.
+//
+//nolint:gochecknoglobals // parsed once
+var graphiQLPage = template.Must(template.New("graphiql").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>WeatherService GraphiQL</title>
+  <link rel="stylesheet" href="https://unpkg.com/graphiql@3/graphiql.min.css">
+  <style>body { margin: 0; height: 100vh; } #graphiql { height: 100vh; }</style>
+</head>
+<body>
+  <div id="graphiql">Loading…</div>
+  <script crossorigin src="https://unpkg.com/react@18/umd/react.production.min.js"></script>
+  <script crossorigin src="https://unpkg.com/react-dom@18/umd/react-dom.production.min.js"></script>
+  <script crossorigin src="https://unpkg.com/graphiql@3/graphiql.min.js"></script>
+  <script>
+    const fetcher = GraphiQL.createFetcher({ url: {{.}} });
+    ReactDOM.createRoot(document.getElementById("graphiql")).render(React.createElement(GraphiQL, { fetcher }));
+  </script>
+</body>
+</html>
+`))
+
+// GraphiQLHandler serves a GraphiQL page querying endpoint, e.g. `/graphql`.
+func GraphiQLHandler(endpoint string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		graphiQLPage.Execute(w, endpoint) //nolint:errcheck // the client has gone when this fails
+	})
+}
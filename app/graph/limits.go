@@ -0,0 +1,143 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/parser"
+)
+
+const (
+	// DefaultMaxDepth allows the deepest field of the schema, e.g. `weather { forecast { wind { speed } } }`, with room to spare.
+	DefaultMaxDepth = 6
+	// DefaultMaxComplexity allows every field for about ten places.
+	DefaultMaxComplexity = 1000
+	// lookupCost is what a field making an upstream lookup for its place costs; other fields cost 1.
+	lookupCost = 10
+	// pollutantCount is how many pollutants the upstream reports.
+	pollutantCount = 8
+)
+
+// limits rejects queries nested deeper than maxDepth, or estimated to cost more than maxComplexity,
+// before they run. Fields inside lists are counted once for each item the list is expected to hold,
+// e.g. once per place. Introspection is not counted, so GraphiQL can load the schema.
+type limits struct {
+	maxDepth      int
+	maxComplexity int
+}
+
+func (l limits) check(query, operationName string, variables map[string]any) error {
+	doc, err := parser.ParseQuery(&ast.Source{Input: query})
+	if err != nil {
+		// the executor reports syntax errors in its own words
+		return nil
+	}
+	op := doc.Operations.ForName(operationName)
+	if op == nil {
+		return nil
+	}
+	a := &analysis{doc: doc, variables: variables}
+	complexity := a.cost(op.SelectionSet, 1, map[string]bool{})
+	if a.depth > l.maxDepth {
+		return fmt.Errorf("query depth %d exceeds the limit of %d", a.depth, l.maxDepth)
+	}
+	if complexity > l.maxComplexity {
+		return fmt.Errorf("query complexity %d exceeds the limit of %d", complexity, l.maxComplexity)
+	}
+	return nil
+}
+
+type analysis struct {
+	doc       *ast.QueryDocument
+	variables map[string]any
+	// depth is the deepest field seen.
+	depth int
+}
+
+// cost sums the cost of the fields in set, which is at depth.
+func (a *analysis) cost(set ast.SelectionSet, depth int, spreading map[string]bool) int {
+	total := 0
+	for _, sel := range set {
+		switch sel := sel.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(sel.Name, "__") {
+				continue
+			}
+			a.depth = max(a.depth, depth)
+			cost := 1
+			switch sel.Name {
+			case "current", "forecast", "airQuality":
+				cost = lookupCost
+			}
+			total += cost + a.items(sel)*a.cost(sel.SelectionSet, depth+1, spreading)
+		case *ast.InlineFragment:
+			total += a.cost(sel.SelectionSet, depth, spreading)
+		case *ast.FragmentSpread:
+			frag := a.doc.Fragments.ForName(sel.Name)
+			// fragments that spread themselves are invalid, and the executor says so
+			if frag == nil || spreading[sel.Name] {
+				continue
+			}
+			spreading[sel.Name] = true
+			total += a.cost(frag.SelectionSet, depth, spreading)
+			delete(spreading, sel.Name)
+		}
+	}
+	return total
+}
+
+// items is how many items the list f returns is expected to hold, or 1 when it is not a list.
+func (a *analysis) items(f *ast.Field) int {
+	switch f.Name {
+	case "weather":
+		n := 0
+		if places, ok := a.argument(f, "places").([]any); ok {
+			n += len(places)
+		}
+		if a.argument(f, "tag") != nil {
+			n += maxPlaces
+		}
+		return n
+	case "forecast":
+		hours := int64(24)
+		if h, ok := integer(a.argument(f, "hours")); ok {
+			hours = min(max(h, 0), maxForecastHours)
+		}
+		return int(hours) / int(forecastInterval.Hours())
+	case "pollutants":
+		return pollutantCount
+	}
+	return 1
+}
+
+// integer reads an Int argument. Literals parse to int64, but variables are decoded from JSON as
+// float64, or json.Number when the decoder uses numbers.
+func integer(v any) (int64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	case float64:
+		return int64(v), true
+	case json.Number:
+		n, err := v.Int64()
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// argument is the value of f's argument name, with variables substituted, or nil.
+func (a *analysis) argument(f *ast.Field, name string) any {
+	arg := f.Arguments.ForName(name)
+	if arg == nil {
+		return nil
+	}
+	v, err := arg.Value.Value(a.variables)
+	if err != nil {
+		return nil
+	}
+	return v
+}
@@ -0,0 +1,222 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+	apperrors "weathersvc/app/app_errors"
+	"weathersvc/app/locations"
+
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+const (
+	// maxPlaces bounds the places one query may ask about, given or tagged.
+	maxPlaces = 25
+	// maxForecastHours is as far ahead as the upstream forecasts.
+	maxForecastHours = 120
+	// forecastInterval is the time between forecast steps.
+	forecastInterval = 3 * time.Hour
+)
+
+type resolver struct {
+	store locations.Store
+}
+
+type placeInput struct {
+	Latitude   *float64
+	Longitude  *float64
+	LocationID *graphql.ID
+}
+
+func (r *resolver) Weather(ctx context.Context, args struct {
+	Places *[]placeInput
+	Tag    *string
+}) ([]*placeWeather, error) {
+	var out []*placeWeather
+	if args.Places != nil {
+		for _, in := range *args.Places {
+			p, err := r.place(ctx, in)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, &placeWeather{place: p})
+		}
+	}
+	if args.Tag != nil {
+		sites, err := r.store.List(ctx, *args.Tag)
+		if err != nil {
+			return nil, apperrors.ErrInternalServiceError
+		}
+		for _, loc := range sites {
+			out = append(out, &placeWeather{place: savedPlace(loc)})
+		}
+	}
+	if len(out) > maxPlaces {
+		return nil, apperrors.CreateInvalidRequestError(fmt.Sprintf("at most %d places may be asked about at once", maxPlaces))
+	}
+	return out, nil
+}
+
+// place checks in gives coordinates in range or a saved location, and looks the location up.
+func (r *resolver) place(ctx context.Context, in placeInput) (*place, error) {
+	if in.LocationID != nil {
+		loc, err := r.store.Get(ctx, string(*in.LocationID))
+		if err != nil {
+			return nil, err
+		}
+		return savedPlace(loc), nil
+	}
+	if in.Latitude == nil || in.Longitude == nil {
+		return nil, apperrors.CreateInvalidRequestError("give latitude and longitude or locationId for each place")
+	}
+	if *in.Latitude < -90 || *in.Latitude > 90 || *in.Longitude < -180 || *in.Longitude > 180 {
+		return nil, apperrors.CreateInvalidRequestError("latitude or longitude is out of range")
+	}
+	return &place{lat: *in.Latitude, lon: *in.Longitude}, nil
+}
+
+// place is where the weather is asked for. name is set for saved locations; coordinates are named by
+// the upstream.
+type place struct {
+	id       *graphql.ID
+	name     *string
+	lat, lon float64
+}
+
+func savedPlace(loc locations.Location) *place {
+	id, name := graphql.ID(loc.ID), loc.Name
+	return &place{id: &id, name: &name, lat: loc.Latitude, lon: loc.Longitude}
+}
+
+func (p *place) ID() *graphql.ID    { return p.id }
+func (p *place) Latitude() float64  { return p.lat }
+func (p *place) Longitude() float64 { return p.lon }
+
+func (p *place) Name(ctx context.Context) (*string, error) {
+	if p.name != nil {
+		return p.name, nil
+	}
+	cond, err := batchFrom(ctx).current(ctx, p.lat, p.lon)
+	if err != nil {
+		return nil, err
+	}
+	if cond.Place == "" {
+		return nil, nil
+	}
+	return &cond.Place, nil
+}
+
+type placeWeather struct {
+	place *place
+}
+
+func (w *placeWeather) Place() *place {
+	return w.place
+}
+
+func (w *placeWeather) Current(ctx context.Context) (*conditions, error) {
+	cond, err := batchFrom(ctx).current(ctx, w.place.lat, w.place.lon)
+	if err != nil {
+		return nil, err
+	}
+	c := &conditions{
+		Summary:     fmt.Sprintf("Outside it is %s with %s and %s.", cond.Temp, cond.Wind, cond.Condition),
+		Condition:   cond.Condition,
+		Temperature: temperature{FeelsLike: cond.FeelsLike, Unit: "F", Class: string(cond.Temp)},
+		Wind:        wind{Speed: cond.WindSpeed, Unit: "mph", Class: string(cond.Wind)},
+		Stale:       cond.Stale,
+	}
+	if !cond.ObservedAt.IsZero() {
+		observed := cond.ObservedAt.Format(time.RFC3339)
+		c.ObservedAt = &observed
+		c.AgeSeconds = int32(time.Since(cond.ObservedAt) / time.Second)
+	}
+	return c, nil
+}
+
+func (w *placeWeather) Forecast(ctx context.Context, args struct{ Hours int32 }) (*[]forecastStep, error) {
+	if args.Hours < 0 || args.Hours > maxForecastHours {
+		return nil, apperrors.CreateInvalidRequestError(fmt.Sprintf("hours must be from 0 to %d", maxForecastHours))
+	}
+	forecast, err := batchFrom(ctx).forecast(ctx, w.place.lat, w.place.lon)
+	if err != nil {
+		return nil, err
+	}
+	until := time.Now().Add(time.Duration(args.Hours) * time.Hour)
+	steps := []forecastStep{}
+	for _, s := range forecast.Steps {
+		if s.Time.After(until) {
+			break
+		}
+		steps = append(steps, forecastStep{
+			Time:                s.Time.Format(time.RFC3339),
+			Condition:           s.Condition,
+			Temperature:         temperature{FeelsLike: s.FeelsLike, Unit: "F", Class: string(s.Temp)},
+			Wind:                wind{Speed: s.WindSpeed, Unit: "mph", Class: string(s.Wind)},
+			PrecipitationChance: s.PrecipitationChance,
+		})
+	}
+	return &steps, nil
+}
+
+func (w *placeWeather) AirQuality(ctx context.Context) (*airQuality, error) {
+	air, err := batchFrom(ctx).airQuality(ctx, w.place.lat, w.place.lon)
+	if err != nil {
+		return nil, err
+	}
+	a := &airQuality{Index: int32(air.Index), Class: string(air.Class), Pollutants: []pollutant{}}
+	if !air.ObservedAt.IsZero() {
+		a.ObservedAt = air.ObservedAt.Format(time.RFC3339)
+	}
+	for name, c := range air.Components {
+		a.Pollutants = append(a.Pollutants, pollutant{Name: name, Concentration: c})
+	}
+	sort.Slice(a.Pollutants, func(i, j int) bool { return a.Pollutants[i].Name < a.Pollutants[j].Name })
+	return a, nil
+}
+
+// The types below are resolved field by field, by name.
+
+type conditions struct {
+	Summary     string
+	Condition   string
+	Temperature temperature
+	Wind        wind
+	ObservedAt  *string
+	AgeSeconds  int32
+	Stale       bool
+}
+
+type temperature struct {
+	FeelsLike float64
+	Unit      string
+	Class     string
+}
+
+type wind struct {
+	Speed float64
+	Unit  string
+	Class string
+}
+
+type forecastStep struct {
+	Time                string
+	Condition           string
+	Temperature         temperature
+	Wind                wind
+	PrecipitationChance float64
+}
+
+type airQuality struct {
+	Index      int32
+	Class      string
+	Pollutants []pollutant
+	ObservedAt string
+}
+
+type pollutant struct {
+	Name          string
+	Concentration float64
+}
@@ -0,0 +1,158 @@
+/*
+health.go: Dependency health. A Monitor runs every check in the background on an interval and keeps
+the latest results, so readiness probes are answered from memory instead of calling the upstream on
+every probe. A failing critical check makes the service unready; any other failure only degrades it.
+*/
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+	"weathersvc/app/config"
+	"weathersvc/app/logging"
+)
+
+const (
+	StatusOK       = "ok"
+	StatusDegraded = "degraded"
+	StatusFailing  = "failing"
+	// StatusPending is reported before a check has completed once.
+	StatusPending = "pending"
+)
+
+// Check is one dependency check. Run returns an optional detail, such as a size, and an error when
+// the dependency is unhealthy.
+type Check struct {
+	Name     string
+	Critical bool
+	Run      func(ctx context.Context) (string, error)
+}
+
+// Result is the latest outcome of a Check.
+type Result struct {
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	Critical  bool      `json:"critical"`
+	Detail    string    `json:"detail,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at,omitempty"`
+	// LatencyMS is how long the check took, in milliseconds.
+	LatencyMS int64 `json:"latency_ms"`
+}
+
+// Report is the readiness of the service and the detail of every check.
+type Report struct {
+	Status string   `json:"status"`
+	Checks []Result `json:"checks"`
+}
+
+// Ready reports whether every critical check passed.
+func (r Report) Ready() bool {
+	return r.Status != StatusFailing
+}
+
+type Monitor interface {
+	// Run checks every dependency on each interval until ctx is cancelled.
+	Run(ctx context.Context)
+	// CheckAll checks every dependency once.
+	CheckAll(ctx context.Context)
+	Report() Report
+}
+
+type monitor struct {
+	checks   []Check
+	interval time.Duration
+	timeout  time.Duration
+	mu       sync.RWMutex
+	results  []Result
+}
+
+// NewMonitor checks each dependency every interval, giving each check up to timeout.
+func NewMonitor(interval, timeout time.Duration, checks ...Check) Monitor {
+	if interval <= 0 {
+		interval = config.DefaultHealthInterval
+	}
+	if timeout <= 0 {
+		timeout = config.DefaultHealthTimeout
+	}
+	results := make([]Result, len(checks))
+	for i, c := range checks {
+		results[i] = Result{Name: c.Name, Status: StatusPending, Critical: c.Critical}
+	}
+	return &monitor{
+		checks:   checks,
+		interval: interval,
+		timeout:  timeout,
+		results:  results,
+	}
+}
+
+func (m *monitor) Run(ctx context.Context) {
+	m.CheckAll(ctx)
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.CheckAll(ctx)
+		}
+	}
+}
+
+func (m *monitor) CheckAll(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i, c := range m.checks {
+		wg.Add(1)
+		go func(i int, c Check) {
+			defer wg.Done()
+			res := m.check(ctx, c)
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			if prev := m.results[i]; prev.Status != res.Status && prev.Status != StatusPending {
+				logging.FromContext(ctx).Warn("dependency health changed", "check", c.Name, "from", prev.Status, "to", res.Status, "error", res.Error)
+			}
+			m.results[i] = res
+		}(i, c)
+	}
+	wg.Wait()
+}
+
+func (m *monitor) check(ctx context.Context, c Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+	start := time.Now()
+	detail, err := c.Run(ctx)
+	res := Result{
+		Name:      c.Name,
+		Status:    StatusOK,
+		Critical:  c.Critical,
+		Detail:    detail,
+		CheckedAt: start.UTC(),
+		LatencyMS: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		res.Status = StatusFailing
+		res.Error = err.Error()
+	}
+	return res
+}
+
+func (m *monitor) Report() Report {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	report := Report{Status: StatusOK, Checks: make([]Result, len(m.results))}
+	copy(report.Checks, m.results)
+	for _, r := range report.Checks {
+		switch {
+		case r.Status == StatusOK:
+		case r.Critical:
+			report.Status = StatusFailing
+		case report.Status == StatusOK:
+			report.Status = StatusDegraded
+		}
+	}
+	return report
+}
@@ -0,0 +1,57 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMonitor_Report(t *testing.T) {
+	var upstreamErr error
+	m := NewMonitor(time.Minute, 50*time.Millisecond,
+		Check{Name: "store", Critical: true, Run: func(ctx context.Context) (string, error) {
+			return "3 entries", nil
+		}},
+		Check{Name: "upstream", Run: func(ctx context.Context) (string, error) {
+			return "", upstreamErr
+		}},
+	)
+	t.Run("Should not be ready before critical checks have run", func(t *testing.T) {
+		report := m.Report()
+		assert.Equal(t, StatusFailing, report.Status)
+		assert.False(t, report.Ready())
+		assert.Equal(t, StatusPending, report.Checks[0].Status)
+	})
+	t.Run("Should be ready when every check passes", func(t *testing.T) {
+		m.CheckAll(context.Background())
+		report := m.Report()
+		assert.Equal(t, StatusOK, report.Status)
+		assert.Equal(t, "3 entries", report.Checks[0].Detail)
+		assert.False(t, report.Checks[0].CheckedAt.IsZero())
+	})
+	t.Run("Should be degraded but ready when a non-critical check fails", func(t *testing.T) {
+		upstreamErr = errors.New("connection refused")
+		m.CheckAll(context.Background())
+		report := m.Report()
+		assert.Equal(t, StatusDegraded, report.Status)
+		assert.True(t, report.Ready())
+		assert.Equal(t, StatusFailing, report.Checks[1].Status)
+		assert.Equal(t, "connection refused", report.Checks[1].Error)
+	})
+}
+
+func TestMonitor_CheckAll(t *testing.T) {
+	m := NewMonitor(time.Minute, 10*time.Millisecond, Check{Name: "slow", Critical: true, Run: func(ctx context.Context) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}})
+	t.Run("Should fail checks that exceed the timeout", func(t *testing.T) {
+		m.CheckAll(context.Background())
+		report := m.Report()
+		assert.False(t, report.Ready())
+		assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks[0].Error)
+	})
+}
@@ -0,0 +1,145 @@
+package history
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const observationsBucket = "observations"
+
+type boltRepository struct {
+	db      *bolt.DB
+	sweeper *sweeper
+}
+
+// NewBoltRepository opens (or creates) an embedded bbolt database at path, keeping observations as long
+// as retention allows. Observations are bucketed by location and keyed by time so range queries and
+// expiry are cursor scans.
+func NewBoltRepository(path string, retention Retention) (Repository, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("error opening history store: %w", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(observationsBucket))
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error creating history store: %w", err)
+	}
+	return &boltRepository{db: db, sweeper: &sweeper{retention: retention, now: time.Now}}, nil
+}
+
+func (b *boltRepository) Save(ctx context.Context, o Observation) error {
+	value, err := json.Marshal(o)
+	if err != nil {
+		return err
+	}
+	err = b.db.Update(func(tx *bolt.Tx) error {
+		loc, err := tx.Bucket([]byte(observationsBucket)).CreateBucketIfNotExists([]byte(locationKey(o.Latitude, o.Longitude)))
+		if err != nil {
+			return err
+		}
+		seq, err := loc.NextSequence()
+		if err != nil {
+			return err
+		}
+		// the sequence keeps readings taken in the same nanosecond from overwriting each other
+		key := make([]byte, 16)
+		binary.BigEndian.PutUint64(key, timeKey(o.Time))
+		binary.BigEndian.PutUint64(key[8:], seq)
+		return loc.Put(key, value)
+	})
+	if err != nil {
+		return err
+	}
+	if due, cutoff := b.sweeper.due(); due {
+		if err := b.sweep(cutoff); err != nil {
+			// the observation is saved; what should have been dropped goes with the next sweep
+			slog.Error("failed to apply history retention", "error", err)
+		}
+	}
+	return nil
+}
+
+// sweep drops observations taken before cutoff and those over the cap at each location.
+func (b *boltRepository) sweep(cutoff time.Time) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		root := tx.Bucket([]byte(observationsBucket))
+		var empty [][]byte
+		err := root.ForEachBucket(func(name []byte) error {
+			loc := root.Bucket(name)
+			drop := 0
+			if limit := b.sweeper.retention.MaxPerLocation; limit > 0 {
+				drop = max(loc.Stats().KeyN-limit, 0)
+			}
+			c := loc.Cursor()
+			for k, _ := c.First(); k != nil; k, _ = c.First() {
+				if drop == 0 && (cutoff.IsZero() || binary.BigEndian.Uint64(k[:8]) >= timeKey(cutoff)) {
+					break
+				}
+				if err := c.Delete(); err != nil {
+					return err
+				}
+				drop = max(drop-1, 0)
+			}
+			if k, _ := c.First(); k == nil {
+				empty = append(empty, append([]byte(nil), name...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, name := range empty {
+			if err := root.DeleteBucket(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (b *boltRepository) Query(ctx context.Context, lat, lon float64, from, to time.Time) ([]Observation, error) {
+	var out []Observation
+	err := b.db.View(func(tx *bolt.Tx) error {
+		loc := tx.Bucket([]byte(observationsBucket)).Bucket([]byte(locationKey(lat, lon)))
+		if loc == nil {
+			return nil
+		}
+		min := make([]byte, 8)
+		binary.BigEndian.PutUint64(min, timeKey(from))
+		max := make([]byte, 8)
+		binary.BigEndian.PutUint64(max, timeKey(to))
+		c := loc.Cursor()
+		for k, v := c.Seek(min); k != nil && bytes.Compare(k[:8], max) <= 0; k, v = c.Next() {
+			var o Observation
+			if err := json.Unmarshal(v, &o); err != nil {
+				return err
+			}
+			out = append(out, o)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error querying history store: %w", err)
+	}
+	return out, nil
+}
+
+func (b *boltRepository) Close() error {
+	return b.db.Close()
+}
+
+// timeKey orders times before the epoch first, as big-endian keys sort as unsigned integers.
+func timeKey(t time.Time) uint64 {
+	return uint64(t.UnixNano()) ^ (1 << 63)
+}
@@ -0,0 +1,82 @@
+/*
+history.go: Observation history. Every upstream observation is saved through a Repository so past
+conditions at a location can be queried later. Each repository applies a Retention as observations are
+saved, so history is bounded however long the service runs.
+*/
+package history
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Observation is one upstream reading with the classifications returned for it.
+type Observation struct {
+	Latitude    float64   `json:"latitude"`
+	Longitude   float64   `json:"longitude"`
+	Time        time.Time `json:"time"`
+	Provider    string    `json:"provider"`
+	FeelsLike   float64   `json:"feels_like"`
+	WindSpeed   float64   `json:"wind_speed"`
+	Description string    `json:"description"`
+	Temp        string    `json:"temp"`
+	Wind        string    `json:"wind"`
+	// Samples is the number of observations averaged into a downsampled observation.
+	Samples int `json:"samples,omitempty"`
+}
+
+// sweepInterval is how often a repository drops the observations its Retention no longer keeps.
+const sweepInterval = time.Minute
+
+// Retention bounds the observations a repository keeps. A zero field keeps observations without that bound.
+type Retention struct {
+	// MaxAge drops observations taken longer ago.
+	MaxAge time.Duration
+	// MaxPerLocation keeps only the latest observations at each location.
+	MaxPerLocation int
+}
+
+// cutoff is the time before which observations are dropped, zero when none are dropped for their age.
+func (r Retention) cutoff(now time.Time) time.Time {
+	if r.MaxAge <= 0 {
+		return time.Time{}
+	}
+	return now.Add(-r.MaxAge)
+}
+
+// sweeper runs a Retention at most every sweepInterval, as observations are saved.
+type sweeper struct {
+	retention Retention
+	mu        sync.Mutex
+	next      time.Time
+	now       func() time.Time
+}
+
+// due reports whether a sweep should run now, and the cutoff it applies.
+func (s *sweeper) due() (bool, time.Time) {
+	if s.retention == (Retention{}) {
+		return false, time.Time{}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if now.Before(s.next) {
+		return false, time.Time{}
+	}
+	s.next = now.Add(sweepInterval)
+	return true, s.retention.cutoff(now)
+}
+
+type Repository interface {
+	Save(ctx context.Context, o Observation) error
+	// Query returns the observations for a location within [from, to], oldest first.
+	Query(ctx context.Context, lat, lon float64, from, to time.Time) ([]Observation, error)
+	Close() error
+}
+
+// locationKey normalizes coordinates to roughly 11m so nearby readings share a history.
+func locationKey(lat, lon float64) string {
+	return fmt.Sprintf("%.4f,%.4f", lat, lon)
+}
@@ -0,0 +1,118 @@
+package history
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistory_Repositories(t *testing.T) {
+	ctx := context.Background()
+	bolt, err := NewBoltRepository(filepath.Join(t.TempDir(), "history.db"), Retention{})
+	require.NoError(t, err)
+	defer bolt.Close()
+	repos := map[string]Repository{
+		"memory": NewMemoryRepository(Retention{}),
+		"bolt":   bolt,
+	}
+	base := time.Date(2026, 10, 13, 12, 0, 0, 0, time.UTC)
+	for name, repo := range repos {
+		t.Run("Should save and query observations in time order for "+name, func(t *testing.T) {
+			for _, offset := range []int{3, 1, 2, 0, 5} {
+				require.NoError(t, repo.Save(ctx, Observation{
+					Latitude:  32.777981,
+					Longitude: -96.796211,
+					Time:      base.Add(time.Duration(offset) * time.Hour),
+					Provider:  "openweathermap",
+					FeelsLike: float64(60 + offset),
+					Temp:      "moderate",
+				}))
+			}
+			require.NoError(t, repo.Save(ctx, Observation{Latitude: 1, Longitude: 1, Time: base}))
+			got, err := repo.Query(ctx, 32.77798, -96.79621, base.Add(time.Hour), base.Add(3*time.Hour))
+			require.NoError(t, err)
+			if assert.Len(t, got, 3) {
+				assert.Equal(t, 61.0, got[0].FeelsLike)
+				assert.Equal(t, 62.0, got[1].FeelsLike)
+				assert.Equal(t, 63.0, got[2].FeelsLike)
+				assert.True(t, got[0].Time.Equal(base.Add(time.Hour)))
+				assert.Equal(t, "openweathermap", got[0].Provider)
+			}
+			got, err = repo.Query(ctx, 10, 10, base, base.Add(time.Hour))
+			require.NoError(t, err)
+			assert.Empty(t, got)
+		})
+	}
+	t.Run("Should keep observations after reopening the bolt store", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "reopen.db")
+		repo, err := NewBoltRepository(path, Retention{})
+		require.NoError(t, err)
+		require.NoError(t, repo.Save(ctx, Observation{Latitude: 1, Longitude: 2, Time: base, Temp: "hot"}))
+		require.NoError(t, repo.Save(ctx, Observation{Latitude: 1, Longitude: 2, Time: base, Temp: "warm"}))
+		require.NoError(t, repo.Close())
+		repo, err = NewBoltRepository(path, Retention{})
+		require.NoError(t, err)
+		defer repo.Close()
+		got, err := repo.Query(ctx, 1, 2, base.Add(-time.Minute), base.Add(time.Minute))
+		require.NoError(t, err)
+		assert.Len(t, got, 2)
+	})
+	t.Run("Should fail to open an invalid bolt path", func(t *testing.T) {
+		_, err := NewBoltRepository(filepath.Join(t.TempDir(), "missing", "history.db"), Retention{})
+		assert.Error(t, err)
+	})
+}
+
+func TestHistory_Retention(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	retention := Retention{MaxAge: 24 * time.Hour, MaxPerLocation: 3}
+	bolt, err := NewBoltRepository(filepath.Join(t.TempDir(), "history.db"), retention)
+	require.NoError(t, err)
+	defer bolt.Close()
+	repos := map[string]Repository{
+		"memory": NewMemoryRepository(retention),
+		"bolt":   bolt,
+	}
+	for name, repo := range repos {
+		t.Run("Should drop observations too old or over the cap for "+name, func(t *testing.T) {
+			var sw *sweeper
+			switch r := repo.(type) {
+			case *memoryRepository:
+				sw = r.sweeper
+			case *boltRepository:
+				sw = r.sweeper
+			}
+			clock := now
+			sw.now = func() time.Time { return clock }
+			save := func(lat float64, at time.Time) {
+				require.NoError(t, repo.Save(ctx, Observation{Latitude: lat, Longitude: 1, Time: at}))
+			}
+			// the first save sweeps, so the rest wait for the next one
+			save(9, now.Add(-48*time.Hour))
+			save(2, now.Add(-25*time.Hour))
+			for i := 5; i > 0; i-- {
+				save(1, now.Add(-time.Duration(i)*time.Hour))
+			}
+			got, err := repo.Query(ctx, 1, 1, now.Add(-30*24*time.Hour), now)
+			require.NoError(t, err)
+			assert.Len(t, got, 5, "nothing is dropped before the next sweep")
+			clock = now.Add(sweepInterval)
+			save(1, now)
+			got, err = repo.Query(ctx, 1, 1, now.Add(-30*24*time.Hour), now)
+			require.NoError(t, err)
+			if assert.Len(t, got, 3) {
+				assert.True(t, got[0].Time.Equal(now.Add(-2*time.Hour)), "the latest are kept")
+			}
+			for _, lat := range []float64{2, 9} {
+				got, err = repo.Query(ctx, lat, 1, now.Add(-30*24*time.Hour), now)
+				require.NoError(t, err)
+				assert.Empty(t, got, "observations older than the max age are dropped")
+			}
+		})
+	}
+}
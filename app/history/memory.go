@@ -0,0 +1,66 @@
+package history
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+type memoryRepository struct {
+	mu           sync.RWMutex
+	observations map[string][]Observation
+	sweeper      *sweeper
+}
+
+// NewMemoryRepository returns a Repository that keeps observations in memory, as long as retention allows.
+func NewMemoryRepository(retention Retention) Repository {
+	return &memoryRepository{observations: map[string][]Observation{}, sweeper: &sweeper{retention: retention, now: time.Now}}
+}
+
+func (m *memoryRepository) Save(ctx context.Context, o Observation) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := locationKey(o.Latitude, o.Longitude)
+	obs := m.observations[key]
+	i := sort.Search(len(obs), func(i int) bool { return obs[i].Time.After(o.Time) })
+	obs = append(obs, Observation{})
+	copy(obs[i+1:], obs[i:])
+	obs[i] = o
+	m.observations[key] = obs
+	if due, cutoff := m.sweeper.due(); due {
+		m.sweep(cutoff)
+	}
+	return nil
+}
+
+// sweep drops observations taken before cutoff and those over the cap at each location.
+func (m *memoryRepository) sweep(cutoff time.Time) {
+	for key, obs := range m.observations {
+		start := sort.Search(len(obs), func(i int) bool { return !obs[i].Time.Before(cutoff) })
+		if limit := m.sweeper.retention.MaxPerLocation; limit > 0 {
+			start = max(start, len(obs)-limit)
+		}
+		switch {
+		case start == len(obs):
+			delete(m.observations, key)
+		case start > 0:
+			// copied so the dropped observations can be freed
+			m.observations[key] = append([]Observation(nil), obs[start:]...)
+		}
+	}
+}
+
+func (m *memoryRepository) Query(ctx context.Context, lat, lon float64, from, to time.Time) ([]Observation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	obs := m.observations[locationKey(lat, lon)]
+	start := sort.Search(len(obs), func(i int) bool { return !obs[i].Time.Before(from) })
+	end := sort.Search(len(obs), func(i int) bool { return obs[i].Time.After(to) })
+	out := make([]Observation, 0, end-start)
+	return append(out, obs[start:end]...), nil
+}
+
+func (m *memoryRepository) Close() error {
+	return nil
+}
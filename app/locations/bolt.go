@@ -0,0 +1,105 @@
+package locations
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+	apperrors "weathersvc/app/app_errors"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const locationsBucket = "locations"
+
+type boltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore opens (or creates) an embedded bbolt database at path, keyed by location id.
+func NewBoltStore(path string) (Store, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("error opening locations store: %w", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(locationsBucket))
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error creating locations store: %w", err)
+	}
+	return &boltStore{db: db}, nil
+}
+
+func (b *boltStore) Create(ctx context.Context, l Location) (Location, error) {
+	return l, b.put(l, false)
+}
+
+func (b *boltStore) Get(ctx context.Context, id string) (Location, error) {
+	var l Location
+	err := b.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket([]byte(locationsBucket)).Get([]byte(id))
+		if v == nil {
+			return apperrors.ErrLocationNotFound
+		}
+		return json.Unmarshal(v, &l)
+	})
+	return l, err
+}
+
+func (b *boltStore) List(ctx context.Context, tag string) ([]Location, error) {
+	out := []Location{}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		// keys iterate in byte order, so the list is already sorted by id
+		return tx.Bucket([]byte(locationsBucket)).ForEach(func(k, v []byte) error {
+			var l Location
+			if err := json.Unmarshal(v, &l); err != nil {
+				return err
+			}
+			if tag == "" || l.HasTag(tag) {
+				out = append(out, l)
+			}
+			return nil
+		})
+	})
+	return out, err
+}
+
+func (b *boltStore) Update(ctx context.Context, l Location) (Location, error) {
+	return l, b.put(l, true)
+}
+
+func (b *boltStore) Delete(ctx context.Context, id string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(locationsBucket))
+		if bucket.Get([]byte(id)) == nil {
+			return apperrors.ErrLocationNotFound
+		}
+		return bucket.Delete([]byte(id))
+	})
+}
+
+func (b *boltStore) Close() error {
+	return b.db.Close()
+}
+
+// put writes l, requiring it to already exist when replace is set and to be new otherwise.
+func (b *boltStore) put(l Location, replace bool) error {
+	value, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(locationsBucket))
+		exists := bucket.Get([]byte(l.ID)) != nil
+		if replace && !exists {
+			return apperrors.ErrLocationNotFound
+		}
+		if !replace && exists {
+			return apperrors.ErrLocationExists
+		}
+		return bucket.Put([]byte(l.ID), value)
+	})
+}
@@ -0,0 +1,74 @@
+/*
+locations.go: Saved locations. Named sites let callers ask for the weather by id or tag instead of
+pasting coordinates.
+*/
+package locations
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	apperrors "weathersvc/app/app_errors"
+)
+
+const (
+	UnitsImperial = "imperial"
+	UnitsMetric   = "metric"
+	UnitsStandard = "standard"
+)
+
+// Location is a named site.
+type Location struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Latitude  float64  `json:"latitude"`
+	Longitude float64  `json:"longitude"`
+	Tags      []string `json:"tags"`
+	// Units is the caller's preferred unit system: imperial, metric or standard.
+	Units string `json:"units"`
+}
+
+type Store interface {
+	Create(ctx context.Context, l Location) (Location, error)
+	Get(ctx context.Context, id string) (Location, error)
+	// List returns every location, or only those carrying tag when it is not empty.
+	List(ctx context.Context, tag string) ([]Location, error)
+	Update(ctx context.Context, l Location) (Location, error)
+	Delete(ctx context.Context, id string) error
+	Close() error
+}
+
+// idPattern keeps ids safe to use in URL paths and query strings.
+var idPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`) //nolint:gochecknoglobals // compiled once
+
+// Validate checks l has a usable id, name and units, defaulting the units to imperial.
+// Coordinates are validated by the caller alongside every other coordinate input.
+func (l *Location) Validate() error {
+	if !idPattern.MatchString(l.ID) {
+		return apperrors.CreateInvalidRequestError("id must be 1-64 letters, digits, `-` or `_`")
+	}
+	if l.Name == "" {
+		return apperrors.CreateInvalidRequestError("name is required")
+	}
+	switch l.Units {
+	case "":
+		l.Units = UnitsImperial
+	case UnitsImperial, UnitsMetric, UnitsStandard:
+	default:
+		return apperrors.CreateInvalidRequestError(fmt.Sprintf("units must be %s, %s or %s", UnitsImperial, UnitsMetric, UnitsStandard))
+	}
+	if l.Tags == nil {
+		l.Tags = []string{}
+	}
+	return nil
+}
+
+// HasTag reports whether the location carries tag.
+func (l Location) HasTag(tag string) bool {
+	for _, t := range l.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
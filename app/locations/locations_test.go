@@ -0,0 +1,75 @@
+package locations
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	apperrors "weathersvc/app/app_errors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocations_Validate(t *testing.T) {
+	t.Run("Should default units and tags", func(t *testing.T) {
+		l := Location{ID: "hq", Name: "Headquarters", Latitude: 32.7, Longitude: -96.8}
+		assert.NoError(t, l.Validate())
+		assert.Equal(t, UnitsImperial, l.Units)
+		assert.Equal(t, []string{}, l.Tags)
+	})
+	tests := map[string]Location{
+		"invalid request: id must be 1-64 letters, digits, `-` or `_`": {ID: "h q", Name: "HQ"},
+		"invalid request: name is required":                            {ID: "hq"},
+		"invalid request: units must be imperial, metric or standard":  {ID: "hq", Name: "HQ", Units: "kelvin"},
+	}
+	for want, l := range tests {
+		t.Run("Should reject "+want, func(t *testing.T) {
+			assert.EqualError(t, l.Validate(), want)
+		})
+	}
+}
+
+func TestLocations_Stores(t *testing.T) {
+	ctx := context.Background()
+	bolt, err := NewBoltStore(filepath.Join(t.TempDir(), "locations.db"))
+	require.NoError(t, err)
+	defer bolt.Close()
+	stores := map[string]Store{
+		"memory": NewMemoryStore(),
+		"bolt":   bolt,
+	}
+	hq := Location{ID: "hq", Name: "Headquarters", Latitude: 32.7, Longitude: -96.8, Tags: []string{"office"}, Units: UnitsImperial}
+	dock := Location{ID: "dock-1", Name: "Dock", Latitude: 29.7, Longitude: -95.3, Tags: []string{"warehouses"}, Units: UnitsMetric}
+	for name, store := range stores {
+		t.Run("Should create, list, update and delete for "+name, func(t *testing.T) {
+			_, err := store.Create(ctx, hq)
+			require.NoError(t, err)
+			_, err = store.Create(ctx, dock)
+			require.NoError(t, err)
+			_, err = store.Create(ctx, hq)
+			assert.ErrorIs(t, err, apperrors.ErrLocationExists)
+			got, err := store.Get(ctx, "hq")
+			require.NoError(t, err)
+			assert.Equal(t, hq, got)
+			all, err := store.List(ctx, "")
+			require.NoError(t, err)
+			assert.Equal(t, []Location{dock, hq}, all)
+			tagged, err := store.List(ctx, "warehouses")
+			require.NoError(t, err)
+			assert.Equal(t, []Location{dock}, tagged)
+			moved := hq
+			moved.Tags = []string{"office", "warehouses"}
+			_, err = store.Update(ctx, moved)
+			require.NoError(t, err)
+			tagged, err = store.List(ctx, "warehouses")
+			require.NoError(t, err)
+			assert.Len(t, tagged, 2)
+			_, err = store.Update(ctx, Location{ID: "nope"})
+			assert.ErrorIs(t, err, apperrors.ErrLocationNotFound)
+			require.NoError(t, store.Delete(ctx, "hq"))
+			assert.ErrorIs(t, store.Delete(ctx, "hq"), apperrors.ErrLocationNotFound)
+			_, err = store.Get(ctx, "hq")
+			assert.ErrorIs(t, err, apperrors.ErrLocationNotFound)
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package locations
+
+import (
+	"context"
+	"sort"
+	"sync"
+	apperrors "weathersvc/app/app_errors"
+)
+
+type memoryStore struct {
+	mu        sync.RWMutex
+	locations map[string]Location
+}
+
+// NewMemoryStore returns a Store that keeps locations in memory for the life of the process.
+func NewMemoryStore() Store {
+	return &memoryStore{locations: map[string]Location{}}
+}
+
+func (m *memoryStore) Create(ctx context.Context, l Location) (Location, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.locations[l.ID]; ok {
+		return Location{}, apperrors.ErrLocationExists
+	}
+	m.locations[l.ID] = l
+	return l, nil
+}
+
+func (m *memoryStore) Get(ctx context.Context, id string) (Location, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	l, ok := m.locations[id]
+	if !ok {
+		return Location{}, apperrors.ErrLocationNotFound
+	}
+	return l, nil
+}
+
+func (m *memoryStore) List(ctx context.Context, tag string) ([]Location, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := []Location{}
+	for _, l := range m.locations {
+		if tag == "" || l.HasTag(tag) {
+			out = append(out, l)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out, nil
+}
+
+func (m *memoryStore) Update(ctx context.Context, l Location) (Location, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.locations[l.ID]; !ok {
+		return Location{}, apperrors.ErrLocationNotFound
+	}
+	m.locations[l.ID] = l
+	return l, nil
+}
+
+func (m *memoryStore) Delete(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.locations[id]; !ok {
+		return apperrors.ErrLocationNotFound
+	}
+	delete(m.locations, id)
+	return nil
+}
+
+func (m *memoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,72 @@
+/*
+logging.go: Structured logging with log/slog. Local environments log text and deployed ones log
+JSON. Every record passes through redaction, so an upstream URL or error carrying the Open Weather Map
+app id never reaches the logs.
+*/
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// appIDPattern matches the app id query parameter in a URL or in an error quoting one.
+var appIDPattern = regexp.MustCompile(`(?i)(appid=)[^&\s"']+`) //nolint:gochecknoglobals // compiled once
+
+// Redacted replaces secrets removed from logs.
+const Redacted = "REDACTED"
+
+type loggerKey struct{}
+
+// New returns a logger writing to w at level, as text when env is a local environment and JSON
+// otherwise. A *slog.LevelVar level can be changed while the logger is in use.
+func New(w io.Writer, env string, level slog.Leveler) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redact}
+	if isLocal(env) {
+		return slog.New(slog.NewTextHandler(w, opts))
+	}
+	return slog.New(slog.NewJSONHandler(w, opts))
+}
+
+func isLocal(env string) bool {
+	switch strings.ToLower(env) {
+	case "", "local", "dev", "development", "test", "testing":
+		return true
+	default:
+		return false
+	}
+}
+
+// RedactURL hides the app id in a URL, or in any text quoting one.
+func RedactURL(s string) string {
+	return appIDPattern.ReplaceAllString(s, "${1}"+Redacted)
+}
+
+// redact is the handlers' ReplaceAttr. Errors are logged as their redacted message.
+func redact(_ []string, a slog.Attr) slog.Attr {
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(RedactURL(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			a.Value = slog.StringValue(RedactURL(err.Error()))
+		}
+	}
+	return a
+}
+
+// WithLogger returns a copy of ctx carrying l.
+func WithLogger(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// FromContext returns the logger carried by ctx, or the default logger.
+func FromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return slog.Default()
+}
@@ -0,0 +1,55 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogging_New(t *testing.T) {
+	t.Run("Should log text in local environments", func(t *testing.T) {
+		var buf bytes.Buffer
+		New(&buf, "local", slog.LevelInfo).Info("hello", "n", 1)
+		assert.Contains(t, buf.String(), "level=INFO msg=hello n=1")
+	})
+	t.Run("Should log JSON in deployed environments", func(t *testing.T) {
+		var buf bytes.Buffer
+		New(&buf, "production", slog.LevelInfo).Info("hello", "n", 1)
+		var got map[string]any
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+		assert.Equal(t, "hello", got["msg"])
+		assert.Equal(t, float64(1), got["n"])
+	})
+	t.Run("Should drop records below the level", func(t *testing.T) {
+		var buf bytes.Buffer
+		l := New(&buf, "", slog.LevelWarn)
+		l.Info("quiet")
+		assert.Empty(t, buf.String())
+		l.Warn("loud")
+		assert.Contains(t, buf.String(), "loud")
+	})
+	t.Run("Should redact the app id from strings, errors and messages", func(t *testing.T) {
+		var buf bytes.Buffer
+		l := New(&buf, "", slog.LevelInfo)
+		l.Info("calling https://owm/weather?appid=s3cret",
+			"url", "https://owm/weather?lat=1&APPID=s3cret&lon=2",
+			"error", errors.New(`Get "https://owm/weather?appid=s3cret": timeout`))
+		assert.NotContains(t, buf.String(), "s3cret")
+		assert.Contains(t, buf.String(), "APPID=REDACTED&lon=2")
+	})
+}
+
+func TestLogging_FromContext(t *testing.T) {
+	t.Run("Should return the logger carried by the context", func(t *testing.T) {
+		l := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+		assert.Same(t, l, FromContext(WithLogger(context.Background(), l)))
+	})
+	t.Run("Should fall back to the default logger", func(t *testing.T) {
+		assert.Same(t, slog.Default(), FromContext(context.Background()))
+	})
+}
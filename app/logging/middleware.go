@@ -0,0 +1,56 @@
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// HeaderRequestID carries the request id from the caller, or from us when the caller sent none.
+const HeaderRequestID = "X-Request-ID"
+
+// maxRequestID bounds a propagated request id so callers cannot bloat every log line.
+const maxRequestID = 128
+
+// Middleware propagates a valid incoming X-Request-ID or generates one, echoes it on the response
+// and carries a logger tagged with it, and with the trace id when the request is traced, in the
+// request context. Handlers and the calls they make log through FromContext.
+func Middleware(base *slog.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(HeaderRequestID)
+			if !validRequestID(id) {
+				id = newRequestID()
+			}
+			w.Header().Set(HeaderRequestID, id)
+			l := base.With("request_id", id)
+			if sc := trace.SpanContextFromContext(r.Context()); sc.HasTraceID() {
+				l = l.With("trace_id", sc.TraceID().String())
+			}
+			next.ServeHTTP(w, r.WithContext(WithLogger(r.Context(), l)))
+		})
+	}
+}
+
+// validRequestID accepts ids of visible ASCII characters, so they are safe to log and echo.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestID {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
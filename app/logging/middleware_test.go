@@ -0,0 +1,49 @@
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	r := mux.NewRouter()
+	r.Use(Middleware(New(&buf, "", slog.LevelInfo)))
+	r.HandleFunc("/weather/get", func(w http.ResponseWriter, r *http.Request) {
+		FromContext(r.Context()).Info("handled")
+	})
+	do := func(id string) *httptest.ResponseRecorder {
+		buf.Reset()
+		req := httptest.NewRequest("GET", "/weather/get", nil)
+		if id != "" {
+			req.Header.Set(HeaderRequestID, id)
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+	t.Run("Should propagate the caller's request id", func(t *testing.T) {
+		rr := do("req-123")
+		assert.Equal(t, "req-123", rr.Header().Get(HeaderRequestID))
+		assert.Contains(t, buf.String(), "request_id=req-123")
+	})
+	t.Run("Should generate a request id when none is sent", func(t *testing.T) {
+		rr := do("")
+		id := rr.Header().Get(HeaderRequestID)
+		assert.Len(t, id, 32)
+		assert.Contains(t, buf.String(), "request_id="+id)
+	})
+	t.Run("Should replace invalid request ids", func(t *testing.T) {
+		for _, id := range []string{"has space", strings.Repeat("a", maxRequestID+1)} {
+			rr := do(id)
+			assert.Len(t, rr.Header().Get(HeaderRequestID), 32)
+		}
+	})
+}
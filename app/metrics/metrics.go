@@ -0,0 +1,256 @@
+/*
+metrics.go: Prometheus metrics for the server, service and upstream. Every collector is registered on
+the Metrics' own registry, which also carries the Go runtime and process collectors, and is served by
+Handler. A nil *Metrics records nothing, so callers without one need no checks.
+*/
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+	apperrors "weathersvc/app/app_errors"
+	"weathersvc/app/budget"
+	openweather "weathersvc/app/open_weather"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "weathersvc"
+
+// Cache lookup results.
+const (
+	CacheHit   = "hit"
+	CacheStale = "stale"
+	CacheMiss  = "miss"
+)
+
+// Upstream call outcomes, mapped from the apperrors values.
+const (
+	OutcomeOK              = "ok"
+	OutcomeTooManyRequests = "too_many_requests"
+	OutcomeNotFound        = "not_found"
+	OutcomeInvalidAppID    = "invalid_app_id"
+	OutcomeInternalError   = "internal_error"
+	OutcomeCanceled        = "canceled"
+	OutcomeError           = "error"
+)
+
+type Metrics struct {
+	registry        *prometheus.Registry
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	upstream        *prometheus.CounterVec
+	upstreamLatency *prometheus.HistogramVec
+	cache           *prometheus.CounterVec
+	temp            *prometheus.CounterVec
+	wind            *prometheus.CounterVec
+}
+
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by route template, method and status code.",
+		}, []string{"route", "method", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route template, method and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "code"}),
+		upstream: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "upstream_requests_total",
+			Help:      "Upstream weather calls by provider and outcome.",
+		}, []string{"provider", "outcome"}),
+		upstreamLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "upstream_request_duration_seconds",
+			Help:      "Upstream weather call latency by provider and outcome.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"provider", "outcome"}),
+		cache: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cache_lookups_total",
+			Help:      "Weather cache lookups by result: hit, stale or miss.",
+		}, []string{"result"}),
+		temp: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "temperature_classifications_total",
+			Help:      "Temperature classifications returned to callers.",
+		}, []string{"temp"}),
+		wind: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "wind_classifications_total",
+			Help:      "Wind classifications returned to callers.",
+		}, []string{"wind"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.upstream,
+		m.upstreamLatency,
+		m.cache,
+		m.temp,
+		m.wind,
+	)
+	return m
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	if m == nil {
+		return http.NotFoundHandler()
+	}
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveUpstream records one upstream call and how long it took.
+func (m *Metrics) ObserveUpstream(provider string, d time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	outcome := Outcome(err)
+	m.upstream.WithLabelValues(provider, outcome).Inc()
+	m.upstreamLatency.WithLabelValues(provider, outcome).Observe(d.Seconds())
+}
+
+// ObserveCache records the result of one cache lookup.
+func (m *Metrics) ObserveCache(result string) {
+	if m == nil {
+		return
+	}
+	m.cache.WithLabelValues(result).Inc()
+}
+
+// ObserveCondition records the classifications returned to a caller.
+func (m *Metrics) ObserveCondition(temp, wind string) {
+	if m == nil {
+		return
+	}
+	m.temp.WithLabelValues(temp).Inc()
+	m.wind.WithLabelValues(wind).Inc()
+}
+
+// WatchBudget exports the upstream budget consumption reported by usage on every scrape.
+func (m *Metrics) WatchBudget(usage func() []budget.Usage) {
+	if m == nil {
+		return
+	}
+	m.registry.MustRegister(newBudgetCollector(usage))
+}
+
+// WatchKeys exports the per app id usage reported by usage on every scrape.
+func (m *Metrics) WatchKeys(usage func() []openweather.KeyUsage) {
+	if m == nil {
+		return
+	}
+	m.registry.MustRegister(newKeyCollector(usage))
+}
+
+// Outcome maps an upstream error onto its outcome label. Transport failures are plain errors.
+func Outcome(err error) string {
+	switch {
+	case err == nil:
+		return OutcomeOK
+	case errors.Is(err, apperrors.ErrTooManyRequests):
+		return OutcomeTooManyRequests
+	case errors.Is(err, apperrors.ErrNotFound):
+		return OutcomeNotFound
+	case errors.Is(err, apperrors.ErrInvalidOWMAppID):
+		return OutcomeInvalidAppID
+	case errors.Is(err, apperrors.ErrInternalServiceError):
+		return OutcomeInternalError
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return OutcomeCanceled
+	default:
+		return OutcomeError
+	}
+}
+
+// budgetCollector reads the budget usage at scrape time rather than mirroring it into gauges.
+type budgetCollector struct {
+	usage   func() []budget.Usage
+	used    *prometheus.Desc
+	limit   *prometheus.Desc
+	refused *prometheus.Desc
+}
+
+func newBudgetCollector(usage func() []budget.Usage) *budgetCollector {
+	return &budgetCollector{
+		usage: usage,
+		used: prometheus.NewDesc(prometheus.BuildFQName(namespace, "upstream_budget", "used"),
+			"Upstream calls counted against the budget in the current window.", []string{"window"}, nil),
+		limit: prometheus.NewDesc(prometheus.BuildFQName(namespace, "upstream_budget", "limit"),
+			"Upstream call budget for the window; 0 is unlimited.", []string{"window"}, nil),
+		refused: prometheus.NewDesc(prometheus.BuildFQName(namespace, "upstream_budget", "refused"),
+			"Upstream calls refused in the current window.", []string{"window"}, nil),
+	}
+}
+
+func (c *budgetCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.used
+	ch <- c.limit
+	ch <- c.refused
+}
+
+func (c *budgetCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, u := range c.usage() {
+		ch <- prometheus.MustNewConstMetric(c.used, prometheus.GaugeValue, float64(u.Used), u.Window)
+		ch <- prometheus.MustNewConstMetric(c.limit, prometheus.GaugeValue, float64(u.Limit), u.Window)
+		ch <- prometheus.MustNewConstMetric(c.refused, prometheus.GaugeValue, float64(u.Refused), u.Window)
+	}
+}
+
+// keyCollector reads the app id usage at scrape time, like budgetCollector.
+type keyCollector struct {
+	usage       func() []openweather.KeyUsage
+	requests    *prometheus.Desc
+	rateLimited *prometheus.Desc
+	rejected    *prometheus.Desc
+	state       *prometheus.Desc
+}
+
+func newKeyCollector(usage func() []openweather.KeyUsage) *keyCollector {
+	return &keyCollector{
+		usage: usage,
+		requests: prometheus.NewDesc(prometheus.BuildFQName(namespace, "upstream_key", "requests_total"),
+			"Upstream calls made with each app id, named by its last four characters and #n when those repeat.", []string{"key"}, nil),
+		rateLimited: prometheus.NewDesc(prometheus.BuildFQName(namespace, "upstream_key", "rate_limited_total"),
+			"Upstream calls rate limited for each app id.", []string{"key"}, nil),
+		rejected: prometheus.NewDesc(prometheus.BuildFQName(namespace, "upstream_key", "rejected_total"),
+			"Upstream calls rejected for an invalid app id.", []string{"key"}, nil),
+		state: prometheus.NewDesc(prometheus.BuildFQName(namespace, "upstream_key", "state"),
+			"1 for the state each app id is in: active, cooling_down or invalid.", []string{"key", "state"}, nil),
+	}
+}
+
+func (c *keyCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.requests
+	ch <- c.rateLimited
+	ch <- c.rejected
+	ch <- c.state
+}
+
+func (c *keyCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, u := range c.usage() {
+		ch <- prometheus.MustNewConstMetric(c.requests, prometheus.CounterValue, float64(u.Requests), u.Key)
+		ch <- prometheus.MustNewConstMetric(c.rateLimited, prometheus.CounterValue, float64(u.RateLimited), u.Key)
+		ch <- prometheus.MustNewConstMetric(c.rejected, prometheus.CounterValue, float64(u.Rejected), u.Key)
+		for _, state := range []string{openweather.KeyActive, openweather.KeyCoolingDown, openweather.KeyInvalid} {
+			v := 0.0
+			if u.State == state {
+				v = 1
+			}
+			ch <- prometheus.MustNewConstMetric(c.state, prometheus.GaugeValue, v, u.Key, state)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	apperrors "weathersvc/app/app_errors"
+	"weathersvc/app/budget"
+	"weathersvc/app/config"
+	openweather "weathersvc/app/open_weather"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+	rr := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	body, err := io.ReadAll(rr.Body)
+	assert.NoError(t, err)
+	return string(body)
+}
+
+func TestMetrics_Handler(t *testing.T) {
+	m := NewMetrics()
+	m.ObserveUpstream("openweathermap", 20*time.Millisecond, nil)
+	m.ObserveUpstream("openweathermap", time.Millisecond, apperrors.ErrTooManyRequests)
+	m.ObserveCache(CacheHit)
+	m.ObserveCache(CacheMiss)
+	m.ObserveCondition("hot", "calm winds")
+	m.WatchBudget(func() []budget.Usage {
+		return []budget.Usage{{Window: budget.WindowMinute, Used: 3, Limit: 60, Refused: 1}}
+	})
+	m.WatchKeys(func() []openweather.KeyUsage {
+		return []openweather.KeyUsage{{Key: "****abcd", Weight: 1, State: openweather.KeyCoolingDown, Requests: 7, RateLimited: 2}}
+	})
+	body := scrape(t, m)
+	t.Run("Should export upstream calls by provider and outcome", func(t *testing.T) {
+		assert.Contains(t, body, `weathersvc_upstream_requests_total{outcome="ok",provider="openweathermap"} 1`)
+		assert.Contains(t, body, `weathersvc_upstream_requests_total{outcome="too_many_requests",provider="openweathermap"} 1`)
+		assert.Contains(t, body, `weathersvc_upstream_request_duration_seconds_count{outcome="ok",provider="openweathermap"} 1`)
+	})
+	t.Run("Should export cache lookups and classifications", func(t *testing.T) {
+		assert.Contains(t, body, `weathersvc_cache_lookups_total{result="hit"} 1`)
+		assert.Contains(t, body, `weathersvc_cache_lookups_total{result="miss"} 1`)
+		assert.Contains(t, body, `weathersvc_temperature_classifications_total{temp="hot"} 1`)
+		assert.Contains(t, body, `weathersvc_wind_classifications_total{wind="calm winds"} 1`)
+	})
+	t.Run("Should export budget usage at scrape time", func(t *testing.T) {
+		assert.Contains(t, body, `weathersvc_upstream_budget_used{window="minute"} 3`)
+		assert.Contains(t, body, `weathersvc_upstream_budget_limit{window="minute"} 60`)
+		assert.Contains(t, body, `weathersvc_upstream_budget_refused{window="minute"} 1`)
+	})
+	t.Run("Should export app id usage at scrape time", func(t *testing.T) {
+		assert.Contains(t, body, `weathersvc_upstream_key_requests_total{key="****abcd"} 7`)
+		assert.Contains(t, body, `weathersvc_upstream_key_rate_limited_total{key="****abcd"} 2`)
+		assert.Contains(t, body, `weathersvc_upstream_key_rejected_total{key="****abcd"} 0`)
+		assert.Contains(t, body, `weathersvc_upstream_key_state{key="****abcd",state="cooling_down"} 1`)
+		assert.Contains(t, body, `weathersvc_upstream_key_state{key="****abcd",state="active"} 0`)
+	})
+	t.Run("Should export app ids whose last characters collide", func(t *testing.T) {
+		c := openweather.NewClient(&config.App{
+			WeatherClientConfig: config.WeatherClientConfig{
+				AppID:  "one-abcd",
+				AppIDs: []config.AppKey{{ID: "two-abcd", Weight: 1}, {ID: "one-abcd", Weight: 1}, {ID: "ab", Weight: 1}, {ID: "cd", Weight: 1}},
+			},
+		})
+		m := NewMetrics()
+		m.WatchKeys(c.Keys)
+		body := scrape(t, m)
+		assert.Contains(t, body, `weathersvc_upstream_key_requests_total{key="****abcd"} 0`)
+		assert.Contains(t, body, `weathersvc_upstream_key_requests_total{key="****abcd#2"} 0`)
+		assert.NotContains(t, body, `key="****abcd#3"`)
+		assert.Contains(t, body, `weathersvc_upstream_key_requests_total{key="****"} 0`)
+		assert.Contains(t, body, `weathersvc_upstream_key_requests_total{key="****#2"} 0`)
+	})
+	t.Run("Should export Go runtime metrics", func(t *testing.T) {
+		assert.Contains(t, body, "go_goroutines")
+	})
+}
+
+func TestMetrics_Middleware(t *testing.T) {
+	m := NewMetrics()
+	r := mux.NewRouter()
+	r.Use(m.Middleware())
+	r.HandleFunc("/alerts/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	for _, id := range []string{"a", "b"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/alerts/"+id, nil))
+	}
+	t.Run("Should label requests by route template, method and status", func(t *testing.T) {
+		body := scrape(t, m)
+		assert.Contains(t, body, `weathersvc_http_requests_total{code="404",method="get",route="/alerts/{id}"} 2`)
+		assert.Contains(t, body, `weathersvc_http_request_duration_seconds_count{code="404",method="get",route="/alerts/{id}"} 2`)
+	})
+	t.Run("Should pass requests through without metrics", func(t *testing.T) {
+		var none *Metrics
+		rr := httptest.NewRecorder()
+		none.Middleware()(http.NotFoundHandler()).ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+		none.ObserveCache(CacheHit)
+	})
+}
+
+func TestMetrics_Outcome(t *testing.T) {
+	t.Run("Should map upstream errors onto outcomes", func(t *testing.T) {
+		for err, want := range map[error]string{
+			nil:                               OutcomeOK,
+			apperrors.ErrTooManyRequests:      OutcomeTooManyRequests,
+			apperrors.ErrNotFound:             OutcomeNotFound,
+			apperrors.ErrInvalidOWMAppID:      OutcomeInvalidAppID,
+			apperrors.ErrInternalServiceError: OutcomeInternalError,
+			context.DeadlineExceeded:          OutcomeCanceled,
+			errors.New("connection refused"):  OutcomeError,
+		} {
+			assert.Equal(t, want, Outcome(err))
+		}
+	})
+}
@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// unmatchedRoute labels requests that did not match a route, so unknown paths cannot grow the label set.
+const unmatchedRoute = "unmatched"
+
+// Middleware counts and times requests by mux route template, method and status code. The wrapped
+// ResponseWriter keeps the Flusher and Hijacker interfaces, so streams and websockets still work.
+func (m *Metrics) Middleware() mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		if m == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			labels := prometheus.Labels{"route": Route(r)}
+			h := promhttp.InstrumentHandlerDuration(m.requestDuration.MustCurryWith(labels),
+				promhttp.InstrumentHandlerCounter(m.requests.MustCurryWith(labels), next))
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Route returns the template of the mux route that matched r.
+func Route(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return unmatchedRoute
+	}
+	if tmpl, err := route.GetPathTemplate(); err == nil {
+		return tmpl
+	}
+	return unmatchedRoute
+}
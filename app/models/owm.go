@@ -9,7 +9,11 @@ type WeatherResponse struct {
 	Weather []Weather `json:"weather"`
 	Main    Main      `json:"main"`
 	Wind    Wind      `json:"wind"`
-	Cod     int       `json:"cod"`
+	// Dt is the unix time the upstream took the observation.
+	Dt  int64 `json:"dt"`
+	Cod int   `json:"cod"`
+	// Name is the upstream's name for the place nearest the coordinates, if it knows one.
+	Name string `json:"name"`
 }
 
 type Weather struct {
@@ -23,3 +27,38 @@ type Main struct {
 type Wind struct {
 	Speed float64 `json:"speed"`
 }
+
+// ForecastResponse is the 5 day forecast in 3 hour steps.
+type ForecastResponse struct {
+	List []ForecastItem `json:"list"`
+	City City           `json:"city"`
+}
+
+type ForecastItem struct {
+	// Dt is the unix time the step forecasts.
+	Dt      int64     `json:"dt"`
+	Weather []Weather `json:"weather"`
+	Main    Main      `json:"main"`
+	Wind    Wind      `json:"wind"`
+	// Pop is the probability of precipitation, from 0 to 1.
+	Pop float64 `json:"pop"`
+}
+
+type City struct {
+	Name string `json:"name"`
+}
+
+// AirPollutionResponse is the current air quality; List holds a single entry.
+type AirPollutionResponse struct {
+	List []AirPollutionItem `json:"list"`
+}
+
+type AirPollutionItem struct {
+	Dt   int64 `json:"dt"`
+	Main struct {
+		// Aqi is the air quality index from 1, good, to 5, very poor.
+		Aqi int `json:"aqi"`
+	} `json:"main"`
+	// Components are concentrations in μg/m3, e.g. `pm2_5`.
+	Components map[string]float64 `json:"components"`
+}
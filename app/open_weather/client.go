@@ -1,94 +1,198 @@
 package openweather
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"net/url"
 	apperrors "weathersvc/app/app_errors"
 	"weathersvc/app/config"
+	"weathersvc/app/logging"
 	"weathersvc/app/models"
-
-	"github.com/opentracing-contrib/go-stdlib/nethttp"
+	"weathersvc/app/tracing"
 )
 
 type Client interface {
-	ApiTest() error
-	GetWeather(lat, lon string) (*models.WeatherResponse, error)
+	ApiTest(ctx context.Context) error
+	GetWeather(ctx context.Context, lat, lon string) (*models.WeatherResponse, error)
+	// GetForecast and GetAirPollution count as upstream calls as GetWeather does
+	GetForecast(ctx context.Context, lat, lon string) (*models.ForecastResponse, error)
+	GetAirPollution(ctx context.Context, lat, lon string) (*models.AirPollutionResponse, error)
+	// Keys reports how each app id has been used
+	Keys() []KeyUsage
 }
 type client struct {
 	client *http.Client
 	host   string
-	appId  string
+	// keys spreads calls over the app ids, any of which may be rotated while the service runs.
+	keys *keyPool
 }
 
 func NewClient(conf *config.App) Client {
 	httpClient := &http.Client{
-		Transport: &nethttp.Transport{},
+		Transport: tracing.Transport(nil),
 	}
 	return &client{
 		client: httpClient,
 		host:   conf.WeatherClientConfig.Host,
-		appId:  conf.WeatherClientConfig.AppID,
+		keys:   newKeyPool(conf.WeatherClientConfig),
 	}
 }
 
-func (c *client) ApiTest() error {
-	_, err := c.GetWeather("0", "0")
+func (c *client) Keys() []KeyUsage {
+	return c.keys.usage()
+}
+
+func (c *client) ApiTest(ctx context.Context) error {
+	_, err := c.GetWeather(ctx, "0", "0")
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-// GetWeather takes in string: latitude longitude
-func (c *client) GetWeather(lat, long string) (*models.WeatherResponse, error) {
+// GetWeather takes in ctx, string: latitude longitude. A call that is rate limited or rejected for its
+// app id is retried with the next key in rotation.
+func (c *client) GetWeather(ctx context.Context, lat, long string) (*models.WeatherResponse, error) {
+	var data *models.WeatherResponse
+	err := c.rotate(func(appID string) (err error) {
+		data, err = c.getWeather(ctx, lat, long, appID)
+		return err
+	})
+	return data, err
+}
+
+// GetForecast returns the 5 day forecast in 3 hour steps from the forecast endpoint beside the
+// configured weather endpoint.
+func (c *client) GetForecast(ctx context.Context, lat, long string) (*models.ForecastResponse, error) {
+	var data *models.ForecastResponse
+	err := c.rotate(func(appID string) error {
+		return c.get(ctx, "forecast", lat, long, appID, &data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// GetAirPollution returns the current air quality from the air pollution endpoint beside the
+// configured weather endpoint.
+func (c *client) GetAirPollution(ctx context.Context, lat, long string) (*models.AirPollutionResponse, error) {
+	var data *models.AirPollutionResponse
+	err := c.rotate(func(appID string) error {
+		return c.get(ctx, "air_pollution", lat, long, appID, &data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// rotate calls call with the next app id in rotation until it is neither rate limited nor rejected,
+// or every key has been tried.
+func (c *client) rotate(call func(appID string) error) error {
+	tried := map[*key]bool{}
+	for {
+		k, err := c.keys.next(tried)
+		if err != nil {
+			return err
+		}
+		id := k.id()
+		err = call(id)
+		c.keys.report(k, id, err)
+		if !errors.Is(err, apperrors.ErrTooManyRequests) && !errors.Is(err, apperrors.ErrInvalidOWMAppID) {
+			return err
+		}
+		tried[k] = true
+	}
+}
+
+func (c *client) getWeather(ctx context.Context, lat, long, appID string) (*models.WeatherResponse, error) {
+	u, err := url.Parse(c.host)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing host: %w", err)
+	}
+	var data *models.WeatherResponse
+	if _, err := c.send(ctx, u, lat, long, appID, &data); err != nil {
+		return nil, err
+	}
+	if err := codeError(data.Cod); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// get calls the upstream endpoint named, e.g. `forecast`, in the same directory as the weather
+// endpoint. Unlike the weather endpoint, these do not all report their status in the body.
+func (c *client) get(ctx context.Context, endpoint, lat, long, appID string, out any) error {
 	u, err := url.Parse(c.host)
 	if err != nil {
-		return nil, nil
+		return fmt.Errorf("error parsing host: %w", err)
 	}
+	u = u.ResolveReference(&url.URL{Path: endpoint})
+	status, err := c.send(ctx, u, lat, long, appID, out)
+	if status == http.StatusOK || status == 0 {
+		return err
+	}
+	// error bodies do not match out, so the status alone is reported
+	return codeError(status)
+}
+
+// send calls u for the coordinates and decodes the JSON body into out, returning the HTTP status.
+func (c *client) send(ctx context.Context, u *url.URL, lat, long, appID string, out any) (int, error) {
 	query := url.Values{}
 	query.Add("lat", lat)
 	query.Add("lon", long)
 	query.Add("units", "imperial")
-	query.Add("appid", c.appId)
+	query.Add("appid", appID)
 	u.RawQuery = query.Encode()
 	// Create the HTTP request
-	req, err := http.NewRequest("GET", u.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
 	if err != nil {
-		return nil, fmt.Errorf("error creating request: %v", err)
+		return 0, fmt.Errorf("error creating request: %v", err)
 	}
 	// Set headers if necessary
 	req.Header.Set("Content-Type", "application/json")
+	logger := logging.FromContext(ctx)
+	logger.Debug("calling upstream", "url", logging.RedactURL(u.String()))
 	// Send the request
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("error sending request: %v", err)
+		// the transport error quotes the request URL, app id included
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			uerr.URL = logging.RedactURL(uerr.URL)
+		}
+		return 0, fmt.Errorf("error sending request: %w", err)
 	}
 	defer resp.Body.Close()
 	// Read the response body
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("error reading response: %v", err)
+		return resp.StatusCode, fmt.Errorf("error reading response: %v", err)
 	}
-	var data *models.WeatherResponse
-	err = json.Unmarshal(body, &data)
-	if err != nil {
-		log.Printf("error on Unmarshall: %v", err)
-		return nil, fmt.Errorf("error unmarshalling response: %v", err)
+	if err := json.Unmarshal(body, out); err != nil {
+		logger.Warn("upstream response is not valid JSON", "status", resp.StatusCode, "bytes", len(body), "error", err)
+		return resp.StatusCode, fmt.Errorf("error unmarshalling response: %v", err)
 	}
-	switch data.Cod {
+	return resp.StatusCode, nil
+}
+
+// codeError maps an upstream status code onto the matching apperrors sentinel, or nil for 200.
+func codeError(code int) error {
+	switch code {
 	case 200:
-		return data, nil
+		return nil
 	case 401:
-		return nil, apperrors.ErrInvalidOWMAppID
+		return apperrors.ErrInvalidOWMAppID
 	case 404:
-		return nil, apperrors.ErrNotFound
+		return apperrors.ErrNotFound
 	case 429:
-		return nil, apperrors.ErrTooManyRequests
+		return apperrors.ErrTooManyRequests
 	default:
-		return nil, apperrors.ErrInternalServiceError
+		return apperrors.ErrInternalServiceError
 	}
 }
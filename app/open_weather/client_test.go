@@ -1,13 +1,19 @@
 package openweather
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+	apperrors "weathersvc/app/app_errors"
 	"weathersvc/app/config"
+	"weathersvc/app/secrets"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type responseWriter struct {
@@ -38,8 +44,16 @@ func Test_GetWeather(t *testing.T) {
 		}))
 		defer testServer.Close()
 		owmClient := NewClient(conf)
-		resp, err := owmClient.GetWeather("0", "0")
-		assert.EqualError(t, err, "error sending request: Get \"fake?appid=fakefake&lat=0&lon=0&units=imperial\": unsupported protocol scheme \"\"")
+		resp, err := owmClient.GetWeather(context.Background(), "0", "0")
+		assert.EqualError(t, err, "error sending request: Get \"fake?appid=REDACTED&lat=0&lon=0&units=imperial\": unsupported protocol scheme \"\"")
+		assert.Nil(t, resp)
+	})
+	t.Run("Should fail to get weather for a host that does not parse", func(t *testing.T) {
+		owmClient := NewClient(&config.App{
+			WeatherClientConfig: config.WeatherClientConfig{Host: "http://bad host", AppID: "fakefake"},
+		})
+		resp, err := owmClient.GetWeather(context.Background(), "0", "0")
+		assert.ErrorContains(t, err, "error parsing host")
 		assert.Nil(t, resp)
 	})
 	t.Run("Should return 401", func(t *testing.T) {
@@ -60,7 +74,7 @@ func Test_GetWeather(t *testing.T) {
 		defer testServer.Close()
 		conf.WeatherClientConfig.Host = testServer.URL
 		owmClient := NewClient(conf)
-		resp, err := owmClient.GetWeather("0", "0")
+		resp, err := owmClient.GetWeather(context.Background(), "0", "0")
 		assert.EqualError(t, err, "config `WEATHER_ID` is invalid")
 		assert.Nil(t, resp)
 	})
@@ -82,7 +96,7 @@ func Test_GetWeather(t *testing.T) {
 		defer testServer.Close()
 		conf.WeatherClientConfig.Host = testServer.URL
 		owmClient := NewClient(conf)
-		resp, err := owmClient.GetWeather("0", "0")
+		resp, err := owmClient.GetWeather(context.Background(), "0", "0")
 		assert.EqualError(t, err, "too many requests; limit reached")
 		assert.Nil(t, resp)
 	})
@@ -104,7 +118,7 @@ func Test_GetWeather(t *testing.T) {
 		defer testServer.Close()
 		conf.WeatherClientConfig.Host = testServer.URL
 		owmClient := NewClient(conf)
-		resp, err := owmClient.GetWeather("0", "0")
+		resp, err := owmClient.GetWeather(context.Background(), "0", "0")
 		assert.EqualError(t, err, "weather for coordinates not found")
 		assert.Nil(t, resp)
 	})
@@ -126,7 +140,7 @@ func Test_GetWeather(t *testing.T) {
 		defer testServer.Close()
 		conf.WeatherClientConfig.Host = testServer.URL
 		owmClient := NewClient(conf)
-		resp, err := owmClient.GetWeather("0", "0")
+		resp, err := owmClient.GetWeather(context.Background(), "0", "0")
 		assert.EqualError(t, err, "internal service error")
 		assert.Nil(t, resp)
 	})
@@ -148,7 +162,7 @@ func Test_GetWeather(t *testing.T) {
 		defer testServer.Close()
 		conf.WeatherClientConfig.Host = testServer.URL
 		owmClient := NewClient(conf)
-		resp, err := owmClient.GetWeather("0", "0")
+		resp, err := owmClient.GetWeather(context.Background(), "0", "0")
 		assert.EqualError(t, err, "error unmarshalling response: invalid character 'o' looking for beginning of value")
 		assert.Nil(t, resp)
 	})
@@ -182,7 +196,7 @@ func Test_GetWeather(t *testing.T) {
 		defer testServer.Close()
 		conf.WeatherClientConfig.Host = testServer.URL
 		owmClient := NewClient(conf)
-		resp, err := owmClient.GetWeather("0", "0")
+		resp, err := owmClient.GetWeather(context.Background(), "0", "0")
 		assert.Nil(t, resp)
 		assert.EqualError(t, err, "error reading response: unexpected EOF")
 	})
@@ -204,10 +218,36 @@ func Test_GetWeather(t *testing.T) {
 		defer testServer.Close()
 		conf.WeatherClientConfig.Host = testServer.URL
 		owmClient := NewClient(conf)
-		resp, err := owmClient.GetWeather("0", "0")
+		resp, err := owmClient.GetWeather(context.Background(), "0", "0")
 		assert.NoError(t, err)
 		assert.NotNil(t, resp)
 	})
+	t.Run("Should send the rotated app id", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "app_id")
+		require.NoError(t, os.WriteFile(path, []byte("first\n"), 0o600))
+		secret, err := secrets.New(context.Background(), "WEATHER_ID", secrets.NewFileProvider(), path)
+		require.NoError(t, err)
+		var got []string
+		testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			got = append(got, req.URL.Query().Get("appid"))
+			res.Write([]byte(`{"cod": 200}`))
+		}))
+		defer testServer.Close()
+		owmClient := NewClient(&config.App{
+			WeatherClientConfig: config.WeatherClientConfig{
+				Host:        testServer.URL,
+				AppID:       secret.Value(),
+				AppIDSecret: secret,
+			},
+		})
+		_, err = owmClient.GetWeather(context.Background(), "0", "0")
+		assert.NoError(t, err)
+		require.NoError(t, os.WriteFile(path, []byte("second\n"), 0o600))
+		require.NoError(t, secret.Refresh(context.Background()))
+		_, err = owmClient.GetWeather(context.Background(), "0", "0")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"first", "second"}, got)
+	})
 }
 
 func Test_ApiTest(t *testing.T) {
@@ -229,7 +269,7 @@ func Test_ApiTest(t *testing.T) {
 		defer testServer.Close()
 		conf.WeatherClientConfig.Host = testServer.URL
 		owmClient := NewClient(conf)
-		err := owmClient.ApiTest()
+		err := owmClient.ApiTest(context.Background())
 		assert.EqualError(t, err, "config `WEATHER_ID` is invalid")
 	})
 
@@ -251,8 +291,50 @@ func Test_ApiTest(t *testing.T) {
 		defer testServer.Close()
 		conf.WeatherClientConfig.Host = testServer.URL
 		owmClient := NewClient(conf)
-		err := owmClient.ApiTest()
+		err := owmClient.ApiTest(context.Background())
 		assert.NoError(t, err)
 	})
 
 }
+
+func Test_GetForecastAndAirPollution(t *testing.T) {
+	var paths []string
+	status := http.StatusOK
+	testServer := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		paths = append(paths, req.URL.Path)
+		res.WriteHeader(status)
+		switch {
+		case status != http.StatusOK:
+			res.Write([]byte(`{"cod": 401, "message": "Invalid API key."}`))
+		case req.URL.Path == "/data/2.5/forecast":
+			res.Write([]byte(`{"cod":"200","list":[{"dt":1720198800,"main":{"feels_like":80.5},"weather":[{"description":"light rain"}],"wind":{"speed":5},"pop":0.4}],"city":{"name":"Dallas"}}`))
+		default:
+			res.Write([]byte(`{"list":[{"dt":1720197314,"main":{"aqi":2},"components":{"pm2_5":8.1,"o3":60}}]}`))
+		}
+	}))
+	defer testServer.Close()
+	owmClient := NewClient(&config.App{
+		WeatherClientConfig: config.WeatherClientConfig{Host: testServer.URL + "/data/2.5/weather", AppID: "fakefake"},
+	})
+	t.Run("Should call the endpoints beside the weather endpoint", func(t *testing.T) {
+		forecast, err := owmClient.GetForecast(context.Background(), "0", "0")
+		require.NoError(t, err)
+		assert.Equal(t, "Dallas", forecast.City.Name)
+		if assert.Len(t, forecast.List, 1) {
+			assert.Equal(t, 0.4, forecast.List[0].Pop)
+		}
+		air, err := owmClient.GetAirPollution(context.Background(), "0", "0")
+		require.NoError(t, err)
+		if assert.Len(t, air.List, 1) {
+			assert.Equal(t, 2, air.List[0].Main.Aqi)
+			assert.Equal(t, 8.1, air.List[0].Components["pm2_5"])
+		}
+		assert.Equal(t, []string{"/data/2.5/forecast", "/data/2.5/air_pollution"}, paths)
+	})
+	t.Run("Should map the status of error responses", func(t *testing.T) {
+		status = http.StatusUnauthorized
+		forecast, err := owmClient.GetForecast(context.Background(), "0", "0")
+		assert.ErrorIs(t, err, apperrors.ErrInvalidOWMAppID)
+		assert.Nil(t, forecast)
+	})
+}
@@ -0,0 +1,233 @@
+/*
+keys.go: The pool of app ids upstream calls are spread over. Keys are picked by smooth weighted
+round-robin, so keys of equal weight simply take turns. A key that is rate limited sits out a cooldown,
+and a key that is rejected as invalid is left out until its value changes, e.g. by rotation. A list of
+app ids set by reference is rotated as a whole: keys still listed keep their state, the rest are dropped.
+*/
+package openweather
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+	apperrors "weathersvc/app/app_errors"
+	"weathersvc/app/config"
+	"weathersvc/app/secrets"
+)
+
+// Key states reported by KeyUsage.
+const (
+	KeyActive      = "active"
+	KeyCoolingDown = "cooling_down"
+	KeyInvalid     = "invalid"
+)
+
+// KeyUsage reports how one app id has been used. The id itself is never reported.
+type KeyUsage struct {
+	// Key names the app id by its last four characters, followed by #2, #3 and so on for each later
+	// key ending in the same characters.
+	Key         string `json:"key"`
+	Weight      int    `json:"weight"`
+	State       string `json:"state"`
+	Requests    int64  `json:"requests"`
+	RateLimited int64  `json:"rate_limited"`
+	Rejected    int64  `json:"rejected"`
+}
+
+type key struct {
+	id     func() string
+	weight int
+	// current is the key's running score for smooth weighted round-robin.
+	current int
+	// until is when a rate limited key rejoins the rotation.
+	until time.Time
+	// invalid is the id value that was rejected; the key rejoins once its id changes.
+	invalid     string
+	requests    int64
+	rateLimited int64
+	rejected    int64
+}
+
+type keyPool struct {
+	mu   sync.Mutex
+	keys []*key
+	// listed is where the keys from the list of app ids start in keys.
+	listed int
+	// listSecret re-reads the list of app ids when it is set by reference; list is its value as last applied.
+	listSecret *secrets.Secret
+	list       string
+	cooldown   time.Duration
+	now        func() time.Time
+}
+
+// newKeyPool builds the pool from the app id and any more app ids, either of which may be rotated by
+// its Secret.
+func newKeyPool(conf config.WeatherClientConfig) *keyPool {
+	p := &keyPool{cooldown: conf.KeyCooldown, now: time.Now, listSecret: conf.AppIDsSecret}
+	if p.cooldown == 0 {
+		p.cooldown = config.DefaultKeyCooldown
+	}
+	if conf.AppIDSecret != nil {
+		p.keys = append(p.keys, &key{id: conf.AppIDSecret.Value, weight: 1})
+	} else if conf.AppID != "" {
+		appID := conf.AppID
+		p.keys = append(p.keys, &key{id: func() string { return appID }, weight: 1})
+	}
+	p.listed = len(p.keys)
+	p.setList(conf.AppIDs)
+	if p.listSecret != nil {
+		p.list = p.listSecret.Value()
+	}
+	return p
+}
+
+// setList replaces the keys from the list of app ids with keys, keeping the state of those still listed.
+// An id listed twice, or already set as the app id, is only used once.
+func (p *keyPool) setList(keys []config.AppKey) {
+	was := make(map[string]*key, len(p.keys)-p.listed)
+	for _, k := range p.keys[p.listed:] {
+		was[k.id()] = k
+	}
+	seen := make(map[string]bool, p.listed+len(keys))
+	for _, k := range p.keys[:p.listed] {
+		seen[k.id()] = true
+	}
+	// capped so appending copies the app id's key rather than writing over the old list
+	list := p.keys[:p.listed:p.listed]
+	for _, ak := range keys {
+		if seen[ak.ID] {
+			slog.Warn("upstream app id listed more than once, using it once", "key", fingerprint(ak.ID))
+			continue
+		}
+		seen[ak.ID] = true
+		k, ok := was[ak.ID]
+		if ok {
+			delete(was, ak.ID)
+		} else {
+			id := ak.ID
+			k = &key{id: func() string { return id }}
+		}
+		k.weight = max(ak.Weight, 1)
+		list = append(list, k)
+	}
+	p.keys = list
+}
+
+// refresh applies the list of app ids as last read by its Secret, if it changed. A list that does not
+// parse is logged and the keys in use are kept.
+func (p *keyPool) refresh() {
+	if p.listSecret == nil {
+		return
+	}
+	v := p.listSecret.Value()
+	if v == p.list {
+		return
+	}
+	p.list = v
+	keys, err := config.ParseAppKeys(v)
+	if err != nil {
+		slog.Error("rotated upstream app ids are invalid, keeping the ones in use", "error", err)
+		return
+	}
+	p.setList(keys)
+	slog.Info("upstream app ids rotated", "keys", len(keys))
+}
+
+// state reports whether k is in rotation, and why not when it is not.
+func (p *keyPool) state(k *key, now time.Time) string {
+	switch {
+	case k.invalid != "" && k.invalid == k.id():
+		return KeyInvalid
+	case now.Before(k.until):
+		return KeyCoolingDown
+	default:
+		return KeyActive
+	}
+}
+
+// next picks the key for the next call, skipping those already tried for it. The error explains why
+// no key is left: ErrInvalidOWMAppID when every key was rejected, ErrTooManyRequests otherwise.
+func (p *keyPool) next(tried map[*key]bool) (*key, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.refresh()
+	now := p.now()
+	var picked *key
+	total := 0
+	invalid := true
+	for _, k := range p.keys {
+		state := p.state(k, now)
+		if state != KeyInvalid {
+			invalid = false
+		}
+		if state != KeyActive || tried[k] {
+			continue
+		}
+		k.current += k.weight
+		total += k.weight
+		if picked == nil || k.current > picked.current {
+			picked = k
+		}
+	}
+	if picked == nil {
+		if invalid {
+			return nil, apperrors.ErrInvalidOWMAppID
+		}
+		return nil, apperrors.ErrTooManyRequests
+	}
+	picked.current -= total
+	picked.requests++
+	return picked, nil
+}
+
+// report takes k out of rotation when the upstream rate limited or rejected it.
+func (p *keyPool) report(k *key, id string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch {
+	case errors.Is(err, apperrors.ErrTooManyRequests):
+		k.rateLimited++
+		k.until = p.now().Add(p.cooldown)
+		slog.Warn("upstream app id rate limited, leaving it out of rotation", "key", fingerprint(id), "cooldown", p.cooldown)
+	case errors.Is(err, apperrors.ErrInvalidOWMAppID):
+		k.rejected++
+		k.invalid = id
+		slog.Error("upstream app id rejected as invalid, leaving it out of rotation", "key", fingerprint(id))
+	}
+}
+
+func (p *keyPool) usage() []KeyUsage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.refresh()
+	now := p.now()
+	usage := make([]KeyUsage, 0, len(p.keys))
+	// fingerprints are not unique, but metrics need each key named apart
+	named := make(map[string]int, len(p.keys))
+	for _, k := range p.keys {
+		name := fingerprint(k.id())
+		named[name]++
+		if n := named[name]; n > 1 {
+			name = fmt.Sprintf("%s#%d", name, n)
+		}
+		usage = append(usage, KeyUsage{
+			Key:         name,
+			Weight:      k.weight,
+			State:       p.state(k, now),
+			Requests:    k.requests,
+			RateLimited: k.rateLimited,
+			Rejected:    k.rejected,
+		})
+	}
+	return usage
+}
+
+// fingerprint names an app id by its last four characters, enough to tell keys apart without revealing them.
+func fingerprint(id string) string {
+	if len(id) <= 4 {
+		return "****"
+	}
+	return "****" + id[len(id)-4:]
+}
@@ -0,0 +1,168 @@
+package openweather
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+	"weathersvc/app/config"
+	"weathersvc/app/secrets"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newKeyServer stands in for the upstream, answering each app id with the code set for it, or 200.
+func newKeyServer(t *testing.T, codes map[string]int) (*httptest.Server, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var got []string
+	ts := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		id := req.URL.Query().Get("appid")
+		mu.Lock()
+		got = append(got, id)
+		cod, ok := codes[id]
+		mu.Unlock()
+		if !ok {
+			cod = 200
+		}
+		res.Write([]byte(fmt.Sprintf(`{"cod": %d}`, cod)))
+	}))
+	t.Cleanup(ts.Close)
+	return ts, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), got...)
+	}
+}
+
+func newKeyClient(host string, keys ...config.AppKey) *client {
+	return NewClient(&config.App{
+		WeatherClientConfig: config.WeatherClientConfig{
+			Host:        host,
+			AppID:       "key-0000",
+			AppIDs:      keys,
+			KeyCooldown: time.Minute,
+		},
+	}).(*client)
+}
+
+func Test_KeyPool(t *testing.T) {
+	ctx := context.Background()
+	t.Run("Should take turns with keys of equal weight", func(t *testing.T) {
+		ts, got := newKeyServer(t, nil)
+		c := newKeyClient(ts.URL, config.AppKey{ID: "key-1111", Weight: 1})
+		for i := 0; i < 4; i++ {
+			_, err := c.GetWeather(ctx, "0", "0")
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, []string{"key-0000", "key-1111", "key-0000", "key-1111"}, got())
+	})
+	t.Run("Should spread calls by weight", func(t *testing.T) {
+		ts, got := newKeyServer(t, nil)
+		c := newKeyClient(ts.URL, config.AppKey{ID: "key-1111", Weight: 3})
+		for i := 0; i < 8; i++ {
+			_, err := c.GetWeather(ctx, "0", "0")
+			assert.NoError(t, err)
+		}
+		counts := map[string]int{}
+		for _, id := range got() {
+			counts[id]++
+		}
+		assert.Equal(t, map[string]int{"key-0000": 2, "key-1111": 6}, counts)
+	})
+	t.Run("Should retry a rate limited call with the next key and cool the key down", func(t *testing.T) {
+		ts, got := newKeyServer(t, map[string]int{"key-0000": 429})
+		c := newKeyClient(ts.URL, config.AppKey{ID: "key-1111", Weight: 1})
+		now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+		c.keys.now = func() time.Time { return now }
+		for i := 0; i < 3; i++ {
+			_, err := c.GetWeather(ctx, "0", "0")
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, []string{"key-0000", "key-1111", "key-1111", "key-1111"}, got())
+		assert.Equal(t, KeyCoolingDown, c.Keys()[0].State)
+		now = now.Add(time.Minute)
+		assert.Equal(t, KeyActive, c.Keys()[0].State)
+	})
+	t.Run("Should leave out a rejected key for good", func(t *testing.T) {
+		ts, got := newKeyServer(t, map[string]int{"key-0000": 401})
+		c := newKeyClient(ts.URL, config.AppKey{ID: "key-1111", Weight: 1})
+		for i := 0; i < 3; i++ {
+			_, err := c.GetWeather(ctx, "0", "0")
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, []string{"key-0000", "key-1111", "key-1111", "key-1111"}, got())
+		assert.Equal(t, []KeyUsage{
+			{Key: "****0000", Weight: 1, State: KeyInvalid, Requests: 1, Rejected: 1},
+			{Key: "****1111", Weight: 1, State: KeyActive, Requests: 3},
+		}, c.Keys())
+	})
+	t.Run("Should fail once no key is left", func(t *testing.T) {
+		ts, got := newKeyServer(t, map[string]int{"key-0000": 401, "key-1111": 429})
+		c := newKeyClient(ts.URL, config.AppKey{ID: "key-1111", Weight: 1})
+		_, err := c.GetWeather(ctx, "0", "0")
+		assert.EqualError(t, err, "too many requests; limit reached")
+		_, err = c.GetWeather(ctx, "0", "0")
+		assert.EqualError(t, err, "too many requests; limit reached")
+		assert.Len(t, got(), 2, "keys out of rotation are not called")
+		c = newKeyClient(ts.URL)
+		_, err = c.GetWeather(ctx, "0", "0")
+		assert.EqualError(t, err, "config `WEATHER_ID` is invalid")
+	})
+	t.Run("Should use an app id listed more than once only once", func(t *testing.T) {
+		ts, got := newKeyServer(t, nil)
+		c := newKeyClient(ts.URL, config.AppKey{ID: "key-0000", Weight: 1}, config.AppKey{ID: "key-1111", Weight: 1},
+			config.AppKey{ID: "key-1111", Weight: 3})
+		for i := 0; i < 4; i++ {
+			_, err := c.GetWeather(ctx, "0", "0")
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, []string{"key-0000", "key-1111", "key-0000", "key-1111"}, got())
+		assert.Len(t, c.Keys(), 2)
+	})
+	t.Run("Should name app ids apart when their last characters collide", func(t *testing.T) {
+		ts, _ := newKeyServer(t, nil)
+		c := newKeyClient(ts.URL, config.AppKey{ID: "one-0000", Weight: 1}, config.AppKey{ID: "abc", Weight: 1},
+			config.AppKey{ID: "xyz", Weight: 1})
+		var names []string
+		for _, u := range c.Keys() {
+			names = append(names, u.Key)
+		}
+		assert.Equal(t, []string{"****0000", "****0000#2", "****", "****#2"}, names)
+	})
+	t.Run("Should rotate app ids set by reference, keeping the state of those still listed", func(t *testing.T) {
+		ts, got := newKeyServer(t, map[string]int{"key-1111": 429})
+		path := filepath.Join(t.TempDir(), "app_ids")
+		require.NoError(t, os.WriteFile(path, []byte("key-1111,key-2222"), 0o600))
+		secret, err := secrets.New(ctx, "WEATHER_IDS", secrets.NewFileProvider(), path)
+		require.NoError(t, err)
+		keys, err := config.ParseAppKeys(secret.Value())
+		require.NoError(t, err)
+		c := NewClient(&config.App{
+			WeatherClientConfig: config.WeatherClientConfig{Host: ts.URL, AppIDs: keys, AppIDsSecret: secret, KeyCooldown: time.Minute},
+		}).(*client)
+		_, err = c.GetWeather(ctx, "0", "0")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"key-1111", "key-2222"}, got())
+		rotate := func(ids string) {
+			require.NoError(t, os.WriteFile(path, []byte(ids), 0o600))
+			require.NoError(t, secret.Refresh(ctx))
+		}
+		rotate("key-3333=2,key-1111")
+		assert.Equal(t, []KeyUsage{
+			{Key: "****3333", Weight: 2, State: KeyActive},
+			{Key: "****1111", Weight: 1, State: KeyCoolingDown, Requests: 1, RateLimited: 1},
+		}, c.Keys())
+		_, err = c.GetWeather(ctx, "0", "0")
+		assert.NoError(t, err)
+		assert.Equal(t, "key-3333", got()[2])
+		rotate("key-4444=x")
+		assert.Len(t, c.Keys(), 2, "an invalid list leaves the keys in use")
+	})
+}
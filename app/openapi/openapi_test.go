@@ -0,0 +1,219 @@
+package openapi
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type point struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type base struct {
+	ID string `json:"id"`
+}
+
+type site struct {
+	base
+	Name    string            `json:"name"`
+	Point   point             `json:"point"`
+	Tags    []string          `json:"tags"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	SeenAt  *time.Time        `json:"seen_at,omitempty"`
+	Parent  *site             `json:"parent"`
+	Count   uint              `json:"count,omitempty"`
+	Secret  string            `json:"-"`
+	Message string
+	hidden  string
+}
+
+func TestDocument_Schema(t *testing.T) {
+	d := New(Info{Title: "test", Version: "1"})
+	t.Run("Should reference named structs from the components", func(t *testing.T) {
+		assert.Equal(t, &Schema{Ref: "#/components/schemas/site"}, d.Schema(site{}))
+		assert.Equal(t, &Schema{Type: "array", Items: &Schema{Ref: "#/components/schemas/point"}}, d.Schema([]point{}))
+		assert.Contains(t, d.Components.Schemas, "point")
+	})
+	t.Run("Should describe fields as encoding/json encodes them", func(t *testing.T) {
+		s := d.Components.Schemas["site"]
+		assert.Equal(t, []string{"id", "name", "point", "tags", "parent", "Message"}, s.Required)
+		assert.Equal(t, &Schema{Type: "string"}, s.Properties["id"], "embedded fields are flattened")
+		assert.Equal(t, &Schema{Type: []string{"array", "null"}, Items: &Schema{Type: "string"}}, s.Properties["tags"])
+		assert.Equal(t, &Schema{Type: "object", AdditionalProperties: &Schema{Type: "string"}}, s.Properties["labels"])
+		assert.Equal(t, &Schema{Type: "string", Format: "date-time"}, s.Properties["seen_at"])
+		assert.Equal(t, &Schema{AnyOf: []*Schema{{Ref: "#/components/schemas/site"}, {Type: "null"}}}, s.Properties["parent"])
+		assert.Equal(t, "integer", s.Properties["count"].Type)
+		assert.NotContains(t, s.Properties, "Secret")
+		assert.NotContains(t, s.Properties, "hidden")
+	})
+	t.Run("Should require no fields of request bodies", func(t *testing.T) {
+		assert.Equal(t, &Schema{Ref: "#/components/schemas/siteRequest"}, d.RequestSchema(site{}))
+		s := d.Components.Schemas["siteRequest"]
+		assert.Empty(t, s.Required)
+		assert.Equal(t, &Schema{Ref: "#/components/schemas/pointRequest"}, s.Properties["point"])
+		assert.Equal(t, &Schema{Type: []string{"object", "null"}, AdditionalProperties: &Schema{Type: "string"}}, s.Properties["labels"])
+	})
+	t.Run("Should qualify a struct name another package already took", func(t *testing.T) {
+		type point struct{}
+		assert.Equal(t, &Schema{Ref: "#/components/schemas/openapi.point"}, d.Schema(point{}))
+	})
+}
+
+func TestDocument_YAML(t *testing.T) {
+	d := New(Info{Title: "test", Version: "1"})
+	d.Add(http.MethodGet, "/points/{id}", Operation{
+		Summary:   "Get Point",
+		Responses: Responses(map[int]*Response{http.StatusOK: JSONResponse("", d.Schema(point{}))}),
+	})
+	b, err := d.YAML()
+	require.NoError(t, err)
+	assert.Equal(t, `openapi: 3.1.0
+info:
+  title: test
+  version: "1"
+paths:
+  /points/{id}:
+    get:
+      summary: Get Point
+      operationId: getPointsId
+      responses:
+        "200":
+          description: OK
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/point'
+components:
+  schemas:
+    point:
+      type: object
+      properties:
+        latitude:
+          type: number
+        longitude:
+          type: number
+      required:
+        - latitude
+        - longitude
+`, string(b))
+}
+
+func TestValidator_Middleware(t *testing.T) {
+	d := New(Info{Title: "test", Version: "1"})
+	d.Add(http.MethodPost, "/points", Operation{
+		Summary:     "Create Point",
+		Parameters:  []Parameter{{Name: "limit", In: "query", Schema: &Schema{Type: "integer"}}},
+		RequestBody: &RequestBody{Required: true, Content: map[string]MediaType{JSON: {Schema: d.RequestSchema(point{})}}},
+		Responses: Responses(map[int]*Response{
+			http.StatusCreated:    JSONResponse("", d.Schema(point{})),
+			http.StatusBadRequest: TextResponse(""),
+		}),
+	})
+	d.Add(http.MethodGet, "/points", Operation{
+		Summary:    "List Points",
+		Parameters: []Parameter{{Name: "near", In: "query", Required: true, Schema: &Schema{Type: "number"}}},
+		Responses:  Responses(map[int]*Response{http.StatusOK: JSONResponse("", d.Schema([]point{}))}),
+	})
+	v, err := NewValidator(d, true)
+	require.NoError(t, err)
+	// reply is what the handlers write, so tests can make them drift from the document
+	reply := `{"latitude": 1, "longitude": 2}`
+	r := mux.NewRouter()
+	r.Use(v.Middleware)
+	r.HandleFunc("/points", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", JSON)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(reply))
+	}).Methods("POST")
+	r.HandleFunc("/points", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", JSON)
+		w.Write([]byte("[" + reply + "]"))
+	}).Methods("GET")
+	r.HandleFunc("/undocumented", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(method, target, bytes.NewBufferString(body)))
+		return rr
+	}
+	t.Run("Should pass requests and responses matching the document", func(t *testing.T) {
+		rr := do("POST", "/points?limit=5", `{"latitude": 1, "longitude": 2}`)
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		assert.JSONEq(t, reply, rr.Body.String())
+		assert.Equal(t, http.StatusOK, do("GET", "/points?near=1.5", "").Code)
+	})
+	t.Run("Should match body field names regardless of case", func(t *testing.T) {
+		assert.Equal(t, http.StatusCreated, do("POST", "/points", `{"Latitude": 1, "LONGITUDE": 2}`).Code)
+	})
+	t.Run("Should reject requests not matching the document 400", func(t *testing.T) {
+		for _, tc := range []struct{ method, target, body, want string }{
+			{"POST", "/points", `{"latitude": "north", "longitude": 2}`, "invalid request: body at '/latitude': got string, want number"},
+			{"POST", "/points", `[1, 2]`, "invalid request: body at '': got array, want object"},
+			{"POST", "/points", `{"latitude": 1,`, "invalid request: body is not valid JSON"},
+			{"POST", "/points", "", "request body missing"},
+			{"POST", "/points?limit=many", `{"latitude": 1, "longitude": 2}`, "invalid request: query parameter `limit` at '': got string, want integer"},
+			{"GET", "/points", "", "invalid request: query parameter `near` is required"},
+		} {
+			rr := do(tc.method, tc.target, tc.body)
+			assert.Equal(t, http.StatusBadRequest, rr.Code, tc.target+" "+tc.body)
+			assert.Contains(t, rr.Body.String(), tc.want)
+		}
+	})
+	t.Run("Should replace responses that drifted from the document 500", func(t *testing.T) {
+		reply = `{"latitude": 1, "longitude": "west"}`
+		rr := do("POST", "/points", `{"latitude": 1, "longitude": 2}`)
+		assert.Equal(t, http.StatusInternalServerError, rr.Code)
+		assert.Contains(t, rr.Body.String(), "response does not match the API spec: status 201 body at '/longitude': got string, want number")
+		reply = `{"latitude": 1, "longitude": 2}`
+	})
+	t.Run("Should replace responses missing a required header 500", func(t *testing.T) {
+		d := New(Info{Title: "test", Version: "1"})
+		res := TextResponse("")
+		res.Headers = map[string]*Header{"Sunset": {Required: true, Schema: &Schema{Type: "string"}}}
+		d.Add(http.MethodGet, "/old", Operation{Summary: "Old", Deprecated: true, Responses: Responses(map[int]*Response{http.StatusOK: res})})
+		v, err := NewValidator(d, true)
+		require.NoError(t, err)
+		sunset := ""
+		r := mux.NewRouter()
+		r.Use(v.Middleware)
+		r.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+			if sunset != "" {
+				w.Header().Set("Sunset", sunset)
+			}
+			w.Header().Set("Content-Type", Text)
+			w.Write([]byte("old"))
+		}).Methods("GET")
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("GET", "/old", nil))
+		assert.Equal(t, http.StatusInternalServerError, rr.Code)
+		assert.Contains(t, rr.Body.String(), "status 200 is missing the Sunset header")
+		sunset = "Mon, 19 Apr 2027 00:00:00 GMT"
+		rr = httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("GET", "/old", nil))
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+	t.Run("Should pass routes the document does not describe", func(t *testing.T) {
+		assert.Equal(t, http.StatusTeapot, do("GET", "/undocumented", "").Code)
+	})
+	t.Run("Should only check responses when asked", func(t *testing.T) {
+		v, err := NewValidator(d, false)
+		require.NoError(t, err)
+		r := mux.NewRouter()
+		r.Use(v.Middleware)
+		r.HandleFunc("/points", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		}).Methods("GET")
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("GET", "/points?near=1", nil))
+		assert.Equal(t, http.StatusTeapot, rr.Code)
+	})
+}
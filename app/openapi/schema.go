@@ -0,0 +1,151 @@
+package openapi
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+//nolint:gochecknoglobals // types with their own JSON encoding
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	bytesType    = reflect.TypeOf([]byte(nil))
+)
+
+// Schema returns the schema of the JSON encoding of v's type, for describing responses. Named structs
+// are added to the components and referenced, so each is described once. Fields follow the
+// encoding/json rules: the `json` tag names a field, `-` skips it and fields without `omitempty` are
+// required. A slice or map field without `omitempty` may also be null, as a nil one encodes.
+func (d *Document) Schema(v any) *Schema {
+	return d.schemaOf(reflect.TypeOf(v), false)
+}
+
+// RequestSchema returns the schema of the JSON v's type decodes, for describing request bodies. No
+// field is required, as a missing one decodes to its zero value; handlers check the ones they need.
+// Named structs are added to the components with a `Request` suffix unless their name has one.
+func (d *Document) RequestSchema(v any) *Schema {
+	return d.schemaOf(reflect.TypeOf(v), true)
+}
+
+func (d *Document) schemaOf(t reflect.Type, input bool) *Schema {
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return d.schemaOf(t.Elem(), input)
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if t == durationType {
+			return &Schema{Type: "integer", Description: "nanoseconds"}
+		}
+		return &Schema{Type: "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		zero := 0.0
+		return &Schema{Type: "integer", Minimum: &zero}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t == bytesType {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: d.schemaOf(t.Elem(), input)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schemaOf(t.Elem(), input)}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return d.structSchema(t, input)
+		}
+		return d.ref(t, input)
+	default:
+		// interfaces hold any JSON value
+		return &Schema{}
+	}
+}
+
+// ref adds the named struct t to the components, under its package qualified name when another type
+// already took the plain one.
+func (d *Document) ref(t reflect.Type, input bool) *Schema {
+	suffix := ""
+	if input && !strings.HasSuffix(t.Name(), "Request") {
+		suffix = "Request"
+	}
+	name := t.Name() + suffix
+	if s, ok := d.Components.Schemas[name]; ok && s.goType != t {
+		name = t.String() + suffix
+	}
+	if _, ok := d.Components.Schemas[name]; !ok {
+		// claim the name first so recursive types refer to it rather than recursing forever
+		placeholder := &Schema{goType: t}
+		d.Components.Schemas[name] = placeholder
+		*placeholder = *d.structSchema(t, input)
+		placeholder.goType = t
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// resolve follows a reference to a component schema.
+func (d *Document) resolve(s *Schema) *Schema {
+	if name, ok := strings.CutPrefix(s.Ref, "#/components/schemas/"); ok {
+		if c, ok := d.Components.Schemas[name]; ok {
+			return c
+		}
+	}
+	return s
+}
+
+func (d *Document) structSchema(t reflect.Type, input bool) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	d.addFields(s, t, input)
+	return s
+}
+
+// addFields adds the encoded fields of t to s, flattening embedded structs as encoding/json does.
+func (d *Document) addFields(s *Schema, t reflect.Type, input bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		ft := f.Type
+		if f.Anonymous && name == "" {
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				d.addFields(s, ft, input)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fs := d.schemaOf(ft, input)
+		// a null decodes as a zero value, and encodes for nil slices, maps and pointers without omitempty
+		omitEmpty := strings.Contains(","+opts+",", ",omitempty,")
+		if k := ft.Kind(); (input || !omitEmpty) && ((k == reflect.Slice && ft != bytesType) || k == reflect.Map || k == reflect.Pointer) {
+			fs = nullable(fs)
+		}
+		if !input && !omitEmpty {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = fs
+	}
+}
+
+func nullable(s *Schema) *Schema {
+	if typ, ok := s.Type.(string); ok && s.Ref == "" {
+		s.Type = []string{typ, "null"}
+		return s
+	}
+	return &Schema{AnyOf: []*Schema{s, {Type: "null"}}}
+}
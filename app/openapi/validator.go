@@ -0,0 +1,335 @@
+package openapi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	apperrors "weathersvc/app/app_errors"
+
+	"github.com/gorilla/mux"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// documentURL names the document while its schemas are compiled; it is never fetched.
+const documentURL = "openapi.json"
+
+// Validator checks requests, and optionally responses, against the operations of a document.
+type Validator struct {
+	ops       map[string]*compiledOperation
+	responses bool
+}
+
+type compiledOperation struct {
+	params []compiledParameter
+	// body is nil when the operation takes no JSON body.
+	body         *jsonschema.Schema
+	bodyRequired bool
+	// bodyFields are the documented names of the body's properties.
+	bodyFields []string
+	// responses maps status codes to the media types documented for them and their schema, which is
+	// nil for media types other than JSON.
+	responses map[string]map[string]*jsonschema.Schema
+	// headers maps status codes to the headers they must be sent with.
+	headers map[string][]string
+	// streamed responses are written as they happen, so they are not buffered to be checked.
+	streamed bool
+}
+
+type compiledParameter struct {
+	Parameter
+	schema *jsonschema.Schema
+}
+
+// NewValidator compiles the schemas of every operation in d. Responses are only checked when
+// validateResponses is set, as they must be buffered; it is meant for tests.
+func NewValidator(d *Document, validateResponses bool) (*Validator, error) {
+	b, err := d.JSON()
+	if err != nil {
+		return nil, err
+	}
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	c := jsonschema.NewCompiler()
+	c.DefaultDraft(jsonschema.Draft2020)
+	c.AssertFormat()
+	if err := c.AddResource(documentURL, doc); err != nil {
+		return nil, err
+	}
+	compile := func(ptr ...string) (*jsonschema.Schema, error) {
+		for i, p := range ptr {
+			ptr[i] = strings.NewReplacer("~", "~0", "/", "~1").Replace(p)
+		}
+		return c.Compile(documentURL + "#/" + strings.Join(ptr, "/"))
+	}
+	v := &Validator{ops: map[string]*compiledOperation{}, responses: validateResponses}
+	for path, item := range d.Paths {
+		for method, op := range *item {
+			cop := &compiledOperation{responses: map[string]map[string]*jsonschema.Schema{}, headers: map[string][]string{}}
+			for i, p := range op.Parameters {
+				s, err := compile("paths", path, method, "parameters", strconv.Itoa(i), "schema")
+				if err != nil {
+					return nil, fmt.Errorf("error compiling %s %s parameter %s: %w", method, path, p.Name, err)
+				}
+				cop.params = append(cop.params, compiledParameter{Parameter: p, schema: s})
+			}
+			if op.RequestBody != nil {
+				if _, ok := op.RequestBody.Content[JSON]; ok {
+					if cop.body, err = compile("paths", path, method, "requestBody", "content", JSON, "schema"); err != nil {
+						return nil, fmt.Errorf("error compiling %s %s request body: %w", method, path, err)
+					}
+					cop.bodyRequired = op.RequestBody.Required
+					for name := range d.resolve(op.RequestBody.Content[JSON].Schema).Properties {
+						cop.bodyFields = append(cop.bodyFields, name)
+					}
+				}
+			}
+			for status, res := range op.Responses {
+				types := map[string]*jsonschema.Schema{}
+				for mt := range res.Content {
+					if mt == EventStream {
+						cop.streamed = true
+					}
+					if mt != JSON {
+						types[mt] = nil
+						continue
+					}
+					if types[mt], err = compile("paths", path, method, "responses", status, "content", mt, "schema"); err != nil {
+						return nil, fmt.Errorf("error compiling %s %s response %s: %w", method, path, status, err)
+					}
+				}
+				for name, h := range res.Headers {
+					if h.Required {
+						cop.headers[status] = append(cop.headers[status], name)
+					}
+				}
+				if status == strconv.Itoa(http.StatusSwitchingProtocols) {
+					cop.streamed = true
+				}
+				cop.responses[status] = types
+			}
+			v.ops[strings.ToUpper(method)+" "+path] = cop
+		}
+	}
+	return v, nil
+}
+
+// Middleware rejects requests whose parameters or JSON body do not match the operation documented
+// for the matched mux route with 400 Bad Request. Routes that are not documented pass through.
+//
+// When responses are validated, a response with an undocumented status or media type, or a JSON body
+// not matching its schema, is logged and replaced by 500 Internal Server Error so tests fail loudly.
+func (v *Validator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op := v.operation(r)
+		if op == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if err := op.validateRequest(r); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !v.responses || op.streamed {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		if err := op.validateResponse(rec); err != nil {
+			slog.ErrorContext(r.Context(), "response does not match the API spec", "method", r.Method, "path", r.URL.Path, "error", err)
+			http.Error(w, "response does not match the API spec: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(rec.status)
+		w.Write(rec.body.Bytes()) //nolint:errcheck // the client has gone when this fails
+	})
+}
+
+func (v *Validator) operation(r *http.Request) *compiledOperation {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return nil
+	}
+	tmpl, err := route.GetPathTemplate()
+	if err != nil {
+		return nil
+	}
+	return v.ops[r.Method+" "+tmpl]
+}
+
+func (op *compiledOperation) validateRequest(r *http.Request) error {
+	for _, p := range op.params {
+		var raw string
+		var ok bool
+		switch p.In {
+		case "query":
+			var values []string
+			values, ok = r.URL.Query()[p.Name]
+			if ok {
+				raw = values[0]
+			}
+		case "header":
+			raw = r.Header.Get(p.Name)
+			ok = raw != ""
+		case "path":
+			raw, ok = mux.Vars(r)[p.Name]
+		}
+		if !ok {
+			if p.Required {
+				return apperrors.CreateInvalidRequestError(fmt.Sprintf("%s parameter `%s` is required", p.In, p.Name))
+			}
+			continue
+		}
+		if err := p.schema.Validate(parameterValue(p.Schema, raw)); err != nil {
+			return apperrors.CreateInvalidRequestError(fmt.Sprintf("%s parameter `%s` %s", p.In, p.Name, describe(err)))
+		}
+	}
+	if op.body == nil {
+		return nil
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return apperrors.CreateInvalidRequestError(err.Error())
+	}
+	// handlers read the body again
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if len(bytes.TrimSpace(body)) == 0 {
+		if op.bodyRequired {
+			return apperrors.ErrNoBody
+		}
+		return nil
+	}
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(body))
+	if err != nil {
+		return apperrors.CreateInvalidRequestError("body is not valid JSON")
+	}
+	if err := op.body.Validate(foldKeys(doc, op.bodyFields)); err != nil {
+		return apperrors.CreateInvalidRequestError("body " + describe(err))
+	}
+	return nil
+}
+
+// foldKeys renames the properties of a JSON object that match a documented name but for case to that
+// name, as encoding/json matches them when handlers decode the body.
+func foldKeys(doc any, names []string) any {
+	obj, ok := doc.(map[string]any)
+	if !ok {
+		return doc
+	}
+	for k, v := range obj {
+		for _, name := range names {
+			if k != name && strings.EqualFold(k, name) {
+				if _, taken := obj[name]; !taken {
+					delete(obj, k)
+					obj[name] = v
+				}
+				break
+			}
+		}
+	}
+	return obj
+}
+
+// parameterValue converts a query, header or path parameter to the JSON type its schema expects, so
+// `lat=abc` fails a number schema rather than passing as a string.
+func parameterValue(s *Schema, raw string) any {
+	switch s.Type {
+	case "number":
+		if f, err := strconv.ParseFloat(raw, 64); err == nil {
+			return f
+		}
+	case "integer":
+		if i, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			return i
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(raw); err == nil {
+			return b
+		}
+	}
+	return raw
+}
+
+func (op *compiledOperation) validateResponse(rec *recorder) error {
+	types, ok := op.responses[strconv.Itoa(rec.status)]
+	if !ok {
+		return fmt.Errorf("status %d is not documented", rec.status)
+	}
+	for _, name := range op.headers[strconv.Itoa(rec.status)] {
+		if rec.Header().Get(name) == "" {
+			return fmt.Errorf("status %d is missing the %s header", rec.status, name)
+		}
+	}
+	if len(types) == 0 {
+		if rec.body.Len() > 0 {
+			return fmt.Errorf("status %d is documented without a body", rec.status)
+		}
+		return nil
+	}
+	mt, _, err := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	if err != nil {
+		return fmt.Errorf("status %d has no media type", rec.status)
+	}
+	schema, ok := types[mt]
+	if !ok {
+		return fmt.Errorf("status %d is not documented as %s", rec.status, mt)
+	}
+	if schema == nil {
+		return nil
+	}
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(rec.body.Bytes()))
+	if err != nil {
+		return fmt.Errorf("status %d body is not valid JSON: %w", rec.status, err)
+	}
+	if err := schema.Validate(doc); err != nil {
+		return fmt.Errorf("status %d body %s", rec.status, describe(err))
+	}
+	return nil
+}
+
+// describe lists where and how a value failed its schema, e.g. "at '/latitude': got string, want number".
+func describe(err error) string {
+	var ve *jsonschema.ValidationError
+	if !errors.As(err, &ve) {
+		return err.Error()
+	}
+	var leaves []string
+	var walk func(*jsonschema.ValidationError)
+	walk = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) == 0 {
+			leaves = append(leaves, e.Error())
+		}
+		for _, c := range e.Causes {
+			walk(c)
+		}
+	}
+	walk(ve)
+	return strings.Join(leaves, "; ")
+}
+
+// recorder buffers a response so it can be checked before it is sent.
+type recorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status, r.wroteHeader = status, true
+	}
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.body.Write(b)
+}
@@ -0,0 +1,225 @@
+/*
+poller.go: Shared poller for watched locations. Every subscriber to the same location shares a
+single upstream poll, and subscribers are only notified when the classified condition changes.
+*/
+package poller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+	"weathersvc/app/config"
+	"weathersvc/app/logging"
+	"weathersvc/app/service"
+)
+
+const (
+	// historySize is the number of past events kept per location for Last-Event-ID replay.
+	historySize = 16
+	// subscriberBuffer is the number of undelivered events held for a slow subscriber before the oldest is dropped.
+	subscriberBuffer = 8
+)
+
+// Event is a change in the classified weather condition of a watched location.
+type Event struct {
+	ID        uint64
+	Latitude  float64
+	Longitude float64
+	Cond      service.WeatherCond
+	Time      time.Time
+}
+
+type Poller interface {
+	// Subscribe watches the location and returns a channel of condition changes along with a func to
+	// unsubscribe. Events after lastEventID are replayed; when lastEventID is 0 the latest known event is sent.
+	Subscribe(lat, lon float64, lastEventID uint64) (<-chan Event, func())
+	// Close stops all polling and closes every subscriber channel.
+	Close()
+}
+
+type poller struct {
+	svc       service.Service
+	interval  time.Duration
+	ctx       context.Context
+	cancel    context.CancelFunc
+	mu        sync.Mutex
+	seq       uint64
+	nextSub   int
+	locations map[string]*location
+	closed    bool
+}
+
+type location struct {
+	lat     float64
+	lon     float64
+	history []Event
+	subs    map[int]chan Event
+	stop    context.CancelFunc
+}
+
+func NewPoller(s service.Service, interval time.Duration) Poller {
+	if interval <= 0 {
+		interval = config.DefaultPollInterval
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &poller{
+		svc:       s,
+		interval:  interval,
+		ctx:       ctx,
+		cancel:    cancel,
+		locations: map[string]*location{},
+	}
+}
+
+// Key normalizes coordinates to roughly 11m so nearby requests share one upstream poll.
+func Key(lat, lon float64) string {
+	return fmt.Sprintf("%.4f,%.4f", lat, lon)
+}
+
+func (p *poller) Subscribe(lat, lon float64, lastEventID uint64) (<-chan Event, func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ch := make(chan Event, historySize+subscriberBuffer)
+	if p.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	key := Key(lat, lon)
+	loc, ok := p.locations[key]
+	if !ok {
+		ctx, stop := context.WithCancel(p.ctx)
+		loc = &location{
+			lat:  lat,
+			lon:  lon,
+			subs: map[int]chan Event{},
+			stop: stop,
+		}
+		p.locations[key] = loc
+		go p.watch(ctx, loc)
+	}
+	loc.replay(ch, lastEventID)
+	id := p.nextSub
+	p.nextSub++
+	loc.subs[id] = ch
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() { p.unsubscribe(key, id) })
+	}
+}
+
+func (p *poller) unsubscribe(key string, id int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	loc, ok := p.locations[key]
+	if !ok {
+		return
+	}
+	if ch, ok := loc.subs[id]; ok {
+		delete(loc.subs, id)
+		close(ch)
+	}
+	if len(loc.subs) == 0 {
+		loc.stop()
+		delete(p.locations, key)
+	}
+}
+
+func (p *poller) Close() {
+	p.cancel()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	for key, loc := range p.locations {
+		for id, ch := range loc.subs {
+			delete(loc.subs, id)
+			close(ch)
+		}
+		delete(p.locations, key)
+	}
+}
+
+// watch refreshes the location immediately and then on every interval until ctx is cancelled.
+func (p *poller) watch(ctx context.Context, loc *location) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		p.refresh(ctx, loc)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *poller) refresh(ctx context.Context, loc *location) {
+	cond, err := p.svc.GetWeather(ctx, loc.lat, loc.lon)
+	if err != nil {
+		if ctx.Err() == nil {
+			logging.FromContext(ctx).Warn("poller failed to refresh", "location", Key(loc.lat, loc.lon), "error", err)
+		}
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if ctx.Err() != nil {
+		return
+	}
+	if n := len(loc.history); n > 0 && sameCondition(loc.history[n-1].Cond, cond) {
+		return
+	}
+	p.seq++
+	ev := Event{
+		ID:        p.seq,
+		Latitude:  loc.lat,
+		Longitude: loc.lon,
+		Cond:      cond,
+		Time:      time.Now().UTC(),
+	}
+	loc.history = append(loc.history, ev)
+	if len(loc.history) > historySize {
+		loc.history = loc.history[len(loc.history)-historySize:]
+	}
+	for _, ch := range loc.subs {
+		send(ch, ev)
+	}
+}
+
+// replay queues the events a new subscriber has missed. Must be called with the poller lock held.
+func (l *location) replay(ch chan Event, lastEventID uint64) {
+	n := len(l.history)
+	if n == 0 {
+		return
+	}
+	if lastEventID == 0 || lastEventID > l.history[n-1].ID {
+		ch <- l.history[n-1]
+		return
+	}
+	for _, ev := range l.history {
+		if ev.ID > lastEventID {
+			ch <- ev
+		}
+	}
+}
+
+// send delivers ev without blocking, dropping the oldest queued event for a slow subscriber.
+func send(ch chan Event, ev Event) {
+	select {
+	case ch <- ev:
+		return
+	default:
+	}
+	select {
+	case <-ch:
+	default:
+	}
+	select {
+	case ch <- ev:
+	default:
+	}
+}
+
+func sameCondition(a, b service.WeatherCond) bool {
+	return a.Temp == b.Temp && a.Condition == b.Condition && a.Wind == b.Wind
+}
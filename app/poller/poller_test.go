@@ -0,0 +1,107 @@
+package poller
+
+import (
+	"testing"
+	"time"
+	apperrors "weathersvc/app/app_errors"
+	"weathersvc/app/service"
+	mock_service "weathersvc/mocks/service"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func receive(t *testing.T, ch <-chan Event) Event {
+	t.Helper()
+	select {
+	case ev, ok := <-ch:
+		require.True(t, ok, "channel closed before event was received")
+		return ev
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+	return Event{}
+}
+
+func TestPoller_Subscribe(t *testing.T) {
+	hot := service.WeatherCond{Temp: "hot", Condition: "few clouds", Wind: "calm winds"}
+	cold := service.WeatherCond{Temp: "cold", Condition: "light rain", Wind: "gentle breeze"}
+	t.Run("Should share one upstream poll between subscribers", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		mockService := mock_service.NewMockService(ctrl)
+		mockService.EXPECT().GetWeather(gomock.Any(), 32.7, -96.8).Return(hot, nil).MinTimes(1)
+		p := NewPoller(mockService, time.Hour)
+		defer p.Close()
+		first, unsubFirst := p.Subscribe(32.7, -96.8, 0)
+		defer unsubFirst()
+		ev := receive(t, first)
+		second, unsubSecond := p.Subscribe(32.7, -96.8, 0)
+		defer unsubSecond()
+		assert.Equal(t, ev, receive(t, second))
+		assert.Equal(t, hot, ev.Cond)
+		assert.Equal(t, uint64(1), ev.ID)
+	})
+	t.Run("Should only send events when the condition changes", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		mockService := mock_service.NewMockService(ctrl)
+		gomock.InOrder(
+			mockService.EXPECT().GetWeather(gomock.Any(), gomock.Any(), gomock.Any()).Return(hot, nil).Times(2),
+			mockService.EXPECT().GetWeather(gomock.Any(), gomock.Any(), gomock.Any()).Return(service.WeatherCond{}, apperrors.ErrTooManyRequests),
+			mockService.EXPECT().GetWeather(gomock.Any(), gomock.Any(), gomock.Any()).Return(cold, nil).AnyTimes(),
+		)
+		p := NewPoller(mockService, 10*time.Millisecond)
+		defer p.Close()
+		ch, unsub := p.Subscribe(1, 1, 0)
+		defer unsub()
+		ev := receive(t, ch)
+		assert.Equal(t, hot, ev.Cond)
+		ev = receive(t, ch)
+		assert.Equal(t, cold, ev.Cond)
+		assert.Equal(t, uint64(2), ev.ID)
+	})
+	t.Run("Should replay events after Last-Event-ID", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		mockService := mock_service.NewMockService(ctrl)
+		gomock.InOrder(
+			mockService.EXPECT().GetWeather(gomock.Any(), gomock.Any(), gomock.Any()).Return(hot, nil),
+			mockService.EXPECT().GetWeather(gomock.Any(), gomock.Any(), gomock.Any()).Return(cold, nil).AnyTimes(),
+		)
+		p := NewPoller(mockService, 10*time.Millisecond)
+		defer p.Close()
+		ch, unsub := p.Subscribe(1, 1, 0)
+		defer unsub()
+		receive(t, ch)
+		receive(t, ch)
+		resumed, unsubResumed := p.Subscribe(1, 1, 1)
+		defer unsubResumed()
+		ev := receive(t, resumed)
+		assert.Equal(t, uint64(2), ev.ID)
+		assert.Equal(t, cold, ev.Cond)
+		replayAll, unsubReplayAll := p.Subscribe(1, 1, 0)
+		defer unsubReplayAll()
+		assert.Equal(t, uint64(2), receive(t, replayAll).ID)
+	})
+	t.Run("Should close subscriber channels on Close", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		mockService := mock_service.NewMockService(ctrl)
+		mockService.EXPECT().GetWeather(gomock.Any(), gomock.Any(), gomock.Any()).Return(hot, nil).AnyTimes()
+		p := NewPoller(mockService, time.Hour)
+		ch, unsub := p.Subscribe(1, 1, 0)
+		receive(t, ch)
+		p.Close()
+		_, ok := <-ch
+		assert.False(t, ok)
+		unsub()
+		closed, _ := p.Subscribe(1, 1, 0)
+		_, ok = <-closed
+		assert.False(t, ok)
+	})
+}
+
+func TestPoller_Key(t *testing.T) {
+	t.Run("Should share key for nearby coordinates", func(t *testing.T) {
+		assert.Equal(t, Key(32.777981, -96.796211), Key(32.77798, -96.79621))
+		assert.NotEqual(t, Key(32.7779, -96.7962), Key(32.7781, -96.7962))
+	})
+}
@@ -0,0 +1,141 @@
+package ratelimit
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	apperrors "weathersvc/app/app_errors"
+	"weathersvc/app/auth"
+	"weathersvc/app/config"
+	"weathersvc/app/logging"
+
+	"github.com/gorilla/mux"
+)
+
+// Middleware limits each caller on each route, using the route's limit from conf or the default.
+// Callers are identified by their authenticated principal, or by client address otherwise, so it
+// must run after authentication. Responses carry the RateLimit-* headers from the IETF draft.
+func Middleware(store Store, conf config.RateLimitConfig) func(http.Handler) http.Handler {
+	return LiveMiddleware(store, func() config.RateLimitConfig { return conf })
+}
+
+// LiveMiddleware is Middleware with the limits read from current on each request, so they can be
+// changed while serving.
+func LiveMiddleware(store Store, current func() config.RateLimitConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conf := current()
+			route := routeTemplate(r)
+			rate, ok := conf.RouteRateLimits[route]
+			if !ok {
+				rate = conf.RateLimit
+			}
+			if take(w, r, store, route+"|"+Identity(r, conf.TrustedProxies), rate, true) {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// AddressMiddleware limits each client address across every route to the IPRateLimit read from
+// current on each request. It runs before authentication, so that requests with missing or wrong
+// credentials are limited before they cost a key lookup. Only limited responses carry the RateLimit-*
+// headers, as those of requests let through are set by the per-caller limit.
+func AddressMiddleware(store Store, current func() config.RateLimitConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conf := current()
+			if take(w, r, store, "*|ip:"+ClientIP(r, conf.TrustedProxies), conf.IPRateLimit, false) {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
+
+// take spends a token from the bucket at key, answering 429 and reporting false when it is empty. The
+// RateLimit-* headers are set when always is, and on every limited response.
+func take(w http.ResponseWriter, r *http.Request, store Store, key string, rate config.Rate, always bool) bool {
+	if rate.Count <= 0 {
+		return true
+	}
+	res, err := store.Take(r.Context(), key, rate)
+	if err != nil {
+		// a broken limiter store should not take the API down with it
+		logging.FromContext(r.Context()).Error("rate limiter unavailable, allowing request", "error", err)
+		return true
+	}
+	if always || !res.Allowed {
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(seconds(res.Reset)))
+		w.Header().Set("RateLimit-Policy", strconv.Itoa(rate.Count)+";w="+strconv.Itoa(seconds(rate.Per)))
+	}
+	if !res.Allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(seconds(res.RetryAfter)))
+		http.Error(w, apperrors.ErrRateLimited.Error(), http.StatusTooManyRequests)
+		return false
+	}
+	return true
+}
+
+// Identity returns the caller's rate limit identity: the authenticated principal when there is one,
+// otherwise the client IP.
+func Identity(r *http.Request, trusted []*net.IPNet) string {
+	if p, ok := auth.PrincipalFromContext(r.Context()); ok {
+		return p.Method + ":" + p.Subject
+	}
+	return "ip:" + ClientIP(r, trusted)
+}
+
+// ClientIP returns the address of the client. `X-Forwarded-For` is only honored when the request
+// came from a trusted proxy, and is read right to left up to the first untrusted hop.
+func ClientIP(r *http.Request, trusted []*net.IPNet) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !isTrusted(host, trusted) {
+		return host
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		host = hop
+		if !isTrusted(hop, trusted) {
+			break
+		}
+	}
+	return host
+}
+
+func isTrusted(addr string, trusted []*net.IPNet) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, n := range trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil {
+			return tpl
+		}
+	}
+	return r.URL.Path
+}
+
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
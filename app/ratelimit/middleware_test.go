@@ -0,0 +1,130 @@
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"weathersvc/app/auth"
+	"weathersvc/app/config"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	r := mux.NewRouter()
+	r.Use(Middleware(NewMemoryStore(), config.RateLimitConfig{
+		RateLimit:       config.Rate{Count: 2, Per: time.Minute},
+		RouteRateLimits: map[string]config.Rate{"/alerts/{id}": {Count: 1, Per: time.Second}},
+	}))
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	r.HandleFunc("/weather/get", ok)
+	r.HandleFunc("/alerts/{id}", ok)
+	do := func(path, addr string, ctx context.Context) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil).WithContext(ctx)
+		req.RemoteAddr = addr
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+	bg := context.Background()
+	t.Run("Should limit each client with the default rate", func(t *testing.T) {
+		rr := do("/weather/get", "10.0.0.1:1234", bg)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "2", rr.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, "1", rr.Header().Get("RateLimit-Remaining"))
+		assert.Equal(t, "30", rr.Header().Get("RateLimit-Reset"))
+		assert.Equal(t, "2;w=60", rr.Header().Get("RateLimit-Policy"))
+		assert.Equal(t, http.StatusOK, do("/weather/get", "10.0.0.1:1234", bg).Code)
+		rr = do("/weather/get", "10.0.0.1:1234", bg)
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+		assert.Equal(t, "30", rr.Header().Get("Retry-After"))
+		assert.Contains(t, rr.Body.String(), "rate limit exceeded")
+		assert.Equal(t, http.StatusOK, do("/weather/get", "10.0.0.2:1234", bg).Code)
+	})
+	t.Run("Should use route limits by path template", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, do("/alerts/a", "10.0.0.3:1234", bg).Code)
+		rr := do("/alerts/b", "10.0.0.3:1234", bg)
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+		assert.Equal(t, "1;w=1", rr.Header().Get("RateLimit-Policy"))
+	})
+	t.Run("Should limit authenticated callers by principal", func(t *testing.T) {
+		ctx := auth.WithPrincipal(bg, auth.Principal{Method: auth.MethodAPIKey, Subject: "k1"})
+		assert.Equal(t, http.StatusOK, do("/alerts/a", "10.0.0.4:1234", ctx).Code)
+		assert.Equal(t, http.StatusTooManyRequests, do("/alerts/a", "10.0.0.5:1234", ctx).Code)
+	})
+}
+
+func TestLiveMiddleware(t *testing.T) {
+	t.Run("Should read the limits on each request", func(t *testing.T) {
+		conf := config.RateLimitConfig{RateLimit: config.Rate{Count: 1, Per: time.Minute}}
+		h := LiveMiddleware(NewMemoryStore(), func() config.RateLimitConfig { return conf })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		do := func() *httptest.ResponseRecorder {
+			req := httptest.NewRequest("GET", "/weather/get", nil)
+			req.RemoteAddr = "10.0.0.1:1234"
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+			return rr
+		}
+		assert.Equal(t, http.StatusOK, do().Code)
+		assert.Equal(t, http.StatusTooManyRequests, do().Code)
+		conf = config.RateLimitConfig{}
+		rr := do()
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Empty(t, rr.Header().Get("RateLimit-Limit"))
+	})
+}
+
+func TestClientIP(t *testing.T) {
+	_, proxies, _ := net.ParseCIDR("10.1.0.0/16")
+	trusted := []*net.IPNet{proxies}
+	tests := map[string]struct {
+		remote string
+		xff    string
+		want   string
+	}{
+		"Should use the remote address without a proxy":         {"203.0.113.9:5000", "", "203.0.113.9"},
+		"Should ignore X-Forwarded-For from untrusted clients":  {"203.0.113.9:5000", "198.51.100.1", "203.0.113.9"},
+		"Should honor X-Forwarded-For from trusted proxies":     {"10.1.0.5:5000", "198.51.100.1", "198.51.100.1"},
+		"Should skip trusted hops and stop at the first client": {"10.1.0.5:5000", "1.1.1.1, 198.51.100.1, 10.1.2.3", "198.51.100.1"},
+		"Should fall back to the last hop when all are trusted": {"10.1.0.5:5000", "10.1.9.9", "10.1.9.9"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tc.remote
+			if tc.xff != "" {
+				req.Header.Set("X-Forwarded-For", tc.xff)
+			}
+			assert.Equal(t, tc.want, ClientIP(req, trusted))
+		})
+	}
+}
+
+func TestAddressMiddleware(t *testing.T) {
+	t.Run("Should limit each address across routes and only label limited responses", func(t *testing.T) {
+		conf := config.RateLimitConfig{IPRateLimit: config.Rate{Count: 2, Per: time.Minute}}
+		h := AddressMiddleware(NewMemoryStore(), func() config.RateLimitConfig { return conf })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		do := func(path, addr string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest("GET", path, nil)
+			req.RemoteAddr = addr
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, req)
+			return rr
+		}
+		rr := do("/weather/get", "10.0.0.1:1234")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Empty(t, rr.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, http.StatusOK, do("/alerts", "10.0.0.1:1234").Code)
+		rr = do("/locations", "10.0.0.1:1234")
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+		assert.Equal(t, "2;w=60", rr.Header().Get("RateLimit-Policy"))
+		assert.Equal(t, "30", rr.Header().Get("Retry-After"))
+		assert.Equal(t, http.StatusOK, do("/locations", "10.0.0.2:1234").Code)
+		conf = config.RateLimitConfig{}
+		assert.Equal(t, http.StatusOK, do("/locations", "10.0.0.1:1234").Code)
+	})
+}
@@ -0,0 +1,101 @@
+/*
+store.go: Token bucket limiter state. Each key owns a bucket of Count tokens that refills at Count
+per Per; a request takes one token and is limited when the bucket is empty.
+*/
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+	"weathersvc/app/config"
+)
+
+// sweepInterval is how often full, idle buckets are dropped from the memory store.
+const sweepInterval = time.Minute
+
+// Result describes a bucket after a request tried to take a token.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until a token is available when the request was not allowed.
+	RetryAfter time.Duration
+}
+
+type Store interface {
+	// Take removes one token from key's bucket, creating it full when it does not exist.
+	Take(ctx context.Context, key string, rate config.Rate) (Result, error)
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	rate    config.Rate
+}
+
+type memoryStore struct {
+	now   func() time.Time
+	mu    sync.Mutex
+	keys  map[string]*bucket
+	swept time.Time
+}
+
+// NewMemoryStore returns a Store that keeps buckets in memory, so limits apply per process.
+func NewMemoryStore() Store {
+	return &memoryStore{now: time.Now, keys: map[string]*bucket{}}
+}
+
+func (m *memoryStore) Take(ctx context.Context, key string, rate config.Rate) (Result, error) {
+	now := m.now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if now.Sub(m.swept) >= sweepInterval {
+		m.sweep(now)
+	}
+	b, ok := m.keys[key]
+	if !ok || b.rate != rate {
+		b = &bucket{tokens: float64(rate.Count), updated: now, rate: rate}
+		m.keys[key] = b
+	}
+	b.refill(now)
+	res := Result{Limit: rate.Count}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = b.until(1)
+	}
+	res.Remaining = int(math.Floor(b.tokens))
+	res.Reset = b.until(float64(rate.Count))
+	return res, nil
+}
+
+// sweep drops buckets that have refilled completely, since a new bucket would be identical.
+func (m *memoryStore) sweep(now time.Time) {
+	for key, b := range m.keys {
+		b.refill(now)
+		if b.tokens >= float64(b.rate.Count) {
+			delete(m.keys, key)
+		}
+	}
+	m.swept = now
+}
+
+func (b *bucket) refill(now time.Time) {
+	perToken := b.rate.Per.Seconds() / float64(b.rate.Count)
+	b.tokens = math.Min(float64(b.rate.Count), b.tokens+now.Sub(b.updated).Seconds()/perToken)
+	b.updated = now
+}
+
+// until returns how long the bucket takes to hold n tokens.
+func (b *bucket) until(n float64) time.Duration {
+	if b.tokens >= n {
+		return 0
+	}
+	perToken := b.rate.Per.Seconds() / float64(b.rate.Count)
+	return time.Duration((n - b.tokens) * perToken * float64(time.Second))
+}
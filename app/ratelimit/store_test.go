@@ -0,0 +1,48 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+	"weathersvc/app/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore_Take(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	m := &memoryStore{now: func() time.Time { return now }, keys: map[string]*bucket{}, swept: now}
+	rate := config.Rate{Count: 2, Per: time.Second}
+	t.Run("Should allow a full burst then limit", func(t *testing.T) {
+		res, err := m.Take(ctx, "a", rate)
+		require.NoError(t, err)
+		assert.Equal(t, Result{Allowed: true, Limit: 2, Remaining: 1, Reset: 500 * time.Millisecond}, res)
+		res, _ = m.Take(ctx, "a", rate)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, 0, res.Remaining)
+		res, _ = m.Take(ctx, "a", rate)
+		assert.False(t, res.Allowed)
+		assert.Equal(t, 500*time.Millisecond, res.RetryAfter)
+		assert.Equal(t, time.Second, res.Reset)
+	})
+	t.Run("Should keep separate buckets per key", func(t *testing.T) {
+		res, _ := m.Take(ctx, "b", rate)
+		assert.True(t, res.Allowed)
+	})
+	t.Run("Should refill over time", func(t *testing.T) {
+		now = now.Add(500 * time.Millisecond)
+		res, _ := m.Take(ctx, "a", rate)
+		assert.True(t, res.Allowed)
+		res, _ = m.Take(ctx, "a", rate)
+		assert.False(t, res.Allowed)
+	})
+	t.Run("Should drop full buckets when sweeping", func(t *testing.T) {
+		now = now.Add(sweepInterval)
+		_, _ = m.Take(ctx, "c", rate)
+		assert.NotContains(t, m.keys, "a")
+		assert.NotContains(t, m.keys, "b")
+		assert.Contains(t, m.keys, "c")
+	})
+}
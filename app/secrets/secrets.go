@@ -0,0 +1,91 @@
+/*
+secrets.go: Secrets read by reference, e.g. a mounted Docker or Kubernetes secret file or a Vault KV
+entry, rather than set in plain text. A Secret keeps its last value and can be re-read on an interval
+so the secret may be rotated while the service runs.
+*/
+package secrets
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// refreshTimeout bounds each read when a secret is re-read.
+const refreshTimeout = 30 * time.Second
+
+type Provider interface {
+	// Read returns the secret at ref, e.g. a file path or a Vault `mount/path#field`.
+	Read(ctx context.Context, ref string) (string, error)
+}
+
+type fileProvider struct{}
+
+// NewFileProvider returns a Provider that reads secrets from files, ignoring surrounding whitespace.
+func NewFileProvider() Provider {
+	return fileProvider{}
+}
+
+func (fileProvider) Read(ctx context.Context, path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// Secret is the current value of a secret read from a Provider.
+type Secret struct {
+	// name identifies the secret in logs, e.g. the option it was set by.
+	name     string
+	ref      string
+	provider Provider
+	value    atomic.Pointer[string]
+}
+
+// New reads the secret at ref from p, failing if it cannot be read.
+func New(ctx context.Context, name string, p Provider, ref string) (*Secret, error) {
+	s := &Secret{name: name, ref: ref, provider: p}
+	if err := s.Refresh(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Value returns the secret as last read.
+func (s *Secret) Value() string {
+	return *s.value.Load()
+}
+
+// Refresh re-reads the secret, keeping the last value if the read fails.
+func (s *Secret) Refresh(ctx context.Context) error {
+	v, err := s.provider.Read(ctx, s.ref)
+	if err != nil {
+		return err
+	}
+	if old := s.value.Swap(&v); old != nil && *old != v {
+		slog.Info("secret rotated", "secret", s.name)
+	}
+	return nil
+}
+
+// Watch re-reads the secret every interval until ctx is cancelled.
+func (s *Secret) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			rctx, cancel := context.WithTimeout(ctx, refreshTimeout)
+			if err := s.Refresh(rctx); err != nil {
+				slog.Warn("failed to refresh secret, keeping the last value", "secret", s.name, "error", err)
+			}
+			cancel()
+		}
+	}
+}
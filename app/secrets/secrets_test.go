@@ -0,0 +1,56 @@
+package secrets_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"weathersvc/app/secrets"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileProvider_Read(t *testing.T) {
+	t.Run("Should read the file without surrounding whitespace", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "app_id")
+		require.NoError(t, os.WriteFile(path, []byte("  fakeID\n"), 0o600))
+		got, err := secrets.NewFileProvider().Read(context.Background(), path)
+		assert.NoError(t, err)
+		assert.Equal(t, "fakeID", got)
+	})
+	t.Run("Should fail when the file is missing", func(t *testing.T) {
+		_, err := secrets.NewFileProvider().Read(context.Background(), filepath.Join(t.TempDir(), "missing"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestSecret(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "app_id")
+	t.Run("Should fail when the secret cannot be read", func(t *testing.T) {
+		s, err := secrets.New(ctx, "WEATHER_ID", secrets.NewFileProvider(), path)
+		assert.Error(t, err)
+		assert.Nil(t, s)
+	})
+	t.Run("Should keep the last value when a refresh fails", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, []byte("first"), 0o600))
+		s, err := secrets.New(ctx, "WEATHER_ID", secrets.NewFileProvider(), path)
+		require.NoError(t, err)
+		assert.Equal(t, "first", s.Value())
+		require.NoError(t, os.Remove(path))
+		assert.Error(t, s.Refresh(ctx))
+		assert.Equal(t, "first", s.Value())
+	})
+	t.Run("Should pick up a rotated secret while watching", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, []byte("first"), 0o600))
+		s, err := secrets.New(ctx, "WEATHER_ID", secrets.NewFileProvider(), path)
+		require.NoError(t, err)
+		wctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go s.Watch(wctx, 10*time.Millisecond)
+		require.NoError(t, os.WriteFile(path, []byte("second"), 0o600))
+		assert.Eventually(t, func() bool { return s.Value() == "second" }, time.Second, 10*time.Millisecond)
+	})
+}
@@ -0,0 +1,75 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// VaultPrefix marks an option value as a reference to a Vault KV secret, e.g. `vault:secret/weathersvc#app_id`.
+const VaultPrefix = "vault:"
+
+type vaultProvider struct {
+	client    *http.Client
+	addr      string
+	token     string
+	namespace string
+}
+
+// NewVaultProvider returns a Provider that reads secrets from a Vault KV version 2 engine at addr.
+// References are `mount/path#field`, e.g. `secret/weathersvc#app_id`.
+func NewVaultProvider(addr, token, namespace string) Provider {
+	return &vaultProvider{
+		client:    &http.Client{Timeout: 10 * time.Second},
+		addr:      strings.TrimSuffix(addr, "/"),
+		token:     token,
+		namespace: namespace,
+	}
+}
+
+func (v *vaultProvider) Read(ctx context.Context, ref string) (string, error) {
+	path, field, ok := strings.Cut(strings.TrimPrefix(ref, VaultPrefix), "#")
+	mount, key, hasKey := strings.Cut(strings.Trim(path, "/"), "/")
+	if !ok || !hasKey || field == "" {
+		return "", fmt.Errorf("vault reference %q must be mount/path#field", ref)
+	}
+	u, err := url.JoinPath(v.addr, "v1", mount, "data", key)
+	if err != nil {
+		return "", fmt.Errorf("error creating vault request: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return "", fmt.Errorf("error creating vault request: %v", err)
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error sending vault request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return "", fmt.Errorf("vault responded with status %d for %s/%s", resp.StatusCode, mount, key)
+	}
+	var body struct {
+		Data struct {
+			Data map[string]any `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("error unmarshalling vault response: %v", err)
+	}
+	s, ok := body.Data.Data[field].(string)
+	if !ok {
+		return "", fmt.Errorf("vault secret %s/%s has no string field %q", mount, key, field)
+	}
+	return s, nil
+}
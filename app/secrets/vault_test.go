@@ -0,0 +1,70 @@
+package secrets_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"weathersvc/app/secrets"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newVault stands in for a Vault server holding one KV version 2 secret at secret/weathersvc.
+func newVault(t *testing.T) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		if r.URL.Path != "/v1/secret/data/weathersvc" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"data":{"app_id":"vaultID","retries":3},"metadata":{"version":2}}}`))
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestVaultProvider_Read(t *testing.T) {
+	ctx := context.Background()
+	vault := newVault(t)
+	t.Run("Should read a field of a KV secret", func(t *testing.T) {
+		got, err := secrets.NewVaultProvider(vault.URL, "root", "").Read(ctx, "vault:secret/weathersvc#app_id")
+		assert.NoError(t, err)
+		assert.Equal(t, "vaultID", got)
+		got, err = secrets.NewVaultProvider(vault.URL+"/", "root", "").Read(ctx, "secret/weathersvc#app_id")
+		assert.NoError(t, err)
+		assert.Equal(t, "vaultID", got)
+	})
+	t.Run("Should send the namespace when set", func(t *testing.T) {
+		var namespace string
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			namespace = r.Header.Get("X-Vault-Namespace")
+			w.Write([]byte(`{"data":{"data":{"app_id":"vaultID"}}}`))
+		}))
+		defer ts.Close()
+		_, err := secrets.NewVaultProvider(ts.URL, "root", "team-weather").Read(ctx, "secret/weathersvc#app_id")
+		assert.NoError(t, err)
+		assert.Equal(t, "team-weather", namespace)
+	})
+	t.Run("Should fail for invalid references", func(t *testing.T) {
+		for _, ref := range []string{"secret/weathersvc", "weathersvc#app_id", "secret/weathersvc#"} {
+			_, err := secrets.NewVaultProvider(vault.URL, "root", "").Read(ctx, ref)
+			assert.ErrorContains(t, err, "must be mount/path#field", ref)
+		}
+	})
+	t.Run("Should fail when the secret cannot be read", func(t *testing.T) {
+		_, err := secrets.NewVaultProvider(vault.URL, "wrong", "").Read(ctx, "secret/weathersvc#app_id")
+		assert.EqualError(t, err, "vault responded with status 403 for secret/weathersvc")
+		_, err = secrets.NewVaultProvider(vault.URL, "root", "").Read(ctx, "secret/other#app_id")
+		assert.EqualError(t, err, "vault responded with status 404 for secret/other")
+		_, err = secrets.NewVaultProvider(vault.URL, "root", "").Read(ctx, "secret/weathersvc#retries")
+		assert.EqualError(t, err, `vault secret secret/weathersvc has no string field "retries"`)
+	})
+}
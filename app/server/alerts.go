@@ -0,0 +1,103 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"weathersvc/app/alerts"
+	apperrors "weathersvc/app/app_errors"
+
+	"github.com/gorilla/mux"
+)
+
+type AlertRequest struct {
+	Latitude   float64 `json:"latitude"`
+	Longitude  float64 `json:"longitude"`
+	Condition  string  `json:"condition"`
+	WebhookURL string  `json:"webhook_url"`
+}
+
+func createAlertHandler(store alerts.Store, allowedHosts []string) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var inReq AlertRequest
+		if err := json.NewDecoder(r.Body).Decode(&inReq); err != nil {
+			http.Error(w, apperrors.ErrNoBody.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := validateCoordinates(inReq.Latitude, inReq.Longitude); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, err := alerts.ParseExpression(inReq.Condition); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := alerts.ValidateWebhookURL(inReq.WebhookURL, allowedHosts); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rule, err := store.Create(r.Context(), alerts.Rule{
+			Latitude:   inReq.Latitude,
+			Longitude:  inReq.Longitude,
+			Condition:  inReq.Condition,
+			WebhookURL: inReq.WebhookURL,
+		})
+		if err != nil {
+			http.Error(w, apperrors.ErrInternalServiceError.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusCreated, rule)
+	}
+}
+
+func listAlertsHandler(store alerts.Store) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rules, err := store.List(r.Context())
+		if err != nil {
+			http.Error(w, apperrors.ErrInternalServiceError.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusOK, rules)
+	}
+}
+
+func getAlertHandler(store alerts.Store) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rule, err := store.Get(r.Context(), mux.Vars(r)["id"])
+		if err != nil {
+			writeAlertError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, rule)
+	}
+}
+
+func deleteAlertHandler(store alerts.Store) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := store.Delete(r.Context(), mux.Vars(r)["id"]); err != nil {
+			writeAlertError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func deadLettersHandler(e alerts.Engine) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, e.DeadLetters())
+	}
+}
+
+func writeAlertError(w http.ResponseWriter, err error) {
+	if errors.Is(err, apperrors.ErrAlertNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, apperrors.ErrInternalServiceError.Error(), http.StatusInternalServerError)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"weathersvc/app/alerts"
+	"weathersvc/app/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertHandlers(t *testing.T) {
+	s := newTestServer(t, &config.App{Port: "0"}, nil)
+	do := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		if body != nil {
+			require.NoError(t, json.NewEncoder(&buf).Encode(body))
+		}
+		req := httptest.NewRequest(method, path, &buf)
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		s.router.ServeHTTP(rr, req)
+		return rr
+	}
+	var created alerts.Rule
+	t.Run("Should create alert 201", func(t *testing.T) {
+		rr := do("POST", "/alerts", AlertRequest{
+			Latitude:   32.7,
+			Longitude:  -96.8,
+			Condition:  `wind >= "gale winds"`,
+			WebhookURL: "https://hooks.example.com/weather",
+		})
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&created))
+		assert.NotEmpty(t, created.ID)
+		assert.Equal(t, `wind >= "gale winds"`, created.Condition)
+	})
+	t.Run("Should get and list alerts 200", func(t *testing.T) {
+		rr := do("GET", "/alerts/"+created.ID, nil)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var got alerts.Rule
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&got))
+		assert.Equal(t, created.ID, got.ID)
+		rr = do("GET", "/alerts", nil)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var list []alerts.Rule
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&list))
+		assert.Len(t, list, 1)
+	})
+	t.Run("Should list dead letters 200", func(t *testing.T) {
+		rr := do("GET", "/alerts/deadletters", nil)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, "[]", rr.Body.String())
+	})
+	t.Run("Should delete alert 204 then 404", func(t *testing.T) {
+		rr := do("DELETE", "/alerts/"+created.ID, nil)
+		assert.Equal(t, http.StatusNoContent, rr.Code)
+		rr = do("DELETE", "/alerts/"+created.ID, nil)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+		rr = do("GET", "/alerts/"+created.ID, nil)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+		assert.Contains(t, rr.Body.String(), "alert not found")
+	})
+	t.Run("Should fail 400 for invalid alerts", func(t *testing.T) {
+		tests := map[string]AlertRequest{
+			"invalid request: latitude is out of range":                 {Latitude: 91, Longitude: 1, Condition: `temp == "hot"`, WebhookURL: "https://a.b"},
+			"invalid request: unknown wind \"breezy\"":                  {Latitude: 1, Longitude: 1, Condition: `wind == "breezy"`, WebhookURL: "https://a.b"},
+			"invalid request: webhook_url must be an http or https URL": {Latitude: 1, Longitude: 1, Condition: `temp == "hot"`, WebhookURL: "ftp://a.b"},
+			"invalid request: webhook_url must not be a private":        {Latitude: 1, Longitude: 1, Condition: `temp == "hot"`, WebhookURL: "http://169.254.169.254/"},
+		}
+		for want, req := range tests {
+			rr := do("POST", "/alerts", req)
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+			assert.Contains(t, rr.Body.String(), want)
+		}
+		rr := do("POST", "/alerts", nil)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
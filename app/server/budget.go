@@ -0,0 +1,12 @@
+package server
+
+import (
+	"net/http"
+	"weathersvc/app/service"
+)
+
+func budgetHandler(s service.Service) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.UpstreamUsage())
+	}
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"weathersvc/app/service"
+)
+
+// caching is how long clients and caches may reuse weather responses, which carry validators so that
+// clients polling an unchanged condition are answered 304 Not Modified without a body.
+type caching struct {
+	maxAge time.Duration
+	// authenticated is set when responses depend on credentials, which shared caches do not key on.
+	authenticated bool
+}
+
+// notModified sets the Cache-Control, ETag and Last-Modified of a response describing the condition
+// observed at observed and tagged by etag. When the request's If-None-Match, or else If-Modified-Since,
+// shows the client holds the response already, it answers 304 Not Modified and reports true.
+//
+// byURL is set when the request is identified by its URL alone. Only those responses may be kept by
+// shared caches, and only when credentials are not required: conditions looked up by coordinates in the
+// body would be served for any coordinates, and authenticated ones to callers without credentials.
+func (c caching) notModified(w http.ResponseWriter, r *http.Request, etag string, observed time.Time, byURL bool) bool {
+	directives := fmt.Sprintf("max-age=%d", int(c.maxAge/time.Second))
+	if c.maxAge < time.Second {
+		directives = "no-cache"
+	}
+	if !byURL || c.authenticated {
+		directives = "private, " + directives
+	}
+	w.Header().Set("Cache-Control", directives)
+	w.Header().Set("ETag", etag)
+	if !observed.IsZero() {
+		w.Header().Set("Last-Modified", observed.UTC().Format(http.TimeFormat))
+	}
+	if !fresh(r, etag, observed) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// fresh evaluates the request's preconditions as RFC 9110 section 13.2.2 orders them: If-Modified-Since
+// is ignored when If-None-Match is sent.
+func fresh(r *http.Request, etag string, observed time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			// If-None-Match uses the weak comparison
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == "*" || tag == etag {
+				return true
+			}
+		}
+		return false
+	}
+	if observed.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	return !observed.Truncate(time.Second).After(since)
+}
+
+// weatherETag is a strong validator for a response describing cond. It covers everything a response
+// is derived from, with variant for what the response adds, such as a saved location, so it changes
+// whenever the body does other than `age_seconds`, which follows from `observed_at`.
+func weatherETag(cond service.WeatherCond, variant ...any) string {
+	h := sha256.New()
+	json.NewEncoder(h).Encode([]any{cond, variant}) //nolint:errcheck // conditions and places always encode
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"weathersvc/app/config"
+	"weathersvc/app/service"
+	mock_service "weathersvc/mocks/service"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_Caching(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockService := mock_service.NewMockService(ctrl)
+	observed := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	cond := service.WeatherCond{Temp: "hot", Condition: "clear sky", Wind: "calm", ObservedAt: observed}
+	mockService.EXPECT().GetWeather(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ any, _, _ float64) (service.WeatherCond, error) { return cond, nil },
+	).AnyTimes()
+	s := newTestServer(t, &config.App{Port: "0", CacheMaxAge: time.Minute}, mockService)
+	do := func(target string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, bytes.NewBufferString(`{"latitude": 32.78, "longitude": -96.8}`))
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rr := httptest.NewRecorder()
+		s.router.ServeHTTP(rr, req)
+		return rr
+	}
+	t.Run("Should set the caching headers on every version", func(t *testing.T) {
+		for _, target := range []string{"/weather/get", "/v1/weather/get", "/v2/weather/get"} {
+			rr := do(target, nil)
+			require.Equal(t, http.StatusOK, rr.Code, target)
+			assert.Equal(t, "private, max-age=60", rr.Header().Get("Cache-Control"), target)
+			assert.Regexp(t, `^"[0-9a-f]{32}"$`, rr.Header().Get("ETag"), target)
+			assert.Equal(t, "Mon, 19 Oct 2026 12:00:00 GMT", rr.Header().Get("Last-Modified"), target)
+		}
+		assert.NotEqual(t, do("/v1/weather/get", nil).Header().Get("ETag"), do("/v2/weather/get", nil).Header().Get("ETag"))
+	})
+	t.Run("Should answer 304 while the condition is unchanged", func(t *testing.T) {
+		etag := do("/v1/weather/get", nil).Header().Get("ETag")
+		for name, header := range map[string]http.Header{
+			"tag":      {"If-None-Match": {etag}},
+			"weak tag": {"If-None-Match": {`"other", W/` + etag}},
+			"any tag":  {"If-None-Match": {"*"}},
+			"date":     {"If-Modified-Since": {"Mon, 19 Oct 2026 12:00:00 GMT"}},
+		} {
+			rr := do("/v1/weather/get", header)
+			assert.Equal(t, http.StatusNotModified, rr.Code, name)
+			assert.Empty(t, rr.Body.String(), name)
+			assert.Equal(t, etag, rr.Header().Get("ETag"), name)
+			assert.Equal(t, "private, max-age=60", rr.Header().Get("Cache-Control"), name)
+		}
+		legacy := do("/weather/get", http.Header{"If-None-Match": {etag}})
+		assert.Equal(t, http.StatusNotModified, legacy.Code)
+		assert.NotEmpty(t, legacy.Header().Get("Sunset"))
+	})
+	t.Run("Should answer 200 once the condition changes", func(t *testing.T) {
+		etag := do("/v1/weather/get", nil).Header().Get("ETag")
+		cond.Stale = true
+		defer func() { cond.Stale = false }()
+		rr := do("/v1/weather/get", http.Header{"If-None-Match": {etag}})
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.NotEqual(t, etag, rr.Header().Get("ETag"))
+		// If-Modified-Since is ignored when If-None-Match is sent
+		rr = do("/v1/weather/get", http.Header{"If-None-Match": {etag}, "If-Modified-Since": {"Mon, 19 Oct 2026 12:00:00 GMT"}})
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, http.StatusOK, do("/v1/weather/get", http.Header{"If-Modified-Since": {"Mon, 19 Oct 2026 11:59:59 GMT"}}).Code)
+	})
+	t.Run("Should let shared caches keep saved locations, whose URL identifies them", func(t *testing.T) {
+		created := httptest.NewRecorder()
+		s.router.ServeHTTP(created, httptest.NewRequest("POST", "/locations", bytes.NewBufferString(
+			`{"id": "dallas", "name": "Dallas, TX", "latitude": 32.78, "longitude": -96.8, "tags": ["office"]}`)))
+		require.Equal(t, http.StatusCreated, created.Code, created.Body.String())
+		for _, target := range []string{"/v1/weather/get?location_id=dallas", "/v2/weather/get?location_id=dallas"} {
+			rr := do(target, nil)
+			require.Equal(t, http.StatusOK, rr.Code, target)
+			assert.Equal(t, "max-age=60", rr.Header().Get("Cache-Control"), target)
+			assert.Equal(t, http.StatusNotModified, do(target, http.Header{"If-None-Match": {rr.Header().Get("ETag")}}).Code, target)
+		}
+		assert.Empty(t, do("/v1/weather/get?tag=office", nil).Header().Get("ETag"), "lists carry no validators")
+	})
+	t.Run("Should keep saved locations private when credentials are required", func(t *testing.T) {
+		s := newTestServer(t, &config.App{Port: "0", CacheMaxAge: time.Minute, AuthConfig: config.AuthConfig{AdminAPIKey: "admin-key"}}, mockService)
+		do := func(method, target, body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+			req.Header.Set("X-API-Key", "admin-key")
+			rr := httptest.NewRecorder()
+			s.router.ServeHTTP(rr, req)
+			return rr
+		}
+		require.Equal(t, http.StatusCreated, do("POST", "/locations", `{"id": "dallas", "name": "Dallas, TX", "latitude": 32.78, "longitude": -96.8}`).Code)
+		for _, target := range []string{"/v1/weather/get?location_id=dallas", "/v2/weather/get?location_id=dallas"} {
+			rr := do("GET", target, "")
+			require.Equal(t, http.StatusOK, rr.Code, target)
+			assert.Equal(t, "private, max-age=60", rr.Header().Get("Cache-Control"), target)
+		}
+	})
+	t.Run("Should make clients revalidate when the max age is 0", func(t *testing.T) {
+		s := newTestServer(t, &config.App{Port: "0"}, mockService)
+		rr := httptest.NewRecorder()
+		s.router.ServeHTTP(rr, httptest.NewRequest("GET", "/v1/weather/get", bytes.NewBufferString(`{"latitude": 32.78, "longitude": -96.8}`)))
+		assert.Equal(t, "private, no-cache", rr.Header().Get("Cache-Control"))
+	})
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	apperrors "weathersvc/app/app_errors"
+	"weathersvc/app/health"
+	"weathersvc/app/locations"
+)
+
+// healthCheckID is looked up to exercise the locations store read path; it is never created.
+const healthCheckID = "healthcheck"
+
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, health.Report{Status: health.StatusOK, Checks: []health.Result{}})
+}
+
+func readyzHandler(m health.Monitor) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := m.Report()
+		status := http.StatusOK
+		if !report.Ready() {
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, status, report)
+	}
+}
+
+func locationsCheck(store locations.Store) health.Check {
+	return health.Check{
+		Name:     "locations",
+		Critical: true,
+		Run: func(ctx context.Context) (string, error) {
+			if _, err := store.Get(ctx, healthCheckID); err != nil && !errors.Is(err, apperrors.ErrLocationNotFound) {
+				return "", err
+			}
+			return "", nil
+		},
+	}
+}
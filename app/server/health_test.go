@@ -0,0 +1,64 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"weathersvc/app/config"
+	"weathersvc/app/health"
+	mock_service "weathersvc/mocks/service"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthHandlers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	svc := mock_service.NewMockService(ctrl)
+	var upstreamErr error
+	svc.EXPECT().Checks().Return([]health.Check{{
+		Name:     "openweathermap",
+		Critical: true,
+		Run:      func(ctx context.Context) (string, error) { return "", upstreamErr },
+	}})
+	s := newTestServer(t, &config.App{Port: "0"}, svc)
+	do := func(path string) (*httptest.ResponseRecorder, health.Report) {
+		rr := httptest.NewRecorder()
+		s.router.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		var report health.Report
+		assert.NoError(t, json.NewDecoder(rr.Body).Decode(&report))
+		return rr, report
+	}
+	t.Run("Should report liveness 200", func(t *testing.T) {
+		rr, report := do("/healthz")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, health.StatusOK, report.Status)
+	})
+	t.Run("Should not be ready before the first check 503", func(t *testing.T) {
+		rr, _ := do("/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	})
+	t.Run("Should report every dependency when ready 200", func(t *testing.T) {
+		s.health.CheckAll(context.Background())
+		rr, report := do("/readyz")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, health.StatusOK, report.Status)
+		names := []string{}
+		for _, c := range report.Checks {
+			names = append(names, c.Name)
+		}
+		assert.Equal(t, []string{"openweathermap", "locations"}, names)
+	})
+	t.Run("Should not be ready when a critical dependency fails 503", func(t *testing.T) {
+		upstreamErr = errors.New("upstream unreachable")
+		s.health.CheckAll(context.Background())
+		rr, report := do("/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+		assert.Equal(t, health.StatusFailing, report.Status)
+		assert.Equal(t, "upstream unreachable", report.Checks[0].Error)
+	})
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"net/http"
+	"time"
+	apperrors "weathersvc/app/app_errors"
+	"weathersvc/app/history"
+	"weathersvc/app/service"
+)
+
+// defaultHistoryWindow is how far back history goes when `from` is not given.
+const defaultHistoryWindow = 24 * time.Hour
+
+type HistoryResponse struct {
+	Latitude     float64               `json:"latitude"`
+	Longitude    float64               `json:"longitude"`
+	From         time.Time             `json:"from"`
+	To           time.Time             `json:"to"`
+	Step         string                `json:"step,omitempty"`
+	Observations []history.Observation `json:"observations"`
+}
+
+func historyHandler(s service.Service) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lat, lon, err := queryCoordinates(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		q := r.URL.Query()
+		to := time.Now().UTC()
+		if v := q.Get("to"); v != "" {
+			if to, err = time.Parse(time.RFC3339, v); err != nil {
+				http.Error(w, apperrors.CreateInvalidRequestError("to must be an RFC 3339 time").Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		from := to.Add(-defaultHistoryWindow)
+		if v := q.Get("from"); v != "" {
+			if from, err = time.Parse(time.RFC3339, v); err != nil {
+				http.Error(w, apperrors.CreateInvalidRequestError("from must be an RFC 3339 time").Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if !from.Before(to) {
+			http.Error(w, apperrors.CreateInvalidRequestError("from must be before to").Error(), http.StatusBadRequest)
+			return
+		}
+		var step time.Duration
+		if v := q.Get("step"); v != "" {
+			if step, err = time.ParseDuration(v); err != nil || step <= 0 {
+				http.Error(w, apperrors.CreateInvalidRequestError("step must be a positive duration such as 1h").Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		obs, err := s.GetHistory(r.Context(), lat, lon, from, to, step)
+		if err != nil {
+			http.Error(w, apperrors.ErrInternalServiceError.Error(), http.StatusInternalServerError)
+			return
+		}
+		resp := HistoryResponse{
+			Latitude:     lat,
+			Longitude:    lon,
+			From:         from,
+			To:           to,
+			Observations: obs,
+		}
+		if step > 0 {
+			resp.Step = step.String()
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
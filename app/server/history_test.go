@@ -0,0 +1,74 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	apperrors "weathersvc/app/app_errors"
+	"weathersvc/app/history"
+	mock_service "weathersvc/mocks/service"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistoryHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockService := mock_service.NewMockService(ctrl)
+	from := time.Date(2026, 10, 13, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+	t.Run("Should pass 200 with downsampled history", func(t *testing.T) {
+		mockService.EXPECT().GetHistory(gomock.Any(), 32.7, -96.8, from, to, time.Hour).Return([]history.Observation{
+			{Latitude: 32.7, Longitude: -96.8, Time: from, Temp: "moderate", Wind: "calm winds", Samples: 4},
+		}, nil)
+		req := httptest.NewRequest("GET", "/weather/history?lat=32.7&lon=-96.8&from=2026-10-13T00:00:00Z&to=2026-10-14T00:00:00Z&step=1h", nil)
+		rr := httptest.NewRecorder()
+		historyHandler(mockService)(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var got HistoryResponse
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&got))
+		assert.Equal(t, "1h0m0s", got.Step)
+		assert.True(t, got.From.Equal(from))
+		if assert.Len(t, got.Observations, 1) {
+			assert.Equal(t, 4, got.Observations[0].Samples)
+		}
+	})
+	t.Run("Should default to the last 24 hours", func(t *testing.T) {
+		mockService.EXPECT().GetHistory(gomock.Any(), 32.7, -96.8, gomock.Any(), gomock.Any(), time.Duration(0)).DoAndReturn(
+			func(_ interface{}, _, _ float64, from, to time.Time, _ time.Duration) ([]history.Observation, error) {
+				assert.Equal(t, 24*time.Hour, to.Sub(from))
+				return nil, nil
+			})
+		req := httptest.NewRequest("GET", "/weather/history?lat=32.7&lon=-96.8", nil)
+		rr := httptest.NewRecorder()
+		historyHandler(mockService)(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+	t.Run("Should fail 500 when the store fails", func(t *testing.T) {
+		mockService.EXPECT().GetHistory(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, apperrors.ErrInternalServiceError)
+		req := httptest.NewRequest("GET", "/weather/history?lat=32.7&lon=-96.8", nil)
+		rr := httptest.NewRecorder()
+		historyHandler(mockService)(rr, req)
+		assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	})
+	t.Run("Should fail 400 for invalid parameters", func(t *testing.T) {
+		tests := map[string]string{
+			"/weather/history?lat=32.7":                                                             "invalid request: lat and lon query parameters are required",
+			"/weather/history?lat=32.7&lon=-96.8&from=yesterday":                                    "invalid request: from must be an RFC 3339 time",
+			"/weather/history?lat=32.7&lon=-96.8&to=today":                                          "invalid request: to must be an RFC 3339 time",
+			"/weather/history?lat=32.7&lon=-96.8&from=2026-10-14T00:00:00Z&to=2026-10-13T00:00:00Z": "invalid request: from must be before to",
+			"/weather/history?lat=32.7&lon=-96.8&step=-1h":                                          "invalid request: step must be a positive duration such as 1h",
+		}
+		for path, want := range tests {
+			req := httptest.NewRequest("GET", path, nil)
+			rr := httptest.NewRecorder()
+			historyHandler(mockService)(rr, req)
+			assert.Equal(t, http.StatusBadRequest, rr.Code, path)
+			assert.Contains(t, rr.Body.String(), want, path)
+		}
+	})
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+	apperrors "weathersvc/app/app_errors"
+	"weathersvc/app/auth"
+	"weathersvc/app/config"
+
+	"github.com/gorilla/mux"
+)
+
+// bootstrapKeyID is the id of the admin key configured with `ADMIN_API_KEY`.
+const bootstrapKeyID = "admin"
+
+type KeyRequest struct {
+	Name      string `json:"name"`
+	Admin     bool   `json:"admin"`
+	Metrics   bool   `json:"metrics"`
+	PerMinute int    `json:"per_minute"`
+	PerDay    int    `json:"per_day"`
+}
+
+// IssuedKey is returned once when a key is issued; only its hash is kept.
+type IssuedKey struct {
+	auth.Key
+	APIKey string `json:"api_key"`
+}
+
+// newKeyStore opens the configured API key store and installs the bootstrap admin key.
+func newKeyStore(conf config.AuthConfig) (auth.KeyStore, error) {
+	keys := auth.NewMemoryKeyStore()
+	if conf.APIKeysPath != "" {
+		var err error
+		if keys, err = auth.NewFileKeyStore(conf.APIKeysPath); err != nil {
+			return nil, err
+		}
+	}
+	if conf.AdminAPIKey != "" {
+		if err := keys.Import(context.Background(), auth.Key{
+			ID:        bootstrapKeyID,
+			Name:      "ADMIN_API_KEY",
+			Hash:      auth.HashKey(conf.AdminAPIKey),
+			Admin:     true,
+			CreatedAt: time.Now().UTC(),
+		}); err != nil {
+			return nil, err
+		}
+	}
+	if !conf.Enabled() {
+		slog.Warn("API key authentication is disabled: set `API_KEYS_PATH` or `ADMIN_API_KEY` to enable it")
+	}
+	return keys, nil
+}
+
+// newVerifier builds the bearer token verifier, or returns nil when no OIDC issuer is configured.
+func newVerifier(conf config.AuthConfig) (auth.Verifier, error) {
+	if !conf.OIDCEnabled() {
+		return nil, nil
+	}
+	if conf.JWKSFile != "" {
+		keys, err := auth.NewStaticKeySet(conf.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		return auth.NewVerifier(keys, conf.OIDCIssuer, conf.OIDCAudience), nil
+	}
+	url := conf.JWKSURL
+	if url == "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		var err error
+		if url, err = auth.DiscoverJWKSURL(ctx, conf.OIDCIssuer); err != nil {
+			return nil, err
+		}
+	}
+	return auth.NewVerifier(auth.NewRemoteKeySet(url, conf.JWKSRefresh), conf.OIDCIssuer, conf.OIDCAudience), nil
+}
+
+func issueKeyHandler(keys auth.KeyStore) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var inReq KeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&inReq); err != nil {
+			http.Error(w, apperrors.ErrNoBody.Error(), http.StatusBadRequest)
+			return
+		}
+		if inReq.Name == "" {
+			http.Error(w, apperrors.CreateInvalidRequestError("name is required").Error(), http.StatusBadRequest)
+			return
+		}
+		if inReq.Admin && inReq.Metrics {
+			http.Error(w, apperrors.CreateInvalidRequestError("a key is either an admin or a metrics key").Error(), http.StatusBadRequest)
+			return
+		}
+		if inReq.PerMinute < 0 || inReq.PerDay < 0 {
+			http.Error(w, apperrors.CreateInvalidRequestError("quotas must not be negative").Error(), http.StatusBadRequest)
+			return
+		}
+		k, plaintext, err := keys.Issue(r.Context(), auth.Key{
+			Name:      inReq.Name,
+			Admin:     inReq.Admin,
+			Metrics:   inReq.Metrics,
+			PerMinute: inReq.PerMinute,
+			PerDay:    inReq.PerDay,
+		})
+		if err != nil {
+			http.Error(w, apperrors.ErrInternalServiceError.Error(), http.StatusInternalServerError)
+			return
+		}
+		k.Hash = ""
+		writeJSON(w, http.StatusCreated, IssuedKey{Key: k, APIKey: plaintext})
+	}
+}
+
+func listKeysHandler(keys auth.KeyStore) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list, err := keys.List(r.Context())
+		if err != nil {
+			http.Error(w, apperrors.ErrInternalServiceError.Error(), http.StatusInternalServerError)
+			return
+		}
+		for i := range list {
+			list[i].Hash = ""
+		}
+		writeJSON(w, http.StatusOK, list)
+	}
+}
+
+func revokeKeyHandler(keys auth.KeyStore) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := keys.Revoke(r.Context(), mux.Vars(r)["id"]); err != nil {
+			if errors.Is(err, apperrors.ErrKeyNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			http.Error(w, apperrors.ErrInternalServiceError.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
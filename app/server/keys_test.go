@@ -0,0 +1,184 @@
+package server
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"weathersvc/app/auth"
+	"weathersvc/app/config"
+	"weathersvc/app/metrics"
+	mock_service "weathersvc/mocks/service"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyHandlers(t *testing.T) {
+	s := newTestServer(t, &config.App{Port: "0", AuthConfig: config.AuthConfig{
+		AdminAPIKey:     "bootstrap",
+		APIKeyPerMinute: 100,
+		APIKeyPerDay:    1000,
+	}}, nil)
+	do := func(method, path, key string, body interface{}) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		if body != nil {
+			require.NoError(t, json.NewEncoder(&buf).Encode(body))
+		}
+		req := httptest.NewRequest(method, path, &buf)
+		req.Header.Set(auth.HeaderAPIKey, key)
+		rr := httptest.NewRecorder()
+		s.router.ServeHTTP(rr, req)
+		return rr
+	}
+	var issued IssuedKey
+	t.Run("Should reject requests without an API key 401", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, do("GET", "/locations", "", nil).Code)
+	})
+	t.Run("Should serve swagger without an API key", func(t *testing.T) {
+		assert.NotEqual(t, http.StatusUnauthorized, do("GET", "/swagger/index.html", "", nil).Code)
+	})
+	t.Run("Should issue keys with the admin key 201", func(t *testing.T) {
+		rr := do("POST", "/admin/keys", "bootstrap", KeyRequest{Name: "dashboard", PerMinute: 2})
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&issued))
+		assert.NotEmpty(t, issued.APIKey)
+		assert.Empty(t, issued.Hash)
+	})
+	t.Run("Should forbid admin endpoints to other keys 403", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, do("GET", "/admin/keys", issued.APIKey, nil).Code)
+	})
+	t.Run("Should enforce the key quota 429", func(t *testing.T) {
+		rr := do("GET", "/locations", issued.APIKey, nil)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "2", rr.Header().Get("X-RateLimit-Limit"))
+		assert.Equal(t, "0", rr.Header().Get("X-RateLimit-Remaining"))
+		rr = do("GET", "/locations", issued.APIKey, nil)
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+		assert.Contains(t, rr.Body.String(), "API key quota exceeded")
+	})
+	t.Run("Should list keys without hashes 200", func(t *testing.T) {
+		rr := do("GET", "/admin/keys", "bootstrap", nil)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var keys []auth.Key
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&keys))
+		assert.Len(t, keys, 2)
+		for _, k := range keys {
+			assert.Empty(t, k.Hash)
+		}
+	})
+	t.Run("Should revoke keys 204 then 404", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, do("DELETE", "/admin/keys/"+issued.ID, "bootstrap", nil).Code)
+		assert.Equal(t, http.StatusNotFound, do("DELETE", "/admin/keys/"+issued.ID, "bootstrap", nil).Code)
+		assert.Equal(t, http.StatusUnauthorized, do("GET", "/locations", issued.APIKey, nil).Code)
+	})
+}
+
+func TestBearerAuthorization(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	jwks, err := json.Marshal(map[string]interface{}{"keys": []map[string]string{{
+		"kid": "k1",
+		"kty": "RSA",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}})
+	require.NoError(t, err)
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(jwksFile, jwks, 0o600))
+	s := newTestServer(t, &config.App{Port: "0", AuthConfig: config.AuthConfig{
+		OIDCIssuer:      "https://id.example.com",
+		OIDCAudience:    "weathersvc",
+		JWKSFile:        jwksFile,
+		APIKeyPerMinute: 100,
+		APIKeyPerDay:    1000,
+	}}, nil)
+	token := func(scope string) string {
+		tok := jwt.NewWithClaims(jwt.SigningMethodRS256, auth.Claims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    "https://id.example.com",
+				Subject:   "alice",
+				Audience:  jwt.ClaimStrings{"weathersvc"},
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			},
+			Scope: scope,
+		})
+		tok.Header["kid"] = "k1"
+		signed, err := tok.SignedString(key)
+		require.NoError(t, err)
+		return signed
+	}
+	do := func(method, path, bearer string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+bearer)
+		rr := httptest.NewRecorder()
+		s.router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+	t.Run("Should authorize routes by scope", func(t *testing.T) {
+		reader := token("alerts:read locations:read")
+		assert.Equal(t, http.StatusOK, do("GET", "/alerts", reader))
+		assert.Equal(t, http.StatusOK, do("GET", "/locations", reader))
+		assert.Equal(t, http.StatusForbidden, do("DELETE", "/alerts/abc", reader))
+		assert.Equal(t, http.StatusForbidden, do("GET", "/admin/keys", reader))
+	})
+	t.Run("Should allow admins every route", func(t *testing.T) {
+		admin := token("admin")
+		assert.Equal(t, http.StatusNotFound, do("DELETE", "/alerts/abc", admin))
+		assert.Equal(t, http.StatusOK, do("GET", "/admin/keys", admin))
+	})
+	t.Run("Should reject invalid tokens 401", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, do("GET", "/alerts", "not-a-token"))
+	})
+}
+
+func TestMetricsKeys(t *testing.T) {
+	svc := mock_service.NewMockService(gomock.NewController(t))
+	svc.EXPECT().Checks().AnyTimes()
+	got, err := NewServer(&config.App{Port: "0", Env: "test", AuthConfig: config.AuthConfig{AdminAPIKey: "bootstrap"}}, svc, metrics.NewMetrics())
+	require.NoError(t, err)
+	s := got.(*server)
+	do := func(method, path, key string, body interface{}) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		if body != nil {
+			require.NoError(t, json.NewEncoder(&buf).Encode(body))
+		}
+		req := httptest.NewRequest(method, path, &buf)
+		req.Header.Set(auth.HeaderAPIKey, key)
+		rr := httptest.NewRecorder()
+		s.router.ServeHTTP(rr, req)
+		return rr
+	}
+	issue := func(req KeyRequest) IssuedKey {
+		rr := do("POST", "/admin/keys", "bootstrap", req)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		var k IssuedKey
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&k))
+		return k
+	}
+	scraper := issue(KeyRequest{Name: "prometheus", Metrics: true})
+	client := issue(KeyRequest{Name: "dashboard"})
+	t.Run("Should let metrics keys and admins read metrics 200", func(t *testing.T) {
+		assert.True(t, scraper.Metrics)
+		assert.Equal(t, http.StatusOK, do("GET", "/metrics", scraper.APIKey, nil).Code)
+		assert.Equal(t, http.StatusOK, do("GET", "/metrics", "bootstrap", nil).Code)
+	})
+	t.Run("Should forbid metrics keys everything else and other keys the metrics 403", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, do("GET", "/locations", scraper.APIKey, nil).Code)
+		assert.Equal(t, http.StatusForbidden, do("GET", "/admin/keys", scraper.APIKey, nil).Code)
+		assert.Equal(t, http.StatusForbidden, do("GET", "/metrics", client.APIKey, nil).Code)
+	})
+	t.Run("Should reject keys that are both admin and metrics keys 400", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, do("POST", "/admin/keys", "bootstrap", KeyRequest{Name: "both", Admin: true, Metrics: true}).Code)
+	})
+}
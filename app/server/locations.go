@@ -0,0 +1,193 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	apperrors "weathersvc/app/app_errors"
+	"weathersvc/app/locations"
+	"weathersvc/app/service"
+
+	"github.com/gorilla/mux"
+)
+
+// maxConcurrentSites bounds the upstream lookups made for one tag query.
+const maxConcurrentSites = 8
+
+// SiteResponse is the weather at one saved location, or the reason it could not be fetched.
+type SiteResponse struct {
+	Location locations.Location `json:"location"`
+	Weather  *Response          `json:"weather,omitempty"`
+	Error    string             `json:"error,omitempty"`
+}
+
+// hasLocationQuery routes /weather/get requests that name a saved location or tag instead of sending coordinates.
+func hasLocationQuery(r *http.Request, _ *mux.RouteMatch) bool {
+	q := r.URL.Query()
+	return q.Has("location_id") || q.Has("tag")
+}
+
+// savedLocationHandler serves /v1/weather/get, and the legacy /weather/get, for a saved location with
+// `location_id`, or for every saved location with `tag`; both handlers are documented as the one
+// `/weather/get` operation.
+func savedLocationHandler(s service.Service, store locations.Store, cache caching) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if id := q.Get("location_id"); id != "" {
+			loc, err := store.Get(r.Context(), id)
+			if err != nil {
+				writeLocationError(w, err)
+				return
+			}
+			wResp, err := s.GetWeather(r.Context(), loc.Latitude, loc.Longitude)
+			if err != nil {
+				writeServiceError(w, err)
+				return
+			}
+			if cache.notModified(w, r, weatherETag(wResp), wResp.ObservedAt, true) {
+				return
+			}
+			writeJSON(w, http.StatusOK, NewResponse(wResp))
+			return
+		}
+		tag := q.Get("tag")
+		if tag == "" {
+			http.Error(w, apperrors.CreateInvalidRequestError("location_id or tag must not be empty").Error(), http.StatusBadRequest)
+			return
+		}
+		sites, err := store.List(r.Context(), tag)
+		if err != nil {
+			http.Error(w, apperrors.ErrInternalServiceError.Error(), http.StatusInternalServerError)
+			return
+		}
+		out := make([]SiteResponse, len(sites))
+		for i, res := range lookupSites(r.Context(), s, sites) {
+			out[i].Location = sites[i]
+			if res.err != nil {
+				out[i].Error = res.err.Error()
+				continue
+			}
+			resp := NewResponse(res.cond)
+			out[i].Weather = &resp
+		}
+		writeJSON(w, http.StatusOK, out)
+	}
+}
+
+// siteResult is the condition at one saved location, or why it could not be fetched.
+type siteResult struct {
+	cond service.WeatherCond
+	err  error
+}
+
+// lookupSites gets the weather at each site concurrently, at most maxConcurrentSites at a time, and
+// returns the results in the order of sites.
+func lookupSites(ctx context.Context, s service.Service, sites []locations.Location) []siteResult {
+	out := make([]siteResult, len(sites))
+	sem := make(chan struct{}, maxConcurrentSites)
+	var wg sync.WaitGroup
+	for i, loc := range sites {
+		wg.Add(1)
+		go func(i int, loc locations.Location) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			out[i].cond, out[i].err = s.GetWeather(ctx, loc.Latitude, loc.Longitude)
+		}(i, loc)
+	}
+	wg.Wait()
+	return out
+}
+
+func createLocationHandler(store locations.Store) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		loc, err := decodeLocation(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if loc, err = store.Create(r.Context(), loc); err != nil {
+			writeLocationError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, loc)
+	}
+}
+
+func listLocationsHandler(store locations.Store) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		locs, err := store.List(r.Context(), r.URL.Query().Get("tag"))
+		if err != nil {
+			writeLocationError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, locs)
+	}
+}
+
+func getLocationHandler(store locations.Store) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		loc, err := store.Get(r.Context(), mux.Vars(r)["id"])
+		if err != nil {
+			writeLocationError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, loc)
+	}
+}
+
+func updateLocationHandler(store locations.Store) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		loc, err := decodeLocation(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if loc.ID != mux.Vars(r)["id"] {
+			http.Error(w, apperrors.CreateInvalidRequestError("id does not match the path").Error(), http.StatusBadRequest)
+			return
+		}
+		if loc, err = store.Update(r.Context(), loc); err != nil {
+			writeLocationError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, loc)
+	}
+}
+
+func deleteLocationHandler(store locations.Store) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := store.Delete(r.Context(), mux.Vars(r)["id"]); err != nil {
+			writeLocationError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func decodeLocation(r *http.Request) (locations.Location, error) {
+	var loc locations.Location
+	if err := json.NewDecoder(r.Body).Decode(&loc); err != nil {
+		return loc, apperrors.ErrNoBody
+	}
+	if err := loc.Validate(); err != nil {
+		return loc, err
+	}
+	if err := validateCoordinates(loc.Latitude, loc.Longitude); err != nil {
+		return loc, err
+	}
+	return loc, nil
+}
+
+func writeLocationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, apperrors.ErrLocationNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, apperrors.ErrLocationExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, apperrors.ErrInternalServiceError.Error(), http.StatusInternalServerError)
+	}
+}
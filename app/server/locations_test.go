@@ -0,0 +1,123 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	apperrors "weathersvc/app/app_errors"
+	"weathersvc/app/config"
+	"weathersvc/app/locations"
+	"weathersvc/app/service"
+	mock_service "weathersvc/mocks/service"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocationHandlers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockService := mock_service.NewMockService(ctrl)
+	s := newTestServer(t, &config.App{Port: "0"}, mockService)
+	do := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		if body != nil {
+			require.NoError(t, json.NewEncoder(&buf).Encode(body))
+		}
+		req := httptest.NewRequest(method, path, &buf)
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		s.router.ServeHTTP(rr, req)
+		return rr
+	}
+	t.Run("Should create locations 201 then 409", func(t *testing.T) {
+		rr := do("POST", "/locations", locations.Location{ID: "dallas", Name: "Dallas", Latitude: 32.7, Longitude: -96.8, Tags: []string{"warehouses"}})
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		var got locations.Location
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&got))
+		assert.Equal(t, locations.UnitsImperial, got.Units)
+		rr = do("POST", "/locations", locations.Location{ID: "dallas", Name: "Dallas", Latitude: 32.7, Longitude: -96.8})
+		assert.Equal(t, http.StatusConflict, rr.Code)
+		rr = do("POST", "/locations", locations.Location{ID: "austin", Name: "Austin", Latitude: 30.3, Longitude: -97.7, Tags: []string{"warehouses"}})
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		rr = do("POST", "/locations", locations.Location{ID: "hq", Name: "HQ", Latitude: 40.7, Longitude: -74})
+		assert.Equal(t, http.StatusCreated, rr.Code)
+	})
+	t.Run("Should fail 400 for invalid locations", func(t *testing.T) {
+		tests := map[string]locations.Location{
+			"invalid request: id must be 1-64 letters, digits, `-` or `_`": {ID: "a b", Name: "x"},
+			"invalid request: name is required":                            {ID: "x"},
+			"invalid request: latitude is out of range":                    {ID: "x", Name: "x", Latitude: 91},
+			"invalid request: units must be imperial, metric or standard":  {ID: "x", Name: "x", Units: "kelvin"},
+		}
+		for want, loc := range tests {
+			rr := do("POST", "/locations", loc)
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+			assert.Contains(t, rr.Body.String(), want)
+		}
+	})
+	t.Run("Should list and filter locations 200", func(t *testing.T) {
+		var list []locations.Location
+		rr := do("GET", "/locations", nil)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&list))
+		assert.Len(t, list, 3)
+		rr = do("GET", "/locations?tag=warehouses", nil)
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&list))
+		assert.Len(t, list, 2)
+	})
+	t.Run("Should update locations 200", func(t *testing.T) {
+		rr := do("PUT", "/locations/hq", locations.Location{ID: "hq", Name: "Head Office", Latitude: 40.7, Longitude: -74, Units: locations.UnitsMetric})
+		assert.Equal(t, http.StatusOK, rr.Code)
+		rr = do("GET", "/locations/hq", nil)
+		var got locations.Location
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&got))
+		assert.Equal(t, "Head Office", got.Name)
+		assert.Equal(t, locations.UnitsMetric, got.Units)
+		rr = do("PUT", "/locations/hq", locations.Location{ID: "other", Name: "x", Latitude: 1, Longitude: 1})
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		rr = do("PUT", "/locations/nope", locations.Location{ID: "nope", Name: "x", Latitude: 1, Longitude: 1})
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+	t.Run("Should get weather by location_id 200", func(t *testing.T) {
+		mockService.EXPECT().GetWeather(gomock.Any(), 32.7, -96.8).Return(service.WeatherCond{
+			Temp: "hot", Condition: "clear sky", Wind: "calm",
+		}, nil)
+		rr := do("GET", "/weather/get?location_id=dallas", nil)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var got Response
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&got))
+		assert.Equal(t, "hot", got.Temp)
+		rr = do("GET", "/weather/get?location_id=nope", nil)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+	t.Run("Should get weather for every location with a tag 200", func(t *testing.T) {
+		mockService.EXPECT().GetWeather(gomock.Any(), 32.7, -96.8).Return(service.WeatherCond{
+			Temp: "hot", Condition: "clear sky", Wind: "calm",
+		}, nil)
+		mockService.EXPECT().GetWeather(gomock.Any(), 30.3, -97.7).Return(service.WeatherCond{}, apperrors.ErrTooManyRequests)
+		rr := do("GET", "/weather/get?tag=warehouses", nil)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var got []SiteResponse
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&got))
+		require.Len(t, got, 2)
+		byID := map[string]SiteResponse{}
+		for _, site := range got {
+			byID[site.Location.ID] = site
+		}
+		require.NotNil(t, byID["dallas"].Weather)
+		assert.Equal(t, "hot", byID["dallas"].Weather.Temp)
+		assert.Nil(t, byID["austin"].Weather)
+		assert.Equal(t, apperrors.ErrTooManyRequests.Error(), byID["austin"].Error)
+	})
+	t.Run("Should delete locations 204 then 404", func(t *testing.T) {
+		rr := do("DELETE", "/locations/hq", nil)
+		assert.Equal(t, http.StatusNoContent, rr.Code)
+		rr = do("DELETE", "/locations/hq", nil)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+		assert.Contains(t, rr.Body.String(), "location not found")
+	})
+}
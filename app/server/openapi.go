@@ -0,0 +1,406 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"weathersvc/app/alerts"
+	apperrors "weathersvc/app/app_errors"
+	"weathersvc/app/auth"
+	"weathersvc/app/budget"
+	"weathersvc/app/graph"
+	"weathersvc/app/health"
+	"weathersvc/app/locations"
+	"weathersvc/app/openapi"
+)
+
+// OpenAPI describes every route NewServer registers. It is served at `/openapi.json`, checked into
+// docs/openapi.yaml with `weathersvc openapi`, and requests are validated against it.
+func OpenAPI() *openapi.Document {
+	d := openapi.New(openapi.Info{
+		Title:   "WeatherService API",
+		Version: "1.0",
+		Description: "Classifies the current weather at a latitude/longitude from Open Weather Map. " +
+			"Routes other than the probes and these docs require `X-API-Key` or a bearer token once " +
+			"`ADMIN_API_KEY`, `API_KEYS_PATH` or `OIDC_ISSUER` is set; bearer tokens need the scope listed for the route.",
+	})
+	d.Components.SecuritySchemes["ApiKeyAuth"] = openapi.SecurityScheme{
+		Type:        "apiKey",
+		In:          "header",
+		Name:        "X-API-Key",
+		Description: "API key issued by `POST /admin/keys`, or the `ADMIN_API_KEY`.",
+	}
+	d.Components.SecuritySchemes["BearerAuth"] = openapi.SecurityScheme{
+		Type:         "http",
+		Scheme:       "bearer",
+		BearerFormat: "JWT",
+		Description:  "Token from the `OIDC_ISSUER` identity provider carrying the route's scope.",
+	}
+	str := &openapi.Schema{Type: "string"}
+	num := &openapi.Schema{Type: "number"}
+	invalid := openapi.TextResponse("Request invalid and reason")
+	failure := openapi.TextResponse("Internal Service Failure")
+	noContent := &openapi.Response{}
+	query := func(name, description string, schema *openapi.Schema, required bool) openapi.Parameter {
+		return openapi.Parameter{Name: name, In: "query", Description: description, Required: required, Schema: schema}
+	}
+	pathID := func(description string) openapi.Parameter {
+		return openapi.Parameter{Name: "id", In: "path", Description: description, Required: true, Schema: str}
+	}
+	body := func(description string, v any) *openapi.RequestBody {
+		return &openapi.RequestBody{Description: description, Required: true, Content: map[string]openapi.MediaType{openapi.JSON: {Schema: d.RequestSchema(v)}}}
+	}
+
+	d.Add(http.MethodGet, "/healthz", openapi.Operation{
+		Summary:     "Liveness",
+		Description: "Reports that the process is up and serving requests. It does not check dependencies.",
+		Tags:        []string{"probes"},
+		Responses:   openapi.Responses(map[int]*openapi.Response{http.StatusOK: openapi.JSONResponse("", d.Schema(health.Report{}))}),
+	})
+	d.Add(http.MethodGet, "/readyz", openapi.Operation{
+		Summary:     "Readiness",
+		Description: "Reports the latest background check of each dependency. A failing critical dependency makes the service unready; other failures report it as degraded.",
+		Tags:        []string{"probes"},
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			http.StatusOK:                 openapi.JSONResponse("Ready or degraded", d.Schema(health.Report{})),
+			http.StatusServiceUnavailable: openapi.JSONResponse("A critical dependency is failing", d.Schema(health.Report{})),
+		}),
+	})
+	d.Add(http.MethodGet, "/openapi.json", openapi.Operation{
+		Summary:   "API Description",
+		Tags:      []string{"docs"},
+		Responses: openapi.Responses(map[int]*openapi.Response{http.StatusOK: openapi.JSONResponse("This document", &openapi.Schema{Type: "object"})}),
+	})
+
+	// weatherGet documents /weather/get in each version; they take the same requests and differ in
+	// the shape of the weather returned
+	weatherGet := func(description string, weather, sites any) openapi.Operation {
+		// single conditions carry validators; lists by tag do not
+		validators := func(required bool) map[string]*openapi.Header {
+			return map[string]*openapi.Header{
+				"Cache-Control": {Description: "`max-age` from `CACHE_MAX_AGE`, `private` for coordinates in the body", Required: required, Schema: str},
+				"ETag":          {Description: "Strong validator of the condition; send it as `If-None-Match`", Required: required, Schema: str},
+				"Last-Modified": {Description: "When the upstream observed the condition; send it as `If-Modified-Since`", Schema: str},
+			}
+		}
+		ok := openapi.JSONResponse("The classified condition, or one per location with `tag`", &openapi.Schema{OneOf: []*openapi.Schema{
+			d.Schema(weather),
+			d.Schema(sites),
+		}})
+		ok.Headers = validators(false)
+		return openapi.Operation{
+			Summary: "Local Weather Condition",
+			Description: description + " Pass `location_id` for a saved location, or `tag` for a list, one per saved location with the tag, " +
+				"instead of coordinates in the JSON body.",
+			Tags: []string{"weather"},
+			Parameters: []openapi.Parameter{
+				query("location_id", "saved location id", str, false),
+				query("tag", "saved location tag", str, false),
+			},
+			RequestBody: &openapi.RequestBody{
+				Description: "coordinates, required unless `location_id` or `tag` is given. Field names match regardless of case, e.g. `Latitude`.",
+				Content:     map[string]openapi.MediaType{openapi.JSON: {Schema: d.RequestSchema(DecimalRequest{})}},
+			},
+			Responses: openapi.Responses(map[int]*openapi.Response{
+				http.StatusOK:                  ok,
+				http.StatusNotModified:         {Description: "The condition has not changed since `If-None-Match` or `If-Modified-Since`", Headers: validators(true)},
+				http.StatusBadRequest:          invalid,
+				http.StatusNotFound:            openapi.TextResponse("Coordinates or location not found"),
+				http.StatusTooManyRequests:     openapi.TextResponse("Limit reached"),
+				http.StatusInternalServerError: failure,
+			}),
+		}
+	}
+	d.Add(http.MethodGet, "/v1/weather/get", secured(auth.ScopeWeatherRead, weatherGet(
+		"Get the classified weather condition at a latitude/longitude as a `Response`. The v1 shape does not change.",
+		Response{}, []SiteResponse{},
+	)))
+	d.Add(http.MethodGet, "/v2/weather/get", secured(auth.ScopeWeatherRead, weatherGet(
+		"Get the weather at a latitude/longitude as a `WeatherV2`: the feels like temperature and wind speed with their classes, "+
+			"and the place observed. Readings are imperial for coordinates, and in the preferred units of saved locations.",
+		WeatherV2{}, []SiteV2{},
+	)))
+	legacy := weatherGet("Deprecated in favor of `/v1/weather/get`, which it matches, and removed at its `Sunset`.", Response{}, []SiteResponse{})
+	legacy.Deprecated = true
+	for status, res := range legacy.Responses {
+		// the rate limit is also reached before the handler, which sets the headers
+		if status != "429" {
+			// responses such as invalid are shared with other operations, so copy them first
+			withHeaders := *res
+			legacy.Responses[status] = &withHeaders
+			withHeaders.Headers = map[string]*openapi.Header{
+				"Deprecation": {Description: "When the route was deprecated, e.g. `@1792368000`", Required: true, Schema: str},
+				"Sunset":      {Description: "When the route will be removed, as an HTTP date", Required: true, Schema: str},
+				"Link":        {Description: "The `successor-version`", Required: true, Schema: str},
+			}
+			for name, h := range res.Headers {
+				withHeaders.Headers[name] = h
+			}
+		}
+	}
+	d.Add(http.MethodGet, "/weather/get", secured(auth.ScopeWeatherRead, legacy))
+	d.Add(http.MethodGet, "/weather/stream", secured(auth.ScopeWeatherRead, openapi.Operation{
+		Summary:     "Live Weather Condition Stream",
+		Description: "Server-Sent Events stream that pushes a `condition` event, whose data is a `Response`, whenever the classified weather condition changes for the given latitude/longitude. Send `Last-Event-ID` to resume.",
+		Tags:        []string{"weather"},
+		Parameters: []openapi.Parameter{
+			query("lat", "latitude", num, true),
+			query("lon", "longitude", num, true),
+			{Name: "Last-Event-ID", In: "header", Description: "id of the last event received", Schema: &openapi.Schema{Type: "integer"}},
+		},
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			http.StatusOK:                  {Description: "Event stream", Content: map[string]openapi.MediaType{openapi.EventStream: {Schema: str}}},
+			http.StatusBadRequest:          invalid,
+			http.StatusInternalServerError: failure,
+		}),
+	}))
+	d.Add(http.MethodGet, "/weather/history", secured(auth.ScopeWeatherRead, openapi.Operation{
+		Summary:     "Weather History",
+		Description: "Observations recorded for the latitude/longitude between `from` and `to` (RFC 3339, default the last 24 hours). Set `step` (e.g. `1h`) to average observations into buckets.",
+		Tags:        []string{"weather"},
+		Parameters: []openapi.Parameter{
+			query("lat", "latitude", num, true),
+			query("lon", "longitude", num, true),
+			query("from", "start time, RFC 3339", str, false),
+			query("to", "end time, RFC 3339", str, false),
+			query("step", "downsampling bucket width, e.g. 1h", str, false),
+		},
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			http.StatusOK:                  openapi.JSONResponse("", d.Schema(HistoryResponse{})),
+			http.StatusBadRequest:          invalid,
+			http.StatusInternalServerError: failure,
+		}),
+	}))
+	d.Add(http.MethodGet, "/ws", secured(auth.ScopeWeatherRead, openapi.Operation{
+		Summary: "Weather Condition Subscriptions",
+		Description: "Websocket endpoint. Send `{\"action\":\"subscribe\",\"latitude\":32.7,\"longitude\":-96.8}` (or `unsubscribe`) to manage watched locations " +
+			"and receive a `condition` message whenever a watched condition changes.",
+		Tags: []string{"weather"},
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			http.StatusSwitchingProtocols: {},
+			http.StatusBadRequest:         invalid,
+		}),
+	}))
+
+	// graphQL documents /graphql, which takes the same request as a JSON body or query parameters
+	graphQL := func(op openapi.Operation) openapi.Operation {
+		op.Summary = "GraphQL Query"
+		op.Description = "Query the current conditions, forecast, air quality and place name of several places, " +
+			"given or tagged, choosing the fields. Each upstream lookup is made once per query. Queries deeper than " +
+			fmt.Sprintf("%d fields or costing more than %d are refused with an error. ", graph.DefaultMaxDepth, graph.DefaultMaxComplexity) +
+			"The schema is introspectable, and explored at `/graphiql`, unless `ENV` is production."
+		op.Tags = []string{"graphql"}
+		op.Responses = openapi.Responses(map[int]*openapi.Response{
+			http.StatusOK: openapi.JSONResponse("The result, with any errors in the query or its lookups", &openapi.Schema{
+				Type: "object",
+				Properties: map[string]*openapi.Schema{
+					"data": {Type: []any{"object", "null"}},
+					"errors": {Type: "array", Items: &openapi.Schema{
+						Type:       "object",
+						Properties: map[string]*openapi.Schema{"message": str},
+						Required:   []string{"message"},
+					}},
+				},
+			}),
+			http.StatusBadRequest: invalid,
+		})
+		return op
+	}
+	d.Add(http.MethodGet, "/graphql", secured(auth.ScopeWeatherRead, graphQL(openapi.Operation{
+		Parameters: []openapi.Parameter{
+			query("query", "GraphQL query", str, true),
+			query("operationName", "operation to run when the query has several", str, false),
+			query("variables", "JSON object of variables", str, false),
+		},
+	})))
+	d.Add(http.MethodPost, "/graphql", secured(auth.ScopeWeatherRead, graphQL(openapi.Operation{
+		RequestBody: body("GraphQL request", graph.QueryRequest{}),
+	})))
+	d.Add(http.MethodGet, "/graphiql", openapi.Operation{
+		Summary:     "GraphiQL",
+		Description: "Page for exploring `/graphql`. Not served when `ENV` is production.",
+		Tags:        []string{"graphql"},
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			http.StatusOK: {Content: map[string]openapi.MediaType{openapi.HTML: {Schema: str}}},
+		}),
+	})
+
+	d.Add(http.MethodPost, "/alerts", secured(auth.ScopeAlertsWrite, openapi.Operation{
+		Summary: "Create Weather Alert",
+		Description: "Create an alert that POSTs an HMAC-signed notification to `webhook_url` when `condition` (e.g. `wind >= \"gale winds\"`) starts or stops matching at the location. " +
+			"`webhook_url` may not be a private, loopback or link-local address, or outside `ALERT_WEBHOOK_HOSTS` when that is set.",
+		Tags:        []string{"alerts"},
+		RequestBody: body("alert rule", AlertRequest{}),
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			http.StatusCreated:             openapi.JSONResponse("", d.Schema(alerts.Rule{})),
+			http.StatusBadRequest:          invalid,
+			http.StatusInternalServerError: failure,
+		}),
+	}))
+	d.Add(http.MethodGet, "/alerts", secured(auth.ScopeAlertsRead, openapi.Operation{
+		Summary: "List Weather Alerts",
+		Tags:    []string{"alerts"},
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			http.StatusOK:                  openapi.JSONResponse("", d.Schema([]alerts.Rule{})),
+			http.StatusInternalServerError: failure,
+		}),
+	}))
+	d.Add(http.MethodGet, "/alerts/deadletters", secured(auth.ScopeAlertsRead, openapi.Operation{
+		Summary:     "List Undeliverable Alert Notifications",
+		Description: "Notifications whose webhook failed every retry.",
+		Tags:        []string{"alerts"},
+		Responses:   openapi.Responses(map[int]*openapi.Response{http.StatusOK: openapi.JSONResponse("", d.Schema([]alerts.DeadLetter{}))}),
+	}))
+	d.Add(http.MethodGet, "/alerts/{id}", secured(auth.ScopeAlertsRead, openapi.Operation{
+		Summary:    "Get Weather Alert",
+		Tags:       []string{"alerts"},
+		Parameters: []openapi.Parameter{pathID("alert id")},
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			http.StatusOK:                  openapi.JSONResponse("", d.Schema(alerts.Rule{})),
+			http.StatusNotFound:            openapi.TextResponse("Alert not found"),
+			http.StatusInternalServerError: failure,
+		}),
+	}))
+	d.Add(http.MethodDelete, "/alerts/{id}", secured(auth.ScopeAlertsWrite, openapi.Operation{
+		Summary:    "Delete Weather Alert",
+		Tags:       []string{"alerts"},
+		Parameters: []openapi.Parameter{pathID("alert id")},
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			http.StatusNoContent:           noContent,
+			http.StatusNotFound:            openapi.TextResponse("Alert not found"),
+			http.StatusInternalServerError: failure,
+		}),
+	}))
+
+	d.Add(http.MethodPost, "/locations", secured(auth.ScopeLocationsWrite, openapi.Operation{
+		Summary:     "Create Saved Location",
+		Tags:        []string{"locations"},
+		RequestBody: body("location", locations.Location{}),
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			http.StatusCreated:             openapi.JSONResponse("", d.Schema(locations.Location{})),
+			http.StatusBadRequest:          invalid,
+			http.StatusConflict:            openapi.TextResponse("Location already exists"),
+			http.StatusInternalServerError: failure,
+		}),
+	}))
+	d.Add(http.MethodGet, "/locations", secured(auth.ScopeLocationsRead, openapi.Operation{
+		Summary:    "List Saved Locations",
+		Tags:       []string{"locations"},
+		Parameters: []openapi.Parameter{query("tag", "only locations with this tag", str, false)},
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			http.StatusOK:                  openapi.JSONResponse("", d.Schema([]locations.Location{})),
+			http.StatusInternalServerError: failure,
+		}),
+	}))
+	d.Add(http.MethodGet, "/locations/{id}", secured(auth.ScopeLocationsRead, openapi.Operation{
+		Summary:    "Get Saved Location",
+		Tags:       []string{"locations"},
+		Parameters: []openapi.Parameter{pathID("location id")},
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			http.StatusOK:                  openapi.JSONResponse("", d.Schema(locations.Location{})),
+			http.StatusNotFound:            openapi.TextResponse("Location not found"),
+			http.StatusInternalServerError: failure,
+		}),
+	}))
+	d.Add(http.MethodPut, "/locations/{id}", secured(auth.ScopeLocationsWrite, openapi.Operation{
+		Summary:     "Replace Saved Location",
+		Tags:        []string{"locations"},
+		Parameters:  []openapi.Parameter{pathID("location id")},
+		RequestBody: body("location", locations.Location{}),
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			http.StatusOK:                  openapi.JSONResponse("", d.Schema(locations.Location{})),
+			http.StatusBadRequest:          invalid,
+			http.StatusNotFound:            openapi.TextResponse("Location not found"),
+			http.StatusInternalServerError: failure,
+		}),
+	}))
+	d.Add(http.MethodDelete, "/locations/{id}", secured(auth.ScopeLocationsWrite, openapi.Operation{
+		Summary:    "Delete Saved Location",
+		Tags:       []string{"locations"},
+		Parameters: []openapi.Parameter{pathID("location id")},
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			http.StatusNoContent:           noContent,
+			http.StatusNotFound:            openapi.TextResponse("Location not found"),
+			http.StatusInternalServerError: failure,
+		}),
+	}))
+
+	d.Add(http.MethodPost, "/admin/keys", secured(auth.ScopeAdmin, openapi.Operation{
+		Summary:     "Issue API Key",
+		Description: "Issue a new API key. The plaintext `api_key` is only returned in this response. Zero quotas use the service defaults. A `metrics` key may only read `/metrics`.",
+		Tags:        []string{"admin"},
+		RequestBody: body("key", KeyRequest{}),
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			http.StatusCreated:             openapi.JSONResponse("", d.Schema(IssuedKey{})),
+			http.StatusBadRequest:          invalid,
+			http.StatusInternalServerError: failure,
+		}),
+	}))
+	d.Add(http.MethodGet, "/admin/keys", secured(auth.ScopeAdmin, openapi.Operation{
+		Summary: "List API Keys",
+		Tags:    []string{"admin"},
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			http.StatusOK:                  openapi.JSONResponse("", d.Schema([]auth.Key{})),
+			http.StatusInternalServerError: failure,
+		}),
+	}))
+	d.Add(http.MethodDelete, "/admin/keys/{id}", secured(auth.ScopeAdmin, openapi.Operation{
+		Summary:    "Revoke API Key",
+		Tags:       []string{"admin"},
+		Parameters: []openapi.Parameter{pathID("key id")},
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			http.StatusNoContent:           noContent,
+			http.StatusNotFound:            openapi.TextResponse("API key not found"),
+			http.StatusInternalServerError: failure,
+		}),
+	}))
+	d.Add(http.MethodGet, "/admin/budget", secured(auth.ScopeAdmin, openapi.Operation{
+		Summary:     "Upstream Call Budget",
+		Description: "Get how many Open Weather Map calls have been made and refused in the current minute, day and month.",
+		Tags:        []string{"admin"},
+		Responses:   openapi.Responses(map[int]*openapi.Response{http.StatusOK: openapi.JSONResponse("", d.Schema([]budget.Usage{}))}),
+	}))
+	d.Add(http.MethodGet, "/metrics", secured(auth.ScopeMetricsRead, openapi.Operation{
+		Summary: "Prometheus Metrics",
+		Tags:    []string{"admin"},
+		Responses: openapi.Responses(map[int]*openapi.Response{
+			http.StatusOK: {Description: "Metrics in the Prometheus text format", Content: map[string]openapi.MediaType{openapi.Text: {Schema: str}}},
+		}),
+	}))
+	return d
+}
+
+// secured documents that op accepts either credential, the scope a bearer token needs, and the
+// responses of the authentication and rate limit middleware.
+func secured(scope string, op openapi.Operation) openapi.Operation {
+	op.Security = []map[string][]string{{"ApiKeyAuth": {}}, {"BearerAuth": {scope}}}
+	op.Responses["401"] = openapi.TextResponse("Missing or invalid credentials")
+	op.Responses["403"] = openapi.TextResponse("Missing the `" + scope + "` scope")
+	if _, ok := op.Responses["429"]; !ok {
+		op.Responses["429"] = openapi.TextResponse("Rate limit or API key quota exceeded")
+	}
+	return op
+}
+
+// specHandler serves the document as JSON for clients and the Swagger UI.
+func specHandler(spec *openapi.Document) http.Handler {
+	b, err := spec.JSON()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err != nil {
+			http.Error(w, apperrors.ErrInternalServiceError.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", openapi.JSON)
+		w.Write(b) //nolint:errcheck // the client has gone when this fails
+	})
+}
+
+// isTestEnv reports whether env is one tests run in, where responses are checked against the spec.
+func isTestEnv(env string) bool {
+	switch strings.ToLower(env) {
+	case "test", "testing":
+		return true
+	default:
+		return false
+	}
+}
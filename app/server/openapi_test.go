@@ -0,0 +1,146 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+	"weathersvc/app/budget"
+	"weathersvc/app/config"
+	"weathersvc/app/history"
+	"weathersvc/app/metrics"
+	"weathersvc/app/service"
+	mock_service "weathersvc/mocks/service"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestOpenAPI fails when the routes or the JSON the handlers write drift from the API description, or
+// when docs/openapi.yaml was not regenerated after the description changed.
+func TestOpenAPI(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	svc := mock_service.NewMockService(ctrl)
+	observed := time.Date(2026, 10, 13, 12, 0, 0, 0, time.UTC)
+	svc.EXPECT().GetWeather(gomock.Any(), gomock.Any(), gomock.Any()).Return(service.WeatherCond{
+		Temp: "hot", Wind: "calm", Condition: "clear sky", ObservedAt: observed,
+	}, nil).AnyTimes()
+	svc.EXPECT().GetHistory(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return([]history.Observation{
+		{Latitude: 32.7, Longitude: -96.8, Time: observed, Temp: "hot", Wind: "calm"},
+	}, nil).AnyTimes()
+	svc.EXPECT().UpstreamUsage().Return([]budget.Usage{{Window: budget.WindowMinute, Used: 3, Limit: 60}}).AnyTimes()
+	svc.EXPECT().Checks().AnyTimes()
+	// metrics are built so /metrics answers rather than 404 as it does in the other tests
+	built, err := NewServer(&config.App{Port: "0", Env: "test", AuthConfig: config.AuthConfig{AdminAPIKey: "admin-key"}}, svc, metrics.NewMetrics())
+	require.NoError(t, err)
+	s := built.(*server)
+	spec := OpenAPI()
+
+	t.Run("Should document every route and no others", func(t *testing.T) {
+		routes := map[string]bool{}
+		err := s.router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+			tmpl, tErr := route.GetPathTemplate()
+			methods, mErr := route.GetMethods()
+			if tErr != nil || mErr != nil {
+				// subrouters and the swagger UI's prefix route are not operations
+				return nil
+			}
+			for _, m := range methods {
+				routes[m+" "+tmpl] = true
+				assert.NotNil(t, spec.Operation(m, tmpl), "route %s %s is not documented", m, tmpl)
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		for path, item := range spec.Paths {
+			for method := range *item {
+				assert.True(t, routes[strings.ToUpper(method)+" "+path], "%s %s is documented but not routed", method, path)
+			}
+		}
+	})
+	t.Run("Should match the checked in docs/openapi.yaml", func(t *testing.T) {
+		want, err := spec.YAML()
+		require.NoError(t, err)
+		got, err := os.ReadFile("../../docs/openapi.yaml")
+		require.NoError(t, err)
+		assert.Equal(t, string(want), string(got), "regenerate it with `go run . openapi > docs/openapi.yaml`")
+	})
+	t.Run("Should answer every operation as documented", func(t *testing.T) {
+		do := func(method, target, body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+			req.Header.Set("X-API-Key", "admin-key")
+			rr := httptest.NewRecorder()
+			s.router.ServeHTTP(rr, req)
+			return rr
+		}
+		location := `{"id": "dallas", "name": "Dallas, TX", "latitude": 32.78, "longitude": -96.8, "tags": ["office"]}`
+		rr := do("POST", "/alerts", `{"latitude": 32.78, "longitude": -96.8, "condition": "temp >= hot", "webhook_url": "https://example.com/hook"}`)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		var rule struct{ ID string }
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&rule))
+		rr = do("POST", "/admin/keys", `{"name": "ci"}`)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+		var key struct{ ID string }
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&key))
+		// each documented operation, in an order that leaves what later calls need in place
+		calls := []struct {
+			method, target, body string
+			want                 int
+		}{
+			{"GET", "/healthz", "", http.StatusOK},
+			{"GET", "/readyz", "", http.StatusServiceUnavailable},
+			{"GET", "/openapi.json", "", http.StatusOK},
+			{"POST", "/locations", location, http.StatusCreated},
+			{"GET", "/locations?tag=office", "", http.StatusOK},
+			{"GET", "/locations/dallas", "", http.StatusOK},
+			{"PUT", "/locations/dallas", location, http.StatusOK},
+			{"GET", "/weather/get", `{"Latitude": 32.78, "Longitude": -96.8}`, http.StatusOK},
+			{"GET", "/weather/get?location_id=dallas", "", http.StatusOK},
+			{"GET", "/weather/get?tag=office", "", http.StatusOK},
+			{"GET", "/v1/weather/get", `{"latitude": 32.78, "longitude": -96.8}`, http.StatusOK},
+			{"GET", "/v1/weather/get?location_id=dallas", "", http.StatusOK},
+			{"GET", "/v2/weather/get", `{"latitude": 32.78, "longitude": -96.8}`, http.StatusOK},
+			{"GET", "/v2/weather/get?location_id=dallas", "", http.StatusOK},
+			{"GET", "/v2/weather/get?tag=office", "", http.StatusOK},
+			{"GET", "/weather/history?lat=32.7&lon=-96.8", "", http.StatusOK},
+			{"GET", "/graphql?query=%7B%20weather(tag%3A%20%22office%22)%20%7B%20place%20%7B%20name%20%7D%20%7D%20%7D", "", http.StatusOK},
+			{"POST", "/graphql", `{"query": "{ weather(tag: \"office\") { current { summary } } }"}`, http.StatusOK},
+			{"GET", "/graphiql", "", http.StatusOK},
+			// streams are not buffered to be checked, so only their requests are
+			{"GET", "/weather/stream?lat=north&lon=-96.8", "", http.StatusBadRequest},
+			{"GET", "/ws", "", http.StatusBadRequest},
+			{"GET", "/alerts", "", http.StatusOK},
+			{"GET", "/alerts/deadletters", "", http.StatusOK},
+			{"GET", "/alerts/" + rule.ID, "", http.StatusOK},
+			{"DELETE", "/alerts/" + rule.ID, "", http.StatusNoContent},
+			{"DELETE", "/locations/dallas", "", http.StatusNoContent},
+			{"GET", "/admin/keys", "", http.StatusOK},
+			{"DELETE", "/admin/keys/" + key.ID, "", http.StatusNoContent},
+			{"GET", "/admin/budget", "", http.StatusOK},
+			{"GET", "/metrics", "", http.StatusOK},
+		}
+		covered := map[string]bool{"POST /alerts": true, "POST /admin/keys": true}
+		for _, c := range calls {
+			rr := do(c.method, c.target, c.body)
+			assert.Equal(t, c.want, rr.Code, "%s %s: %s", c.method, c.target, rr.Body.String())
+			req := httptest.NewRequest(c.method, c.target, nil)
+			var match mux.RouteMatch
+			if assert.True(t, s.router.Match(req, &match), c.target) {
+				tmpl, _ := match.Route.GetPathTemplate()
+				covered[c.method+" "+tmpl] = true
+			}
+		}
+		for path, item := range spec.Paths {
+			for method := range *item {
+				assert.True(t, covered[strings.ToUpper(method)+" "+path], "add a call to %s %s", method, path)
+			}
+		}
+	})
+}
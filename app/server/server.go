@@ -6,29 +6,60 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net"
 	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"weathersvc/app/alerts"
 	apperrors "weathersvc/app/app_errors"
+	"weathersvc/app/auth"
 	"weathersvc/app/config"
+	"weathersvc/app/graph"
+	"weathersvc/app/health"
+	"weathersvc/app/locations"
+	"weathersvc/app/logging"
+	"weathersvc/app/metrics"
+	"weathersvc/app/openapi"
+	"weathersvc/app/poller"
+	"weathersvc/app/ratelimit"
 	"weathersvc/app/service"
-	_ "weathersvc/docs"
+	"weathersvc/app/tracing"
 
 	"github.com/gorilla/mux"
-	httpSwagger "github.com/swaggo/http-swagger"
+	httpSwagger "github.com/swaggo/http-swagger/v2"
 )
 
 type Server interface {
 	Open() (err error)
 	Close() error
 	Port() int
+	// Handler returns the routes without listening, e.g. to serve them from a test listener.
+	Handler() http.Handler
+	// Reload applies the options of conf that may change while serving: the rate limits.
+	Reload(conf *config.App)
 }
 type server struct {
-	ln     net.Listener
-	server *http.Server
-	router *mux.Router
-	Addr   string
+	ln        net.Listener
+	server    *http.Server
+	router    *mux.Router
+	poller    poller.Poller
+	alerts    alerts.Engine
+	health    health.Monitor
+	locations locations.Store
+	hub       *wsHub
+	// ctx scopes background work started by Open and is cancelled on shutdown.
+	ctx context.Context
+	// rateLimits is read on every request and swapped on Reload.
+	rateLimits *atomic.Pointer[config.RateLimitConfig]
+	// shutdownTimeout is how long Close lets in-flight requests drain.
+	shutdownTimeout time.Duration
+	// closed is closed once Close has drained requests and closed the stores.
+	closed    chan struct{}
+	closeOnce sync.Once
+	Addr      string
 }
 
 type DecimalRequest struct {
@@ -40,39 +71,188 @@ type Response struct {
 	Temp      string
 	Condition string
 	Wind      string
+	// ObservedAt and AgeSeconds describe when the upstream observed the condition.
+	ObservedAt *time.Time `json:"observed_at,omitempty"`
+	AgeSeconds int64      `json:"age_seconds,omitempty"`
+	// Stale is set when the condition is served from cache past its TTL.
+	Stale bool `json:"stale,omitempty"`
 }
 
-func NewServer(conf *config.App, s service.Service) Server {
+func NewServer(conf *config.App, s service.Service, m *metrics.Metrics) (Server, error) {
+	locStore := locations.NewMemoryStore()
+	if conf.LocationsPath != "" {
+		var err error
+		if locStore, err = locations.NewBoltStore(conf.LocationsPath); err != nil {
+			return nil, err
+		}
+	}
+	keys, err := newKeyStore(conf.AuthConfig)
+	if err != nil {
+		locStore.Close()
+		return nil, err
+	}
+	verifier, err := newVerifier(conf.AuthConfig)
+	if err != nil {
+		locStore.Close()
+		return nil, err
+	}
+	spec := OpenAPI()
+	// responses are buffered to be checked, so that only happens in tests
+	validator, err := openapi.NewValidator(spec, isTestEnv(conf.Env))
+	if err != nil {
+		locStore.Close()
+		return nil, err
+	}
+	validate := validator.Middleware
+	// the schema is for exploring, so production does not describe it
+	gql, err := graph.NewHandler(s, locStore, graph.Options{Introspection: !isProductionEnv(conf.Env)})
+	if err != nil {
+		locStore.Close()
+		return nil, err
+	}
+	p := poller.NewPoller(s, conf.PollInterval)
+	monitor := health.NewMonitor(conf.HealthInterval, conf.HealthTimeout, append(s.Checks(), locationsCheck(locStore))...)
 	r := mux.NewRouter()
-	r.HandleFunc("/weather/get", weatherHandler(s)).Methods("GET")
-	r.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
-	return &server{
-		server: &http.Server{
-			Handler:           r,
-			ReadHeaderTimeout: 3 * time.Second,
-		},
-		router: r,
-		Addr:   fmt.Sprintf("0.0.0.0:%s", conf.Port),
+	r.Use(logging.Middleware(slog.Default()), tracing.Middleware(), m.Middleware())
+	r.PathPrefix("/swagger/").Handler(httpSwagger.Handler(httpSwagger.URL("/openapi.json")))
+	r.Handle("/openapi.json", validate(specHandler(spec))).Methods("GET")
+	// probes stay open to the orchestrator without credentials or rate limits
+	r.Handle("/healthz", validate(http.HandlerFunc(healthzHandler))).Methods("GET")
+	r.Handle("/readyz", validate(http.HandlerFunc(readyzHandler(monitor)))).Methods("GET")
+	if !isProductionEnv(conf.Env) {
+		// the page only loads GraphiQL; its queries to /graphql carry the credentials entered in it
+		r.Handle("/graphiql", validate(graph.GraphiQLHandler("/graphql"))).Methods("GET")
 	}
+	// every route below the docs requires an API key or bearer token once authentication is configured
+	api := r.PathPrefix("/").Subrouter()
+	rateLimits := &atomic.Pointer[config.RateLimitConfig]{}
+	rateLimits.Store(&conf.RateLimitConfig)
+	currentLimits := func() config.RateLimitConfig { return *rateLimits.Load() }
+	limiter := ratelimit.NewMemoryStore()
+	// client addresses are limited before authentication, so guessing credentials is limited too
+	api.Use(ratelimit.AddressMiddleware(limiter, currentLimits))
+	// scope authorizes a route once authentication is configured
+	scope := func(_ string, h http.HandlerFunc) http.HandlerFunc { return h }
+	if conf.AuthConfig.Enabled() {
+		api.Use(auth.Middleware(keys, auth.NewQuota(), auth.Limits{PerMinute: conf.APIKeyPerMinute, PerDay: conf.APIKeyPerDay}, verifier))
+		scope = auth.RequireScope
+	}
+	// route limits are keyed by the authenticated caller, so this must run after authentication
+	api.Use(ratelimit.LiveMiddleware(limiter, currentLimits))
+	// callers learn of missing credentials before mistakes in their requests
+	api.Use(validate)
+	// /v1 is pinned to the Response shape and /v2 is where it evolves; the unversioned route serves v1
+	// until it is sunset
+	cache := caching{maxAge: conf.CacheMaxAge, authenticated: conf.AuthConfig.Enabled()}
+	v1 := api.PathPrefix("/v1").Subrouter()
+	v1.HandleFunc("/weather/get", scope(auth.ScopeWeatherRead, savedLocationHandler(s, locStore, cache))).Methods("GET").MatcherFunc(hasLocationQuery)
+	v1.HandleFunc("/weather/get", scope(auth.ScopeWeatherRead, weatherHandler(s, cache))).Methods("GET")
+	v2 := api.PathPrefix("/v2").Subrouter()
+	v2.HandleFunc("/weather/get", scope(auth.ScopeWeatherRead, savedLocationV2Handler(s, locStore, cache))).Methods("GET").MatcherFunc(hasLocationQuery)
+	v2.HandleFunc("/weather/get", scope(auth.ScopeWeatherRead, weatherV2Handler(s, cache))).Methods("GET")
+	api.HandleFunc("/weather/get", deprecated("/v1/weather/get", scope(auth.ScopeWeatherRead, savedLocationHandler(s, locStore, cache)))).Methods("GET").MatcherFunc(hasLocationQuery)
+	api.HandleFunc("/weather/get", deprecated("/v1/weather/get", scope(auth.ScopeWeatherRead, weatherHandler(s, cache)))).Methods("GET")
+	api.HandleFunc("/weather/stream", scope(auth.ScopeWeatherRead, streamHandler(p))).Methods("GET")
+	api.HandleFunc("/weather/history", scope(auth.ScopeWeatherRead, historyHandler(s))).Methods("GET")
+	hub := newWSHub(p, conf.MaxSubscriptions)
+	api.HandleFunc("/ws", scope(auth.ScopeWeatherRead, hub.handler)).Methods("GET")
+	api.HandleFunc("/graphql", scope(auth.ScopeWeatherRead, gql.ServeHTTP)).Methods("GET", "POST")
+	alertStore := alerts.NewMemoryStore()
+	engine := alerts.NewEngine(alertStore, s, alerts.NewWebhookNotifier(conf.AlertWebhookSecret, conf.AlertWebhookAttempts, time.Second, conf.AlertWebhookHosts), conf.AlertInterval, conf.AlertHysteresis)
+	api.HandleFunc("/alerts", scope(auth.ScopeAlertsWrite, createAlertHandler(alertStore, conf.AlertWebhookHosts))).Methods("POST")
+	api.HandleFunc("/alerts", scope(auth.ScopeAlertsRead, listAlertsHandler(alertStore))).Methods("GET")
+	api.HandleFunc("/alerts/deadletters", scope(auth.ScopeAlertsRead, deadLettersHandler(engine))).Methods("GET")
+	api.HandleFunc("/alerts/{id}", scope(auth.ScopeAlertsRead, getAlertHandler(alertStore))).Methods("GET")
+	api.HandleFunc("/alerts/{id}", scope(auth.ScopeAlertsWrite, deleteAlertHandler(alertStore))).Methods("DELETE")
+	api.HandleFunc("/locations", scope(auth.ScopeLocationsWrite, createLocationHandler(locStore))).Methods("POST")
+	api.HandleFunc("/locations", scope(auth.ScopeLocationsRead, listLocationsHandler(locStore))).Methods("GET")
+	api.HandleFunc("/locations/{id}", scope(auth.ScopeLocationsRead, getLocationHandler(locStore))).Methods("GET")
+	api.HandleFunc("/locations/{id}", scope(auth.ScopeLocationsWrite, updateLocationHandler(locStore))).Methods("PUT")
+	api.HandleFunc("/locations/{id}", scope(auth.ScopeLocationsWrite, deleteLocationHandler(locStore))).Methods("DELETE")
+	api.HandleFunc("/admin/keys", auth.RequireScope(auth.ScopeAdmin, issueKeyHandler(keys))).Methods("POST")
+	api.HandleFunc("/admin/keys", auth.RequireScope(auth.ScopeAdmin, listKeysHandler(keys))).Methods("GET")
+	api.HandleFunc("/admin/keys/{id}", auth.RequireScope(auth.ScopeAdmin, revokeKeyHandler(keys))).Methods("DELETE")
+	api.HandleFunc("/admin/budget", scope(auth.ScopeAdmin, budgetHandler(s))).Methods("GET")
+	api.HandleFunc("/metrics", scope(auth.ScopeMetricsRead, m.Handler().ServeHTTP)).Methods("GET")
+	timeouts := conf.ServerConfig
+	if timeouts.ReadHeaderTimeout <= 0 {
+		timeouts.ReadHeaderTimeout = config.DefaultReadHeaderTimeout
+	}
+	if timeouts.ShutdownTimeout <= 0 {
+		timeouts.ShutdownTimeout = config.DefaultShutdownTimeout
+	}
+	svr := &http.Server{
+		Handler:           tracing.Handler(r),
+		ReadHeaderTimeout: timeouts.ReadHeaderTimeout,
+		ReadTimeout:       timeouts.ReadTimeout,
+		WriteTimeout:      timeouts.WriteTimeout,
+		IdleTimeout:       timeouts.IdleTimeout,
+	}
+	// streams never go idle on their own, so end them as soon as shutdown begins
+	svr.RegisterOnShutdown(p.Close)
+	ctx, cancel := context.WithCancel(context.Background())
+	svr.RegisterOnShutdown(cancel)
+	return &server{
+		server:          svr,
+		router:          r,
+		poller:          p,
+		alerts:          engine,
+		health:          monitor,
+		locations:       locStore,
+		hub:             hub,
+		ctx:             ctx,
+		rateLimits:      rateLimits,
+		shutdownTimeout: timeouts.ShutdownTimeout,
+		closed:          make(chan struct{}),
+		Addr:            fmt.Sprintf("0.0.0.0:%s", conf.Port),
+	}, nil
 }
 
-// Open validates the server options and begins listening on the bind address.
+// Open validates the server options and begins listening on the bind address. Once Close is called it
+// returns only after Close is done, so callers may then release what requests were using.
 func (s *server) Open() (err error) {
 	if s.ln, err = net.Listen("tcp", s.Addr); err != nil {
 		return fmt.Errorf("error listening, %w", err)
 	}
-	log.Printf("Server started listening for new connections:Port: %v", s.Port())
+	slog.Info("server started listening for new connections", "port", s.Port())
+	go s.alerts.Run(s.ctx)
+	go s.health.Run(s.ctx)
 	if err := s.server.Serve(s.ln); !errors.Is(err, http.ErrServerClosed) {
 		return err
 	}
+	// Serve returns as soon as Shutdown begins, while requests are still draining
+	<-s.closed
 	return nil
 }
 
 // Close gracefully shuts down the server.
 func (s *server) Close() error {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
 	defer cancel()
-	return s.server.Shutdown(ctx)
+	defer s.closeOnce.Do(func() { close(s.closed) })
+	// hijacked websocket connections are not tracked by Shutdown, so they are notified and closed
+	// while requests drain
+	hubClosed := make(chan struct{})
+	go func() {
+		s.hub.Close()
+		close(hubClosed)
+	}()
+	err := s.server.Shutdown(ctx)
+	<-hubClosed
+	// stores are closed only after in-flight requests have drained
+	if cErr := s.locations.Close(); err == nil {
+		err = cErr
+	}
+	return err
+}
+
+func (s *server) Handler() http.Handler {
+	return s.server.Handler
+}
+
+func (s *server) Reload(conf *config.App) {
+	limits := conf.RateLimitConfig
+	s.rateLimits.Store(&limits)
 }
 
 // Port returns the TCP port for the running server.
@@ -84,81 +264,102 @@ func (s *server) Port() int {
 	return s.ln.Addr().(*net.TCPAddr).Port
 }
 
-// @title WeatherService API
-// @version 1.0
-// @description This is a sample server.
-// @termsOfService http://swagger.io/terms/
-// @contact.name API Support
-// @contact.url http://www.swagger.io/support
-// @contact.email support@swagger.io
-// @license.name Apache 2.0
-// @license.url http://www.apache.org/licenses/LICENSE-2.0.html
-// @host localhost:8001
-// @BasePath /
-
-// Handlers
-// @Summary Local Weather Condition
-// @Description Get the local weather condition by entering your latitude/longitude coordinates.
-// @Success 200 {object} Response
-// @Failure 500 {string} Internal Service Failure
-// @Failure 429 {string} ErrorResponse: Limit reached
-// @Failure 404 {string} ErrorResponse: Coordinates not found
-// @Failure 400 {string} ErrorResponse: Request invalid and reason
-// @Router /weather/get [get]
-func weatherHandler(s service.Service) func(w http.ResponseWriter, r *http.Request) {
+// weatherHandler serves /v1/weather/get, and the legacy /weather/get, for the coordinates in the JSON body.
+func weatherHandler(s service.Service, cache caching) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var inReq DecimalRequest
-		body, err := io.ReadAll(r.Body)
+		inReq, err := decodeCoordinates(r)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		if body == nil {
-			http.Error(w, apperrors.ErrNoBody.Error(), http.StatusBadRequest)
-			return
-		}
-		err = json.Unmarshal(body, &inReq)
+		wResp, err := s.GetWeather(r.Context(), inReq.Latitude, inReq.Longitude)
 		if err != nil {
-			http.Error(w, apperrors.ErrNoBody.Error(), http.StatusBadRequest)
+			writeServiceError(w, err)
 			return
 		}
-		if inReq.Latitude == 0 && inReq.Longitude == 0 {
-			http.Error(w, apperrors.CreateInvalidRequestError("latitude and longitude missing or null").Error(), http.StatusBadRequest)
-			return
-		}
-		if !isValidLat(inReq.Latitude) {
-			http.Error(w, apperrors.CreateInvalidRequestError("latitude is out of range").Error(), http.StatusBadRequest)
-			return
-		}
-		if !isValidLon(inReq.Longitude) {
-			http.Error(w, apperrors.CreateInvalidRequestError("longitude is out of range").Error(), http.StatusBadRequest)
-			return
-		}
-		wResp, err := s.GetWeather(r.Context(), inReq.Latitude, inReq.Longitude)
-		if err != nil {
-			switch err.Error() {
-			case apperrors.ErrTooManyRequests.Error():
-				http.Error(w, err.Error(), http.StatusTooManyRequests)
-			case apperrors.ErrNotFound.Error():
-				http.Error(w, err.Error(), http.StatusNotFound)
-			default:
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-			}
+		if cache.notModified(w, r, weatherETag(wResp), wResp.ObservedAt, false) {
 			return
 		}
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
-		msg := fmt.Sprintf("Outside it is %s with %s and %s.", wResp.Temp, wResp.Wind, wResp.Condition)
-		json.NewEncoder(w).Encode(Response{
-			Message:   msg,
-			Temp:      string(wResp.Temp),
-			Condition: wResp.Condition,
-			Wind:      string(wResp.Wind),
-		})
+		json.NewEncoder(w).Encode(NewResponse(wResp))
 
 	}
 }
 
+// decodeCoordinates reads the coordinates in the JSON body of a /weather/get request of any version.
+func decodeCoordinates(r *http.Request) (DecimalRequest, error) {
+	var inReq DecimalRequest
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return inReq, err
+	}
+	if body == nil {
+		return inReq, apperrors.ErrNoBody
+	}
+	if err := json.Unmarshal(body, &inReq); err != nil {
+		return inReq, apperrors.ErrNoBody
+	}
+	if err := validateCoordinates(inReq.Latitude, inReq.Longitude); err != nil {
+		return inReq, err
+	}
+	return inReq, nil
+}
+
+// writeServiceError maps service errors onto the matching HTTP status.
+func writeServiceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, apperrors.ErrTooManyRequests), errors.Is(err, apperrors.ErrBudgetExhausted):
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	case errors.Is(err, apperrors.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// NewResponse describes a classified condition as the weather endpoints return it.
+func NewResponse(wResp service.WeatherCond) Response {
+	msg := fmt.Sprintf("Outside it is %s with %s and %s.", wResp.Temp, wResp.Wind, wResp.Condition)
+	resp := Response{
+		Message:   msg,
+		Temp:      string(wResp.Temp),
+		Condition: wResp.Condition,
+		Wind:      string(wResp.Wind),
+		Stale:     wResp.Stale,
+	}
+	if !wResp.ObservedAt.IsZero() {
+		observed := wResp.ObservedAt
+		resp.ObservedAt = &observed
+		resp.AgeSeconds = int64(time.Since(observed) / time.Second)
+	}
+	return resp
+}
+
+// validateCoordinates checks decimal latitude/longitude are present and in range.
+func validateCoordinates(lat, lon float64) error {
+	if lat == 0 && lon == 0 {
+		return apperrors.CreateInvalidRequestError("latitude and longitude missing or null")
+	}
+	if !isValidLat(lat) {
+		return apperrors.CreateInvalidRequestError("latitude is out of range")
+	}
+	if !isValidLon(lon) {
+		return apperrors.CreateInvalidRequestError("longitude is out of range")
+	}
+	return nil
+}
+
+// isProductionEnv reports whether env is production, where tools for exploring the API are not served.
+func isProductionEnv(env string) bool {
+	switch strings.ToLower(env) {
+	case "prod", "production":
+		return true
+	default:
+		return false
+	}
+}
+
 func isValidLat(l float64) bool {
 	if l < -90 || l > 90 {
 		return false
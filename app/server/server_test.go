@@ -5,21 +5,49 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
 	"testing"
 	"time"
 	apperrors "weathersvc/app/app_errors"
+	"weathersvc/app/budget"
 	"weathersvc/app/config"
+	"weathersvc/app/locations"
+	"weathersvc/app/metrics"
 	"weathersvc/app/service"
 	mock_service "weathersvc/mocks/service"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+// newTestServer builds the concrete server so tests can reach its router and listener. A nil svc is
+// replaced with a mock that has no health checks. Responses are checked against the API spec unless
+// conf names another environment.
+func newTestServer(t *testing.T, conf *config.App, svc service.Service) *server {
+	t.Helper()
+	if conf.Env == "" {
+		conf.Env = "test"
+	}
+	if svc == nil {
+		svc = mock_service.NewMockService(gomock.NewController(t))
+	}
+	if m, ok := svc.(*mock_service.MockService); ok {
+		m.EXPECT().Checks().AnyTimes()
+	}
+	s, err := NewServer(conf, svc, nil)
+	if err != nil {
+		t.Fatalf("Failed to build server: %v", err)
+	}
+	return s.(*server)
+}
+
 func TestServer_NewServer(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	svc := mock_service.NewMockService(ctrl)
+	svc.EXPECT().Checks().AnyTimes()
 	conf := &config.App{
 		Port: "8081",
 		Env:  "testing",
@@ -29,9 +57,17 @@ func TestServer_NewServer(t *testing.T) {
 		},
 	}
 	t.Run("Should build server", func(t *testing.T) {
-		got := NewServer(conf, svc)
+		got, err := NewServer(conf, svc, nil)
+		assert.NoError(t, err)
 		assert.NotNil(t, got)
 	})
+	t.Run("Should fail to build server when the locations store cannot be opened", func(t *testing.T) {
+		badConf := *conf
+		badConf.LocationsPath = filepath.Join(t.TempDir(), "missing", "locations.db")
+		got, err := NewServer(&badConf, svc, nil)
+		assert.Error(t, err)
+		assert.Nil(t, got)
+	})
 }
 
 func TestGetWeatherHandler(t *testing.T) {
@@ -53,7 +89,7 @@ func TestGetWeatherHandler(t *testing.T) {
 		req := httptest.NewRequest("GET", "/weather/get/", bytes.NewBuffer(body))
 		req.Header.Set("Content-Type", "application/json")
 		rr := httptest.NewRecorder()
-		handler := http.HandlerFunc(weatherHandler(mockService))
+		handler := http.HandlerFunc(weatherHandler(mockService, caching{}))
 		handler.ServeHTTP(rr, req)
 		var respBody Response
 		err = json.NewDecoder(rr.Body).Decode(&respBody)
@@ -62,6 +98,30 @@ func TestGetWeatherHandler(t *testing.T) {
 		assert.Equal(t, "few clouds", respBody.Condition)
 		assert.Equal(t, "calm", respBody.Wind)
 	})
+	t.Run("Should report the age of stale conditions", func(t *testing.T) {
+		observed := time.Now().Add(-5 * time.Minute).UTC().Truncate(time.Second)
+		mockService.EXPECT().GetWeather(gomock.Any(), gomock.Any(), gomock.Any()).Return(service.WeatherCond{
+			Temp:       "hot",
+			Condition:  "few clouds",
+			Wind:       "calm",
+			ObservedAt: observed,
+			Stale:      true,
+		}, nil)
+		body, err := json.Marshal(DecimalRequest{Latitude: 1, Longitude: 1})
+		assert.NoError(t, err)
+		req := httptest.NewRequest("GET", "/weather/get/", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(weatherHandler(mockService, caching{}))
+		handler.ServeHTTP(rr, req)
+		var respBody Response
+		err = json.NewDecoder(rr.Body).Decode(&respBody)
+		assert.NoError(t, err)
+		assert.True(t, respBody.Stale)
+		require.NotNil(t, respBody.ObservedAt)
+		assert.Equal(t, observed, respBody.ObservedAt.UTC())
+		assert.InDelta(t, 300, respBody.AgeSeconds, 5)
+	})
 	t.Run("Should fail 500", func(t *testing.T) {
 		mockService.EXPECT().GetWeather(gomock.Any(), gomock.Any(), gomock.Any()).Return(service.WeatherCond{
 			Temp:      "",
@@ -77,7 +137,7 @@ func TestGetWeatherHandler(t *testing.T) {
 		req := httptest.NewRequest("GET", "/weather/get/", bytes.NewBuffer(body))
 		req.Header.Set("Content-Type", "application/json")
 		rr := httptest.NewRecorder()
-		handler := http.HandlerFunc(weatherHandler(mockService))
+		handler := http.HandlerFunc(weatherHandler(mockService, caching{}))
 		handler.ServeHTTP(rr, req)
 		assert.Equal(t, http.StatusInternalServerError, rr.Code)
 		assert.Contains(t, rr.Body.String(), "internal service error")
@@ -97,7 +157,7 @@ func TestGetWeatherHandler(t *testing.T) {
 		req := httptest.NewRequest("GET", "/weather/get/", bytes.NewBuffer(body))
 		req.Header.Set("Content-Type", "application/json")
 		rr := httptest.NewRecorder()
-		handler := http.HandlerFunc(weatherHandler(mockService))
+		handler := http.HandlerFunc(weatherHandler(mockService, caching{}))
 		handler.ServeHTTP(rr, req)
 		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
 		assert.Contains(t, rr.Body.String(), "too many requests; limit reached")
@@ -117,7 +177,7 @@ func TestGetWeatherHandler(t *testing.T) {
 		req := httptest.NewRequest("GET", "/weather/get/", bytes.NewBuffer(body))
 		req.Header.Set("Content-Type", "application/json")
 		rr := httptest.NewRecorder()
-		handler := http.HandlerFunc(weatherHandler(mockService))
+		handler := http.HandlerFunc(weatherHandler(mockService, caching{}))
 		handler.ServeHTTP(rr, req)
 		assert.Equal(t, http.StatusNotFound, rr.Code)
 		assert.Contains(t, rr.Body.String(), "coordinates not found")
@@ -133,7 +193,7 @@ func TestGetWeatherHandler(t *testing.T) {
 		req := httptest.NewRequest("GET", "/weather/get/", bytes.NewBuffer(body))
 		req.Header.Set("Content-Type", "application/json")
 		rr := httptest.NewRecorder()
-		handler := http.HandlerFunc(weatherHandler(mockService))
+		handler := http.HandlerFunc(weatherHandler(mockService, caching{}))
 		handler.ServeHTTP(rr, req)
 		assert.Equal(t, http.StatusBadRequest, rr.Code)
 		assert.Contains(t, rr.Body.String(), "invalid request: latitude and longitude missing or null")
@@ -148,7 +208,7 @@ func TestGetWeatherHandler(t *testing.T) {
 		req := httptest.NewRequest("GET", "/weather/get/", bytes.NewBuffer(body))
 		req.Header.Set("Content-Type", "application/json")
 		rr := httptest.NewRecorder()
-		handler := http.HandlerFunc(weatherHandler(mockService))
+		handler := http.HandlerFunc(weatherHandler(mockService, caching{}))
 		handler.ServeHTTP(rr, req)
 		assert.Equal(t, http.StatusBadRequest, rr.Code)
 		assert.Contains(t, rr.Body.String(), "invalid request: latitude is out of range")
@@ -163,7 +223,7 @@ func TestGetWeatherHandler(t *testing.T) {
 		req := httptest.NewRequest("GET", "/weather/get/", bytes.NewBuffer(body))
 		req.Header.Set("Content-Type", "application/json")
 		rr := httptest.NewRecorder()
-		handler := http.HandlerFunc(weatherHandler(mockService))
+		handler := http.HandlerFunc(weatherHandler(mockService, caching{}))
 		handler.ServeHTTP(rr, req)
 		assert.Equal(t, http.StatusBadRequest, rr.Code)
 		assert.Contains(t, rr.Body.String(), "invalid request: longitude is out of range")
@@ -172,7 +232,7 @@ func TestGetWeatherHandler(t *testing.T) {
 		req := httptest.NewRequest("GET", "/weather/get/", bytes.NewBuffer([]byte{}))
 		req.Header.Set("Content-Type", "application/json")
 		rr := httptest.NewRecorder()
-		handler := http.HandlerFunc(weatherHandler(mockService))
+		handler := http.HandlerFunc(weatherHandler(mockService, caching{}))
 		handler.ServeHTTP(rr, req)
 		assert.Equal(t, http.StatusBadRequest, rr.Code)
 		assert.Contains(t, rr.Body.String(), "request body missing: see `https://github.com/RebGov/WeatherService")
@@ -181,7 +241,7 @@ func TestGetWeatherHandler(t *testing.T) {
 
 func TestServer_Open(t *testing.T) {
 	conf := &config.App{Port: "0"} // Use port "0" to let the system choose an available port
-	s := NewServer(conf, nil).(*server)
+	s := newTestServer(t, conf, nil)
 	t.Run("Should not be listing on port 0", func(t *testing.T) {
 		done := make(chan error)
 		go func() {
@@ -200,7 +260,7 @@ func TestServer_Open(t *testing.T) {
 
 func TestServer_Close(t *testing.T) {
 	conf := &config.App{Port: "0"} // Use port "0" to let the system choose an available port
-	s := NewServer(conf, nil).(*server)
+	s := newTestServer(t, conf, nil)
 	// Use a goroutine to open the server as it will block
 	go func() {
 		err := s.Open()
@@ -213,9 +273,41 @@ func TestServer_Close(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+// closeRecorder is a locations store that takes a while to close, to tell when Close has finished.
+type closeRecorder struct {
+	locations.Store
+	closed atomic.Bool
+}
+
+func (c *closeRecorder) Close() error {
+	time.Sleep(50 * time.Millisecond)
+	c.closed.Store(true)
+	return c.Store.Close()
+}
+
+func TestServer_OpenWaitsForClose(t *testing.T) {
+	s := newTestServer(t, &config.App{Port: "0"}, nil)
+	store := &closeRecorder{Store: s.locations}
+	s.locations = store
+	done := make(chan error)
+	go func() { done <- s.Open() }()
+	// Wait for the server to start
+	time.Sleep(100 * time.Millisecond)
+	t.Run("Should return from Open only once the stores are closed", func(t *testing.T) {
+		go s.Close()
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+			assert.True(t, store.closed.Load(), "Open returned before Close finished")
+		case <-time.After(5 * time.Second):
+			t.Fatal("Open did not return after Close")
+		}
+	})
+}
+
 func TestServer_Port(t *testing.T) {
 	conf := &config.App{Port: "0"} // Use port "0" to let the system choose an available port
-	s := NewServer(conf, nil).(*server)
+	s := newTestServer(t, conf, nil)
 
 	// Check the port before the server is opened
 	assert.Equal(t, 0, s.Port())
@@ -229,3 +321,113 @@ func TestServer_Port(t *testing.T) {
 	// Check if the server is listening on a port
 	assert.NotEqual(t, 0, s.Port())
 }
+
+func TestServer_RateLimit(t *testing.T) {
+	s := newTestServer(t, &config.App{Port: "0", RateLimitConfig: config.RateLimitConfig{
+		RouteRateLimits: map[string]config.Rate{"/locations/{id}": {Count: 1, Per: time.Minute}},
+	}}, nil)
+	do := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		s.router.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		return rr
+	}
+	t.Run("Should limit configured routes 429", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, do("/locations/a").Code)
+		rr := do("/locations/b")
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+		assert.Equal(t, "60", rr.Header().Get("Retry-After"))
+	})
+	t.Run("Should not limit routes without a rate", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, http.StatusOK, do("/locations").Code)
+		}
+	})
+}
+
+func TestServer_AddressRateLimit(t *testing.T) {
+	s := newTestServer(t, &config.App{Port: "0", AuthConfig: config.AuthConfig{AdminAPIKey: "bootstrap"}, RateLimitConfig: config.RateLimitConfig{
+		IPRateLimit: config.Rate{Count: 2, Per: time.Minute},
+	}}, nil)
+	do := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/locations", nil)
+		req.Header.Set("X-API-Key", key)
+		rr := httptest.NewRecorder()
+		s.router.ServeHTTP(rr, req)
+		return rr
+	}
+	t.Run("Should limit requests with wrong credentials before checking them 429", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, do("guess-1").Code)
+		assert.Equal(t, http.StatusUnauthorized, do("guess-2").Code)
+		rr := do("guess-3")
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+		assert.Equal(t, "30", rr.Header().Get("Retry-After"))
+		assert.Equal(t, http.StatusTooManyRequests, do("bootstrap").Code, "the address is limited whatever its credentials")
+	})
+	t.Run("Should leave the probes unlimited", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		s.router.ServeHTTP(rr, httptest.NewRequest("GET", "/healthz", nil))
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+}
+
+func TestServer_Reload(t *testing.T) {
+	s := newTestServer(t, &config.App{Port: "0", RateLimitConfig: config.RateLimitConfig{
+		RateLimit: config.Rate{Count: 1, Per: time.Minute},
+	}}, nil)
+	do := func() int {
+		rr := httptest.NewRecorder()
+		s.router.ServeHTTP(rr, httptest.NewRequest("GET", "/locations", nil))
+		return rr.Code
+	}
+	t.Run("Should apply reloaded rate limits to new requests", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, do())
+		assert.Equal(t, http.StatusTooManyRequests, do())
+		s.Reload(&config.App{})
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, http.StatusOK, do())
+		}
+	})
+}
+
+func TestServer_Budget(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	svc := mock_service.NewMockService(ctrl)
+	svc.EXPECT().UpstreamUsage().Return([]budget.Usage{{Window: budget.WindowMinute, Used: 3, Limit: 60}})
+	s := newTestServer(t, &config.App{Port: "0"}, svc)
+	t.Run("Should report upstream usage 200", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		s.router.ServeHTTP(rr, httptest.NewRequest("GET", "/admin/budget", nil))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		var got []budget.Usage
+		assert.NoError(t, json.NewDecoder(rr.Body).Decode(&got))
+		assert.Equal(t, 3, got[0].Used)
+	})
+	t.Run("Should map an exhausted budget to 429", func(t *testing.T) {
+		svc.EXPECT().GetWeather(gomock.Any(), gomock.Any(), gomock.Any()).Return(service.WeatherCond{}, apperrors.ErrBudgetExhausted)
+		rr := httptest.NewRecorder()
+		s.router.ServeHTTP(rr, httptest.NewRequest("GET", "/weather/get", bytes.NewBufferString(`{"Latitude": 1, "Longitude": 1}`)))
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	})
+}
+
+func TestServer_Metrics(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	svc := mock_service.NewMockService(ctrl)
+	svc.EXPECT().GetWeather(gomock.Any(), gomock.Any(), gomock.Any()).Return(service.WeatherCond{Temp: "hot", Wind: "calm"}, nil)
+	svc.EXPECT().Checks()
+	got, err := NewServer(&config.App{Port: "0"}, svc, metrics.NewMetrics())
+	assert.NoError(t, err)
+	s := got.(*server)
+	t.Run("Should scrape request metrics by route 200", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		s.router.ServeHTTP(rr, httptest.NewRequest("GET", "/weather/get", bytes.NewBufferString(`{"Latitude": 1, "Longitude": 1}`)))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		rr = httptest.NewRecorder()
+		s.router.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Body.String(), `weathersvc_http_requests_total{code="200",method="get",route="/weather/get"} 1`)
+		assert.Contains(t, rr.Body.String(), "go_goroutines")
+	})
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	apperrors "weathersvc/app/app_errors"
+	"weathersvc/app/poller"
+)
+
+// streamHeartbeat keeps idle SSE connections open through proxies that time out silent responses.
+const streamHeartbeat = 15 * time.Second
+
+func streamHandler(p poller.Poller) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lat, lon, err := queryCoordinates(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var lastEventID uint64
+		if v := r.Header.Get("Last-Event-ID"); v != "" {
+			if lastEventID, err = strconv.ParseUint(v, 10, 64); err != nil {
+				http.Error(w, apperrors.CreateInvalidRequestError("Last-Event-ID must be an event id").Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, apperrors.ErrInternalServiceError.Error(), http.StatusInternalServerError)
+			return
+		}
+		events, unsubscribe := p.Subscribe(lat, lon, lastEventID)
+		defer unsubscribe()
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+		heartbeat := time.NewTicker(streamHeartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+					return
+				}
+			case ev, ok := <-events:
+				if !ok {
+					return
+				}
+				data, err := json.Marshal(NewResponse(ev.Cond))
+				if err != nil {
+					return
+				}
+				if _, err := fmt.Fprintf(w, "id: %d\nevent: condition\ndata: %s\n\n", ev.ID, data); err != nil {
+					return
+				}
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// queryCoordinates reads and validates the `lat` and `lon` query parameters.
+func queryCoordinates(r *http.Request) (float64, float64, error) {
+	q := r.URL.Query()
+	if q.Get("lat") == "" || q.Get("lon") == "" {
+		return 0, 0, apperrors.CreateInvalidRequestError("lat and lon query parameters are required")
+	}
+	lat, err := strconv.ParseFloat(q.Get("lat"), 64)
+	if err != nil || !isValidLat(lat) {
+		return 0, 0, apperrors.CreateInvalidRequestError("latitude is out of range")
+	}
+	lon, err := strconv.ParseFloat(q.Get("lon"), 64)
+	if err != nil || !isValidLon(lon) {
+		return 0, 0, apperrors.CreateInvalidRequestError("longitude is out of range")
+	}
+	return lat, lon, nil
+}
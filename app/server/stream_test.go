@@ -0,0 +1,135 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"weathersvc/app/poller"
+	"weathersvc/app/service"
+	mock_service "weathersvc/mocks/service"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readEvent reads one SSE event, skipping keep-alive comments.
+func readEvent(t *testing.T, r *bufio.Reader) []string {
+	t.Helper()
+	var lines []string
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			if len(lines) > 0 {
+				return lines
+			}
+			continue
+		}
+		if !strings.HasPrefix(line, ":") {
+			lines = append(lines, line)
+		}
+	}
+}
+
+func TestStreamHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockService := mock_service.NewMockService(ctrl)
+	mockService.EXPECT().GetWeather(gomock.Any(), 32.5, -96.5).Return(service.WeatherCond{
+		Temp:      "hot",
+		Condition: "few clouds",
+		Wind:      "calm winds",
+	}, nil).AnyTimes()
+	p := poller.NewPoller(mockService, time.Hour)
+	defer p.Close()
+	ts := httptest.NewServer(http.HandlerFunc(streamHandler(p)))
+	defer ts.Close()
+	t.Run("Should stream condition events", func(t *testing.T) {
+		resp, err := http.Get(ts.URL + "?lat=32.5&lon=-96.5")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+		lines := readEvent(t, bufio.NewReader(resp.Body))
+		assert.Equal(t, []string{
+			"id: 1",
+			"event: condition",
+			`data: {"Message":"Outside it is hot with calm winds and few clouds.","Temp":"hot","Condition":"few clouds","Wind":"calm winds"}`,
+		}, lines)
+	})
+	t.Run("Should resume from Last-Event-ID", func(t *testing.T) {
+		// the condition changes on each of the first 10 polls, making events 1 to 10, then holds
+		var polls atomic.Int32
+		changing := mock_service.NewMockService(ctrl)
+		changing.EXPECT().GetWeather(gomock.Any(), 10.5, 20.5).DoAndReturn(func(_ any, _, _ float64) (service.WeatherCond, error) {
+			return service.WeatherCond{Temp: service.Temperature(strconv.Itoa(int(min(polls.Add(1), 10)))), Condition: "clear sky", Wind: "calm"}, nil
+		}).AnyTimes()
+		p := poller.NewPoller(changing, time.Millisecond)
+		defer p.Close()
+		// a subscriber keeps the location watched until every event has been made
+		events, unsubscribe := p.Subscribe(10.5, 20.5, 0)
+		defer unsubscribe()
+		for ev := range events {
+			if ev.ID == 10 {
+				break
+			}
+		}
+		ts := httptest.NewServer(http.HandlerFunc(streamHandler(p)))
+		defer ts.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, "GET", ts.URL+"?lat=10.5&lon=20.5", nil)
+		require.NoError(t, err)
+		req.Header.Set("Last-Event-ID", "7")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		// read until the request times out, as nothing else should follow the replay
+		var ids []string
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if id, ok := strings.CutPrefix(scanner.Text(), "id: "); ok {
+				ids = append(ids, id)
+			}
+		}
+		assert.Equal(t, []string{"8", "9", "10"}, ids)
+	})
+	t.Run("Should fail 400 for invalid Last-Event-ID", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/weather/stream?lat=32.5&lon=-96.5", nil)
+		req.Header.Set("Last-Event-ID", "abc")
+		rr := httptest.NewRecorder()
+		streamHandler(p)(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Contains(t, rr.Body.String(), "invalid request: Last-Event-ID must be an event id")
+	})
+	t.Run("Should fail 400 for missing coordinates", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/weather/stream?lat=32.5", nil)
+		rr := httptest.NewRecorder()
+		streamHandler(p)(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Contains(t, rr.Body.String(), "invalid request: lat and lon query parameters are required")
+	})
+	t.Run("Should fail 400 for lat out of range", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/weather/stream?lat=91&lon=0", nil)
+		rr := httptest.NewRecorder()
+		streamHandler(p)(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Contains(t, rr.Body.String(), "invalid request: latitude is out of range")
+	})
+	t.Run("Should fail 400 for lon out of range", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/weather/stream?lat=0&lon=abc", nil)
+		rr := httptest.NewRecorder()
+		streamHandler(p)(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Contains(t, rr.Body.String(), "invalid request: longitude is out of range")
+	})
+}
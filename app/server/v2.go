@@ -0,0 +1,165 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+	apperrors "weathersvc/app/app_errors"
+	"weathersvc/app/locations"
+	"weathersvc/app/service"
+)
+
+// WeatherV2 is the weather at a place as /v2/weather/get returns it: lowercase keys, the readings as
+// numbers beside the classes they fall in, and the place nested.
+type WeatherV2 struct {
+	Place       PlaceV2       `json:"place"`
+	Summary     string        `json:"summary"`
+	Condition   string        `json:"condition"`
+	Temperature TemperatureV2 `json:"temperature"`
+	Wind        WindV2        `json:"wind"`
+	// ObservedAt is null when the upstream did not say when it observed the condition.
+	ObservedAt *time.Time `json:"observed_at"`
+	AgeSeconds int64      `json:"age_seconds"`
+	// Stale is set when the condition is served from cache past its TTL.
+	Stale bool `json:"stale"`
+}
+
+// PlaceV2 is where the weather was observed.
+type PlaceV2 struct {
+	// ID is set for saved locations.
+	ID string `json:"id,omitempty"`
+	// Name is the saved location's name, or the upstream's name for the coordinates, which may be empty.
+	Name      string   `json:"name"`
+	Latitude  float64  `json:"latitude"`
+	Longitude float64  `json:"longitude"`
+	Tags      []string `json:"tags,omitempty"`
+}
+
+// TemperatureV2 is the feels like temperature in Unit, F, C or K, and its class, e.g. `hot`.
+type TemperatureV2 struct {
+	FeelsLike float64 `json:"feels_like"`
+	Unit      string  `json:"unit"`
+	Class     string  `json:"class"`
+}
+
+// WindV2 is the wind speed in Unit, mph or m/s, and its class, e.g. `light air`.
+type WindV2 struct {
+	Speed float64 `json:"speed"`
+	Unit  string  `json:"unit"`
+	Class string  `json:"class"`
+}
+
+// SiteV2 is the weather at one saved location, or the reason it could not be fetched.
+type SiteV2 struct {
+	Place   PlaceV2    `json:"place"`
+	Weather *WeatherV2 `json:"weather,omitempty"`
+	Error   string     `json:"error,omitempty"`
+}
+
+// NewWeatherV2 describes a classified condition at place, with the readings in units, one of the
+// locations.Units* systems.
+func NewWeatherV2(cond service.WeatherCond, place PlaceV2, units string) WeatherV2 {
+	resp := WeatherV2{
+		Place:     place,
+		Summary:   fmt.Sprintf("Outside it is %s with %s and %s.", cond.Temp, cond.Wind, cond.Condition),
+		Condition: cond.Condition,
+		// the upstream is asked for imperial readings
+		Temperature: TemperatureV2{FeelsLike: cond.FeelsLike, Unit: "F", Class: string(cond.Temp)},
+		Wind:        WindV2{Speed: cond.WindSpeed, Unit: "mph", Class: string(cond.Wind)},
+		Stale:       cond.Stale,
+	}
+	switch units {
+	case locations.UnitsMetric:
+		resp.Temperature.FeelsLike, resp.Temperature.Unit = round2((cond.FeelsLike-32)*5/9), "C"
+		resp.Wind.Speed, resp.Wind.Unit = round2(cond.WindSpeed*metersPerSecondPerMPH), "m/s"
+	case locations.UnitsStandard:
+		resp.Temperature.FeelsLike, resp.Temperature.Unit = round2((cond.FeelsLike-32)*5/9+273.15), "K"
+		resp.Wind.Speed, resp.Wind.Unit = round2(cond.WindSpeed*metersPerSecondPerMPH), "m/s"
+	}
+	if !cond.ObservedAt.IsZero() {
+		observed := cond.ObservedAt
+		resp.ObservedAt = &observed
+		resp.AgeSeconds = int64(time.Since(observed) / time.Second)
+	}
+	return resp
+}
+
+const metersPerSecondPerMPH = 0.44704
+
+func round2(f float64) float64 {
+	return math.Round(f*100) / 100
+}
+
+// savedPlace nests a saved location in a v2 response.
+func savedPlace(loc locations.Location) PlaceV2 {
+	return PlaceV2{ID: loc.ID, Name: loc.Name, Latitude: loc.Latitude, Longitude: loc.Longitude, Tags: loc.Tags}
+}
+
+// weatherV2Handler serves /v2/weather/get for the coordinates in the JSON body, in imperial units.
+func weatherV2Handler(s service.Service, cache caching) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inReq, err := decodeCoordinates(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		cond, err := s.GetWeather(r.Context(), inReq.Latitude, inReq.Longitude)
+		if err != nil {
+			writeServiceError(w, err)
+			return
+		}
+		place := PlaceV2{Name: cond.Place, Latitude: inReq.Latitude, Longitude: inReq.Longitude}
+		if cache.notModified(w, r, weatherETag(cond, place), cond.ObservedAt, false) {
+			return
+		}
+		writeJSON(w, http.StatusOK, NewWeatherV2(cond, place, locations.UnitsImperial))
+	}
+}
+
+// savedLocationV2Handler serves /v2/weather/get for a saved location with `location_id`, or for every
+// saved location with `tag`, in each location's preferred units.
+func savedLocationV2Handler(s service.Service, store locations.Store, cache caching) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if id := q.Get("location_id"); id != "" {
+			loc, err := store.Get(r.Context(), id)
+			if err != nil {
+				writeLocationError(w, err)
+				return
+			}
+			cond, err := s.GetWeather(r.Context(), loc.Latitude, loc.Longitude)
+			if err != nil {
+				writeServiceError(w, err)
+				return
+			}
+			// the location's name, tags and units are part of the response too
+			if cache.notModified(w, r, weatherETag(cond, loc), cond.ObservedAt, true) {
+				return
+			}
+			writeJSON(w, http.StatusOK, NewWeatherV2(cond, savedPlace(loc), loc.Units))
+			return
+		}
+		tag := q.Get("tag")
+		if tag == "" {
+			http.Error(w, apperrors.CreateInvalidRequestError("location_id or tag must not be empty").Error(), http.StatusBadRequest)
+			return
+		}
+		sites, err := store.List(r.Context(), tag)
+		if err != nil {
+			http.Error(w, apperrors.ErrInternalServiceError.Error(), http.StatusInternalServerError)
+			return
+		}
+		out := make([]SiteV2, len(sites))
+		for i, res := range lookupSites(r.Context(), s, sites) {
+			out[i].Place = savedPlace(sites[i])
+			if res.err != nil {
+				out[i].Error = res.err.Error()
+				continue
+			}
+			resp := NewWeatherV2(res.cond, out[i].Place, sites[i].Units)
+			out[i].Weather = &resp
+		}
+		writeJSON(w, http.StatusOK, out)
+	}
+}
@@ -0,0 +1,101 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	apperrors "weathersvc/app/app_errors"
+	"weathersvc/app/config"
+	"weathersvc/app/locations"
+	"weathersvc/app/service"
+	mock_service "weathersvc/mocks/service"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestV2WeatherHandlers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockService := mock_service.NewMockService(ctrl)
+	s := newTestServer(t, &config.App{Port: "0"}, mockService)
+	observed := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	dallas := service.WeatherCond{
+		Temp: "hot", Condition: "clear sky", Wind: "light air", FeelsLike: 95, WindSpeed: 2, Place: "Dallas", ObservedAt: observed,
+	}
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		s.router.ServeHTTP(rr, httptest.NewRequest(method, target, bytes.NewBufferString(body)))
+		return rr
+	}
+	for _, loc := range []locations.Location{
+		{ID: "dallas", Name: "Dallas, TX", Latitude: 32.78, Longitude: -96.8, Tags: []string{"office"}},
+		{ID: "berlin", Name: "Berlin", Latitude: 52.52, Longitude: 13.4, Tags: []string{"office"}, Units: locations.UnitsMetric},
+	} {
+		body, err := json.Marshal(loc)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusCreated, do("POST", "/locations", string(body)).Code)
+	}
+	t.Run("Should nest the place and give the readings as numbers 200", func(t *testing.T) {
+		mockService.EXPECT().GetWeather(gomock.Any(), 32.78, -96.8).Return(dallas, nil)
+		rr := do("GET", "/v2/weather/get", `{"latitude": 32.78, "longitude": -96.8}`)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var got map[string]any
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&got))
+		assert.Equal(t, map[string]any{"name": "Dallas", "latitude": 32.78, "longitude": -96.8}, got["place"])
+		assert.Equal(t, map[string]any{"feels_like": 95.0, "unit": "F", "class": "hot"}, got["temperature"])
+		assert.Equal(t, map[string]any{"speed": 2.0, "unit": "mph", "class": "light air"}, got["wind"])
+		assert.Equal(t, "Outside it is hot with light air and clear sky.", got["summary"])
+		assert.Equal(t, "clear sky", got["condition"])
+		assert.Equal(t, "2026-10-19T12:00:00Z", got["observed_at"])
+		assert.Equal(t, false, got["stale"])
+	})
+	t.Run("Should give a saved location's readings in its preferred units 200", func(t *testing.T) {
+		mockService.EXPECT().GetWeather(gomock.Any(), 52.52, 13.4).Return(service.WeatherCond{
+			Temp: "cold", Condition: "mist", Wind: "gentle breeze", FeelsLike: 50, WindSpeed: 10,
+		}, nil)
+		rr := do("GET", "/v2/weather/get?location_id=berlin", "")
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var got WeatherV2
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&got))
+		assert.Equal(t, PlaceV2{ID: "berlin", Name: "Berlin", Latitude: 52.52, Longitude: 13.4, Tags: []string{"office"}}, got.Place)
+		assert.Equal(t, TemperatureV2{FeelsLike: 10, Unit: "C", Class: "cold"}, got.Temperature)
+		assert.Equal(t, WindV2{Speed: 4.47, Unit: "m/s", Class: "gentle breeze"}, got.Wind)
+		assert.Nil(t, got.ObservedAt)
+		assert.Equal(t, http.StatusNotFound, do("GET", "/v2/weather/get?location_id=nope", "").Code)
+	})
+	t.Run("Should get the weather for every location with a tag 200", func(t *testing.T) {
+		mockService.EXPECT().GetWeather(gomock.Any(), 32.78, -96.8).Return(dallas, nil)
+		mockService.EXPECT().GetWeather(gomock.Any(), 52.52, 13.4).Return(service.WeatherCond{}, apperrors.ErrTooManyRequests)
+		rr := do("GET", "/v2/weather/get?tag=office", "")
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var got []SiteV2
+		require.NoError(t, json.NewDecoder(rr.Body).Decode(&got))
+		require.Len(t, got, 2)
+		byID := map[string]SiteV2{}
+		for _, site := range got {
+			byID[site.Place.ID] = site
+		}
+		require.NotNil(t, byID["dallas"].Weather)
+		assert.Equal(t, "Dallas, TX", byID["dallas"].Weather.Place.Name, "saved names win over the upstream's")
+		assert.Nil(t, byID["berlin"].Weather)
+		assert.Equal(t, apperrors.ErrTooManyRequests.Error(), byID["berlin"].Error)
+	})
+	t.Run("Should fail 400 for invalid coordinates", func(t *testing.T) {
+		rr := do("GET", "/v2/weather/get", `{"latitude": 91, "longitude": 0}`)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Contains(t, rr.Body.String(), "invalid request: latitude is out of range")
+	})
+}
+
+func TestNewWeatherV2(t *testing.T) {
+	t.Run("Should convert the readings to standard units", func(t *testing.T) {
+		got := NewWeatherV2(service.WeatherCond{FeelsLike: 32, WindSpeed: 1}, PlaceV2{}, locations.UnitsStandard)
+		assert.Equal(t, TemperatureV2{FeelsLike: 273.15, Unit: "K"}, got.Temperature)
+		assert.Equal(t, WindV2{Speed: 0.45, Unit: "m/s"}, got.Wind)
+	})
+}
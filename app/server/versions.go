@@ -0,0 +1,27 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// The unversioned /weather/get serves the v1 shape for consumers written before the API was versioned.
+// It was deprecated when /v1 and /v2 were added and is removed at its sunset.
+//
+//nolint:gochecknoglobals // fixed dates announced to consumers
+var (
+	legacyDeprecatedAt = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	legacySunsetAt     = time.Date(2027, time.April, 19, 0, 0, 0, 0, time.UTC)
+)
+
+// deprecated marks the responses of a legacy route with the Deprecation (RFC 9745) and Sunset
+// (RFC 8594) headers, and links the versioned route that replaces it.
+func deprecated(successor string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", fmt.Sprintf("@%d", legacyDeprecatedAt.Unix()))
+		w.Header().Set("Sunset", legacySunsetAt.Format(http.TimeFormat))
+		w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", successor))
+		h(w, r)
+	}
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"weathersvc/app/config"
+	"weathersvc/app/service"
+	mock_service "weathersvc/mocks/service"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_Versions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockService := mock_service.NewMockService(ctrl)
+	mockService.EXPECT().GetWeather(gomock.Any(), gomock.Any(), gomock.Any()).Return(service.WeatherCond{
+		Temp: "hot", Condition: "clear sky", Wind: "calm",
+	}, nil).AnyTimes()
+	s := newTestServer(t, &config.App{Port: "0"}, mockService)
+	do := func(target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		s.router.ServeHTTP(rr, httptest.NewRequest("GET", target, bytes.NewBufferString(`{"Latitude": 32.78, "Longitude": -96.8}`)))
+		return rr
+	}
+	t.Run("Should serve the v1 shape on the legacy route, marked deprecated", func(t *testing.T) {
+		legacy, v1 := do("/weather/get"), do("/v1/weather/get")
+		assert.Equal(t, http.StatusOK, legacy.Code)
+		assert.JSONEq(t, v1.Body.String(), legacy.Body.String())
+		assert.Equal(t, "@1792368000", legacy.Header().Get("Deprecation"))
+		assert.Equal(t, "Mon, 19 Apr 2027 00:00:00 GMT", legacy.Header().Get("Sunset"))
+		assert.Equal(t, `</v1/weather/get>; rel="successor-version"`, legacy.Header().Get("Link"))
+		legacy = httptest.NewRecorder()
+		s.router.ServeHTTP(legacy, httptest.NewRequest("GET", "/weather/get?location_id=nope", nil))
+		assert.Equal(t, http.StatusNotFound, legacy.Code)
+		assert.NotEmpty(t, legacy.Header().Get("Sunset"), "errors are marked too")
+	})
+	t.Run("Should not mark the versioned routes deprecated", func(t *testing.T) {
+		for _, target := range []string{"/v1/weather/get", "/v2/weather/get"} {
+			rr := do(target)
+			assert.Equal(t, http.StatusOK, rr.Code, target)
+			assert.Empty(t, rr.Header().Get("Deprecation"), target)
+			assert.Empty(t, rr.Header().Get("Sunset"), target)
+		}
+	})
+}
@@ -0,0 +1,295 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+	apperrors "weathersvc/app/app_errors"
+	"weathersvc/app/config"
+	"weathersvc/app/poller"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// wsWriteWait is the time allowed to write a message to the client.
+	wsWriteWait = 10 * time.Second
+	// wsPongWait is the time allowed to read the next pong from the client.
+	wsPongWait = 60 * time.Second
+	// wsPingPeriod must be less than wsPongWait so a healthy client always answers in time.
+	wsPingPeriod = (wsPongWait * 9) / 10
+	// wsSendBuffer is the number of queued messages a client may fall behind before it is disconnected.
+	wsSendBuffer = 32
+	// wsMaxMessageSize is the largest message accepted from a client.
+	wsMaxMessageSize = 1024
+)
+
+// wsRequest is a message sent by the client to manage its subscriptions.
+type wsRequest struct {
+	Action    string  `json:"action"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// wsMessage is a message sent by the server to the client.
+type wsMessage struct {
+	Type      string      `json:"type"`
+	ID        uint64      `json:"id,omitempty"`
+	Location  *wsLocation `json:"location,omitempty"`
+	Condition *Response   `json:"condition,omitempty"`
+	Error     string      `json:"error,omitempty"`
+}
+
+type wsLocation struct {
+	Key       string  `json:"key"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// wsHub tracks open websocket connections so they can be notified on shutdown.
+type wsHub struct {
+	poller   poller.Poller
+	maxSubs  int
+	upgrader websocket.Upgrader
+	mu       sync.Mutex
+	conns    map[*wsConn]struct{}
+	closed   bool
+}
+
+type wsConn struct {
+	hub  *wsHub
+	ws   *websocket.Conn
+	addr string
+	send chan wsMessage
+	// done is closed once the connection should be closed; final, code and reason are written first.
+	done chan struct{}
+	// written is closed once the write pump has returned, after writing any close frame.
+	written chan struct{}
+	once    sync.Once
+	final   *wsMessage
+	code    int
+	reason  string
+	mu      sync.Mutex
+	subs    map[string]func()
+}
+
+func newWSHub(p poller.Poller, maxSubs int) *wsHub {
+	if maxSubs <= 0 {
+		maxSubs = config.DefaultMaxSubscriptions
+	}
+	return &wsHub{
+		poller:  p,
+		maxSubs: maxSubs,
+		conns:   map[*wsConn]struct{}{},
+	}
+}
+
+// Close notifies every connected client that the server is shutting down and closes its socket. It
+// returns once every notice and close frame is written, or wsWriteWait has passed.
+func (h *wsHub) Close() {
+	h.mu.Lock()
+	h.closed = true
+	conns := make([]*wsConn, 0, len(h.conns))
+	for c := range h.conns {
+		c.stop(&wsMessage{Type: "shutdown"}, websocket.CloseGoingAway, "server shutting down")
+		conns = append(conns, c)
+	}
+	// the write pumps remove their connections from the hub as they return
+	h.mu.Unlock()
+	deadline := time.NewTimer(wsWriteWait)
+	defer deadline.Stop()
+	for _, c := range conns {
+		select {
+		case <-c.written:
+		case <-deadline.C:
+			slog.Warn("websocket clients were not all notified of the shutdown in time")
+			return
+		}
+	}
+}
+
+func (h *wsHub) add(c *wsConn) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return false
+	}
+	h.conns[c] = struct{}{}
+	return true
+}
+
+func (h *wsHub) remove(c *wsConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.conns, c)
+}
+
+func (h *wsHub) handler(w http.ResponseWriter, r *http.Request) {
+	ws, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader has already replied with an error status
+		return
+	}
+	c := &wsConn{
+		hub:     h,
+		ws:      ws,
+		addr:    r.RemoteAddr,
+		send:    make(chan wsMessage, wsSendBuffer),
+		done:    make(chan struct{}),
+		written: make(chan struct{}),
+		subs:    map[string]func(){},
+	}
+	if !h.add(c) {
+		ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"), time.Now().Add(wsWriteWait))
+		ws.Close()
+		return
+	}
+	go c.writePump()
+	c.readPump()
+}
+
+// stop closes the connection once, optionally sending a final message before the close frame.
+func (c *wsConn) stop(final *wsMessage, code int, reason string) {
+	c.once.Do(func() {
+		c.final = final
+		c.code = code
+		c.reason = reason
+		close(c.done)
+	})
+}
+
+// enqueue queues msg for the client without blocking, disconnecting clients that fall too far behind.
+func (c *wsConn) enqueue(msg wsMessage) {
+	select {
+	case c.send <- msg:
+	default:
+		slog.Warn("websocket client is too slow, disconnecting", "client", c.addr)
+		c.stop(nil, websocket.CloseTryAgainLater, "slow consumer")
+	}
+}
+
+func (c *wsConn) readPump() {
+	defer c.stop(nil, websocket.CloseNormalClosure, "")
+	c.ws.SetReadLimit(wsMaxMessageSize)
+	c.ws.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.ws.SetPongHandler(func(string) error {
+		return c.ws.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+	for {
+		var req wsRequest
+		if err := c.ws.ReadJSON(&req); err != nil {
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			if !errors.As(err, &syntaxErr) && !errors.As(err, &typeErr) {
+				return
+			}
+			// the frame was read but was not a valid request, tell the client and keep going
+			c.enqueue(wsMessage{Type: "error", Error: apperrors.CreateInvalidRequestError("message must be a JSON subscription request").Error()})
+			continue
+		}
+		switch req.Action {
+		case "subscribe":
+			c.subscribe(req.Latitude, req.Longitude)
+		case "unsubscribe":
+			c.unsubscribe(req.Latitude, req.Longitude)
+		default:
+			c.enqueue(wsMessage{Type: "error", Error: apperrors.CreateInvalidRequestError("action must be subscribe or unsubscribe").Error()})
+		}
+	}
+}
+
+func (c *wsConn) writePump() {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.stop(nil, websocket.CloseAbnormalClosure, "")
+		c.ws.Close()
+		close(c.written)
+		c.hub.remove(c)
+		c.unsubscribeAll()
+	}()
+	for {
+		select {
+		case msg := <-c.send:
+			c.ws.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.ws.WriteJSON(msg); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.ws.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.ws.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-c.done:
+			c.ws.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if c.final != nil {
+				if err := c.ws.WriteJSON(c.final); err != nil {
+					return
+				}
+			}
+			c.ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(c.code, c.reason))
+			return
+		}
+	}
+}
+
+func (c *wsConn) subscribe(lat, lon float64) {
+	if err := validateCoordinates(lat, lon); err != nil {
+		c.enqueue(wsMessage{Type: "error", Error: err.Error()})
+		return
+	}
+	loc := &wsLocation{Key: poller.Key(lat, lon), Latitude: lat, Longitude: lon}
+	c.mu.Lock()
+	select {
+	case <-c.done:
+		// the connection is closing and its subscriptions have already been released
+		c.mu.Unlock()
+		return
+	default:
+	}
+	if _, ok := c.subs[loc.Key]; ok {
+		c.mu.Unlock()
+		c.enqueue(wsMessage{Type: "subscribed", Location: loc})
+		return
+	}
+	if len(c.subs) >= c.hub.maxSubs {
+		c.mu.Unlock()
+		c.enqueue(wsMessage{Type: "error", Location: loc, Error: apperrors.CreateInvalidRequestError(fmt.Sprintf("subscription limit of %d reached", c.hub.maxSubs)).Error()})
+		return
+	}
+	events, unsubscribe := c.hub.poller.Subscribe(lat, lon, 0)
+	c.subs[loc.Key] = unsubscribe
+	c.mu.Unlock()
+	c.enqueue(wsMessage{Type: "subscribed", Location: loc})
+	go func() {
+		for ev := range events {
+			resp := NewResponse(ev.Cond)
+			c.enqueue(wsMessage{Type: "condition", ID: ev.ID, Location: loc, Condition: &resp})
+		}
+	}()
+}
+
+func (c *wsConn) unsubscribe(lat, lon float64) {
+	loc := &wsLocation{Key: poller.Key(lat, lon), Latitude: lat, Longitude: lon}
+	c.mu.Lock()
+	unsubscribe, ok := c.subs[loc.Key]
+	delete(c.subs, loc.Key)
+	c.mu.Unlock()
+	if ok {
+		unsubscribe()
+	}
+	c.enqueue(wsMessage{Type: "unsubscribed", Location: loc})
+}
+
+func (c *wsConn) unsubscribeAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, unsubscribe := range c.subs {
+		unsubscribe()
+		delete(c.subs, key)
+	}
+}
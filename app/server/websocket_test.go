@@ -0,0 +1,161 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"weathersvc/app/config"
+	"weathersvc/app/poller"
+	"weathersvc/app/service"
+	mock_service "weathersvc/mocks/service"
+
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func dialWS(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(url, "http"), nil)
+	require.NoError(t, err)
+	return ws
+}
+
+func readWS(t *testing.T, ws *websocket.Conn) wsMessage {
+	t.Helper()
+	var msg wsMessage
+	ws.SetReadDeadline(time.Now().Add(time.Second))
+	require.NoError(t, ws.ReadJSON(&msg))
+	return msg
+}
+
+func TestWebsocketHandler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockService := mock_service.NewMockService(ctrl)
+	mockService.EXPECT().GetWeather(gomock.Any(), gomock.Any(), gomock.Any()).Return(service.WeatherCond{
+		Temp:      "cold",
+		Condition: "light rain",
+		Wind:      "gentle breeze",
+	}, nil).AnyTimes()
+	p := poller.NewPoller(mockService, time.Hour)
+	defer p.Close()
+	hub := newWSHub(p, 2)
+	ts := httptest.NewServer(http.HandlerFunc(hub.handler))
+	defer ts.Close()
+	t.Run("Should subscribe and receive condition changes", func(t *testing.T) {
+		ws := dialWS(t, ts.URL)
+		defer ws.Close()
+		require.NoError(t, ws.WriteJSON(wsRequest{Action: "subscribe", Latitude: 32.7, Longitude: -96.8}))
+		msg := readWS(t, ws)
+		assert.Equal(t, "subscribed", msg.Type)
+		assert.Equal(t, "32.7000,-96.8000", msg.Location.Key)
+		msg = readWS(t, ws)
+		assert.Equal(t, "condition", msg.Type)
+		assert.Equal(t, "32.7000,-96.8000", msg.Location.Key)
+		require.NotNil(t, msg.Condition)
+		assert.Equal(t, "cold", msg.Condition.Temp)
+		assert.Equal(t, "Outside it is cold with gentle breeze and light rain.", msg.Condition.Message)
+		require.NoError(t, ws.WriteJSON(wsRequest{Action: "unsubscribe", Latitude: 32.7, Longitude: -96.8}))
+		msg = readWS(t, ws)
+		assert.Equal(t, "unsubscribed", msg.Type)
+	})
+	t.Run("Should enforce the per-connection subscription limit", func(t *testing.T) {
+		ws := dialWS(t, ts.URL)
+		defer ws.Close()
+		for _, lat := range []float64{10, 20, 30} {
+			require.NoError(t, ws.WriteJSON(wsRequest{Action: "subscribe", Latitude: lat, Longitude: 10}))
+		}
+		var types []string
+		var errMsg string
+		for i := 0; i < 5; i++ {
+			msg := readWS(t, ws)
+			types = append(types, msg.Type)
+			if msg.Type == "error" {
+				errMsg = msg.Error
+			}
+		}
+		assert.ElementsMatch(t, []string{"subscribed", "subscribed", "condition", "condition", "error"}, types)
+		assert.Equal(t, "invalid request: subscription limit of 2 reached", errMsg)
+	})
+	t.Run("Should reply with an error for invalid requests", func(t *testing.T) {
+		ws := dialWS(t, ts.URL)
+		defer ws.Close()
+		require.NoError(t, ws.WriteMessage(websocket.TextMessage, []byte("not json")))
+		assert.Equal(t, "invalid request: message must be a JSON subscription request", readWS(t, ws).Error)
+		require.NoError(t, ws.WriteJSON(wsRequest{Action: "poll", Latitude: 1, Longitude: 1}))
+		assert.Equal(t, "invalid request: action must be subscribe or unsubscribe", readWS(t, ws).Error)
+		require.NoError(t, ws.WriteJSON(wsRequest{Action: "subscribe", Latitude: 91, Longitude: 1}))
+		assert.Equal(t, "invalid request: latitude is out of range", readWS(t, ws).Error)
+	})
+	t.Run("Should notify clients on Close", func(t *testing.T) {
+		ws := dialWS(t, ts.URL)
+		defer ws.Close()
+		require.NoError(t, ws.WriteJSON(wsRequest{Action: "subscribe", Latitude: 1, Longitude: 1}))
+		assert.Equal(t, "subscribed", readWS(t, ws).Type)
+		assert.Equal(t, "condition", readWS(t, ws).Type)
+		hub.Close()
+		assert.Equal(t, "shutdown", readWS(t, ws).Type)
+		_, _, err := ws.ReadMessage()
+		assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "expected going away close, got %v", err)
+	})
+	t.Run("Should reject connections after Close", func(t *testing.T) {
+		ws := dialWS(t, ts.URL)
+		defer ws.Close()
+		ws.SetReadDeadline(time.Now().Add(time.Second))
+		_, _, err := ws.ReadMessage()
+		assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "expected going away close, got %v", err)
+	})
+}
+
+func TestServer_CloseWebsockets(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockService := mock_service.NewMockService(ctrl)
+	mockService.EXPECT().GetWeather(gomock.Any(), gomock.Any(), gomock.Any()).Return(service.WeatherCond{Temp: "cold"}, nil).AnyTimes()
+	s := newTestServer(t, &config.App{Port: "0"}, mockService)
+	opened := make(chan error)
+	go func() { opened <- s.Open() }()
+	// Wait for the server to start
+	time.Sleep(100 * time.Millisecond)
+	t.Run("Should notify clients before Close returns", func(t *testing.T) {
+		ws := dialWS(t, fmt.Sprintf("http://127.0.0.1:%d/ws", s.Port()))
+		defer ws.Close()
+		require.NoError(t, ws.WriteJSON(wsRequest{Action: "subscribe", Latitude: 1, Longitude: 1}))
+		assert.Equal(t, "subscribed", readWS(t, ws).Type)
+		assert.Equal(t, "condition", readWS(t, ws).Type)
+		require.NoError(t, s.Close())
+		// the notice and close frame are already written, so they are read however late
+		assert.Equal(t, "shutdown", readWS(t, ws).Type)
+		_, _, err := ws.ReadMessage()
+		assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "expected going away close, got %v", err)
+		assert.NoError(t, <-opened)
+	})
+}
+
+func TestWebsocketConn_enqueue(t *testing.T) {
+	t.Run("Should disconnect slow consumers", func(t *testing.T) {
+		c := &wsConn{
+			send: make(chan wsMessage, 1),
+			done: make(chan struct{}),
+		}
+		c.enqueue(wsMessage{Type: "condition"})
+		select {
+		case <-c.done:
+			t.Fatal("connection should stay open while the buffer has room")
+		default:
+		}
+		c.enqueue(wsMessage{Type: "condition"})
+		select {
+		case <-c.done:
+		default:
+			t.Fatal("connection should be closed once the buffer is full")
+		}
+		assert.Equal(t, websocket.CloseTryAgainLater, c.code)
+		assert.Equal(t, "slow consumer", c.reason)
+	})
+}
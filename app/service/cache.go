@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+	"weathersvc/app/logging"
+)
+
+// refreshTimeout bounds a background refresh, which outlives the request that started it.
+const refreshTimeout = 30 * time.Second
+
+// lastKnownGood keeps the latest classified condition per location. Entries are served as fresh
+// until ttl, then served stale while a background refresh runs, and dropped after maxStale.
+type lastKnownGood struct {
+	ttl      time.Duration
+	maxStale time.Duration
+	now      func() time.Time
+	mu       sync.Mutex
+	entries  map[string]cacheEntry
+	// refreshing holds the locations with a background refresh in flight, so each runs once.
+	refreshing map[string]bool
+	swept      time.Time
+	wg         sync.WaitGroup
+}
+
+type cacheEntry struct {
+	cond    WeatherCond
+	fetched time.Time
+}
+
+func newLastKnownGood(ttl, maxStale time.Duration) *lastKnownGood {
+	return &lastKnownGood{
+		ttl:        ttl,
+		maxStale:   maxStale,
+		now:        time.Now,
+		entries:    map[string]cacheEntry{},
+		refreshing: map[string]bool{},
+	}
+}
+
+func cacheKey(lat, lon float64) string {
+	return fmt.Sprintf("%.4f,%.4f", lat, lon)
+}
+
+// get returns the cached condition for key and how long ago it was fetched.
+func (c *lastKnownGood) get(key string) (WeatherCond, time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return WeatherCond{}, 0, false
+	}
+	return e.cond, c.now().Sub(e.fetched), true
+}
+
+// limits returns the ttl and maxStale entries are served under.
+func (c *lastKnownGood) limits() (time.Duration, time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ttl, c.maxStale
+}
+
+// setLimits changes the ttl and maxStale, applying to cached entries as well as new ones.
+func (c *lastKnownGood) setLimits(ttl, maxStale time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttl, c.maxStale = ttl, maxStale
+}
+
+func (c *lastKnownGood) put(key string, cond WeatherCond) {
+	now := c.now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = cacheEntry{cond: cond, fetched: now}
+	if now.Sub(c.swept) < c.maxStale {
+		return
+	}
+	for k, e := range c.entries {
+		if now.Sub(e.fetched) >= c.maxStale {
+			delete(c.entries, k)
+		}
+	}
+	c.swept = now
+}
+
+// len returns the number of cached locations, including stale ones not yet swept.
+func (c *lastKnownGood) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// refresh runs fetch in the background unless a refresh for key is already running. On failure the
+// stale entry is kept, so it goes on being served until it passes maxStale. The refresh keeps the
+// values of ctx, such as its logger, but not its cancellation.
+func (c *lastKnownGood) refresh(ctx context.Context, key string, fetch func(ctx context.Context) (WeatherCond, error)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.refreshing[key] {
+		return
+	}
+	c.refreshing[key] = true
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), refreshTimeout)
+		defer cancel()
+		cond, err := fetch(ctx)
+		if err != nil {
+			logging.FromContext(ctx).Warn("failed to refresh, serving the last known condition", "location", key, "error", err)
+		} else {
+			c.put(key, cond)
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(c.refreshing, key)
+	}()
+}
+
+// wait blocks until background refreshes finish.
+func (c *lastKnownGood) wait() {
+	c.wg.Wait()
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"weathersvc/app/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ForecastStep is the condition forecast for one 3 hour step, classified on the same scales as WeatherCond.
+type ForecastStep struct {
+	Time      time.Time
+	Temp      Temperature
+	Condition string
+	Wind      Wind
+	// FeelsLike (°F) and WindSpeed (mph) are the readings Temp and Wind were classified from.
+	FeelsLike float64
+	WindSpeed float64
+	// PrecipitationChance is the probability of precipitation, from 0 to 1.
+	PrecipitationChance float64
+}
+
+// Forecast is the upstream's forecast for the next 5 days.
+type Forecast struct {
+	// Place is the upstream's name for the place forecast, which may be empty.
+	Place string
+	Steps []ForecastStep
+}
+
+// AirQualityClass names an air quality index.
+type AirQualityClass string
+
+const (
+	unknownAir  AirQualityClass = "unknown"
+	goodAir     AirQualityClass = "good"
+	fairAir     AirQualityClass = "fair"
+	moderateAir AirQualityClass = "moderate"
+	poorAir     AirQualityClass = "poor"
+	veryPoorAir AirQualityClass = "very poor"
+)
+
+// AirQuality is the current air quality at a location.
+type AirQuality struct {
+	// Index runs from 1, good, to 5, very poor.
+	Index int
+	Class AirQualityClass
+	// Components are pollutant concentrations in μg/m3 keyed by the upstream's names, e.g. `pm2_5`.
+	Components map[string]float64
+	ObservedAt time.Time
+}
+
+// GetForecast ctx, latitude, longitude. Forecasts are not cached; each call counts against the upstream budget.
+func (w *service) GetForecast(ctx context.Context, lat, lon float64) (forecast Forecast, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "service.GetForecast", trace.WithAttributes(
+		attribute.Float64("weather.latitude", lat),
+		attribute.Float64("weather.longitude", lon),
+	))
+	defer func() { tracing.End(span, err) }()
+	if err := w.reserve(ctx); err != nil {
+		return Forecast{}, err
+	}
+	start := time.Now()
+	resp, err := w.WeatherClient.GetForecast(ctx, fmt.Sprintf("%f", lat), fmt.Sprintf("%f", lon))
+	w.observeUpstream(start, err)
+	if err != nil {
+		return Forecast{}, err
+	}
+	forecast = Forecast{Place: resp.City.Name, Steps: make([]ForecastStep, len(resp.List))}
+	for i, item := range resp.List {
+		step := ForecastStep{
+			Time:                time.Unix(item.Dt, 0).UTC(),
+			Temp:                w.buildTempCondition(item.Main.FeelsLike),
+			Condition:           "unknown",
+			Wind:                w.buildWindCondition(item.Wind.Speed),
+			FeelsLike:           item.Main.FeelsLike,
+			WindSpeed:           item.Wind.Speed,
+			PrecipitationChance: item.Pop,
+		}
+		if len(item.Weather) > 0 {
+			step.Condition = item.Weather[0].Description
+		}
+		forecast.Steps[i] = step
+	}
+	return forecast, nil
+}
+
+// GetAirQuality ctx, latitude, longitude. Air quality is not cached; each call counts against the upstream budget.
+func (w *service) GetAirQuality(ctx context.Context, lat, lon float64) (air AirQuality, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "service.GetAirQuality", trace.WithAttributes(
+		attribute.Float64("weather.latitude", lat),
+		attribute.Float64("weather.longitude", lon),
+	))
+	defer func() { tracing.End(span, err) }()
+	if err := w.reserve(ctx); err != nil {
+		return AirQuality{}, err
+	}
+	start := time.Now()
+	resp, err := w.WeatherClient.GetAirPollution(ctx, fmt.Sprintf("%f", lat), fmt.Sprintf("%f", lon))
+	w.observeUpstream(start, err)
+	if err != nil {
+		return AirQuality{}, err
+	}
+	if len(resp.List) == 0 {
+		return AirQuality{Class: unknownAir}, nil
+	}
+	item := resp.List[0]
+	air = AirQuality{
+		Index:      item.Main.Aqi,
+		Class:      airQualityClass(item.Main.Aqi),
+		Components: item.Components,
+		ObservedAt: time.Unix(item.Dt, 0).UTC(),
+	}
+	return air, nil
+}
+
+func airQualityClass(index int) AirQualityClass {
+	switch index {
+	case 1:
+		return goodAir
+	case 2:
+		return fairAir
+	case 3:
+		return moderateAir
+	case 4:
+		return poorAir
+	case 5:
+		return veryPoorAir
+	default:
+		return unknownAir
+	}
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	apperrors "weathersvc/app/app_errors"
+	"weathersvc/app/health"
+)
+
+// Checks are the outcome of recent upstream calls, the cache size and a read from the history store.
+// The upstream is never critical: while it fails, lookups are served from the cache where possible.
+func (s *service) Checks() []health.Check {
+	checks := []health.Check{{
+		Name: provider,
+		Run: func(ctx context.Context) (string, error) {
+			if s.upstream.due() {
+				// the outcome is observed like any other call, so the error is reported below
+				_ = s.ValidateSvc(ctx)
+			}
+			return s.upstream.check(time.Now())
+		},
+	}}
+	if s.Cache != nil {
+		checks = append(checks, health.Check{
+			Name: "cache",
+			Run: func(ctx context.Context) (string, error) {
+				return fmt.Sprintf("%d locations cached", s.Cache.len()), nil
+			},
+		})
+	}
+	if s.History != nil {
+		checks = append(checks, health.Check{
+			Name:     "history",
+			Critical: true,
+			Run: func(ctx context.Context) (string, error) {
+				now := time.Now()
+				_, err := s.History.Query(ctx, 0, 0, now, now)
+				return "", err
+			},
+		})
+	}
+	return checks
+}
+
+// upstreamHealth remembers how recent upstream calls went, so readiness reports the upstream without
+// spending the budget while calls succeed. The check only calls the upstream itself when no call was
+// made yet, or while it fails and nothing else called it since the previous check.
+type upstreamHealth struct {
+	mu          sync.Mutex
+	lastSuccess time.Time
+	lastFailure time.Time
+	lastErr     error
+	// checked is when the check last ran.
+	checked time.Time
+}
+
+// observe records the outcome of one upstream call. Coordinates the upstream does not know are an
+// answer like any other, and calls the caller gave up on say nothing about the upstream.
+func (h *upstreamHealth) observe(err error) {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err == nil || errors.Is(err, apperrors.ErrNotFound) {
+		h.lastSuccess = time.Now()
+		return
+	}
+	h.lastFailure, h.lastErr = time.Now(), err
+}
+
+// due reports whether the check should call the upstream itself: no call was made yet, or the latest
+// one failed and no call was made since the previous check.
+func (h *upstreamHealth) due() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	switch {
+	case h.lastSuccess.IsZero() && h.lastFailure.IsZero():
+		return true
+	case h.lastFailure.After(h.lastSuccess):
+		return !h.lastFailure.After(h.checked)
+	default:
+		return false
+	}
+}
+
+// check fails while the latest upstream call failed.
+func (h *upstreamHealth) check(now time.Time) (string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checked = now
+	switch {
+	case h.lastSuccess.IsZero() && h.lastFailure.IsZero():
+		return "no upstream calls yet", nil
+	case h.lastFailure.After(h.lastSuccess):
+		return fmt.Sprintf("last failed %s ago", now.Sub(h.lastFailure).Round(time.Second)), h.lastErr
+	default:
+		return fmt.Sprintf("last succeeded %s ago", now.Sub(h.lastSuccess).Round(time.Second)), nil
+	}
+}
+
+// observeUpstream records one upstream call begun at start for readiness and the metrics.
+func (s *service) observeUpstream(start time.Time, err error) {
+	s.upstream.observe(err)
+	s.Metrics.ObserveUpstream(provider, time.Since(start), err)
+}
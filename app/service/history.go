@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"time"
+	"weathersvc/app/history"
+)
+
+// provider names the upstream that produced an observation.
+const provider = "openweathermap"
+
+// GetHistory ctx, latitude, longitude, from, to, step
+func (w *service) GetHistory(ctx context.Context, lat, lon float64, from, to time.Time, step time.Duration) ([]history.Observation, error) {
+	obs, err := w.History.Query(ctx, lat, lon, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if step <= 0 {
+		return obs, nil
+	}
+	return w.downsample(obs, from, step), nil
+}
+
+// downsample averages observations into step-wide buckets aligned to from and reclassifies the averages.
+// The description is the one seen most often in the bucket.
+func (w *service) downsample(obs []history.Observation, from time.Time, step time.Duration) []history.Observation {
+	out := []history.Observation{}
+	for i := 0; i < len(obs); {
+		bucket := from.Add(obs[i].Time.Sub(from) / step * step)
+		end := bucket.Add(step)
+		var feelsLike, windSpeed float64
+		counts := map[string]int{}
+		first := obs[i]
+		n := 0
+		for ; i < len(obs) && obs[i].Time.Before(end); i++ {
+			feelsLike += obs[i].FeelsLike
+			windSpeed += obs[i].WindSpeed
+			counts[obs[i].Description]++
+			n++
+		}
+		description := first.Description
+		for d, c := range counts {
+			if c > counts[description] || (c == counts[description] && d < description) {
+				description = d
+			}
+		}
+		feelsLike /= float64(n)
+		windSpeed /= float64(n)
+		out = append(out, history.Observation{
+			Latitude:    first.Latitude,
+			Longitude:   first.Longitude,
+			Time:        bucket,
+			Provider:    first.Provider,
+			FeelsLike:   feelsLike,
+			WindSpeed:   windSpeed,
+			Description: description,
+			Temp:        string(w.buildTempCondition(feelsLike)),
+			Wind:        string(w.buildWindCondition(windSpeed)),
+			Samples:     n,
+		})
+	}
+	return out
+}
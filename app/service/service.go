@@ -2,28 +2,119 @@ package service
 
 import (
 	"context"
+	"time"
+	"weathersvc/app/budget"
 	"weathersvc/app/config"
+	"weathersvc/app/health"
+	"weathersvc/app/history"
+	"weathersvc/app/metrics"
 	openweather "weathersvc/app/open_weather"
 )
 
 type Service interface {
 	// GetWeather ctx, latitude, longitude
 	GetWeather(ctx context.Context, lat, lon float64) (WeatherCond, error)
+	// GetForecast ctx, latitude, longitude
+	GetForecast(ctx context.Context, lat, lon float64) (Forecast, error)
+	// GetAirQuality ctx, latitude, longitude
+	GetAirQuality(ctx context.Context, lat, lon float64) (AirQuality, error)
+	// GetHistory ctx, latitude, longitude, from, to, step; a zero step returns every observation
+	GetHistory(ctx context.Context, lat, lon float64, from, to time.Time, step time.Duration) ([]history.Observation, error)
 	ValidateSvc(ctx context.Context) error
+	// UpstreamUsage reports how much of each upstream call budget has been used
+	UpstreamUsage() []budget.Usage
+	// Checks are the health checks of the upstream and the service's stores
+	Checks() []health.Check
+	// Reload applies the options of conf that may change while running: the upstream budgets and cache durations
+	Reload(conf *config.App)
+	Close() error
 }
 type service struct {
 	Config        *config.App
 	WeatherClient openweather.Client
+	History       history.Repository
+	Budget        budget.Budget
+	// Cache serves the last known good condition; every lookup goes upstream when it is nil.
+	Cache *lastKnownGood
+	// Metrics records upstream calls, cache lookups and returned classifications; nil records nothing.
+	Metrics *metrics.Metrics
+	// upstream is how recent upstream calls went, reported by the health check.
+	upstream upstreamHealth
 }
 
-func NewService(ctx context.Context, conf *config.App) Service {
+func NewService(ctx context.Context, conf *config.App, m *metrics.Metrics) (Service, error) {
 	cl := openweather.NewClient(conf)
+	m.WatchKeys(cl.Keys)
+	retention := history.Retention{MaxAge: conf.HistoryRetention, MaxPerLocation: conf.HistoryMaxPerLocation}
+	repo := history.NewMemoryRepository(retention)
+	if conf.HistoryPath != "" {
+		var err error
+		if repo, err = history.NewBoltRepository(conf.HistoryPath, retention); err != nil {
+			return nil, err
+		}
+	}
 	return &service{
 		Config:        conf,
 		WeatherClient: cl,
+		History:       repo,
+		Budget:        budget.NewBudget(budgetLimits(conf)),
+		Cache:         newLastKnownGood(conf.CacheTTL, conf.CacheMaxStale),
+		Metrics:       m,
+	}, nil
+}
+
+func budgetLimits(conf *config.App) budget.Limits {
+	return budget.Limits{
+		PerMinute: conf.BudgetPerMinute,
+		PerDay:    conf.BudgetPerDay,
+		PerMonth:  conf.BudgetPerMonth,
+		Reserve:   conf.BudgetReserve,
+	}
+}
+
+func (s *service) Reload(conf *config.App) {
+	if s.Budget != nil {
+		s.Budget.SetLimits(budgetLimits(conf))
+	}
+	if s.Cache != nil {
+		s.Cache.setLimits(conf.CacheTTL, conf.CacheMaxStale)
 	}
 }
 
 func (s *service) ValidateSvc(ctx context.Context) error {
-	return s.WeatherClient.ApiTest()
+	// startup must not be refused in favour of callers that do not exist yet
+	ctx = budget.WithPriority(ctx)
+	if err := s.reserve(ctx); err != nil {
+		return err
+	}
+	start := time.Now()
+	err := s.WeatherClient.ApiTest(ctx)
+	s.observeUpstream(start, err)
+	return err
+}
+
+// reserve counts one upstream call against the budget.
+func (s *service) reserve(ctx context.Context) error {
+	if s.Budget == nil {
+		return nil
+	}
+	return s.Budget.Reserve(ctx)
+}
+
+func (s *service) UpstreamUsage() []budget.Usage {
+	if s.Budget == nil {
+		return []budget.Usage{}
+	}
+	return s.Budget.Usage()
+}
+
+// Close waits for background refreshes and releases the history store.
+func (s *service) Close() error {
+	if s.Cache != nil {
+		s.Cache.wait()
+	}
+	if s.History == nil {
+		return nil
+	}
+	return s.History.Close()
 }
@@ -2,14 +2,24 @@ package service
 
 import (
 	"context"
+	"path/filepath"
 	"testing"
+	"time"
 	apperrors "weathersvc/app/app_errors"
+	"weathersvc/app/budget"
 	"weathersvc/app/config"
+	"weathersvc/app/history"
 	"weathersvc/app/models"
+	"weathersvc/app/tracing"
 	ownMock "weathersvc/mocks/open_weather"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 func TestService_NewService(t *testing.T) {
@@ -23,8 +33,24 @@ func TestService_NewService(t *testing.T) {
 		},
 	}
 	t.Run("Should not fail to create new service", func(t *testing.T) {
-		got := NewService(ctx, conf)
+		got, err := NewService(ctx, conf, nil)
+		assert.NoError(t, err)
 		assert.NotNil(t, got)
+		assert.NoError(t, got.Close())
+	})
+	t.Run("Should create new service with a bolt history store", func(t *testing.T) {
+		boltConf := *conf
+		boltConf.HistoryPath = filepath.Join(t.TempDir(), "history.db")
+		got, err := NewService(ctx, &boltConf, nil)
+		assert.NoError(t, err)
+		assert.NoError(t, got.Close())
+	})
+	t.Run("Should fail to create new service when the history store cannot be opened", func(t *testing.T) {
+		boltConf := *conf
+		boltConf.HistoryPath = filepath.Join(t.TempDir(), "missing", "history.db")
+		got, err := NewService(ctx, &boltConf, nil)
+		assert.Error(t, err)
+		assert.Nil(t, got)
 	})
 }
 func TestService_buildTempCondition(t *testing.T) {
@@ -139,12 +165,12 @@ func TestSevice_ValidateSvc(t *testing.T) {
 		WeatherClient: owm,
 	}
 	t.Run("Should pass validation", func(t *testing.T) {
-		owm.EXPECT().ApiTest().Return(nil)
+		owm.EXPECT().ApiTest(gomock.Any()).Return(nil)
 		err := svc.ValidateSvc(context.Background())
 		assert.NoError(t, err)
 	})
 	t.Run("Should fail validation when user started svc with invalid weather-appid", func(t *testing.T) {
-		owm.EXPECT().ApiTest().Return(apperrors.ErrInvalidOWMAppID)
+		owm.EXPECT().ApiTest(gomock.Any()).Return(apperrors.ErrInvalidOWMAppID)
 		err := svc.ValidateSvc(context.Background())
 		assert.EqualError(t, err, apperrors.ErrInvalidOWMAppID.Error())
 	})
@@ -176,7 +202,7 @@ func TestService_GetWeather(t *testing.T) {
 		}
 		desc := []models.Weather{}
 		desc = append(desc, desc1)
-		owm.EXPECT().GetWeather(gomock.Any(), gomock.Any()).Return(&models.WeatherResponse{
+		owm.EXPECT().GetWeather(gomock.Any(), gomock.Any(), gomock.Any()).Return(&models.WeatherResponse{
 			Weather: desc,
 			Main: models.Main{
 				FeelsLike: 90.4,
@@ -184,13 +210,16 @@ func TestService_GetWeather(t *testing.T) {
 			Wind: models.Wind{
 				Speed: 0,
 			},
-			Cod: 200,
+			Cod:  200,
+			Name: "Dallas",
 		}, nil)
 		got, gErr := svc.GetWeather(context.Background(), 0, 0)
 		assert.NoError(t, gErr)
 		assert.EqualValues(t, got.Temp, expectResp.Temp)
 		assert.EqualValues(t, got.Condition, expectResp.Condition)
 		assert.EqualValues(t, got.Wind, expectResp.Wind)
+		assert.Equal(t, 90.4, got.FeelsLike)
+		assert.Equal(t, "Dallas", got.Place)
 	})
 	t.Run("Should return err 429", func(t *testing.T) {
 		expectResp := WeatherCond{
@@ -198,7 +227,7 @@ func TestService_GetWeather(t *testing.T) {
 			Condition: "",
 			Wind:      "",
 		}
-		owm.EXPECT().GetWeather(gomock.Any(), gomock.Any()).Return(&models.WeatherResponse{}, apperrors.ErrTooManyRequests)
+		owm.EXPECT().GetWeather(gomock.Any(), gomock.Any(), gomock.Any()).Return(&models.WeatherResponse{}, apperrors.ErrTooManyRequests)
 		got, gErr := svc.GetWeather(context.Background(), 0, 0)
 		assert.EqualError(t, gErr, "too many requests; limit reached")
 		assert.EqualValues(t, got.Temp, expectResp.Temp)
@@ -212,7 +241,7 @@ func TestService_GetWeather(t *testing.T) {
 			Wind:      calm,
 		}
 
-		owm.EXPECT().GetWeather(gomock.Any(), gomock.Any()).Return(&models.WeatherResponse{
+		owm.EXPECT().GetWeather(gomock.Any(), gomock.Any(), gomock.Any()).Return(&models.WeatherResponse{
 			Main: models.Main{
 				FeelsLike: 90.4,
 			},
@@ -229,3 +258,334 @@ func TestService_GetWeather(t *testing.T) {
 	})
 
 }
+
+func TestService_GetForecastAndAirQuality(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	owm := ownMock.NewMockClient(ctrl)
+	svc := service{Config: &config.App{}, WeatherClient: owm, Budget: budget.NewBudget(budget.Limits{PerMinute: 2})}
+	t.Run("Should classify each forecast step", func(t *testing.T) {
+		owm.EXPECT().GetForecast(gomock.Any(), "32.780000", "-96.800000").Return(&models.ForecastResponse{
+			List: []models.ForecastItem{
+				{Dt: 1720198800, Main: models.Main{FeelsLike: 95}, Wind: models.Wind{Speed: 2}, Weather: []models.Weather{{Description: "clear sky"}}, Pop: 0.1},
+				{Dt: 1720209600, Main: models.Main{FeelsLike: 30}, Wind: models.Wind{Speed: 0}},
+			},
+			City: models.City{Name: "Dallas"},
+		}, nil)
+		got, err := svc.GetForecast(context.Background(), 32.78, -96.8)
+		assert.NoError(t, err)
+		assert.Equal(t, "Dallas", got.Place)
+		assert.Equal(t, []ForecastStep{
+			{Time: time.Unix(1720198800, 0).UTC(), Temp: hot, Condition: "clear sky", Wind: lightAir, FeelsLike: 95, WindSpeed: 2, PrecipitationChance: 0.1},
+			{Time: time.Unix(1720209600, 0).UTC(), Temp: subFreezing, Condition: "unknown", Wind: calm, FeelsLike: 30},
+		}, got.Steps)
+	})
+	t.Run("Should classify the air quality index", func(t *testing.T) {
+		item := models.AirPollutionItem{Dt: 1720197314, Components: map[string]float64{"pm2_5": 8.1}}
+		item.Main.Aqi = 4
+		owm.EXPECT().GetAirPollution(gomock.Any(), gomock.Any(), gomock.Any()).Return(&models.AirPollutionResponse{List: []models.AirPollutionItem{item}}, nil)
+		got, err := svc.GetAirQuality(context.Background(), 32.78, -96.8)
+		assert.NoError(t, err)
+		assert.Equal(t, AirQuality{Index: 4, Class: poorAir, Components: map[string]float64{"pm2_5": 8.1}, ObservedAt: time.Unix(1720197314, 0).UTC()}, got)
+	})
+	t.Run("Should count against the upstream budget", func(t *testing.T) {
+		_, err := svc.GetAirQuality(context.Background(), 32.78, -96.8)
+		assert.ErrorIs(t, err, apperrors.ErrBudgetExhausted)
+	})
+}
+
+func TestService_Scales(t *testing.T) {
+	t.Run("Should order temperatures from coldest to hottest", func(t *testing.T) {
+		got := TemperatureScale()
+		assert.Equal(t, subFreezing, got[0])
+		assert.Equal(t, extremeHot, got[len(got)-1])
+		assert.NotContains(t, got, UnknownTemp)
+	})
+	t.Run("Should order winds from calmest to strongest", func(t *testing.T) {
+		got := WindScale()
+		assert.Equal(t, calm, got[0])
+		assert.Equal(t, hurricane, got[len(got)-1])
+		assert.NotContains(t, got, unknownWind)
+	})
+}
+
+func TestService_GetHistory(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	owm := ownMock.NewMockClient(ctrl)
+	svc := service{
+		Config:        &config.App{},
+		WeatherClient: owm,
+		History:       history.NewMemoryRepository(history.Retention{}),
+	}
+	ctx := context.Background()
+	base := time.Date(2026, 10, 13, 12, 0, 0, 0, time.UTC)
+	t.Run("Should record every upstream observation", func(t *testing.T) {
+		for i, feelsLike := range []float64{50, 70, 80, 95} {
+			owm.EXPECT().GetWeather(gomock.Any(), gomock.Any(), gomock.Any()).Return(&models.WeatherResponse{
+				Weather: []models.Weather{{Description: map[bool]string{true: "clear sky", false: "light rain"}[i%3 == 0]}},
+				Main:    models.Main{FeelsLike: feelsLike},
+				Wind:    models.Wind{Speed: float64(i * 10)},
+				Dt:      base.Add(time.Duration(i) * 20 * time.Minute).Unix(),
+				Cod:     200,
+			}, nil)
+			_, err := svc.GetWeather(ctx, 32.7, -96.8)
+			assert.NoError(t, err)
+		}
+		got, err := svc.GetHistory(ctx, 32.7, -96.8, base, base.Add(2*time.Hour), 0)
+		assert.NoError(t, err)
+		if assert.Len(t, got, 4) {
+			assert.Equal(t, history.Observation{
+				Latitude:    32.7,
+				Longitude:   -96.8,
+				Time:        base,
+				Provider:    "openweathermap",
+				FeelsLike:   50,
+				WindSpeed:   0,
+				Description: "clear sky",
+				Temp:        string(cold),
+				Wind:        string(calm),
+			}, got[0])
+		}
+	})
+	t.Run("Should downsample into reclassified buckets", func(t *testing.T) {
+		got, err := svc.GetHistory(ctx, 32.7, -96.8, base, base.Add(2*time.Hour), time.Hour)
+		assert.NoError(t, err)
+		if assert.Len(t, got, 2) {
+			assert.Equal(t, base, got[0].Time)
+			assert.Equal(t, 3, got[0].Samples)
+			assert.InDelta(t, 66.67, got[0].FeelsLike, 0.01)
+			assert.Equal(t, string(moderate), got[0].Temp)
+			assert.Equal(t, string(gentalBreeze), got[0].Wind)
+			assert.Equal(t, "light rain", got[0].Description)
+			assert.Equal(t, base.Add(time.Hour), got[1].Time)
+			assert.Equal(t, 1, got[1].Samples)
+			assert.Equal(t, string(hot), got[1].Temp)
+			assert.Equal(t, "clear sky", got[1].Description)
+		}
+	})
+	t.Run("Should return empty history for unknown locations", func(t *testing.T) {
+		got, err := svc.GetHistory(ctx, 1, 1, base, base.Add(time.Hour), time.Minute)
+		assert.NoError(t, err)
+		assert.Empty(t, got)
+	})
+}
+
+func TestService_Budget(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	owm := ownMock.NewMockClient(ctrl)
+	svc := service{
+		Config:        &config.App{},
+		WeatherClient: owm,
+		Budget:        budget.NewBudget(budget.Limits{PerMinute: 2, Reserve: 0.5}),
+	}
+	owm.EXPECT().GetWeather(gomock.Any(), gomock.Any(), gomock.Any()).Return(&models.WeatherResponse{Cod: 200}, nil).Times(2)
+	t.Run("Should refuse non-priority lookups before calling the upstream", func(t *testing.T) {
+		_, err := svc.GetWeather(context.Background(), 1, 1)
+		assert.NoError(t, err)
+		_, err = svc.GetWeather(context.Background(), 1, 1)
+		assert.ErrorIs(t, err, apperrors.ErrBudgetExhausted)
+	})
+	t.Run("Should allow priority lookups into the reserve", func(t *testing.T) {
+		_, err := svc.GetWeather(budget.WithPriority(context.Background()), 1, 1)
+		assert.NoError(t, err)
+	})
+	t.Run("Should report usage", func(t *testing.T) {
+		usage := svc.UpstreamUsage()
+		assert.Equal(t, budget.WindowMinute, usage[0].Window)
+		assert.Equal(t, 2, usage[0].Used)
+		assert.Equal(t, 1, usage[0].Refused)
+	})
+}
+
+func TestService_Cache(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	owm := ownMock.NewMockClient(ctrl)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	cache := newLastKnownGood(time.Minute, 10*time.Minute)
+	cache.now = func() time.Time { return now }
+	svc := service{
+		Config:        &config.App{},
+		WeatherClient: owm,
+		Cache:         cache,
+	}
+	sunny := &models.WeatherResponse{Cod: 200, Dt: now.Unix(), Weather: []models.Weather{{Description: "clear sky"}}}
+	rain := &models.WeatherResponse{Cod: 200, Dt: now.Unix(), Weather: []models.Weather{{Description: "light rain"}}}
+	t.Run("Should serve fresh entries without calling the upstream", func(t *testing.T) {
+		owm.EXPECT().GetWeather(gomock.Any(), "1.000000", "2.000000").Return(sunny, nil).Times(1)
+		cond, err := svc.GetWeather(context.Background(), 1, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, now, cond.ObservedAt)
+		now = now.Add(30 * time.Second)
+		cond, err = svc.GetWeather(context.Background(), 1, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, "clear sky", cond.Condition)
+		assert.False(t, cond.Stale)
+	})
+	t.Run("Should serve stale entries while refreshing in the background", func(t *testing.T) {
+		now = now.Add(time.Minute)
+		owm.EXPECT().GetWeather(gomock.Any(), "1.000000", "2.000000").Return(rain, nil).Times(1)
+		cond, err := svc.GetWeather(context.Background(), 1, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, "clear sky", cond.Condition)
+		assert.True(t, cond.Stale)
+		cache.wait()
+		cond, err = svc.GetWeather(context.Background(), 1, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, "light rain", cond.Condition)
+		assert.False(t, cond.Stale)
+	})
+	t.Run("Should keep serving stale entries while the upstream fails", func(t *testing.T) {
+		now = now.Add(5 * time.Minute)
+		owm.EXPECT().GetWeather(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, apperrors.ErrTooManyRequests).Times(2)
+		for i := 0; i < 2; i++ {
+			cond, err := svc.GetWeather(context.Background(), 1, 2)
+			assert.NoError(t, err)
+			assert.Equal(t, "light rain", cond.Condition)
+			assert.True(t, cond.Stale)
+			cache.wait()
+		}
+	})
+	t.Run("Should return the upstream error past the max staleness", func(t *testing.T) {
+		now = now.Add(10 * time.Minute)
+		owm.EXPECT().GetWeather(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, apperrors.ErrTooManyRequests).Times(1)
+		_, err := svc.GetWeather(context.Background(), 1, 2)
+		assert.ErrorIs(t, err, apperrors.ErrTooManyRequests)
+	})
+}
+
+func TestService_Reload(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	owm := ownMock.NewMockClient(ctrl)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	cache := newLastKnownGood(time.Minute, 10*time.Minute)
+	cache.now = func() time.Time { return now }
+	svc := service{
+		Config:        &config.App{},
+		WeatherClient: owm,
+		Budget:        budget.NewBudget(budget.Limits{PerMinute: 10}),
+		Cache:         cache,
+	}
+	t.Run("Should apply new cache durations and budgets", func(t *testing.T) {
+		owm.EXPECT().GetWeather(gomock.Any(), gomock.Any(), gomock.Any()).Return(&models.WeatherResponse{Cod: 200, Dt: now.Unix()}, nil).Times(2)
+		_, err := svc.GetWeather(context.Background(), 1, 2)
+		assert.NoError(t, err)
+		now = now.Add(30 * time.Second)
+		svc.Reload(&config.App{
+			CacheTTL:      10 * time.Second,
+			CacheMaxStale: time.Minute,
+			BudgetConfig:  config.BudgetConfig{BudgetPerMinute: 20, BudgetPerDay: 100},
+		})
+		cond, err := svc.GetWeather(context.Background(), 1, 2)
+		assert.NoError(t, err)
+		assert.True(t, cond.Stale, "the entry is past the new TTL")
+		cache.wait()
+		usage := svc.UpstreamUsage()
+		assert.Equal(t, 20, usage[0].Limit)
+		assert.Equal(t, 100, usage[1].Limit)
+	})
+}
+
+func TestService_Tracing(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	exp := tracetest.NewInMemoryExporter()
+	tp := tracing.NewProvider(config.TracingConfig{ServiceName: "test", TraceSampleRatio: 1}, sdktrace.WithSyncer(exp))
+	otel.SetTracerProvider(tp)
+	defer tp.Shutdown(context.Background())
+	owm := ownMock.NewMockClient(ctrl)
+	svc := service{
+		Config:        &config.App{},
+		WeatherClient: owm,
+	}
+	t.Run("Should trace lookups and classification", func(t *testing.T) {
+		owm.EXPECT().GetWeather(gomock.Any(), gomock.Any(), gomock.Any()).Return(&models.WeatherResponse{Cod: 200}, nil)
+		_, err := svc.GetWeather(context.Background(), 1, 2)
+		assert.NoError(t, err)
+		spans := exp.GetSpans()
+		assert.Len(t, spans, 2)
+		assert.Equal(t, "service.classify", spans[0].Name)
+		assert.Equal(t, "service.GetWeather", spans[1].Name)
+		assert.Equal(t, spans[1].SpanContext.SpanID(), spans[0].Parent.SpanID())
+	})
+	t.Run("Should record upstream errors on the span", func(t *testing.T) {
+		exp.Reset()
+		owm.EXPECT().GetWeather(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, apperrors.ErrTooManyRequests)
+		_, err := svc.GetWeather(context.Background(), 1, 2)
+		assert.ErrorIs(t, err, apperrors.ErrTooManyRequests)
+		spans := exp.GetSpans()
+		assert.Len(t, spans, 1)
+		assert.Equal(t, codes.Error, spans[0].Status.Code)
+	})
+}
+
+func TestService_Checks(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	owm := ownMock.NewMockClient(ctrl)
+	svc := &service{
+		Config:        &config.App{},
+		WeatherClient: owm,
+		History:       history.NewMemoryRepository(history.Retention{}),
+		Cache:         newLastKnownGood(time.Minute, time.Hour),
+	}
+	checks := svc.Checks()
+	t.Run("Should check the upstream, cache and history", func(t *testing.T) {
+		assert.Len(t, checks, 3)
+		assert.Equal(t, "openweathermap", checks[0].Name)
+		assert.False(t, checks[0].Critical, "the cache may answer while the upstream fails")
+		detail, err := checks[1].Run(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, "0 locations cached", detail)
+		_, err = checks[2].Run(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, checks[2].Critical)
+	})
+	t.Run("Should call the upstream before any other call", func(t *testing.T) {
+		owm.EXPECT().ApiTest(gomock.Any()).Return(nil)
+		detail, err := checks[0].Run(context.Background())
+		assert.NoError(t, err)
+		assert.Contains(t, detail, "last succeeded")
+	})
+	t.Run("Should report the upstream from recent calls without calling it", func(t *testing.T) {
+		detail, err := checks[0].Run(context.Background())
+		assert.NoError(t, err)
+		assert.Contains(t, detail, "last succeeded")
+		owm.EXPECT().GetWeather(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, apperrors.ErrInternalServiceError)
+		_, err = svc.fetch(context.Background(), 1, 2)
+		require.Error(t, err)
+		detail, err = checks[0].Run(context.Background())
+		assert.ErrorIs(t, err, apperrors.ErrInternalServiceError)
+		assert.Contains(t, detail, "last failed")
+		owm.EXPECT().GetWeather(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, apperrors.ErrNotFound)
+		_, err = svc.fetch(context.Background(), 1, 2)
+		require.Error(t, err)
+		detail, err = checks[0].Run(context.Background())
+		assert.NoError(t, err, "an unknown place is an answer")
+		assert.Contains(t, detail, "last succeeded")
+		owm.EXPECT().GetWeather(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, context.Canceled)
+		_, err = svc.fetch(context.Background(), 1, 2)
+		require.Error(t, err)
+		_, err = checks[0].Run(context.Background())
+		assert.NoError(t, err, "calls the caller gave up on are not counted")
+	})
+	t.Run("Should call the upstream while it fails without other calls", func(t *testing.T) {
+		owm.EXPECT().GetWeather(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, apperrors.ErrInternalServiceError)
+		_, err := svc.fetch(context.Background(), 1, 2)
+		require.Error(t, err)
+		_, err = checks[0].Run(context.Background())
+		assert.ErrorIs(t, err, apperrors.ErrInternalServiceError, "a call since the last check is reported as is")
+		owm.EXPECT().ApiTest(gomock.Any()).Return(apperrors.ErrInternalServiceError)
+		_, err = checks[0].Run(context.Background())
+		assert.ErrorIs(t, err, apperrors.ErrInternalServiceError)
+		owm.EXPECT().ApiTest(gomock.Any()).Return(nil)
+		detail, err := checks[0].Run(context.Background())
+		assert.NoError(t, err, "recovers without traffic")
+		assert.Contains(t, detail, "last succeeded")
+		_, err = checks[0].Run(context.Background())
+		assert.NoError(t, err, "a healthy upstream is not called")
+	})
+}
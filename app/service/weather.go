@@ -3,12 +3,30 @@ package service
 import (
 	"context"
 	"fmt"
+	"time"
+	"weathersvc/app/history"
+	"weathersvc/app/logging"
+	"weathersvc/app/metrics"
+	"weathersvc/app/models"
+	"weathersvc/app/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type WeatherCond struct {
 	Temp      Temperature
 	Condition string
 	Wind      Wind
+	// FeelsLike (°F) and WindSpeed (mph) are the readings Temp and Wind were classified from.
+	FeelsLike float64
+	WindSpeed float64
+	// Place is the upstream's name for the place observed, which may be empty.
+	Place string
+	// ObservedAt is when the upstream observed the condition.
+	ObservedAt time.Time
+	// Stale is set when a cached condition is served past its TTL, while it is refreshed or the upstream is failing.
+	Stale bool
 }
 type Temperature string
 type Wind string
@@ -38,28 +56,137 @@ const (
 	hurricane      Wind        = "hurricane/tornado winds"
 )
 
+// TemperatureScale lists the temperature classifications from coldest to hottest.
+func TemperatureScale() []Temperature {
+	return []Temperature{subFreezing, Freezing, cold, moderate, warm, hot, extremeHot}
+}
+
+// WindScale lists the wind classifications from calmest to strongest.
+func WindScale() []Wind {
+	return []Wind{calm, lightAir, lightBreeze, gentalBreeze, moderateBreeze, freshBreeze, strongBreeze, nearGale, gale, severeGale, storm, violentStorm, hurricane}
+}
+
 // GetWeather ctx, latitude, longitude
-func (w *service) GetWeather(ctx context.Context, lat, lon float64) (WeatherCond, error) {
+func (w *service) GetWeather(ctx context.Context, lat, lon float64) (cond WeatherCond, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "service.GetWeather", trace.WithAttributes(
+		attribute.Float64("weather.latitude", lat),
+		attribute.Float64("weather.longitude", lon),
+	))
+	defer func() { tracing.End(span, err) }()
+	cond, err = w.lookup(ctx, lat, lon)
+	if err != nil {
+		return WeatherCond{}, err
+	}
+	span.SetAttributes(attribute.Bool("weather.stale", cond.Stale))
+	w.Metrics.ObserveCondition(string(cond.Temp), string(cond.Wind))
+	return cond, nil
+}
+
+// lookup serves the condition from the cache when it can and fetches it otherwise.
+func (w *service) lookup(ctx context.Context, lat, lon float64) (WeatherCond, error) {
+	if w.Cache == nil {
+		return w.fetch(ctx, lat, lon)
+	}
+	key := cacheKey(lat, lon)
+	ttl, maxStale := w.Cache.limits()
+	if cond, age, ok := w.Cache.get(key); ok && age < maxStale {
+		if age < ttl {
+			w.observeCache(ctx, metrics.CacheHit)
+			return cond, nil
+		}
+		w.observeCache(ctx, metrics.CacheStale)
+		cond.Stale = true
+		// the refresh outlives this request, so it starts its own trace linked back to it
+		link := trace.LinkFromContext(ctx)
+		w.Cache.refresh(ctx, key, func(ctx context.Context) (cond WeatherCond, err error) {
+			ctx, span := tracing.Tracer().Start(ctx, "service.refresh", trace.WithNewRoot(), trace.WithLinks(link))
+			defer func() { tracing.End(span, err) }()
+			return w.fetch(ctx, lat, lon)
+		})
+		return cond, nil
+	}
+	w.observeCache(ctx, metrics.CacheMiss)
+	cond, err := w.fetch(ctx, lat, lon)
+	if err != nil {
+		return WeatherCond{}, err
+	}
+	w.Cache.put(key, cond)
+	return cond, nil
+}
+
+// fetch classifies the current upstream observation for a location and records it to history.
+func (w *service) fetch(ctx context.Context, lat, lon float64) (WeatherCond, error) {
+	if err := w.reserve(ctx); err != nil {
+		return WeatherCond{}, err
+	}
 	sLat := fmt.Sprintf("%f", lat)
 	sLon := fmt.Sprintf("%f", lon)
-	resp, err := w.WeatherClient.GetWeather(sLat, sLon)
+	start := time.Now()
+	resp, err := w.WeatherClient.GetWeather(ctx, sLat, sLon)
+	w.observeUpstream(start, err)
 	if err != nil {
 		return WeatherCond{}, err
 	}
-	tempCond := w.buildTempCondition(resp.Main.FeelsLike)
-	windCond := w.buildWindCondition(resp.Wind.Speed)
-	if len(resp.Weather) == 0 {
-		return WeatherCond{
-			Temp:      tempCond,
-			Condition: "unknown",
-			Wind:      windCond,
-		}, nil
+	tempCond, windCond := w.classify(ctx, resp)
+	cond := WeatherCond{
+		Temp:       tempCond,
+		Condition:  "unknown",
+		Wind:       windCond,
+		FeelsLike:  resp.Main.FeelsLike,
+		WindSpeed:  resp.Wind.Speed,
+		Place:      resp.Name,
+		ObservedAt: time.Now().UTC(),
+	}
+	if resp.Dt > 0 {
+		cond.ObservedAt = time.Unix(resp.Dt, 0).UTC()
+	}
+	if len(resp.Weather) > 0 {
+		cond.Condition = resp.Weather[0].Description
+	}
+	w.record(ctx, lat, lon, resp, cond)
+	return cond, nil
+}
+
+// observeCache records a cache lookup result on the metrics and the current span.
+func (w *service) observeCache(ctx context.Context, result string) {
+	w.Metrics.ObserveCache(result)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("weather.cache", result))
+}
+
+// classify maps the raw upstream readings onto the temperature and wind scales.
+func (w *service) classify(ctx context.Context, resp *models.WeatherResponse) (Temperature, Wind) {
+	_, span := tracing.Tracer().Start(ctx, "service.classify")
+	defer span.End()
+	temp := w.buildTempCondition(resp.Main.FeelsLike)
+	wind := w.buildWindCondition(resp.Wind.Speed)
+	span.SetAttributes(
+		attribute.Float64("weather.feels_like", resp.Main.FeelsLike),
+		attribute.Float64("weather.wind_speed", resp.Wind.Speed),
+		attribute.String("weather.temp", string(temp)),
+		attribute.String("weather.wind", string(wind)),
+	)
+	return temp, wind
+}
+
+// record saves the upstream observation to history. Failures are logged rather than failing the request.
+func (w *service) record(ctx context.Context, lat, lon float64, resp *models.WeatherResponse, cond WeatherCond) {
+	if w.History == nil {
+		return
+	}
+	err := w.History.Save(ctx, history.Observation{
+		Latitude:    lat,
+		Longitude:   lon,
+		Time:        cond.ObservedAt,
+		Provider:    provider,
+		FeelsLike:   resp.Main.FeelsLike,
+		WindSpeed:   resp.Wind.Speed,
+		Description: cond.Condition,
+		Temp:        string(cond.Temp),
+		Wind:        string(cond.Wind),
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to record observation", "error", err)
 	}
-	return WeatherCond{
-		Temp:      tempCond,
-		Condition: resp.Weather[0].Description,
-		Wind:      windCond,
-	}, nil
 }
 
 func (w *service) buildTempCondition(temp float64) Temperature {
@@ -0,0 +1,93 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"weathersvc/app/config"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func useMemoryExporter(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exp := tracetest.NewInMemoryExporter()
+	tp := NewProvider(config.TracingConfig{ServiceName: "test", TraceSampleRatio: 1}, sdktrace.WithSyncer(exp))
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { tp.Shutdown(context.Background()) })
+	return exp
+}
+
+func attr(span tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestTracing_Handler(t *testing.T) {
+	exp := useMemoryExporter(t)
+	r := mux.NewRouter()
+	r.Use(Middleware())
+	r.HandleFunc("/alerts/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	h := Handler(r)
+	t.Run("Should continue the incoming trace in a span named after the route", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/alerts/abc", nil)
+		req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		spans := exp.GetSpans()
+		require.Len(t, spans, 1)
+		assert.Equal(t, "GET /alerts/{id}", spans[0].Name)
+		assert.Equal(t, trace.SpanKindServer, spans[0].SpanKind)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext.TraceID().String())
+		assert.Equal(t, "00f067aa0ba902b7", spans[0].Parent.SpanID().String())
+		assert.Equal(t, "/alerts/{id}", attr(spans[0], "http.route").AsString())
+	})
+}
+
+func TestTracing_Transport(t *testing.T) {
+	exp := useMemoryExporter(t)
+	var traceparent string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer upstream.Close()
+	client := &http.Client{Transport: Transport(nil)}
+	t.Run("Should propagate the trace and keep the query out of the span", func(t *testing.T) {
+		ctx, parent := Tracer().Start(context.Background(), "parent")
+		req, err := http.NewRequestWithContext(ctx, "GET", upstream.URL+"/data/2.5/weather?appid=secret", nil)
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		parent.End()
+		spans := exp.GetSpans()
+		require.Len(t, spans, 2)
+		span := spans[0]
+		assert.Equal(t, trace.SpanKindClient, span.SpanKind)
+		assert.Equal(t, parent.SpanContext().SpanID(), span.Parent.SpanID())
+		assert.Contains(t, traceparent, span.SpanContext.TraceID().String()+"-"+span.SpanContext.SpanID().String())
+		assert.Equal(t, "/data/2.5/weather", attr(span, "url.path").AsString())
+		assert.Equal(t, int64(http.StatusTooManyRequests), attr(span, "http.response.status_code").AsInt64())
+		assert.Equal(t, codes.Error, span.Status.Code)
+		for _, kv := range span.Attributes {
+			assert.NotContains(t, kv.Value.Emit(), "secret")
+		}
+	})
+}
@@ -0,0 +1,58 @@
+package tui
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Location is a place watched on the dashboard.
+type Location struct {
+	Name      string  `json:"name"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// DefaultFile returns where the watched locations are kept unless another file is named.
+func DefaultFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "weathersvc-tui.json"
+	}
+	return filepath.Join(dir, "weathersvc", "tui.json")
+}
+
+// LoadLocations reads the watched locations from path. A missing file is an empty list.
+func LoadLocations(path string) ([]Location, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Location{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading locations: %w", err)
+	}
+	var locs []Location
+	if err := json.Unmarshal(b, &locs); err != nil {
+		return nil, fmt.Errorf("error reading locations from %s: %w", path, err)
+	}
+	return locs, nil
+}
+
+// SaveLocations writes the watched locations to path, creating its directory. The file is replaced
+// whole so a crash never leaves it half written.
+func SaveLocations(path string, locs []Location) error {
+	b, err := json.MarshalIndent(locs, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("error saving locations: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0o644); err != nil {
+		return fmt.Errorf("error saving locations: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,367 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"weathersvc/app/service"
+	"weathersvc/pkg/client"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type view int
+
+const (
+	tableView view = iota
+	detailView
+	addView
+)
+
+// row is a watched location and the last answer the server gave for it.
+type row struct {
+	loc     Location
+	resp    *client.Weather
+	err     error
+	fetched time.Time
+}
+
+// weatherMsg carries the server's answer for loc.
+type weatherMsg struct {
+	loc  Location
+	resp client.Weather
+	err  error
+	at   time.Time
+}
+
+type tickMsg time.Time
+
+type model struct {
+	ctx      context.Context
+	client   *client.Client
+	file     string
+	server   string
+	interval time.Duration
+	rows     []row
+	cursor   int
+	view     view
+	// confirm is set while a delete waits for `y`.
+	confirm bool
+	// form holds the name, latitude and longitude being added, and focus the field being typed in.
+	form    [3]string
+	focus   int
+	formErr string
+	status  string
+	now     func() time.Time
+}
+
+func newModel(ctx context.Context, c *client.Client, opts Options, locs []Location) *model {
+	m := &model{ctx: ctx, client: c, file: opts.File, server: opts.Server, interval: opts.Interval, now: time.Now}
+	for _, l := range locs {
+		m.rows = append(m.rows, row{loc: l})
+	}
+	return m
+}
+
+func (m *model) Init() tea.Cmd {
+	return tea.Batch(m.fetchAll(), m.tick())
+}
+
+func (m *model) tick() tea.Cmd {
+	return tea.Tick(m.interval, func(t time.Time) tea.Msg { return tickMsg(t) })
+}
+
+func (m *model) fetch(l Location) tea.Cmd {
+	return func() tea.Msg {
+		msg := weatherMsg{loc: l, at: m.now()}
+		resp, err := m.client.GetWeather(m.ctx, client.WeatherRequest{Latitude: l.Latitude, Longitude: l.Longitude})
+		if err == nil {
+			msg.resp = *resp
+		}
+		msg.err = err
+		return msg
+	}
+}
+
+func (m *model) fetchAll() tea.Cmd {
+	cmds := make([]tea.Cmd, 0, len(m.rows))
+	for _, r := range m.rows {
+		cmds = append(cmds, m.fetch(r.loc))
+	}
+	return tea.Batch(cmds...)
+}
+
+func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tickMsg:
+		return m, tea.Batch(m.fetchAll(), m.tick())
+	case weatherMsg:
+		for i := range m.rows {
+			// a location removed while its call was in flight is simply not found
+			if m.rows[i].loc == msg.loc {
+				r := &m.rows[i]
+				r.err, r.fetched = msg.err, msg.at
+				if msg.err == nil {
+					resp := msg.resp
+					r.resp = &resp
+				}
+			}
+		}
+		return m, nil
+	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" {
+			return m, tea.Quit
+		}
+		switch m.view {
+		case addView:
+			return m.updateForm(msg)
+		case detailView:
+			switch msg.String() {
+			case "esc", "enter", "backspace", "q":
+				m.view = tableView
+			}
+			return m, nil
+		default:
+			return m.updateTable(msg)
+		}
+	}
+	return m, nil
+}
+
+func (m *model) updateTable(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.confirm {
+		m.confirm = false
+		if msg.String() == "y" {
+			m.remove()
+			return m, nil
+		}
+		m.status = ""
+		return m, nil
+	}
+	switch msg.String() {
+	case "q":
+		return m, tea.Quit
+	case "up", "k":
+		if m.cursor > 0 {
+			m.cursor--
+		}
+	case "down", "j":
+		if m.cursor < len(m.rows)-1 {
+			m.cursor++
+		}
+	case "enter":
+		if len(m.rows) > 0 {
+			m.view = detailView
+		}
+	case "a":
+		m.view, m.form, m.focus, m.formErr = addView, [3]string{}, 0, ""
+	case "d":
+		if len(m.rows) > 0 {
+			m.confirm = true
+			m.status = fmt.Sprintf("remove %s? y/n", m.rows[m.cursor].loc.Name)
+		}
+	case "r":
+		return m, m.fetchAll()
+	}
+	return m, nil
+}
+
+func (m *model) updateForm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.view = tableView
+	case tea.KeyTab, tea.KeyDown:
+		m.focus = (m.focus + 1) % len(m.form)
+	case tea.KeyShiftTab, tea.KeyUp:
+		m.focus = (m.focus + len(m.form) - 1) % len(m.form)
+	case tea.KeyBackspace:
+		if f := []rune(m.form[m.focus]); len(f) > 0 {
+			m.form[m.focus] = string(f[:len(f)-1])
+		}
+	case tea.KeyEnter:
+		if m.focus < len(m.form)-1 {
+			m.focus++
+			return m, nil
+		}
+		return m, m.add()
+	case tea.KeyRunes, tea.KeySpace:
+		m.form[m.focus] += string(msg.Runes)
+	}
+	return m, nil
+}
+
+// add validates the form and starts watching the location it describes.
+func (m *model) add() tea.Cmd {
+	name := strings.TrimSpace(m.form[0])
+	lat, latErr := strconv.ParseFloat(strings.TrimSpace(m.form[1]), 64)
+	lon, lonErr := strconv.ParseFloat(strings.TrimSpace(m.form[2]), 64)
+	switch {
+	case name == "":
+		m.formErr = "name is required"
+	case latErr != nil || lat < -90 || lat > 90:
+		m.formErr = "latitude must be a number from -90 to 90"
+	case lonErr != nil || lon < -180 || lon > 180:
+		m.formErr = "longitude must be a number from -180 to 180"
+	default:
+		l := Location{Name: name, Latitude: lat, Longitude: lon}
+		m.rows = append(m.rows, row{loc: l})
+		m.cursor = len(m.rows) - 1
+		m.view = tableView
+		m.save()
+		return m.fetch(l)
+	}
+	return nil
+}
+
+func (m *model) remove() {
+	m.rows = append(m.rows[:m.cursor], m.rows[m.cursor+1:]...)
+	if m.cursor > 0 && m.cursor >= len(m.rows) {
+		m.cursor--
+	}
+	m.save()
+}
+
+// save keeps the watched locations, reporting a failure in the status line rather than quitting.
+func (m *model) save() {
+	locs := make([]Location, 0, len(m.rows))
+	for _, r := range m.rows {
+		locs = append(locs, r.loc)
+	}
+	m.status = ""
+	if err := SaveLocations(m.file, locs); err != nil {
+		m.status = err.Error()
+	}
+}
+
+var (
+	titleStyle = lipgloss.NewStyle().Bold(true)                                  //nolint:gochecknoglobals // styles are fixed
+	faintStyle = lipgloss.NewStyle().Faint(true)                                 //nolint:gochecknoglobals // styles are fixed
+	errStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))             //nolint:gochecknoglobals // styles are fixed
+	cursorBar  = lipgloss.NewStyle().Foreground(lipgloss.Color("12")).Bold(true) //nolint:gochecknoglobals // styles are fixed
+	// scaleColors run from coldest or calmest to hottest or strongest.
+	scaleColors = []lipgloss.Color{"21", "33", "51", "46", "226", "208", "196"} //nolint:gochecknoglobals // styles are fixed
+)
+
+// classColor colors a class by its place on scale, spreading the scale over scaleColors.
+func classColor[T comparable](scale []T, class T) lipgloss.Style {
+	for i, c := range scale {
+		if c == class {
+			return lipgloss.NewStyle().Foreground(scaleColors[i*len(scaleColors)/len(scale)])
+		}
+	}
+	return faintStyle
+}
+
+func tempStyle(temp string) lipgloss.Style {
+	return classColor(service.TemperatureScale(), service.Temperature(temp))
+}
+
+func windStyle(wind string) lipgloss.Style {
+	return classColor(service.WindScale(), service.Wind(wind))
+}
+
+// cell pads s to width before styling, so escape codes do not upset the columns. Text too long for
+// the column is cut short, leaving a space before the next one.
+func cell(style lipgloss.Style, s string, width int) string {
+	r := []rune(s)
+	if len(r) > width-1 {
+		r = append(r[:width-2], '…')
+	}
+	return style.Render(string(r) + strings.Repeat(" ", width-len(r)))
+}
+
+func (m *model) View() string {
+	var b strings.Builder
+	b.WriteString(titleStyle.Render("weathersvc") + faintStyle.Render(fmt.Sprintf("  %s  every %s", m.server, m.interval)) + "\n\n")
+	switch m.view {
+	case addView:
+		m.viewForm(&b)
+	case detailView:
+		m.viewDetail(&b)
+	default:
+		m.viewTable(&b)
+	}
+	return b.String()
+}
+
+func (m *model) viewTable(b *strings.Builder) {
+	b.WriteString(titleStyle.Render(fmt.Sprintf("  %-24s%-16s%-26s%-24s%s", "LOCATION", "TEMPERATURE", "WIND", "CONDITION", "UPDATED")) + "\n")
+	if len(m.rows) == 0 {
+		b.WriteString(faintStyle.Render("  no locations yet; press a to add one") + "\n")
+	}
+	for i, r := range m.rows {
+		marker := "  "
+		if i == m.cursor {
+			marker = cursorBar.Render("> ")
+		}
+		b.WriteString(marker + cell(lipgloss.NewStyle(), r.loc.Name, 24))
+		switch {
+		case r.resp == nil && r.err == nil:
+			b.WriteString(cell(faintStyle, "loading", 16))
+		case r.err != nil && r.resp == nil:
+			b.WriteString(cell(errStyle, "error", 16) + cell(errStyle, r.err.Error(), 50))
+		default:
+			b.WriteString(cell(tempStyle(r.resp.Temp), r.resp.Temp, 16) + cell(windStyle(r.resp.Wind), r.resp.Wind, 26) + cell(lipgloss.NewStyle(), r.resp.Condition, 24))
+			updated := r.fetched.Format("15:04:05")
+			if r.err != nil {
+				// the last good answer stays up while the server is failing
+				updated = errStyle.Render(updated + " (failing)")
+			} else if r.resp.Stale {
+				updated += " (stale)"
+			}
+			b.WriteString(updated)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+	if m.status != "" {
+		b.WriteString(m.status + "\n")
+	}
+	b.WriteString(faintStyle.Render("↑/↓ move • enter details • a add • d remove • r refresh • q quit"))
+}
+
+func (m *model) viewDetail(b *strings.Builder) {
+	r := m.rows[m.cursor]
+	line := func(label, value string) {
+		fmt.Fprintf(b, "  %-14s%s\n", label, value)
+	}
+	b.WriteString(titleStyle.Render(r.loc.Name) + "\n\n")
+	line("coordinates", fmt.Sprintf("%g, %g", r.loc.Latitude, r.loc.Longitude))
+	if r.resp != nil {
+		line("summary", r.resp.Message)
+		line("temperature", tempStyle(r.resp.Temp).Render(r.resp.Temp))
+		line("wind", windStyle(r.resp.Wind).Render(r.resp.Wind))
+		line("condition", r.resp.Condition)
+		if r.resp.ObservedAt != nil {
+			line("observed", fmt.Sprintf("%s (%s ago)", r.resp.ObservedAt.Local().Format(time.RFC1123), time.Duration(r.resp.AgeSeconds)*time.Second))
+		}
+		if r.resp.Stale {
+			line("stale", "served from cache while the upstream is refreshed or failing")
+		}
+	}
+	if !r.fetched.IsZero() {
+		line("polled", r.fetched.Format("15:04:05"))
+	}
+	if r.err != nil {
+		line("error", errStyle.Render(r.err.Error()))
+	}
+	b.WriteString("\n" + faintStyle.Render("esc back • ctrl+c quit"))
+}
+
+func (m *model) viewForm(b *strings.Builder) {
+	b.WriteString(titleStyle.Render("Add a location") + "\n\n")
+	for i, label := range []string{"name", "latitude", "longitude"} {
+		marker := "  "
+		if i == m.focus {
+			marker = cursorBar.Render("> ")
+		}
+		fmt.Fprintf(b, "%s%-11s%s\n", marker, label, m.form[i])
+	}
+	if m.formErr != "" {
+		b.WriteString("\n" + errStyle.Render(m.formErr) + "\n")
+	}
+	b.WriteString("\n" + faintStyle.Render("tab next field • enter save • esc cancel"))
+}
@@ -0,0 +1,46 @@
+/*
+tui.go: A terminal dashboard for a running server. It polls /v1/weather/get for the locations kept in a
+local file and shows them in a live table, color coded by temperature and wind class.
+*/
+package tui
+
+import (
+	"context"
+	"time"
+	"weathersvc/pkg/client"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// DefaultInterval is how often every location is polled.
+const DefaultInterval = 30 * time.Second
+
+// Options select the server to poll and where the locations are kept.
+type Options struct {
+	// Server is the base URL of the running server.
+	Server string
+	// APIKey is sent as `X-API-Key` when the server requires authentication.
+	APIKey string
+	// Interval is how often every location is polled.
+	Interval time.Duration
+	// File keeps the watched locations between runs.
+	File string
+}
+
+// Run shows the dashboard until the user quits or ctx is cancelled.
+func Run(ctx context.Context, opts Options) error {
+	locs, err := LoadLocations(opts.File)
+	if err != nil {
+		return err
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	c, err := client.New(opts.Server, client.WithAPIKey(opts.APIKey))
+	if err != nil {
+		return err
+	}
+	m := newModel(ctx, c, opts, locs)
+	_, err = tea.NewProgram(m, tea.WithAltScreen(), tea.WithContext(ctx)).Run()
+	return err
+}
@@ -0,0 +1,132 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"weathersvc/pkg/client"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "weathersvc", "tui.json")
+	t.Run("Should start empty without a file", func(t *testing.T) {
+		locs, err := LoadLocations(path)
+		assert.NoError(t, err)
+		assert.Empty(t, locs)
+	})
+	t.Run("Should save and load locations", func(t *testing.T) {
+		want := []Location{{Name: "Dallas, TX", Latitude: 32.78, Longitude: -96.8}}
+		require.NoError(t, SaveLocations(path, want))
+		got, err := LoadLocations(path)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+}
+
+func TestModel(t *testing.T) {
+	dallas := Location{Name: "Dallas, TX", Latitude: 32.78, Longitude: -96.8}
+	newTestModel := func(t *testing.T) *model {
+		opts := Options{Server: "http://localhost:8080", Interval: time.Minute, File: filepath.Join(t.TempDir(), "tui.json")}
+		return newModel(context.Background(), nil, opts, []Location{dallas})
+	}
+	key := func(m *model, keys ...string) tea.Cmd {
+		var cmd tea.Cmd
+		for _, k := range keys {
+			var msg tea.KeyMsg
+			switch k {
+			case "enter":
+				msg = tea.KeyMsg{Type: tea.KeyEnter}
+			case "tab":
+				msg = tea.KeyMsg{Type: tea.KeyTab}
+			case "esc":
+				msg = tea.KeyMsg{Type: tea.KeyEsc}
+			default:
+				msg = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+			}
+			_, cmd = m.Update(msg)
+		}
+		return cmd
+	}
+	t.Run("Should show the answer for each location", func(t *testing.T) {
+		m := newTestModel(t)
+		assert.Contains(t, m.View(), "loading")
+		m.Update(weatherMsg{loc: dallas, resp: client.Weather{Temp: "hot", Wind: "light air", Condition: "clear sky"}, at: time.Now()})
+		view := m.View()
+		assert.Contains(t, view, "Dallas, TX")
+		assert.Contains(t, view, "hot")
+		assert.Contains(t, view, "light air")
+		m.Update(weatherMsg{loc: dallas, err: errors.New("connection refused"), at: time.Now()})
+		assert.Contains(t, m.View(), "hot", "the last good answer stays up")
+		assert.Contains(t, m.View(), "(failing)")
+	})
+	t.Run("Should add a location and save it", func(t *testing.T) {
+		m := newTestModel(t)
+		key(m, "a", "Austin", "tab", "30.27", "tab", "x")
+		assert.Nil(t, key(m, "enter"))
+		assert.Contains(t, m.View(), "longitude must be a number from -180 to 180")
+		key(m, "esc")
+		key(m, "a", "Austin", "enter", "30.27", "enter", "-97.74")
+		assert.NotNil(t, key(m, "enter"), "the new location is fetched")
+		assert.Equal(t, tableView, m.view)
+		assert.Equal(t, 1, m.cursor)
+		locs, err := LoadLocations(m.file)
+		assert.NoError(t, err)
+		assert.Equal(t, []Location{dallas, {Name: "Austin", Latitude: 30.27, Longitude: -97.74}}, locs)
+	})
+	t.Run("Should remove a location once confirmed", func(t *testing.T) {
+		m := newTestModel(t)
+		key(m, "d", "n")
+		assert.Len(t, m.rows, 1)
+		key(m, "d")
+		assert.Contains(t, m.View(), "remove Dallas, TX? y/n")
+		key(m, "y")
+		assert.Empty(t, m.rows)
+		locs, err := LoadLocations(m.file)
+		assert.NoError(t, err)
+		assert.Empty(t, locs)
+	})
+	t.Run("Should drill into a location", func(t *testing.T) {
+		m := newTestModel(t)
+		observed := time.Now().Add(-time.Minute)
+		m.Update(weatherMsg{loc: dallas, resp: client.Weather{Message: "Outside it is hot.", Temp: "hot", ObservedAt: &observed, AgeSeconds: 60, Stale: true}, at: time.Now()})
+		key(m, "enter")
+		view := m.View()
+		assert.Contains(t, view, "Outside it is hot.")
+		assert.Contains(t, view, "32.78, -96.8")
+		assert.Contains(t, view, "(1m0s ago)")
+		assert.Contains(t, view, "served from cache")
+		key(m, "esc")
+		assert.Equal(t, tableView, m.view)
+	})
+	t.Run("Should poll the server through the client", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"Temp":"hot","Condition":"clear sky","Wind":"light air"}`))
+		}))
+		defer ts.Close()
+		c, err := client.New(ts.URL)
+		require.NoError(t, err)
+		m := newTestModel(t)
+		m.client = c
+		msg := m.fetch(dallas)()
+		assert.Equal(t, "hot", msg.(weatherMsg).resp.Temp)
+		assert.NoError(t, msg.(weatherMsg).err)
+	})
+	t.Run("Should color classes by their place on the scale", func(t *testing.T) {
+		assert.Equal(t, scaleColors[0], tempStyle("sub-freezing").GetForeground())
+		assert.Equal(t, scaleColors[len(scaleColors)-1], tempStyle("extremely hot").GetForeground())
+		assert.Equal(t, scaleColors[len(scaleColors)-1], windStyle("hurricane/tornado winds").GetForeground())
+		assert.Equal(t, faintStyle, tempStyle("unknown"))
+	})
+	t.Run("Should cut long cells short", func(t *testing.T) {
+		assert.Equal(t, "Dallas…", strings.TrimSpace(cell(faintStyle.UnsetFaint(), "Dallas, TX", 8)))
+	})
+}
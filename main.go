@@ -2,7 +2,7 @@ package main
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
@@ -14,7 +14,13 @@ func main() {
 	// signal notifycontext allows for capturing shut down/service stop signals to allow graceful stopping of service
 	ctx, stop := signal.NotifyContext(context.Background(), []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGINT}...)
 	defer stop()
-	if err := cmd.Execute(ctx); err != nil {
-		log.Printf("Failed to start server, err: %v", err)
+	// SIGHUP reloads the config rather than stopping the service
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	if err := cmd.Execute(ctx, os.Args[1:], hup); err != nil {
+		slog.Error("weathersvc failed", "error", err)
+		stop()
+		os.Exit(1)
 	}
 }
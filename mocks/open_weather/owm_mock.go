@@ -5,8 +5,10 @@
 package mock_openweather
 
 import (
+	context "context"
 	reflect "reflect"
 	models "weathersvc/app/models"
+	openweather "weathersvc/app/open_weather"
 
 	gomock "github.com/golang/mock/gomock"
 )
@@ -35,30 +37,74 @@ func (m *MockClient) EXPECT() *MockClientMockRecorder {
 }
 
 // ApiTest mocks base method.
-func (m *MockClient) ApiTest() error {
+func (m *MockClient) ApiTest(ctx context.Context) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ApiTest")
+	ret := m.ctrl.Call(m, "ApiTest", ctx)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // ApiTest indicates an expected call of ApiTest.
-func (mr *MockClientMockRecorder) ApiTest() *gomock.Call {
+func (mr *MockClientMockRecorder) ApiTest(ctx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ApiTest", reflect.TypeOf((*MockClient)(nil).ApiTest))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ApiTest", reflect.TypeOf((*MockClient)(nil).ApiTest), ctx)
+}
+
+// GetAirPollution mocks base method.
+func (m *MockClient) GetAirPollution(ctx context.Context, lat, lon string) (*models.AirPollutionResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAirPollution", ctx, lat, lon)
+	ret0, _ := ret[0].(*models.AirPollutionResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAirPollution indicates an expected call of GetAirPollution.
+func (mr *MockClientMockRecorder) GetAirPollution(ctx, lat, lon interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAirPollution", reflect.TypeOf((*MockClient)(nil).GetAirPollution), ctx, lat, lon)
+}
+
+// GetForecast mocks base method.
+func (m *MockClient) GetForecast(ctx context.Context, lat, lon string) (*models.ForecastResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetForecast", ctx, lat, lon)
+	ret0, _ := ret[0].(*models.ForecastResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetForecast indicates an expected call of GetForecast.
+func (mr *MockClientMockRecorder) GetForecast(ctx, lat, lon interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetForecast", reflect.TypeOf((*MockClient)(nil).GetForecast), ctx, lat, lon)
 }
 
 // GetWeather mocks base method.
-func (m *MockClient) GetWeather(lat, lon string) (*models.WeatherResponse, error) {
+func (m *MockClient) GetWeather(ctx context.Context, lat, lon string) (*models.WeatherResponse, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetWeather", lat, lon)
+	ret := m.ctrl.Call(m, "GetWeather", ctx, lat, lon)
 	ret0, _ := ret[0].(*models.WeatherResponse)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetWeather indicates an expected call of GetWeather.
-func (mr *MockClientMockRecorder) GetWeather(lat, lon interface{}) *gomock.Call {
+func (mr *MockClientMockRecorder) GetWeather(ctx, lat, lon interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWeather", reflect.TypeOf((*MockClient)(nil).GetWeather), ctx, lat, lon)
+}
+
+// Keys mocks base method.
+func (m *MockClient) Keys() []openweather.KeyUsage {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Keys")
+	ret0, _ := ret[0].([]openweather.KeyUsage)
+	return ret0
+}
+
+// Keys indicates an expected call of Keys.
+func (mr *MockClientMockRecorder) Keys() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWeather", reflect.TypeOf((*MockClient)(nil).GetWeather), lat, lon)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Keys", reflect.TypeOf((*MockClient)(nil).Keys))
 }
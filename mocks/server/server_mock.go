@@ -5,7 +5,9 @@
 package mock_server
 
 import (
+	http "net/http"
 	reflect "reflect"
+	config "weathersvc/app/config"
 
 	gomock "github.com/golang/mock/gomock"
 )
@@ -47,6 +49,20 @@ func (mr *MockServerMockRecorder) Close() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockServer)(nil).Close))
 }
 
+// Handler mocks base method.
+func (m *MockServer) Handler() http.Handler {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Handler")
+	ret0, _ := ret[0].(http.Handler)
+	return ret0
+}
+
+// Handler indicates an expected call of Handler.
+func (mr *MockServerMockRecorder) Handler() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Handler", reflect.TypeOf((*MockServer)(nil).Handler))
+}
+
 // Open mocks base method.
 func (m *MockServer) Open() error {
 	m.ctrl.T.Helper()
@@ -74,3 +90,15 @@ func (mr *MockServerMockRecorder) Port() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Port", reflect.TypeOf((*MockServer)(nil).Port))
 }
+
+// Reload mocks base method.
+func (m *MockServer) Reload(conf *config.App) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Reload", conf)
+}
+
+// Reload indicates an expected call of Reload.
+func (mr *MockServerMockRecorder) Reload(conf interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reload", reflect.TypeOf((*MockServer)(nil).Reload), conf)
+}
@@ -7,6 +7,11 @@ package mock_service
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
+	budget "weathersvc/app/budget"
+	config "weathersvc/app/config"
+	health "weathersvc/app/health"
+	history "weathersvc/app/history"
 	service "weathersvc/app/service"
 
 	gomock "github.com/golang/mock/gomock"
@@ -35,6 +40,79 @@ func (m *MockService) EXPECT() *MockServiceMockRecorder {
 	return m.recorder
 }
 
+// Checks mocks base method.
+func (m *MockService) Checks() []health.Check {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Checks")
+	ret0, _ := ret[0].([]health.Check)
+	return ret0
+}
+
+// Checks indicates an expected call of Checks.
+func (mr *MockServiceMockRecorder) Checks() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Checks", reflect.TypeOf((*MockService)(nil).Checks))
+}
+
+// Close mocks base method.
+func (m *MockService) Close() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Close")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Close indicates an expected call of Close.
+func (mr *MockServiceMockRecorder) Close() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockService)(nil).Close))
+}
+
+// GetAirQuality mocks base method.
+func (m *MockService) GetAirQuality(ctx context.Context, lat, lon float64) (service.AirQuality, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAirQuality", ctx, lat, lon)
+	ret0, _ := ret[0].(service.AirQuality)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAirQuality indicates an expected call of GetAirQuality.
+func (mr *MockServiceMockRecorder) GetAirQuality(ctx, lat, lon interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAirQuality", reflect.TypeOf((*MockService)(nil).GetAirQuality), ctx, lat, lon)
+}
+
+// GetForecast mocks base method.
+func (m *MockService) GetForecast(ctx context.Context, lat, lon float64) (service.Forecast, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetForecast", ctx, lat, lon)
+	ret0, _ := ret[0].(service.Forecast)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetForecast indicates an expected call of GetForecast.
+func (mr *MockServiceMockRecorder) GetForecast(ctx, lat, lon interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetForecast", reflect.TypeOf((*MockService)(nil).GetForecast), ctx, lat, lon)
+}
+
+// GetHistory mocks base method.
+func (m *MockService) GetHistory(ctx context.Context, lat, lon float64, from, to time.Time, step time.Duration) ([]history.Observation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetHistory", ctx, lat, lon, from, to, step)
+	ret0, _ := ret[0].([]history.Observation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetHistory indicates an expected call of GetHistory.
+func (mr *MockServiceMockRecorder) GetHistory(ctx, lat, lon, from, to, step interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetHistory", reflect.TypeOf((*MockService)(nil).GetHistory), ctx, lat, lon, from, to, step)
+}
+
 // GetWeather mocks base method.
 func (m *MockService) GetWeather(ctx context.Context, lat, lon float64) (service.WeatherCond, error) {
 	m.ctrl.T.Helper()
@@ -50,6 +128,32 @@ func (mr *MockServiceMockRecorder) GetWeather(ctx, lat, lon interface{}) *gomock
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWeather", reflect.TypeOf((*MockService)(nil).GetWeather), ctx, lat, lon)
 }
 
+// Reload mocks base method.
+func (m *MockService) Reload(conf *config.App) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Reload", conf)
+}
+
+// Reload indicates an expected call of Reload.
+func (mr *MockServiceMockRecorder) Reload(conf interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reload", reflect.TypeOf((*MockService)(nil).Reload), conf)
+}
+
+// UpstreamUsage mocks base method.
+func (m *MockService) UpstreamUsage() []budget.Usage {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpstreamUsage")
+	ret0, _ := ret[0].([]budget.Usage)
+	return ret0
+}
+
+// UpstreamUsage indicates an expected call of UpstreamUsage.
+func (mr *MockServiceMockRecorder) UpstreamUsage() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpstreamUsage", reflect.TypeOf((*MockService)(nil).UpstreamUsage))
+}
+
 // ValidateSvc mocks base method.
 func (m *MockService) ValidateSvc(ctx context.Context) error {
 	m.ctrl.T.Helper()
@@ -0,0 +1,217 @@
+/*
+client.go: The Go client for the WeatherService API. It sends the JSON body /v1/weather/get expects on a
+GET, retries calls that were rate limited or failed on the server with backoff, and maps error
+responses back to the apperrors sentinels.
+*/
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultRetries is how many times a call is retried after a 429 or 5xx response.
+	DefaultRetries = 3
+	// DefaultMinBackoff and DefaultMaxBackoff bound the wait before each retry.
+	DefaultMinBackoff = 200 * time.Millisecond
+	DefaultMaxBackoff = 5 * time.Second
+)
+
+// WeatherRequest mirrors the server's DecimalRequest.
+type WeatherRequest struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// Weather mirrors the server's Response.
+type Weather struct {
+	Message   string
+	Temp      string
+	Condition string
+	Wind      string
+	// ObservedAt and AgeSeconds describe when the upstream observed the condition.
+	ObservedAt *time.Time `json:"observed_at,omitempty"`
+	AgeSeconds int64      `json:"age_seconds,omitempty"`
+	// Stale is set when the condition is served from cache past its TTL.
+	Stale bool `json:"stale,omitempty"`
+}
+
+// Client calls a WeatherService server. It is safe for concurrent use.
+type Client struct {
+	base       *url.URL
+	httpClient *http.Client
+	apiKey     string
+	token      string
+	retries    int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sends calls with hc instead of a client with a 30 second timeout.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithAPIKey sends key as `X-API-Key` on every call.
+func WithAPIKey(key string) Option {
+	return func(c *Client) { c.apiKey = key }
+}
+
+// WithBearerToken sends token as an `Authorization: Bearer` header on every call.
+func WithBearerToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+// WithRetries sets how many times a call is retried after a 429 or 5xx response; 0 never retries.
+func WithRetries(n int) Option {
+	return func(c *Client) { c.retries = n }
+}
+
+// WithBackoff sets the wait before the first retry, which doubles on each one up to upper. A
+// `Retry-After` from the server is waited out instead when it is longer, and a call asked to wait
+// longer than upper is not retried.
+func WithBackoff(lower, upper time.Duration) Option {
+	return func(c *Client) { c.minBackoff, c.maxBackoff = lower, upper }
+}
+
+// New returns a Client for the server at baseURL, e.g. `https://weather.example.com`.
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid base URL %q: scheme must be http or https", baseURL)
+	}
+	c := &Client{
+		base:       u,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		retries:    DefaultRetries,
+		minBackoff: DefaultMinBackoff,
+		maxBackoff: DefaultMaxBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// GetWeather returns the classified condition at the request's coordinates.
+func (c *Client) GetWeather(ctx context.Context, req WeatherRequest) (*Weather, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	var w Weather
+	if err := c.do(ctx, "/v1/weather/get", nil, body, &w); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// GetLocationWeather returns the classified condition at the saved location with id.
+func (c *Client) GetLocationWeather(ctx context.Context, id string) (*Weather, error) {
+	var w Weather
+	if err := c.do(ctx, "/v1/weather/get", url.Values{"location_id": {id}}, nil, &w); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// do sends a GET to path, retrying 429 and 5xx responses, and decodes a 200 response into out.
+func (c *Client) do(ctx context.Context, path string, query url.Values, body []byte, out any) error {
+	u := *c.base
+	u.Path += path
+	u.RawQuery = query.Encode()
+	for attempt := 0; ; attempt++ {
+		res, err := c.send(ctx, u.String(), body)
+		if err != nil {
+			return err
+		}
+		if res.StatusCode == http.StatusOK {
+			defer res.Body.Close()
+			if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+				return fmt.Errorf("error decoding response: %w", err)
+			}
+			return nil
+		}
+		apiErr := newError(res)
+		wait, ok := c.backoff(attempt, res.Header.Get("Retry-After"))
+		if !apiErr.Temporary() || attempt >= c.retries || !ok {
+			return apiErr
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return errors.Join(apiErr, err)
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, u string, body []byte) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, r)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error sending request: %w", err)
+	}
+	return res, nil
+}
+
+// backoff is the wait before retry attempt+1: an exponential backoff with jitter, or the server's
+// Retry-After when that is longer. It is not ok to retry when Retry-After is past the longest backoff.
+func (c *Client) backoff(attempt int, retryAfter string) (time.Duration, bool) {
+	d := c.minBackoff << attempt
+	if d <= 0 || d > c.maxBackoff {
+		d = c.maxBackoff
+	}
+	if d > 0 {
+		d = d/2 + time.Duration(rand.Int63n(int64(d/2)+1)) //nolint:gosec // jitter needs no secure randomness
+	}
+	if secs, err := strconv.Atoi(retryAfter); err == nil {
+		after := time.Duration(secs) * time.Second
+		if after > c.maxBackoff {
+			return 0, false
+		}
+		d = max(d, after)
+	}
+	return d, true
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
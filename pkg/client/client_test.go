@@ -0,0 +1,125 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+	apperrors "weathersvc/app/app_errors"
+	"weathersvc/pkg/client"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newFlaky answers with each of codes in turn, then with a condition.
+func newFlaky(t *testing.T, header http.Header, codes ...int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1))
+		if n <= len(codes) {
+			for k, v := range header {
+				w.Header()[k] = v
+			}
+			http.Error(w, http.StatusText(codes[n-1]), codes[n-1])
+			return
+		}
+		w.Write([]byte(`{"Message":"Outside it is hot.","Temp":"hot","Condition":"clear sky","Wind":"light air"}`))
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &calls
+}
+
+func TestNew(t *testing.T) {
+	t.Run("Should fail for a base URL that is not http", func(t *testing.T) {
+		_, err := client.New("localhost:8080")
+		assert.EqualError(t, err, `invalid base URL "localhost:8080": scheme must be http or https`)
+	})
+}
+
+func TestClient_retries(t *testing.T) {
+	ctx := context.Background()
+	req := client.WeatherRequest{Latitude: 32.78, Longitude: -96.8}
+	fast := client.WithBackoff(time.Millisecond, 10*time.Millisecond)
+	t.Run("Should retry 429 and 5xx responses", func(t *testing.T) {
+		ts, calls := newFlaky(t, nil, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+		c, err := client.New(ts.URL, fast)
+		require.NoError(t, err)
+		w, err := c.GetWeather(ctx, req)
+		assert.NoError(t, err)
+		assert.Equal(t, "hot", w.Temp)
+		assert.Equal(t, int32(3), calls.Load())
+	})
+	t.Run("Should give up after the last retry", func(t *testing.T) {
+		ts, calls := newFlaky(t, nil, 500, 500, 500)
+		c, err := client.New(ts.URL, fast, client.WithRetries(2))
+		require.NoError(t, err)
+		_, err = c.GetWeather(ctx, req)
+		assert.ErrorIs(t, err, apperrors.ErrInternalServiceError)
+		var apiErr *client.Error
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, 500, apiErr.StatusCode)
+		assert.Equal(t, int32(3), calls.Load())
+	})
+	t.Run("Should not retry other errors", func(t *testing.T) {
+		ts, calls := newFlaky(t, nil, http.StatusBadRequest)
+		c, err := client.New(ts.URL, fast)
+		require.NoError(t, err)
+		_, err = c.GetWeather(ctx, req)
+		assert.ErrorIs(t, err, apperrors.ErrInvalidRequest)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+	t.Run("Should not retry when asked to wait past the longest backoff", func(t *testing.T) {
+		ts, calls := newFlaky(t, http.Header{"Retry-After": {"60"}}, http.StatusTooManyRequests)
+		c, err := client.New(ts.URL, fast)
+		require.NoError(t, err)
+		_, err = c.GetWeather(ctx, req)
+		assert.ErrorIs(t, err, apperrors.ErrTooManyRequests)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+	t.Run("Should stop waiting when the context is done", func(t *testing.T) {
+		ts, _ := newFlaky(t, nil, 503, 503)
+		c, err := client.New(ts.URL, client.WithBackoff(time.Minute, time.Minute))
+		require.NoError(t, err)
+		ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		_, err = c.GetWeather(ctx, req)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.ErrorIs(t, err, apperrors.ErrInternalServiceError)
+	})
+}
+
+func TestClient_errors(t *testing.T) {
+	for _, tc := range []struct {
+		code int
+		body string
+		want error
+	}{
+		{http.StatusBadRequest, "invalid request: latitude is out of range", apperrors.ErrInvalidRequest},
+		{http.StatusUnauthorized, "unauthorized: missing or invalid credentials", apperrors.ErrUnauthorized},
+		{http.StatusForbidden, "forbidden: missing scope `weather:read`", apperrors.ErrForbidden},
+		{http.StatusNotFound, "location not found", apperrors.ErrLocationNotFound},
+		{http.StatusNotFound, "weather for coordinates not found", apperrors.ErrNotFound},
+		{http.StatusTooManyRequests, "upstream call budget exhausted; retry later", apperrors.ErrBudgetExhausted},
+		{http.StatusTooManyRequests, "rate limit exceeded; retry later", apperrors.ErrRateLimited},
+		{http.StatusBadGateway, "<html>bad gateway</html>", apperrors.ErrInternalServiceError},
+	} {
+		t.Run("Should map "+tc.body, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, tc.body, tc.code)
+			}))
+			defer ts.Close()
+			c, err := client.New(ts.URL, client.WithRetries(0))
+			require.NoError(t, err)
+			_, err = c.GetLocationWeather(context.Background(), "dallas")
+			assert.ErrorIs(t, err, tc.want)
+			assert.EqualError(t, err, fmt.Sprintf("weathersvc responded with status %d: %s", tc.code, tc.body))
+			assert.False(t, errors.Is(err, apperrors.ErrKeyNotFound))
+		})
+	}
+}
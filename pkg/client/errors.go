@@ -0,0 +1,91 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	apperrors "weathersvc/app/app_errors"
+)
+
+// Error is an error response from the server. It wraps the apperrors sentinel the server answered
+// with, so callers can test it with errors.Is, e.g. against apperrors.ErrTooManyRequests.
+type Error struct {
+	StatusCode int
+	// Message is the error text the server wrote.
+	Message string
+	err     error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("weathersvc responded with status %d: %s", e.StatusCode, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// Temporary reports whether the call may succeed if retried: it was rate limited or failed on the server.
+func (e *Error) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
+
+// sentinels are the errors the server answers with, matched by the text it writes. Those that format a
+// reason, like invalid requests, are matched by their prefix.
+func sentinels() []error {
+	return []error{
+		apperrors.ErrInvalidRequest,
+		apperrors.ErrInvalidOWMAppID,
+		apperrors.ErrInternalServiceError,
+		apperrors.ErrTooManyRequests,
+		apperrors.ErrNotFound,
+		apperrors.ErrNoBody,
+		apperrors.ErrAlertNotFound,
+		apperrors.ErrLocationNotFound,
+		apperrors.ErrLocationExists,
+		apperrors.ErrUnauthorized,
+		apperrors.ErrForbidden,
+		apperrors.ErrQuotaExceeded,
+		apperrors.ErrKeyNotFound,
+		apperrors.ErrRateLimited,
+		apperrors.ErrBudgetExhausted,
+	}
+}
+
+// statusError maps a status onto its sentinel when the server's text is not one of them.
+func statusError(code int) error {
+	switch {
+	case code == http.StatusBadRequest:
+		return apperrors.ErrInvalidRequest
+	case code == http.StatusUnauthorized:
+		return apperrors.ErrUnauthorized
+	case code == http.StatusForbidden:
+		return apperrors.ErrForbidden
+	case code == http.StatusNotFound:
+		return apperrors.ErrNotFound
+	case code == http.StatusTooManyRequests:
+		return apperrors.ErrTooManyRequests
+	case code >= http.StatusInternalServerError:
+		return apperrors.ErrInternalServiceError
+	default:
+		return nil
+	}
+}
+
+// newError reads the error response res, which it closes.
+func newError(res *http.Response) *Error {
+	defer res.Body.Close()
+	b, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+	e := &Error{StatusCode: res.StatusCode, Message: strings.TrimSpace(string(b)), err: statusError(res.StatusCode)}
+	for _, s := range sentinels() {
+		if e.Message == s.Error() || strings.HasPrefix(e.Message, s.Error()+": ") {
+			e.err = s
+			break
+		}
+	}
+	if e.err == nil {
+		e.err = errors.New(http.StatusText(res.StatusCode))
+	}
+	return e
+}
@@ -0,0 +1,117 @@
+package client_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	apperrors "weathersvc/app/app_errors"
+	"weathersvc/app/config"
+	"weathersvc/app/server"
+	"weathersvc/app/service"
+	"weathersvc/pkg/client"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newOWM stands in for Open Weather Map: hot and calm everywhere, except that no weather is found at
+// latitude 1 and every call is rate limited at latitude 2.
+func newOWM(t *testing.T) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("lat") {
+		case "1.000000":
+			w.Write([]byte(`{"cod":404,"message":"city not found"}`))
+		case "2.000000":
+			w.Write([]byte(`{"cod":429,"message":"limit reached"}`))
+		default:
+			w.Write([]byte(`{"weather":[{"description":"clear sky"}],"main":{"feels_like":95},"wind":{"speed":2},"dt":1720197314,"cod":200}`))
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// newWeatherService runs the real service and server, with conf's options, on a test listener.
+func newWeatherService(t *testing.T, conf config.App) string {
+	t.Helper()
+	conf.Host = newOWM(t).URL
+	conf.AppID = "fakeID"
+	// responses are checked against the API description in the test environment
+	conf.Env = "test"
+	conf.CacheTTL, conf.CacheMaxStale = time.Minute, time.Hour
+	conf.PollInterval, conf.HealthInterval, conf.HealthTimeout = time.Minute, time.Minute, time.Second
+	svc, err := service.NewService(context.Background(), &conf, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { svc.Close() })
+	svr, err := server.NewServer(&conf, svc, nil)
+	require.NoError(t, err)
+	ts := httptest.NewServer(svr.Handler())
+	t.Cleanup(ts.Close)
+	return ts.URL
+}
+
+func TestClient_integration(t *testing.T) {
+	ctx := context.Background()
+	fast := client.WithBackoff(time.Millisecond, 10*time.Millisecond)
+	base := newWeatherService(t, config.App{})
+	c, err := client.New(base, fast)
+	require.NoError(t, err)
+	t.Run("Should get the weather for coordinates", func(t *testing.T) {
+		w, err := c.GetWeather(ctx, client.WeatherRequest{Latitude: 32.78, Longitude: -96.8})
+		require.NoError(t, err)
+		assert.Equal(t, "Outside it is hot with light air and clear sky.", w.Message)
+		assert.Equal(t, "hot", w.Temp)
+		assert.Equal(t, "light air", w.Wind)
+		assert.Equal(t, "clear sky", w.Condition)
+		if assert.NotNil(t, w.ObservedAt) {
+			assert.Equal(t, time.Unix(1720197314, 0).UTC(), w.ObservedAt.UTC())
+		}
+	})
+	t.Run("Should get the weather for a saved location", func(t *testing.T) {
+		res, err := http.Post(base+"/locations", "application/json",
+			strings.NewReader(`{"id":"dallas","name":"Dallas, TX","latitude":32.78,"longitude":-96.8}`))
+		require.NoError(t, err)
+		res.Body.Close()
+		require.Equal(t, http.StatusCreated, res.StatusCode)
+		w, err := c.GetLocationWeather(ctx, "dallas")
+		require.NoError(t, err)
+		assert.Equal(t, "hot", w.Temp)
+		_, err = c.GetLocationWeather(ctx, "austin")
+		assert.ErrorIs(t, err, apperrors.ErrLocationNotFound)
+	})
+	t.Run("Should map the server's errors", func(t *testing.T) {
+		_, err := c.GetWeather(ctx, client.WeatherRequest{Latitude: 91, Longitude: 0})
+		assert.ErrorIs(t, err, apperrors.ErrInvalidRequest)
+		assert.EqualError(t, err, "weathersvc responded with status 400: invalid request: latitude is out of range")
+		_, err = c.GetWeather(ctx, client.WeatherRequest{Latitude: 1, Longitude: 1})
+		assert.ErrorIs(t, err, apperrors.ErrNotFound)
+		_, err = c.GetWeather(ctx, client.WeatherRequest{Latitude: 2, Longitude: 2})
+		assert.ErrorIs(t, err, apperrors.ErrTooManyRequests)
+	})
+	t.Run("Should send the API key", func(t *testing.T) {
+		base := newWeatherService(t, config.App{AuthConfig: config.AuthConfig{AdminAPIKey: "admin-key"}})
+		anonymous, err := client.New(base)
+		require.NoError(t, err)
+		_, err = anonymous.GetWeather(ctx, client.WeatherRequest{Latitude: 32.78, Longitude: -96.8})
+		assert.ErrorIs(t, err, apperrors.ErrUnauthorized)
+		admin, err := client.New(base, client.WithAPIKey("admin-key"))
+		require.NoError(t, err)
+		_, err = admin.GetWeather(ctx, client.WeatherRequest{Latitude: 32.78, Longitude: -96.8})
+		assert.NoError(t, err)
+	})
+	t.Run("Should report the rate limit without waiting it out", func(t *testing.T) {
+		base := newWeatherService(t, config.App{RateLimitConfig: config.RateLimitConfig{RateLimit: config.Rate{Count: 1, Per: time.Minute}}})
+		c, err := client.New(base, fast)
+		require.NoError(t, err)
+		_, err = c.GetWeather(ctx, client.WeatherRequest{Latitude: 32.78, Longitude: -96.8})
+		assert.NoError(t, err)
+		start := time.Now()
+		_, err = c.GetWeather(ctx, client.WeatherRequest{Latitude: 32.78, Longitude: -96.8})
+		assert.ErrorIs(t, err, apperrors.ErrRateLimited)
+		assert.Less(t, time.Since(start), time.Second)
+	})
+}